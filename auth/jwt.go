@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Clipboard rights carried in the "clipboard" claim, seen from the viewer's side:
+// read = receive the server's clipboard, write = send the local clipboard to the server.
+const (
+	ClipboardNone      = "none"
+	ClipboardRead      = "read"
+	ClipboardWrite     = "write"
+	ClipboardReadWrite = "readwrite"
+)
+
+// Claims holds the registered JWT claims we check, and the console specific claims
+// that decide what a viewer may do in the session.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+
+	// Session is the id of the proxy session (target) the token grants access to
+	Session string `json:"session"`
+	// ViewOnly drops all keyboard, pointer & clipboard input coming from the viewer
+	ViewOnly bool `json:"view_only"`
+	// Clipboard is one of the Clipboard* constants, empty means readwrite
+	Clipboard string `json:"clipboard"`
+	// Record makes recording of the session mandatory
+	Record bool `json:"record"`
+}
+
+// CanReadClipboard reports whether the viewer may receive the server's clipboard.
+func (c *Claims) CanReadClipboard() bool {
+	return c.Clipboard == "" || c.Clipboard == ClipboardRead || c.Clipboard == ClipboardReadWrite
+}
+
+// CanWriteClipboard reports whether the viewer may send its clipboard to the server.
+func (c *Claims) CanWriteClipboard() bool {
+	if c.ViewOnly {
+		return false
+	}
+	return c.Clipboard == "" || c.Clipboard == ClipboardWrite || c.Clipboard == ClipboardReadWrite
+}
+
+// audience accepts both the string and the array form of the "aud" claim.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+	*a = audience(multi)
+	return nil
+}
+
+func (a audience) contains(aud string) bool {
+	for _, s := range a {
+		if s == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// JwtVerifier checks JWTs locally against a set of JWKS keys.
+// Supported algorithms are RS256, ES256 and EdDSA (Ed25519).
+type JwtVerifier struct {
+	Keys *KeySet
+	// Issuer & Audience are checked only when not empty
+	Issuer   string
+	Audience string
+	// Leeway is the allowed clock skew when checking exp & nbf
+	Leeway time.Duration
+	// CookieName & QueryParam name where to look for the token when the
+	// request has no "Authorization: Bearer" header, empty disables the lookup
+	CookieName string
+	QueryParam string
+
+	now func() time.Time
+}
+
+// ErrNoToken is returned by TokenFromRequest when the request carries no token.
+var ErrNoToken = errors.New("no token in request")
+
+// TokenFromRequest finds the raw token in the Authorization header, the configured cookie or query parameter.
+func (v *JwtVerifier) TokenFromRequest(r *http.Request) (string, error) {
+	if h := r.Header.Get("Authorization"); h != "" {
+		const prefix = "bearer "
+		if len(h) > len(prefix) && strings.ToLower(h[:len(prefix)]) == prefix {
+			return strings.TrimSpace(h[len(prefix):]), nil
+		}
+	}
+	if v.CookieName != "" {
+		if c, err := r.Cookie(v.CookieName); err == nil && c.Value != "" {
+			return c.Value, nil
+		}
+	}
+	if v.QueryParam != "" {
+		if t := r.URL.Query().Get(v.QueryParam); t != "" {
+			return t, nil
+		}
+	}
+	return "", ErrNoToken
+}
+
+// VerifyRequest extracts the token from the request and verifies it.
+func (v *JwtVerifier) VerifyRequest(r *http.Request) (*Claims, error) {
+	token, err := v.TokenFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return v.Verify(token)
+}
+
+// Verify checks the token signature and its time, issuer & audience claims.
+func (v *JwtVerifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed jwt")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("bad jwt header: %v", err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("bad jwt signature encoding: %v", err)
+	}
+
+	if v.Keys == nil {
+		return nil, errors.New("no jwks keys configured")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range v.Keys.candidates(header.Kid, header.Alg) {
+		if verifySignature(header.Alg, key, signed, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("jwt signature verification failed (alg=%s, kid=%s)", header.Alg, header.Kid)
+	}
+
+	claims := &Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, fmt.Errorf("bad jwt claims: %v", err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *JwtVerifier) checkClaims(c *Claims) error {
+	now := time.Now()
+	if v.now != nil {
+		now = v.now()
+	}
+
+	if c.ExpiresAt == 0 {
+		return errors.New("jwt has no exp claim")
+	}
+	if now.After(time.Unix(c.ExpiresAt, 0).Add(v.Leeway)) {
+		return errors.New("jwt expired")
+	}
+	if c.NotBefore != 0 && now.Add(v.Leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return errors.New("jwt not valid yet")
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return fmt.Errorf("jwt issuer mismatch: %s", c.Issuer)
+	}
+	if v.Audience != "" && !c.Audience.contains(v.Audience) {
+		return errors.New("jwt audience mismatch")
+	}
+	switch c.Clipboard {
+	case "", ClipboardNone, ClipboardRead, ClipboardWrite, ClipboardReadWrite:
+	default:
+		return fmt.Errorf("jwt has unknown clipboard right: %s", c.Clipboard)
+	}
+	return nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) bool {
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil
+
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return false
+		}
+		digest := sha256.Sum256(signed)
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(pub, digest[:], r, s)
+
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return false
+		}
+		return ed25519.Verify(pub, signed, sig)
+	}
+	// anything else (including "none") is rejected
+	return false
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var b64 = base64.RawURLEncoding
+
+type testSigner struct {
+	alg  string
+	kid  string
+	sign func([]byte) []byte
+	jwk  map[string]string
+}
+
+func newTestSigners(t *testing.T) []testSigner {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pad32 := func(i *big.Int) []byte {
+		b := make([]byte, 32)
+		i.FillBytes(b)
+		return b
+	}
+
+	return []testSigner{
+		{
+			alg: "RS256", kid: "rsa1",
+			sign: func(data []byte) []byte {
+				digest := sha256.Sum256(data)
+				sig, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+				return sig
+			},
+			jwk: map[string]string{"kty": "RSA", "kid": "rsa1", "n": b64.EncodeToString(rsaKey.N.Bytes()), "e": b64.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes())},
+		},
+		{
+			alg: "ES256", kid: "ec1",
+			sign: func(data []byte) []byte {
+				digest := sha256.Sum256(data)
+				r, s, _ := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+				return append(pad32(r), pad32(s)...)
+			},
+			jwk: map[string]string{"kty": "EC", "kid": "ec1", "crv": "P-256", "x": b64.EncodeToString(pad32(ecKey.X)), "y": b64.EncodeToString(pad32(ecKey.Y))},
+		},
+		{
+			alg: "EdDSA", kid: "ed1",
+			sign: func(data []byte) []byte {
+				return ed25519.Sign(edKey, data)
+			},
+			jwk: map[string]string{"kty": "OKP", "kid": "ed1", "crv": "Ed25519", "x": b64.EncodeToString(edPub)},
+		},
+	}
+}
+
+func makeToken(t *testing.T, s testSigner, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": s.alg, "kid": s.kid, "typ": "JWT"})
+	body, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := b64.EncodeToString(header) + "." + b64.EncodeToString(body)
+	return signed + "." + b64.EncodeToString(s.sign([]byte(signed)))
+}
+
+func newTestVerifier(t *testing.T, signers []testSigner) *JwtVerifier {
+	var keys []map[string]string
+	for _, s := range signers {
+		keys = append(keys, s.jwk)
+	}
+	doc, _ := json.Marshal(map[string]interface{}{"keys": keys})
+	ks, err := ParseKeySet(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &JwtVerifier{Keys: ks, Issuer: "console", Audience: "vncproxy"}
+}
+
+func TestJwtVerify(t *testing.T) {
+	signers := newTestSigners(t)
+	v := newTestVerifier(t, signers)
+	exp := time.Now().Add(time.Hour).Unix()
+
+	for _, s := range signers {
+		token := makeToken(t, s, map[string]interface{}{
+			"iss": "console", "aud": []string{"other", "vncproxy"}, "exp": exp,
+			"sub": "user1", "session": "vm-42", "view_only": true, "clipboard": "read", "record": true,
+		})
+		claims, err := v.Verify(token)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", s.alg, err)
+		}
+		if claims.Session != "vm-42" || !claims.ViewOnly || !claims.Record || claims.Subject != "user1" {
+			t.Errorf("%s: wrong claims: %+v", s.alg, claims)
+		}
+		if !claims.CanReadClipboard() || claims.CanWriteClipboard() {
+			t.Errorf("%s: wrong clipboard rights: %+v", s.alg, claims)
+		}
+	}
+}
+
+func TestJwtVerifyRejects(t *testing.T) {
+	signers := newTestSigners(t)
+	v := newTestVerifier(t, signers)
+	s := signers[0]
+	valid := map[string]interface{}{"iss": "console", "aud": "vncproxy", "exp": time.Now().Add(time.Hour).Unix()}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", makeToken(t, s, map[string]interface{}{"iss": "console", "aud": "vncproxy", "exp": time.Now().Add(-time.Hour).Unix()})},
+		{"no exp", makeToken(t, s, map[string]interface{}{"iss": "console", "aud": "vncproxy"})},
+		{"not before", makeToken(t, s, map[string]interface{}{"iss": "console", "aud": "vncproxy", "exp": time.Now().Add(time.Hour).Unix(), "nbf": time.Now().Add(time.Minute).Unix()})},
+		{"issuer", makeToken(t, s, map[string]interface{}{"iss": "evil", "aud": "vncproxy", "exp": time.Now().Add(time.Hour).Unix()})},
+		{"audience", makeToken(t, s, map[string]interface{}{"iss": "console", "aud": "other", "exp": time.Now().Add(time.Hour).Unix()})},
+		{"alg none", b64.EncodeToString([]byte(`{"alg":"none"}`)) + "." + b64.EncodeToString([]byte(`{"exp":9999999999}`)) + "."},
+		{"alg swap", makeToken(t, testSigner{alg: "ES256", kid: "rsa1", sign: s.sign}, valid)},
+		{"tampered", makeToken(t, s, valid) + "A"},
+		{"malformed", "abc.def"},
+	}
+
+	for _, tt := range tests {
+		if _, err := v.Verify(tt.token); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestJwtTokenFromRequest(t *testing.T) {
+	v := &JwtVerifier{CookieName: "vnc_token", QueryParam: "token"}
+
+	r, _ := http.NewRequest("GET", "http://proxy/vm?token=fromquery", nil)
+	if tok, _ := v.TokenFromRequest(r); tok != "fromquery" {
+		t.Errorf("query token: got %q", tok)
+	}
+
+	r.AddCookie(&http.Cookie{Name: "vnc_token", Value: "fromcookie"})
+	if tok, _ := v.TokenFromRequest(r); tok != "fromcookie" {
+		t.Errorf("cookie token: got %q", tok)
+	}
+
+	r.Header.Set("Authorization", "Bearer fromheader")
+	if tok, _ := v.TokenFromRequest(r); tok != "fromheader" {
+		t.Errorf("header token: got %q", tok)
+	}
+
+	r, _ = http.NewRequest("GET", "http://proxy/vm", nil)
+	if _, err := v.TokenFromRequest(r); err != ErrNoToken {
+		t.Errorf("expected ErrNoToken, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTotpDigits = 6
+	DefaultTotpPeriod = 30 * time.Second
+	DefaultTotpSkew   = 1
+)
+
+var (
+	ErrTotpInvalid     = errors.New("invalid totp code")
+	ErrTotpReplay      = errors.New("totp code already used")
+	ErrTotpNotEnrolled = errors.New("user has no enrolled totp secret")
+)
+
+// DecodeTotpSecret decodes a base32 secret as shown in authenticator apps (padding & spaces are optional).
+func DecodeTotpSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.Replace(s, " ", "", -1))
+	s = strings.TrimRight(s, "=")
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+}
+
+// TotpCode computes the RFC 6238 (HMAC-SHA1) code for the given time step counter.
+func TotpCode(secret []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// TotpCandidate is a code accepted in the current skew window, along with its time step counter.
+type TotpCandidate struct {
+	Counter uint64
+	Code    string
+}
+
+// TotpVerifier checks TOTP codes against the secrets enrolled in a UserStore.
+// A code (time step) is accepted only once per user, and Skew steps of clock
+// drift are tolerated in both directions.
+type TotpVerifier struct {
+	Users UserStore
+	// Required rejects users without an enrolled secret, instead of letting them in with a single factor
+	Required bool
+	Digits   int
+	Period   time.Duration
+	Skew     int
+
+	now      func() time.Time
+	mutex    sync.Mutex
+	lastUsed map[string]uint64
+}
+
+func (v *TotpVerifier) digits() int {
+	if v.Digits == 0 {
+		return DefaultTotpDigits
+	}
+	return v.Digits
+}
+
+// CodeLength is the number of digits a code has.
+func (v *TotpVerifier) CodeLength() int {
+	return v.digits()
+}
+
+// Candidates returns the codes valid right now for the user, oldest first.
+func (v *TotpVerifier) Candidates(user string) ([]TotpCandidate, error) {
+	secret, err := v.Users.TotpSecret(user)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, ErrTotpNotEnrolled
+	}
+
+	period := v.Period
+	if period == 0 {
+		period = DefaultTotpPeriod
+	}
+	now := time.Now()
+	if v.now != nil {
+		now = v.now()
+	}
+	current := uint64(now.Unix()) / uint64(period/time.Second)
+
+	var result []TotpCandidate
+	for step := -v.Skew; step <= v.Skew; step++ {
+		if step < 0 && uint64(-step) > current {
+			continue
+		}
+		counter := uint64(int64(current) + int64(step))
+		result = append(result, TotpCandidate{Counter: counter, Code: TotpCode(secret, counter, v.digits())})
+	}
+	return result, nil
+}
+
+// Accept records the time step as used, failing if it (or a later one) was already used by this user.
+func (v *TotpVerifier) Accept(user string, counter uint64) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.lastUsed == nil {
+		v.lastUsed = make(map[string]uint64)
+	}
+	if last, ok := v.lastUsed[user]; ok && counter <= last {
+		return ErrTotpReplay
+	}
+	v.lastUsed[user] = counter
+	return nil
+}
+
+// Check validates a code typed by the user.
+func (v *TotpVerifier) Check(user, code string) error {
+	candidates, err := v.Candidates(user)
+	if err != nil {
+		return err
+	}
+	for _, c := range candidates {
+		if hmac.Equal([]byte(c.Code), []byte(code)) {
+			return v.Accept(user, c.Counter)
+		}
+	}
+	return ErrTotpInvalid
+}
+
+// SplitCode splits a password typed with a trailing code into its two parts.
+func (v *TotpVerifier) SplitCode(password string) (string, string, error) {
+	n := v.digits()
+	if len(password) < n {
+		return "", "", ErrTotpInvalid
+	}
+	code := password[len(password)-n:]
+	for _, ch := range code {
+		if ch < '0' || ch > '9' {
+			return "", "", ErrTotpInvalid
+		}
+	}
+	return password[:len(password)-n], code, nil
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestTotpCodeRFC6238(t *testing.T) {
+	// test vectors from RFC 6238 appendix B (SHA1)
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		time int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+	}
+	for _, tt := range tests {
+		if code := TotpCode(secret, uint64(tt.time/30), 8); code != tt.code {
+			t.Errorf("TotpCode(%d) = %s, want %s", tt.time, code, tt.code)
+		}
+	}
+}
+
+func TestTotpVerifier(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	store, err := NewStaticUserStore([]UserEntry{
+		{Username: "alice", Password: "sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8", TotpSecret: base32.StdEncoding.EncodeToString(secret)},
+		{Username: "bob", Password: "hunter2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !store.CheckPassword("alice", "password") || store.CheckPassword("alice", "hunter2") || !store.CheckPassword("bob", "hunter2") {
+		t.Fatal("CheckPassword returned wrong results")
+	}
+
+	now := time.Unix(1111111111, 0)
+	v := &TotpVerifier{Users: store, Skew: 1, now: func() time.Time { return now }}
+
+	previous := TotpCode(secret, uint64(now.Unix()/30)-1, 6)
+	current := TotpCode(secret, uint64(now.Unix()/30), 6)
+	tooOld := TotpCode(secret, uint64(now.Unix()/30)-2, 6)
+
+	if err := v.Check("alice", tooOld); err != ErrTotpInvalid {
+		t.Errorf("code outside of the skew window: got %v", err)
+	}
+	if err := v.Check("alice", previous); err != nil {
+		t.Errorf("code inside the skew window: got %v", err)
+	}
+	if err := v.Check("alice", previous); err != ErrTotpReplay {
+		t.Errorf("replayed code: got %v", err)
+	}
+	if err := v.Check("alice", current); err != nil {
+		t.Errorf("current code: got %v", err)
+	}
+	if err := v.Check("bob", current); err != ErrTotpNotEnrolled {
+		t.Errorf("not enrolled user: got %v", err)
+	}
+
+	pass, code, err := v.SplitCode("hunter2" + current)
+	if err != nil || pass != "hunter2" || code != current {
+		t.Errorf("SplitCode: got %q %q %v", pass, code, err)
+	}
+	if _, _, err := v.SplitCode("hunter2abcdef"); err == nil {
+		t.Error("SplitCode accepted a non numeric code")
+	}
+}
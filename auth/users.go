@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// UserStore is the authenticator backend used for username/password logins.
+type UserStore interface {
+	// CheckPassword reports whether the password is valid for the user
+	CheckPassword(user, password string) bool
+	// TotpSecret returns the user's enrolled TOTP secret, nil if the user is not enrolled
+	TotpSecret(user string) ([]byte, error)
+}
+
+// UserEntry is a single user in a users file.
+// Password is either plain text or "sha256:<hex digest>", TotpSecret is base32 encoded.
+type UserEntry struct {
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	TotpSecret string `json:"totp_secret"`
+}
+
+// StaticUserStore is a UserStore backed by a fixed list of users.
+type StaticUserStore struct {
+	users map[string]*UserEntry
+}
+
+// NewStaticUserStore builds a store from the given entries, checking all TOTP secrets decode.
+func NewStaticUserStore(entries []UserEntry) (*StaticUserStore, error) {
+	store := &StaticUserStore{users: make(map[string]*UserEntry)}
+	for i := range entries {
+		entry := &entries[i]
+		if entry.TotpSecret != "" {
+			if _, err := DecodeTotpSecret(entry.TotpSecret); err != nil {
+				return nil, fmt.Errorf("user %s: bad totp secret: %v", entry.Username, err)
+			}
+		}
+		store.users[entry.Username] = entry
+	}
+	return store, nil
+}
+
+// LoadUserFile reads a JSON array of UserEntry.
+func LoadUserFile(path string) (*StaticUserStore, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []UserEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("users file %s: %v", path, err)
+	}
+	return NewStaticUserStore(entries)
+}
+
+func (s *StaticUserStore) CheckPassword(user, password string) bool {
+	entry, ok := s.users[user]
+	if !ok {
+		return false
+	}
+	if strings.HasPrefix(entry.Password, "sha256:") {
+		sum := sha256.Sum256([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(entry.Password[7:]))) == 1
+	}
+	return subtle.ConstantTimeCompare([]byte(entry.Password), []byte(password)) == 1
+}
+
+func (s *StaticUserStore) TotpSecret(user string) ([]byte, error) {
+	entry, ok := s.users[user]
+	if !ok || entry.TotpSecret == "" {
+		return nil, nil
+	}
+	return DecodeTotpSecret(entry.TotpSecret)
+}
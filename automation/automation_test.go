@@ -0,0 +1,196 @@
+package automation
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exoscale/vncproxy/common"
+)
+
+var (
+	red  = color.RGBA{0xff, 0, 0, 0xff}
+	blue = color.RGBA{0, 0, 0xff, 0xff}
+)
+
+// pixel32 is a pixel in the format of common.NewPixelFormat(32): little endian, red at bit 16
+func pixel32(c color.RGBA) []byte {
+	return []byte{c.B, c.G, c.R, 0}
+}
+
+func decode(t *testing.T, fb *framebuffer, enc common.EncodingType, rect common.Rectangle, data []byte) {
+	for _, decoder := range fb.decoders() {
+		if decoder.Type() != int32(enc) {
+			continue
+		}
+		r := common.NewRfbReadHelper(bytes.NewReader(data))
+		if _, err := decoder.Read(common.NewPixelFormat(32), &rect, r); err != nil {
+			t.Fatalf("decoding %s: %s", enc, err)
+		}
+		return
+	}
+	t.Fatalf("no decoder for %s", enc)
+}
+
+func TestDecoders(t *testing.T) {
+	fb := newFramebuffer(32, 32)
+
+	// raw 2x1 rectangle at 1,1
+	decode(t, fb, common.EncRaw, common.Rectangle{X: 1, Y: 1, Width: 2, Height: 1}, append(pixel32(red), pixel32(blue)...))
+	img, _ := fb.snapshot()
+	if img.RGBAAt(1, 1) != red || img.RGBAAt(2, 1) != blue {
+		t.Errorf("raw: got %v %v", img.RGBAAt(1, 1), img.RGBAAt(2, 1))
+	}
+
+	// RRE: blue background with a red 1x1 subrectangle at 2,3
+	rre := []byte{0, 0, 0, 1}
+	rre = append(rre, pixel32(blue)...)
+	rre = append(rre, pixel32(red)...)
+	rre = append(rre, 0, 2, 0, 3, 0, 1, 0, 1)
+	decode(t, fb, common.EncRRE, common.Rectangle{X: 10, Y: 10, Width: 4, Height: 4}, rre)
+	img, _ = fb.snapshot()
+	if img.RGBAAt(10, 10) != blue || img.RGBAAt(12, 13) != red {
+		t.Errorf("rre: got %v %v", img.RGBAAt(10, 10), img.RGBAAt(12, 13))
+	}
+
+	// hextile: one tile, red background, a blue 2x1 subrectangle at 1,0
+	hextile := []byte{hextileBackgroundSpecified | hextileForegroundSpecified | hextileAnySubrects}
+	hextile = append(hextile, pixel32(red)...)
+	hextile = append(hextile, pixel32(blue)...)
+	hextile = append(hextile, 1, 0x10, 0x10)
+	decode(t, fb, common.EncHextile, common.Rectangle{X: 16, Y: 16, Width: 16, Height: 16}, hextile)
+	img, _ = fb.snapshot()
+	if img.RGBAAt(16, 16) != red || img.RGBAAt(17, 16) != blue || img.RGBAAt(18, 16) != blue || img.RGBAAt(19, 16) != red {
+		t.Errorf("hextile: got %v %v %v", img.RGBAAt(16, 16), img.RGBAAt(17, 16), img.RGBAAt(19, 16))
+	}
+
+	// copy the raw pixels to 20,1
+	decode(t, fb, common.EncCopyRect, common.Rectangle{X: 20, Y: 1, Width: 2, Height: 1}, []byte{0, 1, 0, 1})
+	img, _ = fb.snapshot()
+	if img.RGBAAt(20, 1) != red || img.RGBAAt(21, 1) != blue {
+		t.Errorf("copyrect: got %v %v", img.RGBAAt(20, 1), img.RGBAAt(21, 1))
+	}
+
+	decode(t, fb, common.EncDesktopSizePseudo, common.Rectangle{Width: 64, Height: 48}, nil)
+	if w, h := fb.size(); w != 64 || h != 48 || !fb.takeResized() {
+		t.Errorf("desktop size: got %dx%d", w, h)
+	}
+	img, _ = fb.snapshot()
+	if img.RGBAAt(1, 1) != red {
+		t.Errorf("resizing lost the pixels")
+	}
+}
+
+func TestDifference(t *testing.T) {
+	screen := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	ref := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	if d := Difference(screen, image.Pt(3, 3), ref); d != 0 {
+		t.Errorf("identical: got %f", d)
+	}
+	ref.SetRGBA(0, 0, color.RGBA{0xff, 0xff, 0xff, 0xff})
+	if d := Difference(screen, image.Pt(3, 3), ref); d != 0.25 {
+		t.Errorf("one white pixel of four: got %f", d)
+	}
+	if d := Difference(screen, image.Pt(7, 7), ref); d != 1 {
+		t.Errorf("off screen: got %f", d)
+	}
+}
+
+func TestScript(t *testing.T) {
+	script, err := ParseScript([]byte(`
+name: boot
+step_timeout: 10s
+steps:
+  - press: ctrl+alt+Delete
+  - name: boot options
+    type: "single\n"
+  - click: {x: 1, y: 2}
+  - sleep: 1s
+`), ".")
+	if err != nil {
+		t.Fatalf("parsing: %s", err)
+	}
+	if len(script.Steps) != 4 || script.Steps[2].Click.Y != 2 || script.Steps[3].Sleep.Seconds() != 1 || script.StepTimeout.Seconds() != 10 {
+		t.Errorf("unexpected script: %+v", script)
+	}
+	if title := script.Steps[0].title(0); title != "1 press" {
+		t.Errorf("unexpected title %q", title)
+	}
+
+	if _, err := ParseScript([]byte("steps:\n  - press: a\n    type: b\n"), "."); err == nil {
+		t.Errorf("expected an error for a step with two actions")
+	}
+	if _, err := ParseScript([]byte("steps:\n  - presss: a\n"), "."); err == nil {
+		t.Errorf("expected an error for an unknown field")
+	}
+}
+
+// TestDocumentedScripts parses the examples of the Script doc & the README
+func TestDocumentedScripts(t *testing.T) {
+	dir := t.TempDir()
+	if err := SavePNG(filepath.Join(dir, "boot-menu.png"), image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := ioutil.ReadFile("script.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc strings.Builder
+	for _, line := range strings.Split(string(source), "\n") {
+		if strings.HasPrefix(line, "type Script struct") {
+			break
+		}
+		if strings.HasPrefix(line, "//\t") {
+			doc.WriteString(strings.TrimPrefix(line, "//\t") + "\n")
+		}
+	}
+
+	readme, err := ioutil.ReadFile("../README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	section := strings.ReplaceAll(string(readme), "\r\n", "\n")
+	section = section[strings.Index(section, "### Automation"):]
+	start := strings.Index(section, "```yaml\n") + len("```yaml\n")
+	example := section[start : start+strings.Index(section[start:], "```")]
+
+	for name, data := range map[string]string{"Script doc": doc.String(), "README": example} {
+		script, err := ParseScript([]byte(data), dir)
+		if err != nil {
+			t.Errorf("%s example: %s", name, err)
+			continue
+		}
+		if step := script.Steps[0]; step.WaitMatch == nil || step.Timeout != 5*time.Minute {
+			t.Errorf("%s example: unexpected first step %+v", name, step)
+		}
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	results := []*ScriptResult{{Name: "boot", Steps: []StepResult{
+		{Name: "menu"},
+		{Name: "login", Err: errors.New("timeout")},
+		{Name: "logout", Skipped: true},
+	}}}
+	var out bytes.Buffer
+	if err := WriteJUnit(&out, results); err != nil {
+		t.Fatalf("writing: %s", err)
+	}
+	for _, expected := range []string{
+		`<testsuite name="boot" tests="3" failures="1" skipped="1" time="0.000">`,
+		`<testcase name="login" classname="boot" time="0.000">`,
+		`<failure message="timeout"></failure>`,
+		`<skipped></skipped>`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("missing %s in:\n%s", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,321 @@
+// Package automation drives a vnc server like a user would: typing, clicking, and waiting for the screen,
+// which is decoded locally. It is meant for unattended tests, like going through an OS installer.
+package automation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/exoscale/vncproxy/client"
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/keysym"
+)
+
+// ErrClosed is returned when the vnc server closed the connection
+var ErrClosed = errors.New("vnc connection closed")
+
+// Options configure the connection to the vnc server
+type Options struct {
+	Password string
+	// Username selects Apple Remote Desktop auth (macOS Screen Sharing)
+	Username string
+	// Exclusive disconnects the other vnc clients
+	Exclusive bool
+	// KeyDelay is the pause between key events, defaults to 10ms
+	KeyDelay time.Duration
+	// PointerDelay is the pause between pointer events of clicks & drags, defaults to 10ms
+	PointerDelay time.Duration
+}
+
+const defaultEventDelay = 10 * time.Millisecond
+
+// Client is an automated vnc client, keeping a decoded copy of the screen up to date
+type Client struct {
+	conn *client.ClientConn
+	fb   *framebuffer
+	opts Options
+
+	// writeLock serializes the messages written by the caller & by the update loop
+	writeLock sync.Mutex
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Dial connects to a vnc server (host:port or unix socket path), ctx bounds the connection & handshake
+func Dial(ctx context.Context, addr string, opts *Options) (*Client, error) {
+	network := "tcp"
+	if len(addr) > 0 && addr[0] == '/' {
+		network = "unix"
+	}
+	var dialer net.Dialer
+	nc, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := New(ctx, nc, opts)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// New runs the RFB handshake on an established connection and starts receiving the screen
+func New(ctx context.Context, nc net.Conn, opts *Options) (*Client, error) {
+	c := &Client{fb: newFramebuffer(0, 0), closed: make(chan struct{})}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.KeyDelay <= 0 {
+		c.opts.KeyDelay = defaultEventDelay
+	}
+	if c.opts.PointerDelay <= 0 {
+		c.opts.PointerDelay = defaultEventDelay
+	}
+
+	var noauth client.ClientAuthNone
+	authArr := []client.ClientAuth{&client.PasswordAuth{Password: c.opts.Password}, &noauth}
+	if c.opts.Username != "" {
+		authArr = append([]client.ClientAuth{&client.ARDAuth{Username: c.opts.Username, Password: c.opts.Password}}, authArr...)
+	}
+	conn, err := client.NewClientConn(nc, &client.ClientConfig{Auth: authArr, Exclusive: c.opts.Exclusive})
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	conn.Listeners.AddListener(&updateListener{c})
+
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+	if err := conn.Connect(); err != nil {
+		return nil, err
+	}
+	nc.SetDeadline(time.Time{})
+
+	c.fb.resize(int(conn.FrameBufferWidth), int(conn.FrameBufferHeight))
+	c.fb.takeResized()
+	pf := common.NewPixelFormat(32)
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if err := conn.SetPixelFormat(pf); err != nil {
+		return nil, err
+	}
+	conn.PixelFormat = *pf
+	if err := conn.SetEncodings(c.fb.decoders()); err != nil {
+		return nil, err
+	}
+	if err := conn.FramebufferUpdateRequest(false, 0, 0, conn.FrameBufferWidth, conn.FrameBufferHeight); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// updateListener follows the server messages: each framebuffer update wakes up the waiters & asks for the next one
+type updateListener struct {
+	c *Client
+}
+
+func (l *updateListener) Consume(seg *common.RfbSegment) error {
+	switch seg.SegmentType {
+	case common.SegmentFullyParsedServerMessage:
+		if _, ok := seg.Message.(*client.MsgFramebufferUpdate); !ok {
+			return nil
+		}
+		l.c.fb.notify()
+		incremental := !l.c.fb.takeResized()
+		width, height := l.c.fb.size()
+		l.c.writeLock.Lock()
+		defer l.c.writeLock.Unlock()
+		return l.c.conn.FramebufferUpdateRequest(incremental, 0, 0, uint16(width), uint16(height))
+	case common.SegmentConnectionClosed:
+		l.c.closeOnce.Do(func() { close(l.c.closed) })
+	}
+	return nil
+}
+
+// Close disconnects from the vnc server
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Size returns the screen's width & height
+func (c *Client) Size() (int, int) {
+	return c.fb.size()
+}
+
+// Screenshot returns a copy of the screen
+func (c *Client) Screenshot() *image.RGBA {
+	img, _ := c.fb.snapshot()
+	return img
+}
+
+// sleep waits d, or until the context is done or the connection closed
+func (c *Client) sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.closed:
+		return ErrClosed
+	}
+}
+
+func (c *Client) sendKeys(ctx context.Context, events []keysym.Event) error {
+	for i, ev := range events {
+		if i > 0 {
+			if err := c.sleep(ctx, c.opts.KeyDelay); err != nil {
+				return err
+			}
+		}
+		c.writeLock.Lock()
+		err := c.conn.KeyEvent(uint32(ev.Keysym), ev.Down)
+		c.writeLock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TypeString types the text, characters needing shift on a US keyboard are typed with shift
+func (c *Client) TypeString(ctx context.Context, text string) error {
+	return c.sendKeys(ctx, keysym.StringEvents(text))
+}
+
+// PressChord presses & releases keys joined by +, like ctrl+alt+Delete (see keysym.ParseChord)
+func (c *Client) PressChord(ctx context.Context, chord string) error {
+	keys, err := keysym.ParseChord(chord)
+	if err != nil {
+		return err
+	}
+	return c.sendKeys(ctx, keysym.Chord(keys...))
+}
+
+func (c *Client) pointer(ctx context.Context, mask client.ButtonMask, x, y int) error {
+	if x < 0 || y < 0 || x > 0xffff || y > 0xffff {
+		return fmt.Errorf("bad pointer position %d,%d", x, y)
+	}
+	if err := c.sleep(ctx, c.opts.PointerDelay); err != nil {
+		return err
+	}
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.conn.PointerEvent(mask, uint16(x), uint16(y))
+}
+
+// MoveTo moves the pointer without pressing buttons
+func (c *Client) MoveTo(ctx context.Context, x, y int) error {
+	return c.pointer(ctx, 0, x, y)
+}
+
+// Click clicks the left button at x,y
+func (c *Client) Click(ctx context.Context, x, y int) error {
+	return c.ClickButton(ctx, client.ButtonLeft, x, y)
+}
+
+// ClickButton moves the pointer to x,y then presses & releases the buttons
+func (c *Client) ClickButton(ctx context.Context, buttons client.ButtonMask, x, y int) error {
+	for _, mask := range []client.ButtonMask{0, buttons, 0} {
+		if err := c.pointer(ctx, mask, x, y); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dragSteps is the number of moves between the press & the release of a drag
+const dragSteps = 10
+
+// Drag presses the left button at x1,y1, moves to x2,y2 and releases it there
+func (c *Client) Drag(ctx context.Context, x1, y1, x2, y2 int) error {
+	if err := c.pointer(ctx, 0, x1, y1); err != nil {
+		return err
+	}
+	for i := 0; i <= dragSteps; i++ {
+		x := x1 + (x2-x1)*i/dragSteps
+		y := y1 + (y2-y1)*i/dragSteps
+		if err := c.pointer(ctx, client.ButtonLeft, x, y); err != nil {
+			return err
+		}
+	}
+	return c.pointer(ctx, 0, x2, y2)
+}
+
+// waitFor calls check on the current screen, then after each update until it returns true
+func (c *Client) waitFor(ctx context.Context, check func(*image.RGBA) bool) error {
+	for {
+		img, updated := c.fb.snapshot()
+		if check(img) {
+			return nil
+		}
+		select {
+		case <-updated:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.closed:
+			return ErrClosed
+		}
+	}
+}
+
+// WaitForScreenChange waits until the region (the whole screen if empty) differs from what it shows now
+func (c *Client) WaitForScreenChange(ctx context.Context, region image.Rectangle) error {
+	before := c.Screenshot()
+	if region.Empty() {
+		region = before.Rect
+	}
+	return c.waitFor(ctx, func(img *image.RGBA) bool {
+		return img.Rect != before.Rect || Difference(img, region.Min, before.SubImage(region)) > 0
+	})
+}
+
+// WaitForRegionMatch waits until the screen at position matches the reference image, within tolerance
+// (see Difference). The error tells the last difference when the context ends first.
+func (c *Client) WaitForRegionMatch(ctx context.Context, at image.Point, ref image.Image, tolerance float64) error {
+	diff := 1.0
+	err := c.waitFor(ctx, func(img *image.RGBA) bool {
+		diff = Difference(img, at, ref)
+		return diff <= tolerance
+	})
+	if err != nil {
+		return fmt.Errorf("screen at %d,%d doesn't match (difference %.4f, tolerance %.4f): %v", at.X, at.Y, diff, tolerance, err)
+	}
+	return nil
+}
+
+// Difference compares the screen at position with the reference image: it is the mean difference of the
+// color channels, from 0 (identical) to 1 (black vs white). References going off screen differ by 1.
+func Difference(screen *image.RGBA, at image.Point, ref image.Image) float64 {
+	bounds := ref.Bounds()
+	if bounds.Empty() {
+		return 0
+	}
+	if !bounds.Sub(bounds.Min).Add(at).In(screen.Rect) {
+		return 1
+	}
+	var total uint64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := ref.At(x, y).RGBA()
+			s := screen.RGBAAt(at.X+x-bounds.Min.X, at.Y+y-bounds.Min.Y)
+			total += absDiff(uint8(r>>8), s.R) + absDiff(uint8(g>>8), s.G) + absDiff(uint8(b>>8), s.B)
+		}
+	}
+	return float64(total) / float64(3*255*bounds.Dx()*bounds.Dy())
+}
+
+func absDiff(a, b uint8) uint64 {
+	if a > b {
+		return uint64(a - b)
+	}
+	return uint64(b - a)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/exoscale/vncproxy/automation"
+	"github.com/exoscale/vncproxy/logger"
+)
+
+func main() {
+	var target = flag.String("target", "", "vnc server (host:port or /path/to/unix.socket), overrides the scripts' target")
+	var password = flag.String("password", "", "vnc password, overrides the scripts' password")
+	var junitFile = flag.String("junit", "", "JUnit XML report to write")
+	var outputDir = flag.String("out", "", "directory of the screenshots, the scripts' directory by default")
+	var timeout = flag.Duration("timeout", 30*time.Minute, "time limit of each script")
+	var logLevel = flag.String("logLevel", "info", "change logging level")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] script.yaml...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	logger.SetLogLevel(*logLevel)
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var results []*automation.ScriptResult
+	failed := false
+	for _, path := range flag.Args() {
+		result, err := runScript(path, *target, *password, *outputDir, *timeout)
+		if err != nil {
+			logger.Errorf("script %s: %s", path, err)
+			result = &automation.ScriptResult{Name: filepath.Base(path), Steps: []automation.StepResult{{Name: "connect", Err: err}}}
+		}
+		for _, step := range result.Steps {
+			switch {
+			case step.Err != nil:
+				logger.Errorf("%s: %s: FAIL (%s): %s", result.Name, step.Name, step.Duration, step.Err)
+			case step.Skipped:
+				logger.Infof("%s: %s: skipped", result.Name, step.Name)
+			default:
+				logger.Infof("%s: %s: ok (%s)", result.Name, step.Name, step.Duration)
+			}
+		}
+		failed = failed || result.Failed()
+		results = append(results, result)
+	}
+
+	if *junitFile != "" {
+		f, err := os.Create(*junitFile)
+		if err != nil {
+			logger.Errorf("unable to create the JUnit report: %s", err)
+			os.Exit(1)
+		}
+		err = automation.WriteJUnit(f, results)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			logger.Errorf("unable to write the JUnit report: %s", err)
+			os.Exit(1)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// runScript connects to the script's vnc server and plays it, a screenshot is saved when a step fails
+func runScript(path, target, password, outputDir string, timeout time.Duration) (*automation.ScriptResult, error) {
+	script, err := automation.LoadScript(path)
+	if err != nil {
+		return nil, err
+	}
+	if target != "" {
+		script.Target = target
+	}
+	if password != "" {
+		script.Password = password
+	}
+	if script.Target == "" {
+		return nil, fmt.Errorf("no target, set it in the script or with -target")
+	}
+	script.OutputDir = outputDir
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	logger.Infof("running %s on %s", script.Name, script.Target)
+	c, err := automation.Dial(ctx, script.Target, &automation.Options{
+		Password: script.Password,
+		Username: script.Username,
+		KeyDelay: script.KeyDelay,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	result := script.Run(ctx, c)
+	if result.Failed() {
+		dir := outputDir
+		if dir == "" {
+			dir = filepath.Dir(path)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + "-failure.png"
+		if err := automation.SavePNG(filepath.Join(dir, name), c.Screenshot()); err != nil {
+			logger.Errorf("unable to save the failure screenshot: %s", err)
+		} else {
+			logger.Infof("failure screenshot: %s", filepath.Join(dir, name))
+		}
+	}
+	return result, nil
+}
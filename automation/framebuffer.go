@@ -0,0 +1,305 @@
+package automation
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sync"
+
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/encodings"
+)
+
+// framebuffer is the local copy of the vnc server's screen, updated by the decoders
+type framebuffer struct {
+	lock sync.Mutex
+	img  *image.RGBA
+	// updated is closed (and replaced) after each framebuffer update
+	updated chan struct{}
+	// resized is set by a DesktopSize rectangle, a full update is requested then
+	resized bool
+}
+
+func newFramebuffer(width, height int) *framebuffer {
+	return &framebuffer{
+		img:     image.NewRGBA(image.Rect(0, 0, width, height)),
+		updated: make(chan struct{}),
+	}
+}
+
+// snapshot returns a copy of the screen & the channel closed by the next update
+func (fb *framebuffer) snapshot() (*image.RGBA, <-chan struct{}) {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	img := image.NewRGBA(fb.img.Rect)
+	copy(img.Pix, fb.img.Pix)
+	return img, fb.updated
+}
+
+// notify wakes up the goroutines waiting for an update
+func (fb *framebuffer) notify() {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	close(fb.updated)
+	fb.updated = make(chan struct{})
+}
+
+func (fb *framebuffer) size() (int, int) {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	return fb.img.Rect.Dx(), fb.img.Rect.Dy()
+}
+
+func (fb *framebuffer) resize(width, height int) {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	copyRect(img, fb.img, img.Rect.Intersect(fb.img.Rect), image.Point{})
+	fb.img = img
+	fb.resized = true
+}
+
+// takeResized tells whether the framebuffer was resized since the last call
+func (fb *framebuffer) takeResized() bool {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	resized := fb.resized
+	fb.resized = false
+	return resized
+}
+
+// fill paints a rectangle, clipped to the screen
+func (fb *framebuffer) fill(r image.Rectangle, c color.RGBA) {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	r = r.Intersect(fb.img.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			fb.img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+// copyRect copies the src rectangle r to dst at dp, overlapping rectangles are handled
+func copyRect(dst, src *image.RGBA, r image.Rectangle, dp image.Point) {
+	rows := make([][]byte, 0, r.Dy())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		start := src.PixOffset(r.Min.X, y)
+		rows = append(rows, append([]byte{}, src.Pix[start:start+4*r.Dx()]...))
+	}
+	for i, row := range rows {
+		if !image.Pt(dp.X, dp.Y+i).In(dst.Rect) || !image.Pt(dp.X+r.Dx()-1, dp.Y+i).In(dst.Rect) {
+			continue
+		}
+		copy(dst.Pix[dst.PixOffset(dp.X, dp.Y+i):], row)
+	}
+}
+
+// readPixel reads one pixel in the connection's pixel format, only true color formats are supported
+func readPixel(r io.Reader, pf *common.PixelFormat) (color.RGBA, error) {
+	var buf [4]byte
+	bpp := int(pf.BPP / 8)
+	if bpp < 1 || bpp > 4 {
+		return color.RGBA{}, fmt.Errorf("unsupported pixel size: %d bits", pf.BPP)
+	}
+	if _, err := io.ReadFull(r, buf[:bpp]); err != nil {
+		return color.RGBA{}, err
+	}
+	var v uint32
+	for i := 0; i < bpp; i++ {
+		if pf.BigEndian != 0 {
+			v = v<<8 | uint32(buf[i])
+		} else {
+			v |= uint32(buf[i]) << (8 * uint(i))
+		}
+	}
+	return color.RGBA{
+		R: scaleColor(v>>pf.RedShift, pf.RedMax),
+		G: scaleColor(v>>pf.GreenShift, pf.GreenMax),
+		B: scaleColor(v>>pf.BlueShift, pf.BlueMax),
+		A: 0xff,
+	}, nil
+}
+
+func scaleColor(v uint32, max uint16) uint8 {
+	if max == 0 {
+		return 0
+	}
+	return uint8((v & uint32(max)) * 255 / uint32(max))
+}
+
+// the decoders are the encodings given to the ClientConn, they paint into the framebuffer while reading.
+// PseudoEncoding provides their type & an empty WriteTo, nothing is written back.
+
+type rawDecoder struct {
+	encodings.PseudoEncoding
+	fb *framebuffer
+}
+
+func (d *rawDecoder) Read(pf *common.PixelFormat, rect *common.Rectangle, r *common.RfbReadHelper) (common.IEncoding, error) {
+	pix := image.NewRGBA(image.Rect(int(rect.X), int(rect.Y), int(rect.X)+int(rect.Width), int(rect.Y)+int(rect.Height)))
+	for y := pix.Rect.Min.Y; y < pix.Rect.Max.Y; y++ {
+		for x := pix.Rect.Min.X; x < pix.Rect.Max.X; x++ {
+			c, err := readPixel(r, pf)
+			if err != nil {
+				return nil, err
+			}
+			pix.SetRGBA(x, y, c)
+		}
+	}
+	d.fb.lock.Lock()
+	copyRect(d.fb.img, pix, pix.Rect.Intersect(d.fb.img.Rect), pix.Rect.Intersect(d.fb.img.Rect).Min)
+	d.fb.lock.Unlock()
+	return d, nil
+}
+
+type copyRectDecoder struct {
+	encodings.PseudoEncoding
+	fb *framebuffer
+}
+
+func (d *copyRectDecoder) Read(pf *common.PixelFormat, rect *common.Rectangle, r *common.RfbReadHelper) (common.IEncoding, error) {
+	srcX, err := r.ReadUint16()
+	if err != nil {
+		return nil, err
+	}
+	srcY, err := r.ReadUint16()
+	if err != nil {
+		return nil, err
+	}
+	d.fb.lock.Lock()
+	src := image.Rect(int(srcX), int(srcY), int(srcX)+int(rect.Width), int(srcY)+int(rect.Height)).Intersect(d.fb.img.Rect)
+	copyRect(d.fb.img, d.fb.img, src, image.Pt(int(rect.X), int(rect.Y)))
+	d.fb.lock.Unlock()
+	return d, nil
+}
+
+type rreDecoder struct {
+	encodings.PseudoEncoding
+	fb *framebuffer
+}
+
+func (d *rreDecoder) Read(pf *common.PixelFormat, rect *common.Rectangle, r *common.RfbReadHelper) (common.IEncoding, error) {
+	count, err := r.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	bg, err := readPixel(r, pf)
+	if err != nil {
+		return nil, err
+	}
+	x0, y0 := int(rect.X), int(rect.Y)
+	d.fb.fill(image.Rect(x0, y0, x0+int(rect.Width), y0+int(rect.Height)), bg)
+	for i := uint32(0); i < count; i++ {
+		c, err := readPixel(r, pf)
+		if err != nil {
+			return nil, err
+		}
+		var sub [4]uint16
+		for j := range sub {
+			if sub[j], err = r.ReadUint16(); err != nil {
+				return nil, err
+			}
+		}
+		d.fb.fill(image.Rect(x0+int(sub[0]), y0+int(sub[1]), x0+int(sub[0]+sub[2]), y0+int(sub[1]+sub[3])), c)
+	}
+	return d, nil
+}
+
+// hextile subencoding flags
+const (
+	hextileRaw                 = 1
+	hextileBackgroundSpecified = 2
+	hextileForegroundSpecified = 4
+	hextileAnySubrects         = 8
+	hextileSubrectsColoured    = 16
+)
+
+type hextileDecoder struct {
+	encodings.PseudoEncoding
+	fb *framebuffer
+}
+
+func (d *hextileDecoder) Read(pf *common.PixelFormat, rect *common.Rectangle, r *common.RfbReadHelper) (common.IEncoding, error) {
+	var bg, fg color.RGBA
+	right, bottom := int(rect.X)+int(rect.Width), int(rect.Y)+int(rect.Height)
+	for ty := int(rect.Y); ty < bottom; ty += 16 {
+		for tx := int(rect.X); tx < right; tx += 16 {
+			tile := image.Rect(tx, ty, tx+16, ty+16).Intersect(image.Rect(tx, ty, right, bottom))
+			subencoding, err := r.ReadUint8()
+			if err != nil {
+				return nil, err
+			}
+
+			if subencoding&hextileRaw != 0 {
+				raw := &rawDecoder{fb: d.fb}
+				tileRect := &common.Rectangle{X: uint16(tx), Y: uint16(ty), Width: uint16(tile.Dx()), Height: uint16(tile.Dy())}
+				if _, err := raw.Read(pf, tileRect, r); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if subencoding&hextileBackgroundSpecified != 0 {
+				if bg, err = readPixel(r, pf); err != nil {
+					return nil, err
+				}
+			}
+			d.fb.fill(tile, bg)
+			if subencoding&hextileForegroundSpecified != 0 {
+				if fg, err = readPixel(r, pf); err != nil {
+					return nil, err
+				}
+			}
+			if subencoding&hextileAnySubrects == 0 {
+				continue
+			}
+
+			count, err := r.ReadUint8()
+			if err != nil {
+				return nil, err
+			}
+			for i := uint8(0); i < count; i++ {
+				c := fg
+				if subencoding&hextileSubrectsColoured != 0 {
+					if c, err = readPixel(r, pf); err != nil {
+						return nil, err
+					}
+				}
+				xy, err := r.ReadUint8()
+				if err != nil {
+					return nil, err
+				}
+				wh, err := r.ReadUint8()
+				if err != nil {
+					return nil, err
+				}
+				x, y := tx+int(xy>>4), ty+int(xy&0xf)
+				d.fb.fill(image.Rect(x, y, x+int(wh>>4)+1, y+int(wh&0xf)+1), c)
+			}
+		}
+	}
+	return d, nil
+}
+
+// desktopSizeDecoder resizes the framebuffer when the vnc server changes its resolution
+type desktopSizeDecoder struct {
+	encodings.PseudoEncoding
+	fb *framebuffer
+}
+
+func (d *desktopSizeDecoder) Read(pf *common.PixelFormat, rect *common.Rectangle, r *common.RfbReadHelper) (common.IEncoding, error) {
+	d.fb.resize(int(rect.Width), int(rect.Height))
+	return d, nil
+}
+
+// decoders returns the encodings asked to the vnc server, in order of preference
+func (fb *framebuffer) decoders() []common.IEncoding {
+	return []common.IEncoding{
+		&copyRectDecoder{encodings.PseudoEncoding{Typ: int32(common.EncCopyRect)}, fb},
+		&hextileDecoder{encodings.PseudoEncoding{Typ: int32(common.EncHextile)}, fb},
+		&rreDecoder{encodings.PseudoEncoding{Typ: int32(common.EncRRE)}, fb},
+		&rawDecoder{encodings.PseudoEncoding{Typ: int32(common.EncRaw)}, fb},
+		&desktopSizeDecoder{encodings.PseudoEncoding{Typ: int32(common.EncDesktopSizePseudo)}, fb},
+	}
+}
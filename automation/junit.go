@@ -0,0 +1,95 @@
+package automation
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// StepResult is the outcome of a script step
+type StepResult struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+	Skipped  bool
+}
+
+// ScriptResult is the outcome of a script run
+type ScriptResult struct {
+	Name     string
+	Duration time.Duration
+	Steps    []StepResult
+}
+
+// Failed tells whether a step failed
+func (r *ScriptResult) Failed() bool {
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit writes the results as a JUnit XML report, one test suite per script & one test case per step
+func WriteJUnit(w io.Writer, results []*ScriptResult) error {
+	report := junitTestSuites{}
+	for _, result := range results {
+		suite := junitTestSuite{Name: result.Name, Tests: len(result.Steps), Time: junitTime(result.Duration)}
+		for _, step := range result.Steps {
+			testCase := junitTestCase{Name: step.Name, ClassName: result.Name, Time: junitTime(step.Duration)}
+			if step.Err != nil {
+				testCase.Failure = &junitFailure{Message: step.Err.Error()}
+				suite.Failures++
+			}
+			if step.Skipped {
+				testCase.Skipped = &struct{}{}
+				suite.Skipped++
+			}
+			suite.Cases = append(suite.Cases, testCase)
+		}
+		report.Suites = append(report.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
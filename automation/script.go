@@ -0,0 +1,251 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Script is a declarative automation scenario:
+//
+//	name: debian installer
+//	target: 127.0.0.1:5900
+//	password: secret
+//	step_timeout: 2m
+//	steps:
+//	  - name: boot menu
+//	    wait_match: {image: boot-menu.png, x: 0, y: 0, tolerance: 0.02}
+//	    timeout: 5m
+//	  - press: tab
+//	  - type: " auto=true priority=critical\n"
+//	  - wait_change: {}
+//	  - click: {x: 512, y: 700}
+//	  - drag: {from: {x: 10, y: 10}, to: {x: 200, y: 10}}
+//	  - sleep: 5s
+//	  - screenshot: installed.png
+//
+// Each step does one thing, the paths are relative to the script's directory (see OutputDir for screenshots).
+type Script struct {
+	Name     string `yaml:"name"`
+	Target   string `yaml:"target"`
+	Password string `yaml:"password"`
+	Username string `yaml:"username"`
+	// StepTimeout bounds the steps without timeout, defaults to 1 minute
+	StepTimeout time.Duration `yaml:"step_timeout"`
+	KeyDelay    time.Duration `yaml:"key_delay"`
+	Steps       []Step        `yaml:"steps"`
+	// OutputDir is where the screenshots go, the script's directory by default
+	OutputDir string `yaml:"-"`
+
+	dir string
+}
+
+// Point is a screen position
+type Point struct {
+	X int `yaml:"x"`
+	Y int `yaml:"y"`
+}
+
+// DragStep drags with the left button
+type DragStep struct {
+	From Point `yaml:"from"`
+	To   Point `yaml:"to"`
+}
+
+// RegionStep limits WaitForScreenChange to a rectangle, the whole screen when empty
+type RegionStep struct {
+	X      int `yaml:"x"`
+	Y      int `yaml:"y"`
+	Width  int `yaml:"width"`
+	Height int `yaml:"height"`
+}
+
+// MatchStep waits for the screen at x,y to match a PNG image
+type MatchStep struct {
+	Image     string  `yaml:"image"`
+	X         int     `yaml:"x"`
+	Y         int     `yaml:"y"`
+	Tolerance float64 `yaml:"tolerance"`
+
+	ref image.Image
+}
+
+// Step is one action of a script
+type Step struct {
+	Name       string        `yaml:"name"`
+	Type       string        `yaml:"type"`
+	Press      string        `yaml:"press"`
+	Click      *Point        `yaml:"click"`
+	Drag       *DragStep     `yaml:"drag"`
+	WaitChange *RegionStep   `yaml:"wait_change"`
+	WaitMatch  *MatchStep    `yaml:"wait_match"`
+	Screenshot string        `yaml:"screenshot"`
+	Sleep      time.Duration `yaml:"sleep"`
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+const defaultStepTimeout = time.Minute
+
+// action returns the name of the step's action, and how many actions it has
+func (s *Step) action() (string, int) {
+	name, count := "", 0
+	set := func(isSet bool, action string) {
+		if isSet {
+			name = action
+			count++
+		}
+	}
+	set(s.Type != "", "type")
+	set(s.Press != "", "press")
+	set(s.Click != nil, "click")
+	set(s.Drag != nil, "drag")
+	set(s.WaitChange != nil, "wait_change")
+	set(s.WaitMatch != nil, "wait_match")
+	set(s.Screenshot != "", "screenshot")
+	set(s.Sleep != 0, "sleep")
+	return name, count
+}
+
+// title names the step in the results
+func (s *Step) title(i int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	action, _ := s.action()
+	return fmt.Sprintf("%d %s", i+1, action)
+}
+
+// LoadScript reads a YAML script and the images it matches against
+func LoadScript(path string) (*Script, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	script, err := ParseScript(data, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("script %s: %v", path, err)
+	}
+	if script.Name == "" {
+		script.Name = filepath.Base(path)
+	}
+	return script, nil
+}
+
+// ParseScript parses a YAML script, dir is where its relative paths start from
+func ParseScript(data []byte, dir string) (*Script, error) {
+	script := &Script{dir: dir}
+	if err := yaml.UnmarshalStrict(data, script); err != nil {
+		return nil, err
+	}
+	if script.StepTimeout <= 0 {
+		script.StepTimeout = defaultStepTimeout
+	}
+	for i := range script.Steps {
+		step := &script.Steps[i]
+		if _, count := step.action(); count != 1 {
+			return nil, fmt.Errorf("step %d: %d actions, expected one", i+1, count)
+		}
+		if step.WaitMatch != nil {
+			var err error
+			if step.WaitMatch.ref, err = LoadPNG(script.path(step.WaitMatch.Image)); err != nil {
+				return nil, fmt.Errorf("step %d: %v", i+1, err)
+			}
+		}
+	}
+	return script, nil
+}
+
+func (s *Script) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.dir, name)
+}
+
+// Run plays the steps in order, the steps after a failure are skipped
+func (s *Script) Run(ctx context.Context, c *Client) *ScriptResult {
+	result := &ScriptResult{Name: s.Name}
+	start := time.Now()
+	failed := false
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		stepResult := StepResult{Name: step.title(i)}
+		if failed || ctx.Err() != nil {
+			stepResult.Skipped = true
+		} else {
+			stepStart := time.Now()
+			stepResult.Err = s.runStep(ctx, c, step)
+			stepResult.Duration = time.Since(stepStart)
+			failed = stepResult.Err != nil
+		}
+		result.Steps = append(result.Steps, stepResult)
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+func (s *Script) runStep(ctx context.Context, c *Client, step *Step) error {
+	timeout := step.Timeout
+	if timeout <= 0 {
+		timeout = s.StepTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	action, _ := step.action()
+	switch action {
+	case "type":
+		return c.TypeString(ctx, step.Type)
+	case "press":
+		return c.PressChord(ctx, step.Press)
+	case "click":
+		return c.Click(ctx, step.Click.X, step.Click.Y)
+	case "drag":
+		return c.Drag(ctx, step.Drag.From.X, step.Drag.From.Y, step.Drag.To.X, step.Drag.To.Y)
+	case "wait_change":
+		r := step.WaitChange
+		return c.WaitForScreenChange(ctx, image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height))
+	case "wait_match":
+		m := step.WaitMatch
+		return c.WaitForRegionMatch(ctx, image.Pt(m.X, m.Y), m.ref, m.Tolerance)
+	case "screenshot":
+		path := s.path(step.Screenshot)
+		if s.OutputDir != "" && !filepath.IsAbs(step.Screenshot) {
+			path = filepath.Join(s.OutputDir, step.Screenshot)
+		}
+		return SavePNG(path, c.Screenshot())
+	case "sleep":
+		return c.sleep(ctx, step.Sleep)
+	}
+	return fmt.Errorf("unknown action %s", action)
+}
+
+// LoadPNG reads a PNG image
+func LoadPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+// SavePNG writes an image as PNG
+func SavePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net"
-	"unicode"
+	"sync"
 
 	"github.com/exoscale/vncproxy/common"
 	"github.com/exoscale/vncproxy/logger"
@@ -54,6 +54,21 @@ type ClientConn struct {
 	PixelFormat common.PixelFormat
 
 	Listeners *common.MultiListener
+
+	// set once the security handshake is done, see Authenticate
+	authenticated bool
+
+	// the ProtocolVersion agreed on with the server
+	protocolVersion string
+
+	// TightCaps are the interaction capabilities sent after ServerInit, nil unless the Tight security type was used
+	TightCaps *common.TightServerInit
+	tightUsed bool
+
+	// Extended Clipboard state: the server's caps & the last text set with CutText
+	clipboardLock       sync.Mutex
+	serverClipboardCaps *common.ExtendedClipboard
+	clipboardText       string
 }
 
 // A ClientConfig structure is used to configure a ClientConn. After
@@ -83,6 +98,8 @@ func NewClientConn(c net.Conn, cfg *ClientConfig) (*ClientConn, error) {
 	return conn, nil
 }
 
+// Connect completes the handshake (skipping the security part if Authenticate was already called),
+// publishes the ServerInit message to the listeners and starts reading server messages.
 func (conn *ClientConn) Connect() error {
 
 	if err := conn.handshake(); err != nil {
@@ -96,6 +113,18 @@ func (conn *ClientConn) Connect() error {
 	return nil
 }
 
+// Authenticate runs only the version & security handshake, this allows the
+// security step to be done before the listeners are set up (e.g. when relaying
+// authentication from another connection). Connect must be called afterwards.
+func (conn *ClientConn) Authenticate() error {
+	if err := conn.authenticate(); err != nil {
+		logger.Errorf("ClientConn.Authenticate error: %v", err)
+		conn.Close()
+		return err
+	}
+	return nil
+}
+
 func (c *ClientConn) Close() error {
 	return c.conn.Close()
 }
@@ -117,12 +146,36 @@ func (c *ClientConn) Read(bytes []byte) (n int, err error) {
 }
 
 // CutText tells the server that the client has new text in its cut buffer.
-// The text string MUST only contain Latin-1 characters. This encoding
-// is compatible with Go's native string format, but can only use up to
-// unicode.MaxLatin values.
+// If the server announced the Extended Clipboard extension the text is sent
+// as UTF-8, otherwise it MUST only contain Latin-1 characters.
 //
 // See RFC 6143 Section 7.5.6
 func (c *ClientConn) CutText(text string) error {
+	c.clipboardLock.Lock()
+	c.clipboardText = text
+	serverCaps := c.serverClipboardCaps
+	c.clipboardLock.Unlock()
+
+	if serverCaps != nil && serverCaps.Flags&common.ClipboardProvide != 0 && serverCaps.Flags&common.ClipboardText != 0 {
+		return c.writeExtendedCutText(common.NewClipboardProvide(text, "", ""))
+	}
+
+	textBytes, ok := common.StringToLatin1(text)
+	if !ok {
+		return fmt.Errorf("text %q is not valid Latin-1", text)
+	}
+	return c.writeCutText(textBytes, false)
+}
+
+func (c *ClientConn) writeExtendedCutText(ext *common.ExtendedClipboard) error {
+	payload, err := ext.Bytes()
+	if err != nil {
+		return err
+	}
+	return c.writeCutText(payload, true)
+}
+
+func (c *ClientConn) writeCutText(payload []byte, extended bool) error {
 	var buf bytes.Buffer
 
 	// This is the fixed size data we'll send
@@ -131,7 +184,7 @@ func (c *ClientConn) CutText(text string) error {
 		uint8(0),
 		uint8(0),
 		uint8(0),
-		uint32(len(text)),
+		common.CutTextLength(payload, extended),
 	}
 
 	for _, val := range fixedData {
@@ -139,23 +192,68 @@ func (c *ClientConn) CutText(text string) error {
 			return err
 		}
 	}
+	buf.Write(payload)
 
-	for _, char := range text {
-		if char > unicode.MaxLatin1 {
-			return fmt.Errorf("Character '%s' is not valid Latin-1", char)
-		}
+	if _, err := c.conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
 
-		if err := binary.Write(&buf, binary.BigEndian, uint8(char)); err != nil {
-			return err
+	return nil
+}
+
+// ExtendedClipboardCaps returns the Extended Clipboard capabilities announced by the server, nil if none
+func (c *ClientConn) ExtendedClipboardCaps() *common.ExtendedClipboard {
+	c.clipboardLock.Lock()
+	defer c.clipboardLock.Unlock()
+	return c.serverClipboardCaps
+}
+
+// handleExtendedClipboard answers the server's extended clipboard messages, as long as the client itself
+// asked for the Extended Clipboard pseudo-encoding (a proxied connection leaves this to the vnc-client)
+func (c *ClientConn) handleExtendedClipboard(ext *common.ExtendedClipboard) {
+	c.clipboardLock.Lock()
+	if ext.Action() == common.ClipboardCaps {
+		c.serverClipboardCaps = ext
+	}
+	text := c.clipboardText
+	c.clipboardLock.Unlock()
+
+	if !c.hasEncoding(common.EncExtendedClipboardPseudo) {
+		return
+	}
+
+	var reply *common.ExtendedClipboard
+	switch ext.Action() {
+	case common.ClipboardCaps:
+		reply = common.NewClipboardCaps(
+			common.ClipboardRequest|common.ClipboardPeek|common.ClipboardNotify|common.ClipboardProvide,
+			map[uint32]uint32{common.ClipboardText: common.MaxClipboardSize})
+	case common.ClipboardRequest:
+		if ext.Formats()&common.ClipboardText == 0 {
+			return
+		}
+		reply = common.NewClipboardProvide(text, "", "")
+	case common.ClipboardPeek:
+		reply = &common.ExtendedClipboard{Flags: common.ClipboardNotify}
+		if text != "" {
+			reply.Flags |= common.ClipboardText
 		}
+	default:
+		return
 	}
 
-	dataLength := 8 + len(text)
-	if _, err := c.conn.Write(buf.Bytes()[0:dataLength]); err != nil {
-		return err
+	if err := c.writeExtendedCutText(reply); err != nil {
+		logger.Errorf("ClientConn.handleExtendedClipboard: error answering %s: %s", ext, err)
 	}
+}
 
-	return nil
+func (c *ClientConn) hasEncoding(encType common.EncodingType) bool {
+	for _, enc := range c.Encs {
+		if enc.Type() == int32(encType) {
+			return true
+		}
+	}
+	return false
 }
 
 // Requests a framebuffer update from the server. There may be an indefinite
@@ -311,6 +409,17 @@ func (c *ClientConn) SetPixelFormat(format *common.PixelFormat) error {
 
 const pvLen = 12 // ProtocolVersion message length.
 
+const (
+	protoVersion33 = "RFB 003.003\n"
+	protoVersion37 = "RFB 003.007\n"
+	protoVersion38 = "RFB 003.008\n"
+)
+
+// ProtocolVersion returns the ProtocolVersion message agreed on with the server (e.g. "RFB 003.008\n").
+func (c *ClientConn) ProtocolVersion() string {
+	return c.protocolVersion
+}
+
 func parseProtocolVersion(pv []byte) (uint, uint, error) {
 	var major, minor uint
 
@@ -330,6 +439,15 @@ func parseProtocolVersion(pv []byte) (uint, uint, error) {
 }
 
 func (c *ClientConn) handshake() error {
+	if !c.authenticated {
+		if err := c.authenticate(); err != nil {
+			return err
+		}
+	}
+	return c.initialize()
+}
+
+func (c *ClientConn) authenticate() error {
 	var protocolVersion [pvLen]byte
 
 	// 7.1.1, read the ProtocolVersion message sent by the server.
@@ -344,39 +462,97 @@ func (c *ClientConn) handshake() error {
 	if maxMajor < 3 {
 		return fmt.Errorf("unsupported major version, less than 3: %d", maxMajor)
 	}
-	if maxMinor < 8 {
-		return fmt.Errorf("unsupported minor version, less than 8: %d", maxMinor)
+
+	// unknown minor versions below 3.7 must be handled as 3.3 (see 7.1.1)
+	switch {
+	case maxMajor > 3 || maxMinor >= 8:
+		c.protocolVersion = protoVersion38
+	case maxMinor == 7:
+		c.protocolVersion = protoVersion37
+	case maxMinor >= 3:
+		c.protocolVersion = protoVersion33
+	default:
+		return fmt.Errorf("unsupported minor version, less than 3: %d", maxMinor)
 	}
 
 	// Respond with the version we will support
-	if _, err = c.conn.Write([]byte("RFB 003.008\n")); err != nil {
+	if _, err = c.conn.Write([]byte(c.protocolVersion)); err != nil {
 		return err
 	}
 
 	// 7.1.2 Security Handshake from server
-	var numSecurityTypes uint8
-	if err = binary.Read(c.conn, binary.BigEndian, &numSecurityTypes); err != nil {
-		return fmt.Errorf("Error reading security types: %v", err)
+	var auth ClientAuth
+	if c.protocolVersion == protoVersion33 {
+		auth, err = c.securityType33()
+	} else {
+		auth, err = c.negotiateSecurityType()
+	}
+	if err != nil {
 		return err
 	}
 
-	if numSecurityTypes == 0 {
-		return fmt.Errorf("Error: no security types: %s", c.readErrorReason())
+	authType := auth.SecurityType()
+	if tight, ok := auth.(*ClientAuthTight); ok {
+		c.tightUsed = true
+		authType, err = tight.handshake(c.conn)
+	} else if sa, ok := auth.(streamAuth); ok {
+		var conn io.ReadWriteCloser
+		if conn, err = sa.handshakeStream(c.conn); err == nil {
+			c.conn = conn
+		}
+	} else {
+		err = auth.Handshake(c.conn)
 	}
-
-	securityTypes := make([]uint8, numSecurityTypes)
-	if err = binary.Read(c.conn, binary.BigEndian, &securityTypes); err != nil {
+	if err != nil {
 		return err
 	}
 
-	clientSecurityTypes := c.config.Auth
-	if clientSecurityTypes == nil {
-		clientSecurityTypes = []ClientAuth{new(ClientAuthNone)}
+	// 7.1.3 SecurityResult Handshake, not sent for None before 3.8
+	if authType != 1 || c.protocolVersion == protoVersion38 {
+		var securityResult uint32
+		if err = binary.Read(c.conn, binary.BigEndian, &securityResult); err != nil {
+			return err
+		}
+
+		if securityResult == 1 {
+			if c.protocolVersion != protoVersion38 {
+				return fmt.Errorf("security handshake failed")
+			}
+			return fmt.Errorf("security handshake failed: %s", c.readErrorReason())
+		}
+	}
+
+	c.authenticated = true
+	return nil
+}
+
+// clientAuths returns the configured auth methods, defaulting to None
+func (c *ClientConn) clientAuths() []ClientAuth {
+	if c.config.Auth == nil {
+		return []ClientAuth{new(ClientAuthNone)}
+	}
+	return c.config.Auth
+}
+
+// negotiateSecurityType picks the first configured auth method offered by the server (3.7+).
+func (c *ClientConn) negotiateSecurityType() (ClientAuth, error) {
+	var numSecurityTypes uint8
+	if err := binary.Read(c.conn, binary.BigEndian, &numSecurityTypes); err != nil {
+		return nil, fmt.Errorf("Error reading security types: %v", err)
+	}
+
+	if numSecurityTypes == 0 {
+		return nil, fmt.Errorf("Error: no security types: %s", c.readErrorReason())
+	}
+
+	securityTypes := make([]uint8, numSecurityTypes)
+	if err := binary.Read(c.conn, binary.BigEndian, &securityTypes); err != nil {
+		return nil, err
 	}
 
 	var auth ClientAuth
 FindAuth:
-	for _, curAuth := range clientSecurityTypes {
+	for _, curAuth := range c.clientAuths() {
 		for _, securityType := range securityTypes {
 			if curAuth.SecurityType() == securityType {
 				// We use the first matching supported authentication
@@ -387,27 +563,37 @@ FindAuth:
 	}
 
 	if auth == nil {
-		return fmt.Errorf("no suitable auth schemes found. server supported: %#v", securityTypes)
+		return nil, fmt.Errorf("no suitable auth schemes found. server supported: %#v", securityTypes)
 	}
 
 	// Respond back with the security type we'll use
-	if err = binary.Write(c.conn, binary.BigEndian, auth.SecurityType()); err != nil {
-		return err
+	if err := binary.Write(c.conn, binary.BigEndian, auth.SecurityType()); err != nil {
+		return nil, err
 	}
+	return auth, nil
+}
 
-	if err = auth.Handshake(c.conn); err != nil {
-		return err
+// securityType33 reads the security type chosen by a 3.3 server.
+func (c *ClientConn) securityType33() (ClientAuth, error) {
+	var securityType uint32
+	if err := binary.Read(c.conn, binary.BigEndian, &securityType); err != nil {
+		return nil, fmt.Errorf("Error reading security type: %v", err)
 	}
 
-	// 7.1.3 SecurityResult Handshake
-	var securityResult uint32
-	if err = binary.Read(c.conn, binary.BigEndian, &securityResult); err != nil {
-		return err
+	if securityType == 0 {
+		return nil, fmt.Errorf("Error: connection failed: %s", c.readErrorReason())
 	}
 
-	if securityResult == 1 {
-		return fmt.Errorf("security handshake failed: %s", c.readErrorReason())
+	for _, curAuth := range c.clientAuths() {
+		if uint32(curAuth.SecurityType()) == securityType {
+			return curAuth, nil
+		}
 	}
+	return nil, fmt.Errorf("no suitable auth schemes found. server requires: %d", securityType)
+}
+
+func (c *ClientConn) initialize() error {
+	var err error
 
 	// 7.3.1 ClientInit
 	var sharedFlag uint8 = 1
@@ -444,6 +630,16 @@ FindAuth:
 	}
 
 	c.DesktopName = string(nameBytes)
+
+	if c.tightUsed {
+		c.TightCaps = &common.TightServerInit{}
+		if err = c.TightCaps.ReadFrom(c.conn); err != nil {
+			return err
+		}
+		logger.Debugf("ClientConn.initialize: Tight capabilities: %d server messages, %d client messages, %d encodings",
+			len(c.TightCaps.ServerMessageCaps), len(c.TightCaps.ClientMessageCaps), len(c.TightCaps.EncodingCaps))
+	}
+
 	srvInit := common.ServerInit{
 		NameLength:  nameLength,
 		NameText:    nameBytes,
@@ -506,11 +702,15 @@ func (c *ClientConn) mainLoop() {
 		reader.SendMessageStart(common.ServerMessageType(messageType))
 		reader.PublishBytes([]byte{byte(messageType)})
 
-		_, err := msg.Read(c, reader)
+		parsedMsg, err := msg.Read(c, reader)
 		if err != nil {
 			logger.Errorf("ClientConn.MainLoop: error parsing message, %s", err)
 			break
 		}
+		c.Listeners.Consume(&common.RfbSegment{
+			SegmentType: common.SegmentFullyParsedServerMessage,
+			Message:     parsedMsg,
+		})
 	}
 }
 
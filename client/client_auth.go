@@ -1,9 +1,20 @@
 package client
 
 import (
+	"bytes"
+	"crypto/aes"
 	"crypto/des"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
+	"math/big"
+
+	"github.com/exoscale/vncproxy/common"
 )
 
 // ClientAuthNone is the "none" authentication. See 7.2.1
@@ -45,6 +56,310 @@ func (p *PasswordAuth) Handshake(c io.ReadWriteCloser) error {
 	return nil
 }
 
+// RelayedPasswordAuth is VNC authentication (7.2.2) done without knowing the password:
+// the server's challenge is passed to Respond, which returns the response computed elsewhere
+// (e.g. by the vnc-client on the other side of a proxy).
+type RelayedPasswordAuth struct {
+	Respond func(challenge []byte) ([]byte, error)
+}
+
+func (p *RelayedPasswordAuth) SecurityType() uint8 {
+	return 2
+}
+
+func (p *RelayedPasswordAuth) Handshake(c io.ReadWriteCloser) error {
+	challenge := make([]uint8, 16)
+	if err := binary.Read(c, binary.BigEndian, &challenge); err != nil {
+		return err
+	}
+
+	response, err := p.Respond(challenge)
+	if err != nil {
+		return err
+	}
+	if len(response) != 16 {
+		return fmt.Errorf("relayed VNC auth response has a bad length: %d", len(response))
+	}
+
+	return binary.Write(c, binary.BigEndian, response)
+}
+
+// ClientAuthTight is the TightVNC security type (16): tunneling is refused, and the first
+// of Auth offered in the server's auth capability list is used.
+type ClientAuthTight struct {
+	Auth []ClientAuth
+}
+
+func (t *ClientAuthTight) SecurityType() uint8 {
+	return 16
+}
+
+func (t *ClientAuthTight) Handshake(c io.ReadWriteCloser) error {
+	_, err := t.handshake(c)
+	return err
+}
+
+// handshake returns the security type of the authentication done (1 when the server asked for none)
+func (t *ClientAuthTight) handshake(c io.ReadWriteCloser) (uint8, error) {
+	tunnels, err := common.ReadTightCapabilities(c)
+	if err != nil {
+		return 0, err
+	}
+	if len(tunnels) > 0 {
+		found := false
+		for _, tunnel := range tunnels {
+			if tunnel.Code == common.TightCapNoTunnel.Code {
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("Tight security: server requires tunneling")
+		}
+		if err := binary.Write(c, binary.BigEndian, common.TightCapNoTunnel.Code); err != nil {
+			return 0, err
+		}
+	}
+
+	authCaps, err := common.ReadTightCapabilities(c)
+	if err != nil {
+		return 0, err
+	}
+	if len(authCaps) == 0 {
+		return 1, nil
+	}
+
+	for _, auth := range t.Auth {
+		for _, authCap := range authCaps {
+			if authCap.Code == uint32(auth.SecurityType()) {
+				if err := binary.Write(c, binary.BigEndian, authCap.Code); err != nil {
+					return 0, err
+				}
+				return auth.SecurityType(), auth.Handshake(c)
+			}
+		}
+	}
+	return 0, fmt.Errorf("Tight security: no suitable auth type found. server supported: %v", authCaps)
+}
+
+// ARDAuth is Apple Remote Desktop authentication (type 30), used by macOS Screen Sharing:
+// a Diffie-Hellman exchange gives the AES key encrypting the username & password.
+type ARDAuth struct {
+	Username string
+	Password string
+}
+
+func (a *ARDAuth) SecurityType() uint8 {
+	return 30
+}
+
+// ardMaxKeyLength bounds the DH key length announced by the server
+const ardMaxKeyLength = 1024
+
+func (a *ARDAuth) Handshake(c io.ReadWriteCloser) error {
+	var generator, keyLength uint16
+	if err := binary.Read(c, binary.BigEndian, &generator); err != nil {
+		return err
+	}
+	if err := binary.Read(c, binary.BigEndian, &keyLength); err != nil {
+		return err
+	}
+	if keyLength == 0 || keyLength > ardMaxKeyLength {
+		return fmt.Errorf("ARD auth: bad key length %d", keyLength)
+	}
+	prime := make([]byte, keyLength)
+	if _, err := io.ReadFull(c, prime); err != nil {
+		return err
+	}
+	serverKey := make([]byte, keyLength)
+	if _, err := io.ReadFull(c, serverKey); err != nil {
+		return err
+	}
+
+	p := new(big.Int).SetBytes(prime)
+	private, err := rand.Int(rand.Reader, p)
+	if err != nil {
+		return err
+	}
+	public := new(big.Int).Exp(big.NewInt(int64(generator)), private, p)
+	shared := new(big.Int).Exp(new(big.Int).SetBytes(serverKey), private, p)
+
+	key := md5.Sum(padBytes(shared.Bytes(), int(keyLength)))
+	credentials, err := a.credentials()
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return err
+	}
+	// AES-128 in ECB mode
+	for i := 0; i < len(credentials); i += aes.BlockSize {
+		block.Encrypt(credentials[i:i+aes.BlockSize], credentials[i:i+aes.BlockSize])
+	}
+
+	if _, err := c.Write(credentials); err != nil {
+		return err
+	}
+	_, err = c.Write(padBytes(public.Bytes(), int(keyLength)))
+	return err
+}
+
+// credentials are the username & password null terminated in 64 byte blocks, padded with random bytes
+func (a *ARDAuth) credentials() ([]byte, error) {
+	if len(a.Username) > 63 || len(a.Password) > 63 {
+		return nil, fmt.Errorf("ARD auth: username and password are limited to 63 bytes")
+	}
+	credentials := make([]byte, 128)
+	if _, err := rand.Read(credentials); err != nil {
+		return nil, err
+	}
+	copy(credentials, a.Username+"\x00")
+	copy(credentials[64:], a.Password+"\x00")
+	return credentials, nil
+}
+
+// padBytes left pads b with zeroes up to length
+func padBytes(b []byte, length int) []byte {
+	if len(b) >= length {
+		return b
+	}
+	padded := make([]byte, length)
+	copy(padded[length-len(b):], b)
+	return padded
+}
+
+// streamAuth is implemented by auth methods which replace the connection once done (e.g. to encrypt it)
+type streamAuth interface {
+	handshakeStream(c io.ReadWriteCloser) (io.ReadWriteCloser, error)
+}
+
+// RsaAesAuth is the RSA-AES authentication (types 5, 6, 129 & 133, see common.SecTypeRA2...):
+// an RSA key exchange gives the AES-EAX keys encrypting the credentials, and the whole
+// session unless an "ne" type is used.
+type RsaAesAuth struct {
+	Type     uint8
+	Username string // sent only when the server asks for a username
+	Password string
+
+	// VerifyServerKey (optional) checks the server's key, e.g. against a known fingerprint.
+	// There is no PKI, so without it a man in the middle can't be detected.
+	VerifyServerKey func(key *rsa.PublicKey) error
+	// KeyBits is the length of the RSA key generated for each connection, defaults to 2048
+	KeyBits int
+}
+
+func (a *RsaAesAuth) SecurityType() uint8 {
+	return a.Type
+}
+
+// Handshake can only be used with the "ne" types, the others have to replace the connection with the encrypted stream
+func (a *RsaAesAuth) Handshake(c io.ReadWriteCloser) error {
+	params, err := common.RsaAesParamsFor(a.Type)
+	if err != nil {
+		return err
+	}
+	if params.Encrypted {
+		return errors.New("RSA-AES: the connection must be replaced by the encrypted stream")
+	}
+	_, err = a.handshakeStream(c)
+	return err
+}
+
+func (a *RsaAesAuth) handshakeStream(c io.ReadWriteCloser) (io.ReadWriteCloser, error) {
+	params, err := common.RsaAesParamsFor(a.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	serverKey, serverKeyBytes, err := common.ReadRsaPublicKey(c)
+	if err != nil {
+		return nil, err
+	}
+	if a.VerifyServerKey != nil {
+		if err := a.VerifyServerKey(serverKey); err != nil {
+			return nil, err
+		}
+	}
+
+	keyBits := a.KeyBits
+	if keyBits == 0 {
+		keyBits = 2048
+	}
+	clientKey, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, err
+	}
+	clientKeyBytes := common.RsaPublicKeyBytes(&clientKey.PublicKey)
+	if _, err := c.Write(clientKeyBytes); err != nil {
+		return nil, err
+	}
+
+	clientRandom := make([]byte, params.KeySize)
+	if _, err := rand.Read(clientRandom); err != nil {
+		return nil, err
+	}
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, serverKey, clientRandom)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.WriteRsaAesRandom(c, encrypted); err != nil {
+		return nil, err
+	}
+	serverRandom, err := common.ReadRsaAesRandom(c, clientKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(serverRandom) != params.KeySize {
+		return nil, fmt.Errorf("RSA-AES: bad server random length: %d", len(serverRandom))
+	}
+
+	stream, err := common.NewAesEaxStream(c, params.SessionKey(clientRandom, serverRandom), params.SessionKey(serverRandom, clientRandom))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := stream.Write(params.KeyHash(clientKeyBytes, serverKeyBytes)); err != nil {
+		return nil, err
+	}
+	serverHash := make([]byte, params.NewHash().Size())
+	if _, err := io.ReadFull(stream, serverHash); err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(serverHash, params.KeyHash(serverKeyBytes, clientKeyBytes)) != 1 {
+		return nil, errors.New("RSA-AES: public key hash mismatch")
+	}
+
+	var subType uint8
+	if err := binary.Read(stream, binary.BigEndian, &subType); err != nil {
+		return nil, err
+	}
+	username := a.Username
+	switch subType {
+	case common.RsaAesSubTypeUserPass:
+	case common.RsaAesSubTypePass:
+		username = ""
+	default:
+		return nil, fmt.Errorf("RSA-AES: unknown sub type %d", subType)
+	}
+	if len(username) > 255 || len(a.Password) > 255 {
+		return nil, errors.New("RSA-AES: username and password are limited to 255 bytes")
+	}
+
+	var credentials bytes.Buffer
+	credentials.WriteByte(uint8(len(username)))
+	credentials.WriteString(username)
+	credentials.WriteByte(uint8(len(a.Password)))
+	credentials.WriteString(a.Password)
+	if _, err := stream.Write(credentials.Bytes()); err != nil {
+		return nil, err
+	}
+
+	if params.Encrypted {
+		return stream, nil
+	}
+	return c, nil
+}
+
 func (p *PasswordAuth) reverseBits(b byte) byte {
 	var reverse = [256]int{
 		0, 128, 64, 192, 32, 160, 96, 224,
@@ -2,6 +2,12 @@ package client
 
 import (
 	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math/big"
 	"net"
 	"testing"
 	"time"
@@ -162,3 +168,84 @@ func TestClientAuthPasswordReject_Impl(t *testing.T) {
 		t.Fatal("PasswordAuth didn't complete properly")
 	}
 }
+
+func TestClientAuthRelayedPassword_Impl(t *testing.T) {
+	randomValue := []byte{
+		0xa4, 0x51, 0x3f, 0xa5, 0x1f, 0x87, 0x06, 0x10,
+		0xa4, 0x5f, 0xae, 0xbf, 0x4d, 0xac, 0x12, 0x22,
+	}
+
+	expectedResponse := []byte{
+		0x71, 0xe4, 0x41, 0x30, 0x43, 0x65, 0x4e, 0x39,
+		0xda, 0x6d, 0x49, 0x93, 0x43, 0xf6, 0x5e, 0x29,
+	}
+
+	// the response is computed on "the other side", by a regular PasswordAuth
+	downstream := PasswordAuth{Password: "Ch_#!T@8"}
+	var relayedChallenge []byte
+	raw := RelayedPasswordAuth{Respond: func(challenge []byte) ([]byte, error) {
+		relayedChallenge = challenge
+		return downstream.encrypt(downstream.Password, challenge)
+	}}
+
+	conn := &fakeNetConnection{DataToSend: randomValue, ExpectData: expectedResponse, Test: t}
+	if err := raw.Handshake(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(relayedChallenge, randomValue) {
+		t.Fatal("RelayedPasswordAuth didn't relay the server challenge")
+	}
+
+	if !conn.Matched {
+		t.Fatal("RelayedPasswordAuth didn't pass the relayed response back to the wire")
+	}
+}
+
+func TestClientAuthARD(t *testing.T) {
+	prime, err := rand.Prime(rand.Reader, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyLength := 64
+	serverPrivate := big.NewInt(123456789)
+	serverPublic := new(big.Int).Exp(big.NewInt(2), serverPrivate, prime)
+
+	srv, cli := net.Pipe()
+	defer srv.Close()
+	go func() {
+		defer cli.Close()
+		auth := &ARDAuth{Username: "qa", Password: "secret"}
+		if err := auth.Handshake(cli); err != nil {
+			t.Errorf("handshake failed: %v", err)
+		}
+	}()
+
+	binary.Write(srv, binary.BigEndian, uint16(2))
+	binary.Write(srv, binary.BigEndian, uint16(keyLength))
+	srv.Write(padBytes(prime.Bytes(), keyLength))
+	srv.Write(padBytes(serverPublic.Bytes(), keyLength))
+
+	credentials := make([]byte, 128)
+	clientPublic := make([]byte, keyLength)
+	if _, err := io.ReadFull(srv, credentials); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(srv, clientPublic); err != nil {
+		t.Fatal(err)
+	}
+
+	shared := new(big.Int).Exp(new(big.Int).SetBytes(clientPublic), serverPrivate, prime)
+	key := md5.Sum(padBytes(shared.Bytes(), keyLength))
+	block, _ := aes.NewCipher(key[:])
+	for i := 0; i < len(credentials); i += aes.BlockSize {
+		block.Decrypt(credentials[i:i+aes.BlockSize], credentials[i:i+aes.BlockSize])
+	}
+
+	if user := string(credentials[:bytes.IndexByte(credentials, 0)]); user != "qa" {
+		t.Errorf("decrypted username %q", user)
+	}
+	if pass := string(credentials[64 : 64+bytes.IndexByte(credentials[64:], 0)]); pass != "secret" {
+		t.Errorf("decrypted password %q", pass)
+	}
+}
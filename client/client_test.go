@@ -1,8 +1,11 @@
 package client
 
 import (
+	"encoding/binary"
 	"fmt"
+	"io"
 	"net"
+	"strings"
 	"testing"
 )
 
@@ -61,3 +64,60 @@ func TestParseProtocolVersion(t *testing.T) {
 		}
 	}
 }
+
+// fakeServer33 runs the server side of a 3.3 handshake choosing secType, and returns
+// the version the client replied with
+func fakeServer33(c net.Conn, secType uint32, reason string) string {
+	defer c.Close()
+	c.Write([]byte("RFB 003.003\n"))
+	reply := make([]byte, pvLen)
+	if _, err := io.ReadFull(c, reply); err != nil {
+		return ""
+	}
+	binary.Write(c, binary.BigEndian, secType)
+	switch secType {
+	case 0:
+		binary.Write(c, binary.BigEndian, uint32(len(reason)))
+		c.Write([]byte(reason))
+	case 2:
+		challenge := make([]byte, 16)
+		c.Write(challenge)
+		io.ReadFull(c, challenge)
+		binary.Write(c, binary.BigEndian, uint32(0))
+	}
+	return string(reply)
+}
+
+func TestClientAuthenticate33(t *testing.T) {
+	tests := []struct {
+		secType uint32
+		reason  string
+		isErr   bool
+	}{
+		{1, "", false},
+		{2, "", false},
+		{0, "too many connections", true},
+	}
+
+	for _, tt := range tests {
+		srv, cli := net.Pipe()
+		replies := make(chan string, 1)
+		go func() { replies <- fakeServer33(srv, tt.secType, tt.reason) }()
+
+		conn, _ := NewClientConn(cli, &ClientConfig{Auth: []ClientAuth{&PasswordAuth{Password: "pass"}, new(ClientAuthNone)}})
+		err := conn.Authenticate()
+		if err == nil && tt.isErr {
+			t.Errorf("sec type %d: expected error", tt.secType)
+		}
+		if err != nil && !tt.isErr {
+			t.Errorf("sec type %d: unexpected error %v", tt.secType, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("sec type %d: error %q doesn't contain the reason", tt.secType, err)
+		}
+		cli.Close()
+		if reply := <-replies; reply != protoVersion33 {
+			t.Errorf("sec type %d: client replied with version %q", tt.secType, reply)
+		}
+	}
+}
@@ -60,9 +60,11 @@ func (fbm *MsgFramebufferUpdate) Read(c common.IClientConn, r *common.RfbReadHel
 		encMap[enc.Type()] = enc
 	}
 
-	// We must always support the raw encoding
-	rawEnc := new(encodings.RawEncoding)
-	encMap[rawEnc.Type()] = rawEnc
+	// We must always support the raw encoding, clients decoding pixels bring their own
+	if _, ok := encMap[int32(common.EncRaw)]; !ok {
+		rawEnc := new(encodings.RawEncoding)
+		encMap[rawEnc.Type()] = rawEnc
+	}
 
 	rects := make([]common.Rectangle, numRects)
 	for i := uint16(0); i < numRects; i++ {
@@ -234,10 +236,12 @@ func (sf *MsgServerFence) Read(info common.IClientConn, c *common.RfbReadHelper)
 }
 
 // MsgServerCutText indicates the server has new text in the cut buffer.
+// Text is decoded from Latin-1, extended clipboard messages are in Extended instead.
 //
 // See RFC 6143 Section 7.6.4
 type MsgServerCutText struct {
-	Text string
+	Text     string
+	Extended *common.ExtendedClipboard
 }
 
 func (fbm *MsgServerCutText) CopyTo(r io.Reader, w io.Writer, c common.IClientConn) error {
@@ -248,6 +252,9 @@ func (fbm *MsgServerCutText) CopyTo(r io.Reader, w io.Writer, c common.IClientCo
 	return err
 }
 func (m *MsgServerCutText) String() string {
+	if m.Extended != nil {
+		return fmt.Sprintf("MsgServerCutText (type=%d, extended %s)", m.Type(), m.Extended)
+	}
 	return fmt.Sprintf("MsgServerCutText (type=%d)", m.Type())
 }
 
@@ -261,14 +268,32 @@ func (m *MsgServerCutText) Read(conn common.IClientConn, r *common.RfbReadHelper
 	if _, err := io.ReadFull(r, padding[:]); err != nil {
 		return nil, err
 	}
-	textLength, err := r.ReadUint32()
+	length, err := r.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	textLength, extended, err := common.ParseCutTextLength(length)
 	if err != nil {
 		return nil, err
 	}
-	textBytes, err := r.ReadBytes(int(textLength))
+	textBytes, err := r.ReadBytes(textLength)
 	if err != nil {
 		return nil, err
 	}
 	r.SendMessageEnd(common.ServerMessageType(m.Type()))
-	return &MsgServerCutText{string(textBytes)}, nil
+
+	if !extended {
+		return &MsgServerCutText{Text: common.Latin1ToString(textBytes)}, nil
+	}
+
+	ext, err := common.ParseExtendedClipboard(textBytes)
+	if err != nil {
+		// the message was already passed on to the listeners as is
+		logger.Warnf("MsgServerCutText.Read: bad extended clipboard message: %s", err)
+		return &MsgServerCutText{}, nil
+	}
+	if cc, ok := conn.(*ClientConn); ok {
+		cc.handleExtendedClipboard(ext)
+	}
+	return &MsgServerCutText{Extended: ext}, nil
 }
@@ -0,0 +1,210 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+const eaxTagSize = 16
+
+// eax is the AES-EAX authenticated encryption mode (Bellare, Rogaway & Wagner), with 16 byte nonces & tags.
+type eax struct {
+	block  cipher.Block
+	k1, k2 [aes.BlockSize]byte // CMAC subkeys
+}
+
+func newEax(key []byte) (*eax, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	e := &eax{block: block}
+	var l [aes.BlockSize]byte
+	block.Encrypt(l[:], l[:])
+	e.k1 = cmacDouble(l)
+	e.k2 = cmacDouble(e.k1)
+	return e, nil
+}
+
+// cmacDouble multiplies by x in GF(2^128)
+func cmacDouble(b [aes.BlockSize]byte) [aes.BlockSize]byte {
+	var out [aes.BlockSize]byte
+	carry := b[0] >> 7
+	for i := 0; i < aes.BlockSize-1; i++ {
+		out[i] = b[i]<<1 | b[i+1]>>7
+	}
+	out[aes.BlockSize-1] = b[aes.BlockSize-1] << 1
+	if carry != 0 {
+		out[aes.BlockSize-1] ^= 0x87
+	}
+	return out
+}
+
+// omac is the CMAC of data prefixed with a block holding the tweak t
+func (e *eax) omac(t byte, data []byte) [aes.BlockSize]byte {
+	var mac [aes.BlockSize]byte
+	mac[aes.BlockSize-1] = t
+	if len(data) == 0 {
+		// the tweak block is the last (complete) block
+		for i := range mac {
+			mac[i] ^= e.k1[i]
+		}
+		e.block.Encrypt(mac[:], mac[:])
+		return mac
+	}
+	e.block.Encrypt(mac[:], mac[:])
+
+	for len(data) > aes.BlockSize {
+		for i := 0; i < aes.BlockSize; i++ {
+			mac[i] ^= data[i]
+		}
+		e.block.Encrypt(mac[:], mac[:])
+		data = data[aes.BlockSize:]
+	}
+
+	var last [aes.BlockSize]byte
+	copy(last[:], data)
+	subkey := e.k1
+	if len(data) < aes.BlockSize {
+		last[len(data)] = 0x80
+		subkey = e.k2
+	}
+	for i := range mac {
+		mac[i] ^= last[i] ^ subkey[i]
+	}
+	e.block.Encrypt(mac[:], mac[:])
+	return mac
+}
+
+// seal encrypts plaintext into dst (same length) and returns the tag
+func (e *eax) seal(dst, nonce, header, plaintext []byte) [eaxTagSize]byte {
+	n := e.omac(0, nonce)
+	h := e.omac(1, header)
+	cipher.NewCTR(e.block, n[:]).XORKeyStream(dst, plaintext)
+	c := e.omac(2, dst[:len(plaintext)])
+	var tag [eaxTagSize]byte
+	for i := range tag {
+		tag[i] = n[i] ^ h[i] ^ c[i]
+	}
+	return tag
+}
+
+// open checks the tag and decrypts ciphertext into dst (same length)
+func (e *eax) open(dst, nonce, header, ciphertext, tag []byte) error {
+	n := e.omac(0, nonce)
+	h := e.omac(1, header)
+	c := e.omac(2, ciphertext)
+	var expected [eaxTagSize]byte
+	for i := range expected {
+		expected[i] = n[i] ^ h[i] ^ c[i]
+	}
+	if subtle.ConstantTimeCompare(expected[:], tag) != 1 {
+		return errors.New("aes-eax: message authentication failed")
+	}
+	cipher.NewCTR(e.block, n[:]).XORKeyStream(dst, ciphertext)
+	return nil
+}
+
+// AesEaxMaxMessageSize is the largest plaintext sent in a single encrypted message
+const AesEaxMaxMessageSize = 8192
+
+// AesEaxStream wraps a connection in the RSA-AES security types encryption: every message is
+// [u16 length][ciphertext][16 byte tag], encrypted with AES-EAX using the length as associated
+// data and a little endian message counter (one per direction) as nonce.
+type AesEaxStream struct {
+	rw io.ReadWriter
+
+	readLock   sync.Mutex
+	dec        *eax
+	readNonce  [16]byte
+	readBuffer []byte
+
+	writeLock  sync.Mutex
+	enc        *eax
+	writeNonce [16]byte
+}
+
+// NewAesEaxStream creates the stream, readKey decrypts incoming messages & writeKey encrypts outgoing ones
+func NewAesEaxStream(rw io.ReadWriter, readKey, writeKey []byte) (*AesEaxStream, error) {
+	dec, err := newEax(readKey)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := newEax(writeKey)
+	if err != nil {
+		return nil, err
+	}
+	return &AesEaxStream{rw: rw, dec: dec, enc: enc}, nil
+}
+
+func incrementNonce(nonce *[16]byte) {
+	for i := range nonce {
+		nonce[i]++
+		if nonce[i] != 0 {
+			break
+		}
+	}
+}
+
+func (s *AesEaxStream) Read(p []byte) (int, error) {
+	s.readLock.Lock()
+	defer s.readLock.Unlock()
+
+	for len(s.readBuffer) == 0 {
+		var header [2]byte
+		if _, err := io.ReadFull(s.rw, header[:]); err != nil {
+			return 0, err
+		}
+		message := make([]byte, int(binary.BigEndian.Uint16(header[:]))+eaxTagSize)
+		if _, err := io.ReadFull(s.rw, message); err != nil {
+			return 0, err
+		}
+		ciphertext := message[:len(message)-eaxTagSize]
+		if err := s.dec.open(ciphertext, s.readNonce[:], header[:], ciphertext, message[len(ciphertext):]); err != nil {
+			return 0, err
+		}
+		incrementNonce(&s.readNonce)
+		s.readBuffer = ciphertext
+	}
+
+	n := copy(p, s.readBuffer)
+	s.readBuffer = s.readBuffer[n:]
+	return n, nil
+}
+
+func (s *AesEaxStream) Write(p []byte) (int, error) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	written := 0
+	for written < len(p) {
+		chunk := p[written:]
+		if len(chunk) > AesEaxMaxMessageSize {
+			chunk = chunk[:AesEaxMaxMessageSize]
+		}
+		message := make([]byte, 2+len(chunk)+eaxTagSize)
+		binary.BigEndian.PutUint16(message, uint16(len(chunk)))
+		tag := s.enc.seal(message[2:2+len(chunk)], s.writeNonce[:], message[:2], chunk)
+		copy(message[2+len(chunk):], tag[:])
+		incrementNonce(&s.writeNonce)
+
+		if _, err := s.rw.Write(message); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+	}
+	return written, nil
+}
+
+// Close closes the underlying connection
+func (s *AesEaxStream) Close() error {
+	if closer, ok := s.rw.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
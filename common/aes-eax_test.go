@@ -0,0 +1,72 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"testing"
+)
+
+func TestEaxVectors(t *testing.T) {
+	// test vectors from the EAX paper
+	tests := []struct{ key, nonce, header, msg, cipher string }{
+		{"233952DEE4D5ED5F9B9C6D6FF80FF478", "62EC67F9C3A4A407FCB2A8C49031A8B3", "6BFB914FD07EAE6B", "", "E037830E8389F27B025A2D6527E79D01"},
+		{"91945D3F4DCBEE0BF45EF52255F095A4", "BECAF043B0A23D843194BA972C66DEBD", "FA3BFD4806EB53FA", "F7FB", "19DD5C4C9331049D0BDAB0277408F67967E5"},
+		{"01F74AD64077F2E704C0F60ADA3DD523", "70C3DB4F0D26368400A10ED05D2BFF5E", "234A3463C1264AC6", "1A47CB4933", "D851D5BAE03A59F238A23E39199DC9266626C40F80"},
+	}
+	for _, tt := range tests {
+		key, _ := hex.DecodeString(tt.key)
+		nonce, _ := hex.DecodeString(tt.nonce)
+		header, _ := hex.DecodeString(tt.header)
+		msg, _ := hex.DecodeString(tt.msg)
+		expected, _ := hex.DecodeString(tt.cipher)
+
+		e, err := newEax(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ciphertext := make([]byte, len(msg))
+		tag := e.seal(ciphertext, nonce, header, msg)
+		if got := append(ciphertext, tag[:]...); !bytes.Equal(got, expected) {
+			t.Errorf("seal(%s) = %X, want %s", tt.msg, got, tt.cipher)
+		}
+
+		plaintext := make([]byte, len(msg))
+		if err := e.open(plaintext, nonce, header, expected[:len(msg)], expected[len(msg):]); err != nil || !bytes.Equal(plaintext, msg) {
+			t.Errorf("open(%s) = %X, %v", tt.cipher, plaintext, err)
+		}
+		expected[0] ^= 1
+		if err := e.open(plaintext, nonce, header, expected[:len(msg)], expected[len(msg):]); err == nil {
+			t.Errorf("open accepted a tampered message")
+		}
+	}
+}
+
+func TestAesEaxStream(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	k1 := bytes.Repeat([]byte{1}, 16)
+	k2 := bytes.Repeat([]byte{2}, 16)
+	sa, _ := NewAesEaxStream(a, k1, k2)
+	sb, _ := NewAesEaxStream(b, k2, k1)
+
+	payload := bytes.Repeat([]byte("rfb"), AesEaxMaxMessageSize)
+	go func() {
+		sa.Write(payload)
+		sa.Write([]byte("done"))
+	}()
+
+	received := make([]byte, len(payload)+4)
+	n := 0
+	for n < len(received) {
+		r, err := sb.Read(received[n:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		n += r
+	}
+	if !bytes.Equal(received, append(payload, "done"...)) {
+		t.Error("stream content differs")
+	}
+}
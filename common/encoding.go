@@ -139,6 +139,8 @@ func (enct EncodingType) String() string {
 		return "EncVMWFrameStamp"
 	case EncOffscreenCopyRect:
 		return "EncOffscreenCopyRect"
+	case EncExtendedClipboardPseudo:
+		return "EncExtendedClipboardPseudo"
 	}
 	return ""
 }
@@ -202,6 +204,7 @@ const (
 	EncVMWServerCaps                 EncodingType = 122 + 0x574d5600
 	EncVMWFrameStamp                 EncodingType = 124 + 0x574d5600
 	EncOffscreenCopyRect             EncodingType = 126 + 0x574d5600
+	EncExtendedClipboardPseudo       EncodingType = -1063131698 // 0xC0A1E5CE
 )
 
 // PixelFormat describes the way a pixel is formatted for a VNC connection.
@@ -0,0 +1,255 @@
+package common
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Extended Clipboard formats & actions (flags of the extended cut text messages)
+const (
+	ClipboardText  uint32 = 1 << 0
+	ClipboardRTF   uint32 = 1 << 1
+	ClipboardHTML  uint32 = 1 << 2
+	ClipboardDIB   uint32 = 1 << 3
+	ClipboardFiles uint32 = 1 << 4
+
+	ClipboardFormatMask uint32 = 0xffff
+
+	ClipboardCaps    uint32 = 1 << 24
+	ClipboardRequest uint32 = 1 << 25
+	ClipboardPeek    uint32 = 1 << 26
+	ClipboardNotify  uint32 = 1 << 27
+	ClipboardProvide uint32 = 1 << 28
+
+	ClipboardActionMask uint32 = 0xff000000
+)
+
+// MaxClipboardSize bounds cut text payloads (and decompressed extended clipboard data)
+const MaxClipboardSize = 32 * 1024 * 1024
+
+// ExtendedClipboard is the payload of an extended ServerCutText/ClientCutText message, sent as a negative
+// length once both sides announced the Extended Clipboard pseudo-encoding (0xC0A1E5CE).
+// Text, RTF & HTML are null terminated UTF-8 with CRLF line endings on the wire, they are kept as Go
+// strings (without the terminator) in Data.
+type ExtendedClipboard struct {
+	Flags uint32
+	// MaxSizes are the largest payloads accepted per format (caps action)
+	MaxSizes map[uint32]uint32
+	// Data holds the content per format (provide action)
+	Data map[uint32][]byte
+}
+
+// Action returns the action of the message, caps messages also carry the flags of the supported actions
+func (e *ExtendedClipboard) Action() uint32 {
+	for _, action := range []uint32{ClipboardCaps, ClipboardRequest, ClipboardPeek, ClipboardNotify, ClipboardProvide} {
+		if e.Flags&action != 0 {
+			return action
+		}
+	}
+	return e.Flags & ClipboardActionMask
+}
+
+// Formats returns the format flags of the message
+func (e *ExtendedClipboard) Formats() uint32 {
+	return e.Flags & ClipboardFormatMask
+}
+
+// Text returns the provided UTF-8 text, with LF line endings
+func (e *ExtendedClipboard) Text() string {
+	return strings.Replace(string(e.Data[ClipboardText]), "\r\n", "\n", -1)
+}
+
+func (e *ExtendedClipboard) String() string {
+	actions := map[uint32]string{ClipboardCaps: "caps", ClipboardRequest: "request", ClipboardPeek: "peek", ClipboardNotify: "notify", ClipboardProvide: "provide"}
+	formats := []string{}
+	for _, f := range []struct {
+		flag uint32
+		name string
+	}{{ClipboardText, "text"}, {ClipboardRTF, "rtf"}, {ClipboardHTML, "html"}, {ClipboardDIB, "dib"}, {ClipboardFiles, "files"}} {
+		if e.Flags&f.flag != 0 {
+			if data, ok := e.Data[f.flag]; ok {
+				formats = append(formats, fmt.Sprintf("%s(%d bytes)", f.name, len(data)))
+			} else {
+				formats = append(formats, f.name)
+			}
+		}
+	}
+	return fmt.Sprintf("%s [%s]", actions[e.Action()], strings.Join(formats, " "))
+}
+
+// NewClipboardCaps announces the formats & actions supported, with the max size of each format
+func NewClipboardCaps(actions uint32, maxSizes map[uint32]uint32) *ExtendedClipboard {
+	e := &ExtendedClipboard{Flags: ClipboardCaps | actions, MaxSizes: maxSizes}
+	for format := range maxSizes {
+		e.Flags |= format
+	}
+	return e
+}
+
+// NewClipboardProvide sends text (and optionally rtf & html, empty = absent)
+func NewClipboardProvide(text, rtf, html string) *ExtendedClipboard {
+	e := &ExtendedClipboard{Flags: ClipboardProvide | ClipboardText, Data: map[uint32][]byte{
+		ClipboardText: []byte(normalizeClipboardText(text)),
+	}}
+	if rtf != "" {
+		e.Flags |= ClipboardRTF
+		e.Data[ClipboardRTF] = []byte(rtf)
+	}
+	if html != "" {
+		e.Flags |= ClipboardHTML
+		e.Data[ClipboardHTML] = []byte(html)
+	}
+	return e
+}
+
+// normalizeClipboardText converts line endings to CRLF, as required on the wire
+func normalizeClipboardText(s string) string {
+	return strings.Replace(strings.Replace(s, "\r\n", "\n", -1), "\n", "\r\n", -1)
+}
+
+// clipboardFormats lists the format flags set, in wire order
+func clipboardFormats(flags uint32) []uint32 {
+	var formats []uint32
+	for bit := uint(0); bit < 16; bit++ {
+		if flags&(1<<bit) != 0 {
+			formats = append(formats, 1<<bit)
+		}
+	}
+	return formats
+}
+
+// ParseExtendedClipboard parses the payload of an extended cut text message
+func ParseExtendedClipboard(payload []byte) (*ExtendedClipboard, error) {
+	if len(payload) < 4 {
+		return nil, errors.New("extended clipboard message too short")
+	}
+	e := &ExtendedClipboard{Flags: binary.BigEndian.Uint32(payload)}
+	payload = payload[4:]
+	formats := clipboardFormats(e.Flags)
+
+	switch e.Action() {
+	case ClipboardCaps:
+		e.MaxSizes = make(map[uint32]uint32)
+		for i, format := range formats {
+			if len(payload) < 4*(i+1) {
+				return nil, errors.New("extended clipboard caps too short")
+			}
+			e.MaxSizes[format] = binary.BigEndian.Uint32(payload[4*i:])
+		}
+
+	case ClipboardProvide:
+		zr, err := zlib.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r := io.LimitReader(zr, MaxClipboardSize)
+		e.Data = make(map[uint32][]byte)
+		for _, format := range formats {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return nil, fmt.Errorf("extended clipboard data: %v", err)
+			}
+			if size > MaxClipboardSize {
+				return nil, fmt.Errorf("extended clipboard data too large: %d", size)
+			}
+			// the buffer grows as the data is inflated, rather than trusting the announced size
+			var buf bytes.Buffer
+			if _, err := io.CopyN(&buf, r, int64(size)); err != nil {
+				return nil, fmt.Errorf("extended clipboard data: %v", err)
+			}
+			data := buf.Bytes()
+			if format == ClipboardText || format == ClipboardRTF || format == ClipboardHTML {
+				data = bytes.TrimRight(data, "\x00")
+				if !utf8.Valid(data) {
+					return nil, errors.New("extended clipboard text is not valid UTF-8")
+				}
+			}
+			e.Data[format] = data
+		}
+
+	case ClipboardRequest, ClipboardPeek, ClipboardNotify:
+	default:
+		return nil, fmt.Errorf("unknown extended clipboard action: %x", e.Action())
+	}
+	return e, nil
+}
+
+// Bytes encodes the payload of an extended cut text message
+func (e *ExtendedClipboard) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, e.Flags)
+	formats := clipboardFormats(e.Flags)
+
+	switch e.Action() {
+	case ClipboardCaps:
+		for _, format := range formats {
+			binary.Write(&buf, binary.BigEndian, e.MaxSizes[format])
+		}
+
+	case ClipboardProvide:
+		zw := zlib.NewWriter(&buf)
+		for _, format := range formats {
+			data := e.Data[format]
+			if format == ClipboardText || format == ClipboardRTF || format == ClipboardHTML {
+				data = append(append([]byte{}, data...), 0)
+			}
+			binary.Write(zw, binary.BigEndian, uint32(len(data)))
+			zw.Write(data)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// CutTextLength returns the length field of a cut text message carrying this payload (negative = extended)
+func CutTextLength(payload []byte, extended bool) uint32 {
+	if extended {
+		return uint32(-int32(len(payload)))
+	}
+	return uint32(len(payload))
+}
+
+// ParseCutTextLength interprets the length field of a cut text message
+func ParseCutTextLength(length uint32) (int, bool, error) {
+	extended := int32(length) < 0
+	size := int64(length)
+	if extended {
+		size = -int64(int32(length))
+	}
+	if size > MaxClipboardSize {
+		return 0, false, fmt.Errorf("cut text too large: %d bytes", size)
+	}
+	return int(size), extended, nil
+}
+
+// Latin1ToString decodes legacy (ISO 8859-1) cut text
+func Latin1ToString(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// StringToLatin1 encodes legacy cut text, ok is false if some characters can't be represented
+func StringToLatin1(s string) ([]byte, bool) {
+	b := make([]byte, 0, len(s))
+	ok := true
+	for _, r := range s {
+		if r > 0xff {
+			ok = false
+			r = '?'
+		}
+		b = append(b, byte(r))
+	}
+	return b, ok
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestExtendedClipboardProvide(t *testing.T) {
+	msg := NewClipboardProvide("pässwörd\n€ snippet", "", "<b>x</b>")
+	payload, err := msg.Bytes()
+	if err != nil {
+		t.Fatalf("encoding: %s", err)
+	}
+
+	parsed, err := ParseExtendedClipboard(payload)
+	if err != nil {
+		t.Fatalf("parsing: %s", err)
+	}
+	if parsed.Action() != ClipboardProvide {
+		t.Errorf("expected provide action, got %x", parsed.Action())
+	}
+	if parsed.Formats() != ClipboardText|ClipboardHTML {
+		t.Errorf("unexpected formats: %x", parsed.Formats())
+	}
+	if string(parsed.Data[ClipboardText]) != "pässwörd\r\n€ snippet" {
+		t.Errorf("unexpected wire text: %q", parsed.Data[ClipboardText])
+	}
+	if parsed.Text() != "pässwörd\n€ snippet" {
+		t.Errorf("unexpected text: %q", parsed.Text())
+	}
+	if string(parsed.Data[ClipboardHTML]) != "<b>x</b>" {
+		t.Errorf("unexpected html: %q", parsed.Data[ClipboardHTML])
+	}
+}
+
+func TestExtendedClipboardCaps(t *testing.T) {
+	msg := NewClipboardCaps(ClipboardRequest|ClipboardNotify, map[uint32]uint32{ClipboardText: 1024, ClipboardRTF: 2048})
+	payload, err := msg.Bytes()
+	if err != nil {
+		t.Fatalf("encoding: %s", err)
+	}
+	if len(payload) != 12 {
+		t.Fatalf("expected 12 bytes, got %d", len(payload))
+	}
+
+	parsed, err := ParseExtendedClipboard(payload)
+	if err != nil {
+		t.Fatalf("parsing: %s", err)
+	}
+	if parsed.Action() != ClipboardCaps || parsed.Flags&ClipboardRequest == 0 {
+		t.Errorf("unexpected flags: %x", parsed.Flags)
+	}
+	if parsed.MaxSizes[ClipboardText] != 1024 || parsed.MaxSizes[ClipboardRTF] != 2048 {
+		t.Errorf("unexpected sizes: %v", parsed.MaxSizes)
+	}
+}
+
+func TestCutTextLength(t *testing.T) {
+	size, extended, err := ParseCutTextLength(CutTextLength(make([]byte, 20), true))
+	if err != nil || !extended || size != 20 {
+		t.Errorf("extended length: %d %v %v", size, extended, err)
+	}
+	size, extended, err = ParseCutTextLength(CutTextLength(make([]byte, 20), false))
+	if err != nil || extended || size != 20 {
+		t.Errorf("legacy length: %d %v %v", size, extended, err)
+	}
+	if _, _, err := ParseCutTextLength(0x80000000); err == nil {
+		t.Errorf("expected an error for a huge length")
+	}
+
+	latin1, ok := StringToLatin1("café")
+	if !ok || Latin1ToString(latin1) != "café" || len(latin1) != 4 {
+		t.Errorf("latin-1 round trip failed: %v", latin1)
+	}
+	if _, ok := StringToLatin1("€"); ok {
+		t.Errorf("€ is not latin-1")
+	}
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+)
+
+// RSA-AES security types (TigerVNC & UltraVNC), the "ne" variants only encrypt the security handshake
+const (
+	SecTypeRA2     = 5
+	SecTypeRA2ne   = 6
+	SecTypeRA256   = 129
+	SecTypeRA256ne = 133
+)
+
+// accepted RSA key lengths
+const (
+	RsaAesMinKeyBits = 1024
+	RsaAesMaxKeyBits = 8192
+)
+
+// RSA-AES credential sub types sent by the server
+const (
+	RsaAesSubTypeUserPass = 1
+	RsaAesSubTypePass     = 2
+)
+
+// RsaAesParams describes the variant of an RSA-AES security type
+type RsaAesParams struct {
+	KeySize   int // AES key & random size in bytes
+	NewHash   func() hash.Hash
+	Encrypted bool // whether the session stays encrypted after the security handshake
+}
+
+// RsaAesParamsFor returns the parameters of an RSA-AES security type
+func RsaAesParamsFor(secType uint8) (RsaAesParams, error) {
+	switch secType {
+	case SecTypeRA2:
+		return RsaAesParams{KeySize: 16, NewHash: sha1.New, Encrypted: true}, nil
+	case SecTypeRA2ne:
+		return RsaAesParams{KeySize: 16, NewHash: sha1.New}, nil
+	case SecTypeRA256:
+		return RsaAesParams{KeySize: 32, NewHash: sha256.New, Encrypted: true}, nil
+	case SecTypeRA256ne:
+		return RsaAesParams{KeySize: 32, NewHash: sha256.New}, nil
+	}
+	return RsaAesParams{}, fmt.Errorf("%d is not an RSA-AES security type", secType)
+}
+
+// SessionKey is the AES key used by the side which sent senderRandom: hash(receiverRandom || senderRandom)
+func (p RsaAesParams) SessionKey(receiverRandom, senderRandom []byte) []byte {
+	h := p.NewHash()
+	h.Write(receiverRandom)
+	h.Write(senderRandom)
+	return h.Sum(nil)[:p.KeySize]
+}
+
+// KeyHash is the hash sent to prove both sides saw the same public keys, own key first
+func (p RsaAesParams) KeyHash(ownKey, peerKey []byte) []byte {
+	h := p.NewHash()
+	h.Write(ownKey)
+	h.Write(peerKey)
+	return h.Sum(nil)
+}
+
+// RsaPublicKeyBytes is the wire format of an RSA-AES public key:
+// [u32 key length in bits][modulus][public exponent], both padded to the key length
+func RsaPublicKeyBytes(key *rsa.PublicKey) []byte {
+	bits := key.N.BitLen()
+	size := (bits + 7) / 8
+	buf := make([]byte, 4+2*size)
+	binary.BigEndian.PutUint32(buf, uint32(bits))
+	key.N.FillBytes(buf[4 : 4+size])
+	big.NewInt(int64(key.E)).FillBytes(buf[4+size:])
+	return buf
+}
+
+// ReadRsaPublicKey reads a public key in the RSA-AES wire format, also returning its raw bytes for hashing
+func ReadRsaPublicKey(r io.Reader) (*rsa.PublicKey, []byte, error) {
+	var bits uint32
+	if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+		return nil, nil, err
+	}
+	if bits < RsaAesMinKeyBits || bits > RsaAesMaxKeyBits {
+		return nil, nil, fmt.Errorf("unsupported RSA key length: %d", bits)
+	}
+	size := int(bits+7) / 8
+	buf := make([]byte, 4+2*size)
+	binary.BigEndian.PutUint32(buf, bits)
+	if _, err := io.ReadFull(r, buf[4:]); err != nil {
+		return nil, nil, err
+	}
+
+	n := new(big.Int).SetBytes(buf[4 : 4+size])
+	e := new(big.Int).SetBytes(buf[4+size:])
+	if n.BitLen() != int(bits) || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, nil, fmt.Errorf("invalid RSA public key")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, buf, nil
+}
+
+// WriteRsaAesRandom writes the encrypted random: [u16 length][data]
+func WriteRsaAesRandom(w io.Writer, encrypted []byte) error {
+	if err := binary.Write(w, binary.BigEndian, uint16(len(encrypted))); err != nil {
+		return err
+	}
+	_, err := w.Write(encrypted)
+	return err
+}
+
+// ReadRsaAesRandom reads an encrypted random, which must be as long as the receiver's key
+func ReadRsaAesRandom(r io.Reader, key *rsa.PrivateKey) ([]byte, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if int(length) != key.Size() {
+		return nil, fmt.Errorf("bad RSA-AES random length: %d", length)
+	}
+	encrypted := make([]byte, length)
+	if _, err := io.ReadFull(r, encrypted); err != nil {
+		return nil, err
+	}
+	return rsa.DecryptPKCS1v15(nil, key, encrypted)
+}
@@ -0,0 +1,213 @@
+package common
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// capability vendors used by the Tight protocol extensions
+const (
+	StandardVendor  = "STDV"
+	TridiaVncVendor = "TRDV"
+	TightVncVendor  = "TGHT"
+)
+
+// TightCapability describes a tunnel, auth type, message or encoding in the Tight protocol extensions
+type TightCapability struct {
+	Code   uint32
+	Vendor [4]byte
+	Name   [8]byte
+}
+
+func NewTightCapability(code int32, vendor, name string) TightCapability {
+	c := TightCapability{Code: uint32(code)}
+	copy(c.Vendor[:], vendor)
+	copy(c.Name[:], name)
+	return c
+}
+
+func (t TightCapability) String() string {
+	return string(t.Vendor[:]) + ":" + string(t.Name[:])
+}
+
+func (t *TightCapability) WriteTo(w io.Writer) error {
+	if err := binary.Write(w, binary.BigEndian, t.Code); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, t.Vendor); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, t.Name); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *TightCapability) ReadFrom(r io.Reader) error {
+
+	if err := binary.Read(r, binary.BigEndian, &t.Code); err != nil {
+		return err
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &t.Vendor); err != nil {
+		return err
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &t.Name); err != nil {
+		return err
+	}
+	return nil
+}
+
+const maxTightCapabilities = 256
+
+// ReadTightCapabilities reads a capability list preceded by its uint32 length (tunnel & auth lists)
+func ReadTightCapabilities(r io.Reader) ([]TightCapability, error) {
+	var count uint32
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	if count > maxTightCapabilities {
+		return nil, fmt.Errorf("too many Tight capabilities: %d", count)
+	}
+	caps := make([]TightCapability, count)
+	for i := range caps {
+		if err := caps[i].ReadFrom(r); err != nil {
+			return nil, err
+		}
+	}
+	return caps, nil
+}
+
+// WriteTightCapabilities writes a capability list preceded by its uint32 length (tunnel & auth lists)
+func WriteTightCapabilities(w io.Writer, caps []TightCapability) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(caps))); err != nil {
+		return err
+	}
+	for i := range caps {
+		if err := caps[i].WriteTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tunneling & authentication capabilities
+var (
+	TightCapNoTunnel = NewTightCapability(0, TightVncVendor, "NOTUNNEL")
+	TightCapNoAuth   = NewTightCapability(1, StandardVendor, "NOAUTH__")
+	TightCapVncAuth  = NewTightCapability(2, StandardVendor, "VNCAUTH_")
+	TightCapVeNCrypt = NewTightCapability(19, "VENC", "VENCRYPT")
+)
+
+// tightEncodingCaps are the capabilities of the encodings known to TightVNC
+var tightEncodingCaps = []TightCapability{
+	NewTightCapability(int32(EncCopyRect), StandardVendor, "COPYRECT"),
+	NewTightCapability(int32(EncRRE), StandardVendor, "RRE_____"),
+	NewTightCapability(int32(EncCoRRE), StandardVendor, "CORRE___"),
+	NewTightCapability(int32(EncHextile), StandardVendor, "HEXTILE_"),
+	NewTightCapability(int32(EncZlib), TridiaVncVendor, "ZLIB____"),
+	NewTightCapability(int32(EncZRLE), TridiaVncVendor, "ZRLE____"),
+	NewTightCapability(int32(EncTight), TightVncVendor, "TIGHT___"),
+	NewTightCapability(int32(EncCompressionLevel1), TightVncVendor, "COMPRLVL"),
+	NewTightCapability(int32(EncJPEGQualityLevelPseudo1), TightVncVendor, "JPEGQLVL"),
+	NewTightCapability(int32(EncCursorPseudo), TightVncVendor, "RCHCURSR"),
+	NewTightCapability(int32(EncPointerPosPseudo), TightVncVendor, "POINTPOS"),
+	NewTightCapability(int32(EncLastRectPseudo), TightVncVendor, "LASTRECT"),
+	NewTightCapability(int32(EncDesktopSizePseudo), TightVncVendor, "NEWFBSIZ"),
+}
+
+// TightEncodingCaps returns the capabilities to advertise for the given encodings,
+// encodings unknown to TightVNC (and Raw, which is always supported) are skipped.
+func TightEncodingCaps(encs []IEncoding) []TightCapability {
+	var caps []TightCapability
+	for _, enc := range encs {
+		for _, c := range tightEncodingCaps {
+			if int32(c.Code) == enc.Type() {
+				caps = append(caps, c)
+			}
+		}
+	}
+	return caps
+}
+
+// TightServerInit is the interaction capabilities message sent after ServerInit when the Tight security type is used
+type TightServerInit struct {
+	ServerMessageCaps []TightCapability
+	ClientMessageCaps []TightCapability
+	EncodingCaps      []TightCapability
+}
+
+func (t *TightServerInit) ReadFrom(r io.Reader) error {
+	var numSrvCaps uint16
+	var numCliCaps uint16
+	var numEncCaps uint16
+	var padding uint16
+
+	if err := binary.Read(r, binary.BigEndian, &numSrvCaps); err != nil {
+		return err
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &numCliCaps); err != nil {
+		return err
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &numEncCaps); err != nil {
+		return err
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &padding); err != nil {
+		return err
+	}
+
+	for i := 0; i < int(numSrvCaps); i++ {
+		cap := TightCapability{}
+		if err := cap.ReadFrom(r); err != nil {
+			return err
+		}
+		t.ServerMessageCaps = append(t.ServerMessageCaps, cap)
+	}
+
+	for i := 0; i < int(numCliCaps); i++ {
+		cap := TightCapability{}
+		if err := cap.ReadFrom(r); err != nil {
+			return err
+		}
+		t.ClientMessageCaps = append(t.ClientMessageCaps, cap)
+	}
+
+	for i := 0; i < int(numEncCaps); i++ {
+		cap := TightCapability{}
+		if err := cap.ReadFrom(r); err != nil {
+			return err
+		}
+		t.EncodingCaps = append(t.EncodingCaps, cap)
+	}
+	return nil
+}
+
+func (t *TightServerInit) WriteTo(w io.Writer) error {
+	if err := binary.Write(w, binary.BigEndian, uint16(len(t.ServerMessageCaps))); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint16(len(t.ClientMessageCaps))); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint16(len(t.EncodingCaps))); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, binary.BigEndian, uint16(0)); err != nil {
+		return err
+	}
+
+	for _, caps := range [][]TightCapability{t.ServerMessageCaps, t.ClientMessageCaps, t.EncodingCaps} {
+		for i := range caps {
+			if err := caps[i].WriteTo(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
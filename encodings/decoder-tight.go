@@ -0,0 +1,239 @@
+package encodings
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+
+	"github.com/exoscale/vncproxy/common"
+)
+
+type tightDecoder struct {
+	PseudoEncoding
+	canvas  *Canvas
+	streams [4]zlibStream
+}
+
+// tpixelSize is the size of a Tight pixel: 3 bytes (red, green, blue) for 24 bit depth in 32 bits, else BPP/8
+func tpixelSize(pf *common.PixelFormat) int {
+	return calcTightBytePerPixel(pf)
+}
+
+func tpixelValue(buf []byte, pf *common.PixelFormat) uint32 {
+	if tpixelSize(pf) == 3 {
+		return uint32(buf[0])<<pf.RedShift | uint32(buf[1])<<pf.GreenShift | uint32(buf[2])<<pf.BlueShift
+	}
+	return pixelValue(buf, pf)
+}
+
+func (d *tightDecoder) Read(pf *common.PixelFormat, rect *common.Rectangle, r *common.RfbReadHelper) (common.IEncoding, error) {
+	if err := CheckPixelFormat(pf); err != nil {
+		return nil, err
+	}
+	compctl, err := r.ReadUint8()
+	if err != nil {
+		return nil, err
+	}
+	for i := range d.streams {
+		if compctl&(1<<uint(i)) != 0 {
+			d.streams[i].reset()
+		}
+	}
+	bounds := rectangle(rect)
+	size := tpixelSize(pf)
+	tpixel := func(p []byte) color.RGBA {
+		return PixelColor(pf, tpixelValue(p, pf))
+	}
+
+	// paint runs with the canvas locked
+	var paint func() error
+	switch compType := compctl >> 4; {
+	case compType == TightFill:
+		data, err := r.ReadBytes(size)
+		if err != nil {
+			return nil, err
+		}
+		paint = func() error {
+			d.canvas.fill(bounds, tpixel(data))
+			return nil
+		}
+
+	case compType == TightJpeg:
+		length, err := r.ReadCompactLen()
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.ReadBytes(length)
+		if err != nil {
+			return nil, err
+		}
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("tight jpeg rectangle: %v", err)
+		}
+		paint = func() error {
+			draw.Draw(d.canvas.img, bounds, img, img.Bounds().Min, draw.Src)
+			return nil
+		}
+
+	case compType > TightJpeg:
+		return nil, fmt.Errorf("unsupported tight compression: %x", compctl)
+
+	default:
+		stream := &d.streams[compType&3]
+		filter := uint8(TightFilterCopy)
+		if compType&TightExplicitFilter != 0 {
+			if filter, err = r.ReadUint8(); err != nil {
+				return nil, err
+			}
+		}
+
+		switch filter {
+		case TightFilterPalette:
+			count, err := r.ReadUint8()
+			if err != nil {
+				return nil, err
+			}
+			paletteData, err := r.ReadBytes((int(count) + 1) * size)
+			if err != nil {
+				return nil, err
+			}
+			palette := make([]color.RGBA, int(count)+1)
+			for i := range palette {
+				palette[i] = tpixel(paletteData[i*size:])
+			}
+			// 1 bit per pixel with 2 colors, each row starting on a byte
+			mono, rowSize := len(palette) == 2, bounds.Dx()
+			if mono {
+				rowSize = (bounds.Dx() + 7) / 8
+			}
+			data, err := d.readData(r, stream, rowSize*bounds.Dy())
+			if err != nil {
+				return nil, err
+			}
+			paint = func() error {
+				for y := 0; y < bounds.Dy(); y++ {
+					for x := 0; x < bounds.Dx(); x++ {
+						var index int
+						if mono {
+							index = int(data[y*rowSize+x/8]>>uint(7-x%8)) & 1
+						} else {
+							index = int(data[y*rowSize+x])
+						}
+						if index >= len(palette) {
+							return fmt.Errorf("tight rectangle: bad palette index %d", index)
+						}
+						if p := bounds.Min.Add(image.Pt(x, y)); p.In(d.canvas.img.Rect) {
+							d.canvas.img.SetRGBA(p.X, p.Y, palette[index])
+						}
+					}
+				}
+				return nil
+			}
+
+		case TightFilterCopy, TightFilterGradient:
+			data, err := d.readData(r, stream, bounds.Dx()*bounds.Dy()*size)
+			if err != nil {
+				return nil, err
+			}
+			if filter == TightFilterGradient {
+				undoGradient(data, bounds.Dx(), size, pf)
+			}
+			paint = func() error {
+				d.canvas.paintPixels(bounds, data, size, tpixel)
+				return nil
+			}
+
+		default:
+			return nil, fmt.Errorf("unsupported tight filter: %d", filter)
+		}
+	}
+
+	d.canvas.lock.Lock()
+	defer d.canvas.lock.Unlock()
+	if err := paint(); err != nil {
+		return nil, err
+	}
+	d.canvas.painted(common.EncTight, bounds, image.Point{})
+	return d, nil
+}
+
+// readData reads the pixel data of a basic compression rectangle, zlib compressed unless it is under 12 bytes
+func (d *tightDecoder) readData(r *common.RfbReadHelper, stream *zlibStream, size int) ([]byte, error) {
+	if size < TightMinToCompress {
+		return r.ReadBytes(size)
+	}
+	length, err := r.ReadCompactLen()
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := r.ReadBytes(length)
+	if err != nil {
+		return nil, err
+	}
+	data, err := stream.inflate(compressed, size)
+	if err != nil {
+		return nil, fmt.Errorf("tight rectangle: %v", err)
+	}
+	return data, nil
+}
+
+// undoGradient replaces the differences sent with the gradient filter by the pixels: each color component
+// was predicted as left + above - above left (clamped)
+func undoGradient(data []byte, width, size int, pf *common.PixelFormat) {
+	shifts := []uint8{pf.RedShift, pf.GreenShift, pf.BlueShift}
+	maxes := []uint32{uint32(pf.RedMax), uint32(pf.GreenMax), uint32(pf.BlueMax)}
+	components := func(i int) [3]int32 {
+		var c [3]int32
+		if i < 0 {
+			return c
+		}
+		v := tpixelValue(data[i*size:], pf)
+		for j := range c {
+			c[j] = int32(v >> shifts[j] & maxes[j])
+		}
+		return c
+	}
+	setPixel := func(i int, c [3]int32) {
+		var v uint32
+		for j := range c {
+			v |= uint32(c[j]) << shifts[j]
+		}
+		var buf []byte
+		if size == 3 {
+			buf = []byte{byte(v >> pf.RedShift), byte(v >> pf.GreenShift), byte(v >> pf.BlueShift)}
+		} else {
+			buf = appendPixelValue(nil, pf, v)
+		}
+		copy(data[i*size:], buf)
+	}
+
+	for i := 0; i < len(data)/size; i++ {
+		x := i % width
+		var left, above, aboveLeft [3]int32
+		if x > 0 {
+			left = components(i - 1)
+		}
+		if i >= width {
+			above = components(i - width)
+			if x > 0 {
+				aboveLeft = components(i - width - 1)
+			}
+		}
+		diff := components(i)
+		var c [3]int32
+		for j := range c {
+			predicted := left[j] + above[j] - aboveLeft[j]
+			if predicted < 0 {
+				predicted = 0
+			} else if predicted > int32(maxes[j]) {
+				predicted = int32(maxes[j])
+			}
+			c[j] = (predicted + diff[j]) & int32(maxes[j])
+		}
+		setPixel(i, c)
+	}
+}
@@ -0,0 +1,256 @@
+package encodings
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/exoscale/vncproxy/common"
+)
+
+// zlibStream inflates a zlib stream which goes on from one rectangle to the next. Exactly the bytes of the
+// rectangle are read from it, so the inflater never runs out of input.
+type zlibStream struct {
+	input bytes.Buffer
+	zr    io.ReadCloser
+}
+
+// feed adds the compressed data of a rectangle and returns the reader of the inflated data
+func (s *zlibStream) feed(data []byte) (io.Reader, error) {
+	s.input.Write(data)
+	if s.zr == nil {
+		zr, err := zlib.NewReader(&s.input)
+		if err != nil {
+			return nil, err
+		}
+		s.zr = zr
+	}
+	return s.zr, nil
+}
+
+func (s *zlibStream) inflate(data []byte, size int) ([]byte, error) {
+	zr, err := s.feed(data)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, size)
+	if _, err := io.ReadFull(zr, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *zlibStream) reset() {
+	s.zr = nil
+	s.input.Reset()
+}
+
+// zlibDecoder decodes the Zlib encoding: raw pixels compressed in the connection's zlib stream
+type zlibDecoder struct {
+	PseudoEncoding
+	canvas *Canvas
+	stream zlibStream
+}
+
+func (d *zlibDecoder) Read(pf *common.PixelFormat, rect *common.Rectangle, r *common.RfbReadHelper) (common.IEncoding, error) {
+	if err := CheckPixelFormat(pf); err != nil {
+		return nil, err
+	}
+	length, err := r.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := r.ReadBytes(int(length))
+	if err != nil {
+		return nil, err
+	}
+	size := int(pf.BPP / 8)
+	data, err := d.stream.inflate(compressed, int(rect.Width)*int(rect.Height)*size)
+	if err != nil {
+		return nil, fmt.Errorf("zlib rectangle: %v", err)
+	}
+	d.canvas.lock.Lock()
+	defer d.canvas.lock.Unlock()
+	d.canvas.paintPixels(rectangle(rect), data, size, func(p []byte) color.RGBA {
+		return PixelColor(pf, pixelValue(p, pf))
+	})
+	d.canvas.painted(common.EncZlib, rectangle(rect), image.Point{})
+	return d, nil
+}
+
+type zrleDecoder struct {
+	PseudoEncoding
+	canvas *Canvas
+	stream zlibStream
+}
+
+func (d *zrleDecoder) Read(pf *common.PixelFormat, rect *common.Rectangle, r *common.RfbReadHelper) (common.IEncoding, error) {
+	if err := CheckPixelFormat(pf); err != nil {
+		return nil, err
+	}
+	length, err := r.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := r.ReadBytes(int(length))
+	if err != nil {
+		return nil, err
+	}
+	zr, err := d.stream.feed(compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	d.canvas.lock.Lock()
+	defer d.canvas.lock.Unlock()
+	bounds := rectangle(rect)
+	for ty := bounds.Min.Y; ty < bounds.Max.Y; ty += zrleTileSize {
+		for tx := bounds.Min.X; tx < bounds.Max.X; tx += zrleTileSize {
+			tile := image.Rect(tx, ty, tx+zrleTileSize, ty+zrleTileSize).Intersect(bounds)
+			if err := d.decodeTile(zr, tile, pf); err != nil {
+				return nil, fmt.Errorf("zrle tile %v: %v", tile, err)
+			}
+		}
+	}
+	d.canvas.painted(common.EncZRLE, bounds, image.Point{})
+	return d, nil
+}
+
+func (d *zrleDecoder) decodeTile(zr io.Reader, tile image.Rectangle, pf *common.PixelFormat) error {
+	start, end := cpixelBytes(pf)
+	readCPixels := func(count int) ([]color.RGBA, error) {
+		data := make([]byte, count*(end-start))
+		if _, err := io.ReadFull(zr, data); err != nil {
+			return nil, err
+		}
+		colors := make([]color.RGBA, count)
+		for i := range colors {
+			var pixel [4]byte
+			copy(pixel[start:end], data[i*(end-start):])
+			colors[i] = PixelColor(pf, pixelValue(pixel[:], pf))
+		}
+		return colors, nil
+	}
+	var b [1]byte
+	readByte := func() (int, error) {
+		_, err := io.ReadFull(zr, b[:])
+		return int(b[0]), err
+	}
+	readRunLength := func() (int, error) {
+		length := 1
+		for {
+			v, err := readByte()
+			if err != nil {
+				return 0, err
+			}
+			length += v
+			if v != 255 {
+				return length, nil
+			}
+		}
+	}
+
+	subencoding, err := readByte()
+	if err != nil {
+		return err
+	}
+	pixels := tile.Dx() * tile.Dy()
+	// the colors of the pixels in the tile's row order, painted at the end
+	var tilePixels []color.RGBA
+	switch {
+	case subencoding == zrleRaw:
+		if tilePixels, err = readCPixels(pixels); err != nil {
+			return err
+		}
+	case subencoding == zrleSolid:
+		colors, err := readCPixels(1)
+		if err != nil {
+			return err
+		}
+		d.canvas.fill(tile, colors[0])
+		return nil
+	case subencoding <= 16:
+		// packed palette, each row starts on a byte
+		palette, err := readCPixels(subencoding)
+		if err != nil {
+			return err
+		}
+		bits := 4
+		if subencoding == 2 {
+			bits = 1
+		} else if subencoding <= 4 {
+			bits = 2
+		}
+		row := make([]byte, (tile.Dx()*bits+7)/8)
+		for y := 0; y < tile.Dy(); y++ {
+			if _, err := io.ReadFull(zr, row); err != nil {
+				return err
+			}
+			for x := 0; x < tile.Dx(); x++ {
+				shift := uint(8 - bits - (x*bits)%8)
+				index := int(row[x*bits/8]>>shift) & (1<<uint(bits) - 1)
+				if index >= len(palette) {
+					return fmt.Errorf("bad palette index %d", index)
+				}
+				tilePixels = append(tilePixels, palette[index])
+			}
+		}
+	case subencoding == zrlePlainRLE:
+		for len(tilePixels) < pixels {
+			colors, err := readCPixels(1)
+			if err != nil {
+				return err
+			}
+			length, err := readRunLength()
+			if err != nil {
+				return err
+			}
+			for i := 0; i < length; i++ {
+				tilePixels = append(tilePixels, colors[0])
+			}
+		}
+	case subencoding > zrlePaletteRLE+1:
+		palette, err := readCPixels(subencoding - zrlePaletteRLE)
+		if err != nil {
+			return err
+		}
+		for len(tilePixels) < pixels {
+			index, err := readByte()
+			if err != nil {
+				return err
+			}
+			length := 1
+			if index&128 != 0 {
+				index &= 127
+				if length, err = readRunLength(); err != nil {
+					return err
+				}
+			}
+			if index >= len(palette) {
+				return fmt.Errorf("bad palette index %d", index)
+			}
+			for i := 0; i < length; i++ {
+				tilePixels = append(tilePixels, palette[index])
+			}
+		}
+	default:
+		return fmt.Errorf("bad subencoding %d", subencoding)
+	}
+
+	if len(tilePixels) != pixels {
+		return fmt.Errorf("%d pixels instead of %d", len(tilePixels), pixels)
+	}
+	i := 0
+	for y := tile.Min.Y; y < tile.Max.Y; y++ {
+		for x := tile.Min.X; x < tile.Max.X; x++ {
+			if (image.Point{x, y}).In(d.canvas.img.Rect) {
+				d.canvas.img.SetRGBA(x, y, tilePixels[i])
+			}
+			i++
+		}
+	}
+	return nil
+}
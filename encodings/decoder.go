@@ -0,0 +1,361 @@
+package encodings
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"sync"
+
+	"github.com/exoscale/vncproxy/common"
+)
+
+// Canvas is a copy of a vnc server's screen, the decoders (see NewDecoders) paint the rectangles they read into it
+type Canvas struct {
+	// Painted (optional) is called after each rectangle is painted, with the canvas locked;
+	// src is the source of CopyRect rectangles
+	Painted func(typ common.EncodingType, r image.Rectangle, src image.Point)
+	// Resized (optional) is called after a DesktopSize rectangle, with the canvas locked
+	Resized func(width, height int)
+
+	lock sync.Mutex
+	img  *image.RGBA
+}
+
+// NewCanvas creates a black canvas
+func NewCanvas(width, height int) *Canvas {
+	return &Canvas{img: newBlackImage(width, height)}
+}
+
+func newBlackImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Rect, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	return img
+}
+
+// Lock keeps the decoders from painting, Image can be used until Unlock
+func (c *Canvas) Lock() {
+	c.lock.Lock()
+}
+
+func (c *Canvas) Unlock() {
+	c.lock.Unlock()
+}
+
+// Image returns the screen, the canvas must be locked
+func (c *Canvas) Image() *image.RGBA {
+	return c.img
+}
+
+// Resize changes the screen size keeping the top left pixels
+func (c *Canvas) Resize(width, height int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.resize(width, height)
+}
+
+func (c *Canvas) resize(width, height int) {
+	img := newBlackImage(width, height)
+	draw.Draw(img, img.Rect, c.img, image.Point{}, draw.Src)
+	c.img = img
+}
+
+func (c *Canvas) fill(r image.Rectangle, col color.RGBA) {
+	draw.Draw(c.img, r.Intersect(c.img.Rect), image.NewUniform(col), image.Point{}, draw.Src)
+}
+
+func (c *Canvas) painted(typ common.EncodingType, r image.Rectangle, src image.Point) {
+	if c.Painted != nil {
+		c.Painted(typ, r.Intersect(c.img.Rect), src)
+	}
+}
+
+// DecoderTypes are the encodings of NewDecoders
+var DecoderTypes = []common.EncodingType{
+	common.EncCopyRect,
+	common.EncTight,
+	common.EncZRLE,
+	common.EncHextile,
+	common.EncZlib,
+	common.EncCoRRE,
+	common.EncRRE,
+	common.EncRaw,
+	common.EncDesktopSizePseudo,
+}
+
+// NewDecoders returns the encodings given to a ClientConn to decode the vnc server's rectangles into the canvas,
+// in order of preference. The decoders of a connection keep the zlib streams, they can't be shared.
+func NewDecoders(canvas *Canvas) []common.IEncoding {
+	return []common.IEncoding{
+		&copyRectDecoder{PseudoEncoding{int32(common.EncCopyRect)}, canvas},
+		&tightDecoder{PseudoEncoding: PseudoEncoding{int32(common.EncTight)}, canvas: canvas},
+		&zrleDecoder{PseudoEncoding: PseudoEncoding{int32(common.EncZRLE)}, canvas: canvas},
+		&hextileDecoder{PseudoEncoding{int32(common.EncHextile)}, canvas},
+		&zlibDecoder{PseudoEncoding: PseudoEncoding{int32(common.EncZlib)}, canvas: canvas},
+		&rreDecoder{PseudoEncoding{int32(common.EncCoRRE)}, canvas},
+		&rreDecoder{PseudoEncoding{int32(common.EncRRE)}, canvas},
+		&rawDecoder{PseudoEncoding{int32(common.EncRaw)}, canvas},
+		&desktopSizeDecoder{PseudoEncoding{int32(common.EncDesktopSizePseudo)}, canvas},
+	}
+}
+
+// PixelColor converts a pixel value to a color, the color of ColorMapPalette for color map pixel formats
+func PixelColor(pf *common.PixelFormat, v uint32) color.RGBA {
+	if pf.TrueColor == 0 {
+		pf = &colorMapFormat
+	}
+	return color.RGBA{
+		R: scaleComponent(v>>pf.RedShift, pf.RedMax),
+		G: scaleComponent(v>>pf.GreenShift, pf.GreenMax),
+		B: scaleComponent(v>>pf.BlueShift, pf.BlueMax),
+		A: 0xff,
+	}
+}
+
+func scaleComponent(v uint32, max uint16) uint8 {
+	if max == 0 {
+		return 0
+	}
+	return uint8((v & uint32(max)) * 255 / uint32(max))
+}
+
+// pixelValue reads a pixel value of BPP/8 bytes
+func pixelValue(buf []byte, pf *common.PixelFormat) uint32 {
+	var v uint32
+	for i, b := range buf[:pf.BPP/8] {
+		if pf.BigEndian != 0 {
+			v = v<<8 | uint32(b)
+		} else {
+			v |= uint32(b) << (8 * uint(i))
+		}
+	}
+	return v
+}
+
+// CheckPixelFormat tells whether the decoders can read the pixel format: true color in 8, 16 or 32 bits
+func CheckPixelFormat(pf *common.PixelFormat) error {
+	if pf.TrueColor == 0 || (pf.BPP != 8 && pf.BPP != 16 && pf.BPP != 32) {
+		return fmt.Errorf("unsupported pixel format: %d bits, true color: %d", pf.BPP, pf.TrueColor)
+	}
+	return nil
+}
+
+func readPixel(r io.Reader, pf *common.PixelFormat) (color.RGBA, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:pf.BPP/8]); err != nil {
+		return color.RGBA{}, err
+	}
+	return PixelColor(pf, pixelValue(buf[:], pf)), nil
+}
+
+// paintPixels paints a rectangle of pixels of size bytes each, converted by pixel
+func (c *Canvas) paintPixels(r image.Rectangle, data []byte, size int, pixel func([]byte) color.RGBA) {
+	i := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if (image.Point{x, y}).In(c.img.Rect) {
+				c.img.SetRGBA(x, y, pixel(data[i:i+size]))
+			}
+			i += size
+		}
+	}
+}
+
+func rectangle(rect *common.Rectangle) image.Rectangle {
+	return image.Rect(int(rect.X), int(rect.Y), int(rect.X)+int(rect.Width), int(rect.Y)+int(rect.Height))
+}
+
+// the decoders embed PseudoEncoding for their type & an empty WriteTo, nothing is written back
+
+type rawDecoder struct {
+	PseudoEncoding
+	canvas *Canvas
+}
+
+func (d *rawDecoder) Read(pf *common.PixelFormat, rect *common.Rectangle, r *common.RfbReadHelper) (common.IEncoding, error) {
+	if err := CheckPixelFormat(pf); err != nil {
+		return nil, err
+	}
+	size := int(pf.BPP / 8)
+	data, err := r.ReadBytes(int(rect.Width) * int(rect.Height) * size)
+	if err != nil {
+		return nil, err
+	}
+	d.canvas.lock.Lock()
+	defer d.canvas.lock.Unlock()
+	d.canvas.paintPixels(rectangle(rect), data, size, func(p []byte) color.RGBA {
+		return PixelColor(pf, pixelValue(p, pf))
+	})
+	d.canvas.painted(common.EncRaw, rectangle(rect), image.Point{})
+	return d, nil
+}
+
+type copyRectDecoder struct {
+	PseudoEncoding
+	canvas *Canvas
+}
+
+func (d *copyRectDecoder) Read(pf *common.PixelFormat, rect *common.Rectangle, r *common.RfbReadHelper) (common.IEncoding, error) {
+	srcX, err := r.ReadUint16()
+	if err != nil {
+		return nil, err
+	}
+	srcY, err := r.ReadUint16()
+	if err != nil {
+		return nil, err
+	}
+	d.canvas.lock.Lock()
+	defer d.canvas.lock.Unlock()
+	img := d.canvas.img
+	dst := rectangle(rect)
+	src := image.Pt(int(srcX), int(srcY))
+	// the source & destination may overlap
+	tmp := image.NewRGBA(dst.Sub(dst.Min).Add(src))
+	draw.Draw(tmp, tmp.Rect, img, src, draw.Src)
+	draw.Draw(img, dst, tmp, src, draw.Src)
+	d.canvas.painted(common.EncCopyRect, dst, src)
+	return d, nil
+}
+
+// rreDecoder decodes RRE & CoRRE, which has 8 bit subrectangle coordinates
+type rreDecoder struct {
+	PseudoEncoding
+	canvas *Canvas
+}
+
+func (d *rreDecoder) Read(pf *common.PixelFormat, rect *common.Rectangle, r *common.RfbReadHelper) (common.IEncoding, error) {
+	if err := CheckPixelFormat(pf); err != nil {
+		return nil, err
+	}
+	count, err := r.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	bg, err := readPixel(r, pf)
+	if err != nil {
+		return nil, err
+	}
+	coordSize := 2
+	if d.Typ == int32(common.EncCoRRE) {
+		coordSize = 1
+	}
+	size := int(pf.BPP/8) + 4*coordSize
+	data, err := r.ReadBytes(int(count) * size)
+	if err != nil {
+		return nil, err
+	}
+
+	d.canvas.lock.Lock()
+	defer d.canvas.lock.Unlock()
+	bounds := rectangle(rect)
+	d.canvas.fill(bounds, bg)
+	for i := 0; i < int(count); i++ {
+		sub := data[i*size : (i+1)*size]
+		c := PixelColor(pf, pixelValue(sub, pf))
+		var coords [4]int
+		for j := range coords {
+			p := sub[int(pf.BPP/8)+j*coordSize:]
+			if coordSize == 1 {
+				coords[j] = int(p[0])
+			} else {
+				coords[j] = int(p[0])<<8 | int(p[1])
+			}
+		}
+		x, y := bounds.Min.X+coords[0], bounds.Min.Y+coords[1]
+		d.canvas.fill(image.Rect(x, y, x+coords[2], y+coords[3]).Intersect(bounds), c)
+	}
+	d.canvas.painted(common.EncodingType(d.Typ), bounds, image.Point{})
+	return d, nil
+}
+
+type hextileDecoder struct {
+	PseudoEncoding
+	canvas *Canvas
+}
+
+func (d *hextileDecoder) Read(pf *common.PixelFormat, rect *common.Rectangle, r *common.RfbReadHelper) (common.IEncoding, error) {
+	if err := CheckPixelFormat(pf); err != nil {
+		return nil, err
+	}
+	d.canvas.lock.Lock()
+	defer d.canvas.lock.Unlock()
+	var bg, fg color.RGBA
+	bounds := rectangle(rect)
+	for ty := bounds.Min.Y; ty < bounds.Max.Y; ty += 16 {
+		for tx := bounds.Min.X; tx < bounds.Max.X; tx += 16 {
+			tile := image.Rect(tx, ty, tx+16, ty+16).Intersect(bounds)
+			subencoding, err := r.ReadUint8()
+			if err != nil {
+				return nil, err
+			}
+
+			if subencoding&HextileRaw != 0 {
+				size := int(pf.BPP / 8)
+				data, err := r.ReadBytes(tile.Dx() * tile.Dy() * size)
+				if err != nil {
+					return nil, err
+				}
+				d.canvas.paintPixels(tile, data, size, func(p []byte) color.RGBA {
+					return PixelColor(pf, pixelValue(p, pf))
+				})
+				continue
+			}
+			if subencoding&HextileBackgroundSpecified != 0 {
+				if bg, err = readPixel(r, pf); err != nil {
+					return nil, err
+				}
+			}
+			d.canvas.fill(tile, bg)
+			if subencoding&HextileForegroundSpecified != 0 {
+				if fg, err = readPixel(r, pf); err != nil {
+					return nil, err
+				}
+			}
+			if subencoding&HextileAnySubrects == 0 {
+				continue
+			}
+
+			count, err := r.ReadUint8()
+			if err != nil {
+				return nil, err
+			}
+			for i := uint8(0); i < count; i++ {
+				c := fg
+				if subencoding&HextileSubrectsColoured != 0 {
+					if c, err = readPixel(r, pf); err != nil {
+						return nil, err
+					}
+				}
+				xy, err := r.ReadUint8()
+				if err != nil {
+					return nil, err
+				}
+				wh, err := r.ReadUint8()
+				if err != nil {
+					return nil, err
+				}
+				x, y := tx+int(xy>>4), ty+int(xy&0xf)
+				d.canvas.fill(image.Rect(x, y, x+int(wh>>4)+1, y+int(wh&0xf)+1).Intersect(tile), c)
+			}
+		}
+	}
+	d.canvas.painted(common.EncHextile, bounds, image.Point{})
+	return d, nil
+}
+
+// desktopSizeDecoder resizes the canvas when the vnc server changes its resolution
+type desktopSizeDecoder struct {
+	PseudoEncoding
+	canvas *Canvas
+}
+
+func (d *desktopSizeDecoder) Read(pf *common.PixelFormat, rect *common.Rectangle, r *common.RfbReadHelper) (common.IEncoding, error) {
+	d.canvas.lock.Lock()
+	defer d.canvas.lock.Unlock()
+	d.canvas.resize(int(rect.Width), int(rect.Height))
+	if d.canvas.Resized != nil {
+		d.canvas.Resized(int(rect.Width), int(rect.Height))
+	}
+	return d, nil
+}
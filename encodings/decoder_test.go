@@ -0,0 +1,172 @@
+package encodings
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/exoscale/vncproxy/common"
+)
+
+// testScreen has flat areas, text-like areas with few colors & a gradient
+func testScreen(width, height int) *image.RGBA {
+	img := testImage(width, height, white)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			switch {
+			case x < width/3 && (x/3+y/5)%4 == 0:
+				img.SetRGBA(x, y, red)
+			case x >= width/3 && x < 2*width/3 && (x+y)%7 < 3:
+				img.SetRGBA(x, y, color.RGBA{byte(x * 5), 0x40, byte(y * 3), 0xff})
+			case x >= 2*width/3:
+				img.SetRGBA(x, y, color.RGBA{byte(x), byte(y), byte(x + y), 0xff})
+			}
+		}
+	}
+	return img
+}
+
+// decodeRects reads count rectangles with the decoders
+func decodeRects(t *testing.T, decoders []common.IEncoding, pf *common.PixelFormat, data []byte, count int) {
+	r := common.NewRfbReadHelper(bytes.NewReader(data))
+	for i := 0; i < count; i++ {
+		var rect common.Rectangle
+		var typ int32
+		for _, v := range []interface{}{&rect.X, &rect.Y, &rect.Width, &rect.Height, &typ} {
+			if err := binary.Read(r, binary.BigEndian, v); err != nil {
+				t.Fatalf("reading rectangle %d: %s", i, err)
+			}
+		}
+		found := false
+		for _, dec := range decoders {
+			if dec.Type() == typ {
+				if _, err := dec.Read(pf, &rect, r); err != nil {
+					t.Fatalf("decoding %s: %s", common.EncodingType(typ), err)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("no decoder for %s", common.EncodingType(typ))
+		}
+	}
+	if n := r.Reader.(*bytes.Reader).Len(); n != 0 {
+		t.Errorf("%d bytes left after the rectangles", n)
+	}
+}
+
+// quantized returns the image as seen through the pixel format
+func quantized(img *image.RGBA, pf *common.PixelFormat) *image.RGBA {
+	q := image.NewRGBA(img.Rect)
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			q.SetRGBA(x, y, PixelColor(pf, PixelValue(pf, img.RGBAAt(x, y))))
+		}
+	}
+	return q
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, pf := range []*common.PixelFormat{common.NewPixelFormat(32), common.NewPixelFormat(16)} {
+		for _, typ := range EncoderTypes {
+			src := testScreen(150, 100)
+			canvas := NewCanvas(150, 100)
+			var painted []image.Rectangle
+			canvas.Painted = func(typ common.EncodingType, r image.Rectangle, src image.Point) {
+				painted = append(painted, r)
+			}
+			decoders := NewDecoders(canvas)
+			encoder := NewEncoder(typ, -1, 6)
+
+			// a full update & 2 partial ones, the zlib streams go on
+			updates := []image.Rectangle{src.Rect, image.Rect(10, 10, 140, 60), image.Rect(0, 50, 150, 100)}
+			for i, r := range updates {
+				if i > 0 {
+					draw.Draw(src, r.Inset(5), image.NewUniform(color.RGBA{0x20, byte(0x40 * i), 0x60, 0xff}), image.Point{}, draw.Src)
+				}
+				var buf bytes.Buffer
+				count, err := encoder.Encode(&buf, src, r, pf)
+				if err != nil {
+					t.Fatalf("%s: encoding %v: %s", typ, r, err)
+				}
+				decodeRects(t, decoders, pf, buf.Bytes(), count)
+			}
+
+			canvas.Lock()
+			if !bytes.Equal(canvas.Image().Pix, quantized(src, pf).Pix) {
+				t.Errorf("%s, %d bpp: the decoded screen differs", typ, pf.BPP)
+			}
+			canvas.Unlock()
+			if len(painted) < len(updates) {
+				t.Errorf("%s: %d rectangles painted", typ, len(painted))
+			}
+		}
+	}
+}
+
+func TestDecodeCopyRectAndDesktopSize(t *testing.T) {
+	src := testScreen(64, 64)
+	canvas := NewCanvas(64, 64)
+	decoders := NewDecoders(canvas)
+	pf := common.NewPixelFormat(32)
+
+	var buf bytes.Buffer
+	(&RawEncoder{}).Encode(&buf, src, src.Rect, pf)
+	WriteCopyRect(&buf, image.Rect(8, 8, 40, 40), image.Pt(0, 0))
+	WriteDesktopSize(&buf, 32, 48)
+	var resized image.Point
+	canvas.Resized = func(width, height int) {
+		resized = image.Pt(width, height)
+	}
+	decodeRects(t, decoders, pf, buf.Bytes(), 3)
+
+	draw.Draw(src, image.Rect(8, 8, 40, 40), testScreen(64, 64), image.Point{}, draw.Src)
+	canvas.Lock()
+	defer canvas.Unlock()
+	if resized != image.Pt(32, 48) || canvas.Image().Rect != image.Rect(0, 0, 32, 48) {
+		t.Fatalf("not resized: %v, %v", resized, canvas.Image().Rect)
+	}
+	if !imagesEqual(canvas.Image(), src) {
+		t.Error("the copied rectangle differs")
+	}
+}
+
+// imagesEqual compares the pixels of a, which must be within b
+func imagesEqual(a, b *image.RGBA) bool {
+	for y := a.Rect.Min.Y; y < a.Rect.Max.Y; y++ {
+		for x := a.Rect.Min.X; x < a.Rect.Max.X; x++ {
+			if a.RGBAAt(x, y) != b.RGBAAt(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestTightJpegDecode(t *testing.T) {
+	src := testImage(128, 128, red)
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			src.SetRGBA(x, y, color.RGBA{byte(2 * x), byte(2 * y), 0x80, 0xff})
+		}
+	}
+	canvas := NewCanvas(128, 128)
+	pf := common.NewPixelFormat(32)
+	var buf bytes.Buffer
+	count, err := NewTightEncoder(9, 6).Encode(&buf, src, src.Rect, pf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodeRects(t, NewDecoders(canvas), pf, buf.Bytes(), count)
+
+	canvas.Lock()
+	defer canvas.Unlock()
+	c := canvas.Image().RGBAAt(100, 20)
+	if diff := int(c.R) - 200; diff < -8 || diff > 8 {
+		t.Errorf("unexpected color after jpeg: %v", c)
+	}
+}
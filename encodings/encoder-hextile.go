@@ -0,0 +1,106 @@
+package encodings
+
+import (
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/exoscale/vncproxy/common"
+)
+
+type subrect struct {
+	r image.Rectangle
+	c color.RGBA
+}
+
+// HextileEncoder splits the rectangle in 16x16 tiles, sent as a background with subrectangles or raw.
+// The background & foreground colors are carried over from one tile to the next.
+type HextileEncoder struct{}
+
+func (*HextileEncoder) Type() common.EncodingType {
+	return common.EncHextile
+}
+
+func (*HextileEncoder) Encode(w io.Writer, img *image.RGBA, r image.Rectangle, pf *common.PixelFormat) (int, error) {
+	if err := WriteRectHeader(w, r, common.EncHextile); err != nil {
+		return 0, err
+	}
+
+	var (
+		buf           []byte
+		bg, fg        color.RGBA
+		bgSet, fgSet  bool
+		bytesPerPixel = int(pf.BPP / 8)
+	)
+	for ty := r.Min.Y; ty < r.Max.Y; ty += 16 {
+		for tx := r.Min.X; tx < r.Max.X; tx += 16 {
+			tile := image.Rect(tx, ty, tx+16, ty+16).Intersect(r)
+			tileBg := dominantColor(img, tile)
+
+			var subs []subrect
+			colors := make(map[color.RGBA]bool)
+			forEachSubrect(img, tile, tileBg, func(sub image.Rectangle, c color.RGBA) {
+				subs = append(subs, subrect{sub.Sub(tile.Min), c})
+				colors[c] = true
+			})
+			count := len(subs)
+
+			flags := byte(0)
+			var header []byte
+			if !bgSet || tileBg != bg {
+				flags |= HextileBackgroundSpecified
+				header = AppendPixel(header, pf, tileBg)
+			}
+			var tileFg color.RGBA
+			if count > 0 {
+				tileFg = subs[0].c
+				flags |= HextileAnySubrects
+			}
+			colored := len(colors) > 1
+			if colored {
+				flags |= HextileSubrectsColoured
+			} else if count > 0 && (!fgSet || tileFg != fg) {
+				flags |= HextileForegroundSpecified
+				header = AppendPixel(header, pf, tileFg)
+			}
+
+			var subrects []byte
+			for _, sub := range subs {
+				if colored {
+					subrects = AppendPixel(subrects, pf, sub.c)
+				}
+				subrects = append(subrects, byte(sub.r.Min.X<<4|sub.r.Min.Y), byte((sub.r.Dx()-1)<<4|(sub.r.Dy()-1)))
+			}
+
+			size := 1 + len(header) + len(subrects)
+			if count > 0 {
+				size++
+			}
+			if count > 255 || size > 1+tile.Dx()*tile.Dy()*bytesPerPixel {
+				buf = append(buf, HextileRaw)
+				for y := tile.Min.Y; y < tile.Max.Y; y++ {
+					for x := tile.Min.X; x < tile.Max.X; x++ {
+						buf = AppendPixel(buf, pf, rgbaAt(img, x, y))
+					}
+				}
+				// the colors are undefined after a raw tile
+				bgSet, fgSet = false, false
+				continue
+			}
+
+			buf = append(append(buf, flags), header...)
+			if count > 0 {
+				buf = append(append(buf, byte(count)), subrects...)
+			}
+			bg, bgSet = tileBg, true
+			if flags&HextileForegroundSpecified != 0 {
+				fg, fgSet = tileFg, true
+			}
+			if flags&HextileSubrectsColoured != 0 {
+				fgSet = false
+			}
+		}
+	}
+	_, err := w.Write(buf)
+	return 1, err
+}
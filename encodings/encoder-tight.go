@@ -0,0 +1,201 @@
+package encodings
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+
+	"github.com/exoscale/vncproxy/common"
+)
+
+// the Tight rectangles are split to stay within what the viewers accept
+const (
+	tightMaxWidth      = 2048
+	tightMaxPixels     = 65536
+	tightMaxPalette    = 256
+	tightMinJpegPixels = 4096
+)
+
+// the zlib streams used by the encoder, the viewer keeps one decompressor per stream
+const (
+	tightStreamFullColor = 0
+	tightStreamMono      = 1
+	tightStreamIndexed   = 2
+)
+
+// TightJpegQualities are the JPEG qualities of the Tight quality levels 0-9
+var TightJpegQualities = []int{5, 10, 15, 25, 37, 50, 60, 70, 75, 80}
+
+// TightEncoder sends single color rectangles as fills, the ones with few colors with a palette, and the
+// others as JPEG when the viewer asked for a quality level, else compressed with zlib
+type TightEncoder struct {
+	quality int
+	level   int
+	streams [4]*tightStream
+}
+
+type tightStream struct {
+	buf bytes.Buffer
+	zw  *zlib.Writer
+}
+
+// NewTightEncoder creates a Tight encoder, quality is the JPEG quality level (0-9, -1 for no JPEG)
+// and level the zlib compression level (0-9)
+func NewTightEncoder(quality, level int) *TightEncoder {
+	return &TightEncoder{quality: quality, level: level}
+}
+
+func (*TightEncoder) Type() common.EncodingType {
+	return common.EncTight
+}
+
+func (e *TightEncoder) Encode(w io.Writer, img *image.RGBA, r image.Rectangle, pf *common.PixelFormat) (int, error) {
+	width := r.Dx()
+	if width > tightMaxWidth {
+		width = tightMaxWidth
+	}
+	height := tightMaxPixels / width
+
+	count := 0
+	for y := r.Min.Y; y < r.Max.Y; y += height {
+		for x := r.Min.X; x < r.Max.X; x += width {
+			sub := image.Rect(x, y, x+width, y+height).Intersect(r)
+			if err := WriteRectHeader(w, sub, common.EncTight); err != nil {
+				return count, err
+			}
+			if err := e.encodeRect(w, img, sub, pf); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// tpixel appends a Tight pixel: 3 bytes (red, green, blue) for 24 bit depth in 32 bits, else as in Raw
+func tpixel(buf []byte, pf *common.PixelFormat, c color.RGBA) []byte {
+	if pf.BPP == 32 && pf.Depth == 24 {
+		v := PixelValue(pf, c)
+		return append(buf, byte(v>>pf.RedShift), byte(v>>pf.GreenShift), byte(v>>pf.BlueShift))
+	}
+	return AppendPixel(buf, pf, c)
+}
+
+// appendCompactLen appends a Tight length, 7 bits per byte
+func appendCompactLen(buf []byte, length int) []byte {
+	if length < 0x80 {
+		return append(buf, byte(length))
+	}
+	if length < 0x4000 {
+		return append(buf, byte(length)|0x80, byte(length>>7))
+	}
+	return append(buf, byte(length)|0x80, byte(length>>7)|0x80, byte(length>>14))
+}
+
+func (e *TightEncoder) encodeRect(w io.Writer, img *image.RGBA, r image.Rectangle, pf *common.PixelFormat) error {
+	palette := make(map[color.RGBA]int)
+	var colors []color.RGBA
+	for y := r.Min.Y; y < r.Max.Y && len(colors) <= tightMaxPalette; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := rgbaAt(img, x, y)
+			if _, ok := palette[c]; !ok {
+				palette[c] = len(colors)
+				colors = append(colors, c)
+				if len(colors) > tightMaxPalette {
+					break
+				}
+			}
+		}
+	}
+
+	if len(colors) == 1 {
+		_, err := w.Write(tpixel([]byte{TightFill << 4}, pf, colors[0]))
+		return err
+	}
+
+	pixels := r.Dx() * r.Dy()
+	jpegAllowed := e.quality >= 0 && pf.BPP >= 16 && pf.TrueColor != 0
+	if jpegAllowed && len(colors) > 64 && pixels >= tightMinJpegPixels {
+		var data bytes.Buffer
+		if err := jpeg.Encode(&data, img.SubImage(r), &jpeg.Options{Quality: TightJpegQualities[e.quality]}); err != nil {
+			return err
+		}
+		buf := appendCompactLen([]byte{TightJpeg << 4}, data.Len())
+		_, err := w.Write(append(buf, data.Bytes()...))
+		return err
+	}
+
+	var header, data []byte
+	stream := tightStreamFullColor
+	if len(colors) <= tightMaxPalette {
+		stream = tightStreamIndexed
+		if len(colors) == 2 {
+			stream = tightStreamMono
+		}
+		header = []byte{byte(stream<<4) | TightExplicitFilter<<4, TightFilterPalette, byte(len(colors) - 1)}
+		for _, c := range colors {
+			header = tpixel(header, pf, c)
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			if stream == tightStreamMono {
+				// 1 bit per pixel, each row starts on a byte
+				var b byte
+				for x := r.Min.X; x < r.Max.X; x++ {
+					b = b<<1 | byte(palette[rgbaAt(img, x, y)])
+					if (x-r.Min.X)%8 == 7 {
+						data = append(data, b)
+						b = 0
+					}
+				}
+				if n := r.Dx() % 8; n != 0 {
+					data = append(data, b<<uint(8-n))
+				}
+				continue
+			}
+			for x := r.Min.X; x < r.Max.X; x++ {
+				data = append(data, byte(palette[rgbaAt(img, x, y)]))
+			}
+		}
+	} else {
+		header = []byte{byte(stream << 4)}
+		data = make([]byte, 0, pixels*4)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				data = tpixel(data, pf, rgbaAt(img, x, y))
+			}
+		}
+	}
+
+	if len(data) < TightMinToCompress {
+		_, err := w.Write(append(header, data...))
+		return err
+	}
+	compressed, err := e.compress(stream, data)
+	if err != nil {
+		return err
+	}
+	header = appendCompactLen(header, len(compressed))
+	_, err = w.Write(append(header, compressed...))
+	return err
+}
+
+// compress writes the data in one of the zlib streams, flushed to the end of the data
+func (e *TightEncoder) compress(id int, data []byte) ([]byte, error) {
+	s := e.streams[id]
+	if s == nil {
+		s = &tightStream{}
+		s.zw, _ = zlib.NewWriterLevel(&s.buf, e.level)
+		e.streams[id] = s
+	}
+	s.buf.Reset()
+	if _, err := s.zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := s.zw.Flush(); err != nil {
+		return nil, err
+	}
+	return s.buf.Bytes(), nil
+}
@@ -0,0 +1,207 @@
+package encodings
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/exoscale/vncproxy/common"
+)
+
+// ZRLE tile subencodings
+const (
+	zrleRaw        = 0
+	zrleSolid      = 1
+	zrlePlainRLE   = 128
+	zrlePaletteRLE = 128 // + the palette size
+	zrleTileSize   = 64
+)
+
+// ZRLEEncoder splits the rectangle in 64x64 tiles, each one sent solid, with a palette, run-length encoded
+// or raw (whichever is smallest), and compresses them in the connection's zlib stream
+type ZRLEEncoder struct {
+	buf bytes.Buffer
+	zw  *zlib.Writer
+}
+
+// NewZRLEEncoder creates a ZRLE encoder, level is the zlib compression level (0-9)
+func NewZRLEEncoder(level int) *ZRLEEncoder {
+	e := &ZRLEEncoder{}
+	e.zw, _ = zlib.NewWriterLevel(&e.buf, level)
+	return e
+}
+
+func (*ZRLEEncoder) Type() common.EncodingType {
+	return common.EncZRLE
+}
+
+func (e *ZRLEEncoder) Encode(w io.Writer, img *image.RGBA, r image.Rectangle, pf *common.PixelFormat) (int, error) {
+	if err := WriteRectHeader(w, r, common.EncZRLE); err != nil {
+		return 0, err
+	}
+	for ty := r.Min.Y; ty < r.Max.Y; ty += zrleTileSize {
+		for tx := r.Min.X; tx < r.Max.X; tx += zrleTileSize {
+			tile := image.Rect(tx, ty, tx+zrleTileSize, ty+zrleTileSize).Intersect(r)
+			if _, err := e.zw.Write(encodeZRLETile(img, tile, pf)); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := e.zw.Flush(); err != nil {
+		return 0, err
+	}
+
+	if err := binary.Write(w, binary.BigEndian, uint32(e.buf.Len())); err != nil {
+		return 0, err
+	}
+	_, err := w.Write(e.buf.Bytes())
+	e.buf.Reset()
+	return 1, err
+}
+
+// cpixelBytes returns which bytes of a pixel are sent as a ZRLE CPIXEL: the 3 bytes holding the colors
+// for 32 bit true color formats with a depth up to 24, else all of them
+func cpixelBytes(pf *common.PixelFormat) (int, int) {
+	bpp := int(pf.BPP / 8)
+	if pf.TrueColor == 0 || pf.BPP != 32 || pf.Depth > 24 {
+		return 0, bpp
+	}
+	fitsLow := uint32(pf.RedMax)<<pf.RedShift|uint32(pf.GreenMax)<<pf.GreenShift|uint32(pf.BlueMax)<<pf.BlueShift < 1<<24
+	// the low bytes come first in little endian
+	if fitsLow == (pf.BigEndian == 0) {
+		return 0, 3
+	}
+	return 1, 4
+}
+
+// zrleRun is a run of pixels of the same color, in the tile's row order
+type zrleRun struct {
+	c      color.RGBA
+	length int
+}
+
+// runLengthSize is the size of a ZRLE run length
+func runLengthSize(length int) int {
+	return (length-1)/255 + 1
+}
+
+func appendRunLength(buf []byte, length int) []byte {
+	for length -= 1; length >= 255; length -= 255 {
+		buf = append(buf, 255)
+	}
+	return append(buf, byte(length))
+}
+
+func encodeZRLETile(img *image.RGBA, tile image.Rectangle, pf *common.PixelFormat) []byte {
+	start, end := cpixelBytes(pf)
+	cpixel := func(buf []byte, c color.RGBA) []byte {
+		var pixel [4]byte
+		return append(buf, AppendPixel(pixel[:0], pf, c)[start:end]...)
+	}
+	cpixelSize := end - start
+
+	var runs []zrleRun
+	palette := make(map[color.RGBA]int)
+	var colors []color.RGBA
+	for y := tile.Min.Y; y < tile.Max.Y; y++ {
+		for x := tile.Min.X; x < tile.Max.X; x++ {
+			c := rgbaAt(img, x, y)
+			if len(runs) > 0 && runs[len(runs)-1].c == c {
+				runs[len(runs)-1].length++
+			} else {
+				runs = append(runs, zrleRun{c, 1})
+			}
+			if _, ok := palette[c]; !ok && len(colors) <= 127 {
+				palette[c] = len(colors)
+				colors = append(colors, c)
+			}
+		}
+	}
+
+	if len(colors) == 1 {
+		return cpixel([]byte{zrleSolid}, colors[0])
+	}
+
+	pixels := tile.Dx() * tile.Dy()
+	rawSize := pixels * cpixelSize
+	plainRLESize := 0
+	paletteRLESize := len(colors) * cpixelSize
+	for _, run := range runs {
+		plainRLESize += cpixelSize + runLengthSize(run.length)
+		paletteRLESize++
+		if run.length > 1 {
+			paletteRLESize += runLengthSize(run.length)
+		}
+	}
+	bits := 0
+	switch {
+	case len(colors) == 2:
+		bits = 1
+	case len(colors) <= 4:
+		bits = 2
+	case len(colors) <= 16:
+		bits = 4
+	}
+	packedSize := len(colors)*cpixelSize + tile.Dy()*((tile.Dx()*bits+7)/8)
+
+	best, size := zrleRaw, rawSize
+	if plainRLESize < size {
+		best, size = zrlePlainRLE, plainRLESize
+	}
+	if len(colors) <= 127 && paletteRLESize < size {
+		best, size = zrlePaletteRLE+len(colors), paletteRLESize
+	}
+	if bits > 0 && packedSize < size {
+		best, size = len(colors), packedSize
+	}
+
+	buf := make([]byte, 0, 1+size)
+	buf = append(buf, byte(best))
+	switch {
+	case best == zrleRaw:
+		for y := tile.Min.Y; y < tile.Max.Y; y++ {
+			for x := tile.Min.X; x < tile.Max.X; x++ {
+				buf = cpixel(buf, rgbaAt(img, x, y))
+			}
+		}
+	case best == zrlePlainRLE:
+		for _, run := range runs {
+			buf = appendRunLength(cpixel(buf, run.c), run.length)
+		}
+	case best > zrlePaletteRLE:
+		for _, c := range colors {
+			buf = cpixel(buf, c)
+		}
+		for _, run := range runs {
+			if run.length == 1 {
+				buf = append(buf, byte(palette[run.c]))
+			} else {
+				buf = appendRunLength(append(buf, byte(palette[run.c])|128), run.length)
+			}
+		}
+	default:
+		// packed palette, each row starts on a byte
+		for _, c := range colors {
+			buf = cpixel(buf, c)
+		}
+		for y := tile.Min.Y; y < tile.Max.Y; y++ {
+			var b byte
+			n := 0
+			for x := tile.Min.X; x < tile.Max.X; x++ {
+				b = b<<uint(bits) | byte(palette[rgbaAt(img, x, y)])
+				n += bits
+				if n == 8 {
+					buf = append(buf, b)
+					b, n = 0, 0
+				}
+			}
+			if n > 0 {
+				buf = append(buf, b<<uint(8-n))
+			}
+		}
+	}
+	return buf
+}
@@ -0,0 +1,320 @@
+package encodings
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/exoscale/vncproxy/common"
+)
+
+// Encoder writes framebuffer rectangles in an encoding. An encoder serves a single vnc client:
+// ZRLE & Tight keep zlib streams going from one update to the next.
+type Encoder interface {
+	Type() common.EncodingType
+	// Encode writes the r rectangle of img in the pixel format, rectangle header(s) included,
+	// and returns the number of rectangles written (Tight splits the big ones)
+	Encode(w io.Writer, img *image.RGBA, r image.Rectangle, pf *common.PixelFormat) (int, error)
+}
+
+// EncoderTypes are the encodings NewEncoder supports
+var EncoderTypes = []common.EncodingType{
+	common.EncTight,
+	common.EncZRLE,
+	common.EncHextile,
+	common.EncRRE,
+	common.EncRaw,
+}
+
+// EncodingLevels returns the JPEG quality (0-9, -1 when not asked for) and the compression level (0-9)
+// set by the client's pseudo encodings
+func EncodingLevels(clientEncs []common.EncodingType) (int, int) {
+	quality, compression := -1, 6
+	for _, enc := range clientEncs {
+		if enc >= common.EncJPEGQualityLevelPseudo1 && enc <= common.EncJPEGQualityLevelPseudo10 {
+			quality = int(enc - common.EncJPEGQualityLevelPseudo1)
+		}
+		if enc >= common.EncCompressionLevel1 && enc <= common.EncCompressionLevel10 {
+			compression = int(enc - common.EncCompressionLevel1)
+		}
+	}
+	return quality, compression
+}
+
+// NewEncoder returns an encoder for the encoding, nil if it isn't supported.
+// quality is the Tight JPEG quality (-1 for no JPEG), compression the zlib level.
+func NewEncoder(typ common.EncodingType, quality, compression int) Encoder {
+	switch typ {
+	case common.EncRaw:
+		return &RawEncoder{}
+	case common.EncRRE:
+		return &RREEncoder{}
+	case common.EncHextile:
+		return &HextileEncoder{}
+	case common.EncZRLE:
+		return NewZRLEEncoder(compression)
+	case common.EncTight:
+		return NewTightEncoder(quality, compression)
+	}
+	return nil
+}
+
+// EncoderSet picks the encoder of a vnc client connection from its SetEncodings. The encoders are kept
+// for the connection's lifetime, as a client switching back to an encoding keeps its zlib streams.
+type EncoderSet struct {
+	// Allowed restricts the encodings used, pseudo encodings (like DesktopSize) & CopyRect included,
+	// everything is allowed when empty
+	Allowed []common.EncodingType
+
+	clientEncs []common.EncodingType
+	encoders   map[common.EncodingType]Encoder
+	current    Encoder
+}
+
+// SetEncodings updates the client's encodings, in its order of preference
+func (s *EncoderSet) SetEncodings(clientEncs []common.EncodingType) {
+	s.clientEncs = clientEncs
+	s.current = nil
+	quality, compression := EncodingLevels(clientEncs)
+	if tight, ok := s.encoders[common.EncTight].(*TightEncoder); ok {
+		tight.quality = quality
+	}
+	for _, enc := range clientEncs {
+		if !s.allowed(enc) {
+			continue
+		}
+		if encoder := s.encoders[enc]; encoder != nil {
+			s.current = encoder
+			return
+		}
+		if encoder := NewEncoder(enc, quality, compression); encoder != nil {
+			if s.encoders == nil {
+				s.encoders = make(map[common.EncodingType]Encoder)
+			}
+			s.encoders[enc] = encoder
+			s.current = encoder
+			return
+		}
+	}
+}
+
+// Encoder returns the encoder for the client's preferred encoding, Raw when none is supported
+func (s *EncoderSet) Encoder() Encoder {
+	if s.current == nil {
+		s.current = &RawEncoder{}
+	}
+	return s.current
+}
+
+// Supports tells whether the client announced the encoding, and it is allowed
+func (s *EncoderSet) Supports(enc common.EncodingType) bool {
+	return s.allowed(enc) && containsEncoding(s.clientEncs, enc)
+}
+
+func (s *EncoderSet) allowed(enc common.EncodingType) bool {
+	return len(s.Allowed) == 0 || containsEncoding(s.Allowed, enc)
+}
+
+func containsEncoding(encs []common.EncodingType, enc common.EncodingType) bool {
+	for _, e := range encs {
+		if e == enc {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteRectHeader writes the position, size & encoding of a rectangle
+func WriteRectHeader(w io.Writer, r image.Rectangle, typ common.EncodingType) error {
+	header := []interface{}{uint16(r.Min.X), uint16(r.Min.Y), uint16(r.Dx()), uint16(r.Dy()), int32(typ)}
+	for _, v := range header {
+		if err := binary.Write(w, binary.BigEndian, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCopyRect writes a CopyRect rectangle, r is the destination
+func WriteCopyRect(w io.Writer, r image.Rectangle, src image.Point) error {
+	if err := WriteRectHeader(w, r, common.EncCopyRect); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, []uint16{uint16(src.X), uint16(src.Y)})
+}
+
+// WriteDesktopSize writes a DesktopSize pseudo rectangle, announcing the new framebuffer size
+func WriteDesktopSize(w io.Writer, width, height int) error {
+	return WriteRectHeader(w, image.Rect(0, 0, width, height), common.EncDesktopSizePseudo)
+}
+
+// PixelValue converts a color to a pixel value, an index in ColorMapPalette for color map pixel formats
+func PixelValue(pf *common.PixelFormat, c color.RGBA) uint32 {
+	if pf.TrueColor == 0 {
+		pf = &colorMapFormat
+	}
+	return uint32(c.R)*uint32(pf.RedMax)/255<<pf.RedShift |
+		uint32(c.G)*uint32(pf.GreenMax)/255<<pf.GreenShift |
+		uint32(c.B)*uint32(pf.BlueMax)/255<<pf.BlueShift
+}
+
+// colorMapFormat is the pixel format of the color map indexes: 3 bits of red & green, 2 of blue
+var colorMapFormat = common.PixelFormat{
+	BPP: 8, Depth: 8, TrueColor: 1,
+	RedMax: 7, GreenMax: 7, BlueMax: 3,
+	RedShift: 5, GreenShift: 2, BlueShift: 0,
+}
+
+// ColorMapPalette returns the colors of the color map pixel formats, which must be sent to the vnc-clients
+// (see WriteColorMapEntries) before pixels in such a format
+func ColorMapPalette() []common.Color {
+	colors := make([]common.Color, 256)
+	for i := range colors {
+		c := PixelColor(&colorMapFormat, uint32(i))
+		colors[i] = common.Color{R: uint16(c.R) * 257, G: uint16(c.G) * 257, B: uint16(c.B) * 257}
+	}
+	return colors
+}
+
+// WriteColorMapEntries writes a SetColourMapEntries message setting the colors from first on
+func WriteColorMapEntries(w io.Writer, first int, colors []common.Color) error {
+	buf := appendUint16s([]byte{byte(common.SetColourMapEntries), 0}, first, len(colors))
+	for _, c := range colors {
+		buf = appendUint16s(buf, int(c.R), int(c.G), int(c.B))
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// AppendPixel appends a pixel in the pixel format (BPP/8 bytes)
+func AppendPixel(buf []byte, pf *common.PixelFormat, c color.RGBA) []byte {
+	return appendPixelValue(buf, pf, PixelValue(pf, c))
+}
+
+func appendPixelValue(buf []byte, pf *common.PixelFormat, v uint32) []byte {
+	bpp := int(pf.BPP / 8)
+	for i := 0; i < bpp; i++ {
+		if pf.BigEndian != 0 {
+			buf = append(buf, byte(v>>(8*uint(bpp-1-i))))
+		} else {
+			buf = append(buf, byte(v>>(8*uint(i))))
+		}
+	}
+	return buf
+}
+
+func rgbaAt(img *image.RGBA, x, y int) color.RGBA {
+	i := img.PixOffset(x, y)
+	return color.RGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], 0xff}
+}
+
+// RawEncoder sends the pixels as they are
+type RawEncoder struct{}
+
+func (*RawEncoder) Type() common.EncodingType {
+	return common.EncRaw
+}
+
+func (*RawEncoder) Encode(w io.Writer, img *image.RGBA, r image.Rectangle, pf *common.PixelFormat) (int, error) {
+	if err := WriteRectHeader(w, r, common.EncRaw); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 0, r.Dx()*r.Dy()*int(pf.BPP/8))
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			buf = AppendPixel(buf, pf, rgbaAt(img, x, y))
+		}
+	}
+	_, err := w.Write(buf)
+	return 1, err
+}
+
+// RREEncoder sends the most frequent color as background, and the other colors as subrectangles
+type RREEncoder struct{}
+
+func (*RREEncoder) Type() common.EncodingType {
+	return common.EncRRE
+}
+
+func (*RREEncoder) Encode(w io.Writer, img *image.RGBA, r image.Rectangle, pf *common.PixelFormat) (int, error) {
+	if err := WriteRectHeader(w, r, common.EncRRE); err != nil {
+		return 0, err
+	}
+	bg := dominantColor(img, r)
+	var subrects []byte
+	count := uint32(0)
+	forEachSubrect(img, r, bg, func(sub image.Rectangle, c color.RGBA) {
+		subrects = AppendPixel(subrects, pf, c)
+		subrects = appendUint16s(subrects, sub.Min.X-r.Min.X, sub.Min.Y-r.Min.Y, sub.Dx(), sub.Dy())
+		count++
+	})
+
+	buf := make([]byte, 4, 4+len(subrects)+4)
+	binary.BigEndian.PutUint32(buf, count)
+	buf = AppendPixel(buf, pf, bg)
+	if _, err := w.Write(append(buf, subrects...)); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func appendUint16s(buf []byte, values ...int) []byte {
+	for _, v := range values {
+		buf = append(buf, byte(v>>8), byte(v))
+	}
+	return buf
+}
+
+// dominantColor returns the most frequent color of the rectangle
+func dominantColor(img *image.RGBA, r image.Rectangle) color.RGBA {
+	counts := make(map[color.RGBA]int)
+	var best color.RGBA
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := rgbaAt(img, x, y)
+			counts[c]++
+			if counts[c] > counts[best] {
+				best = c
+			}
+		}
+	}
+	return best
+}
+
+// forEachSubrect covers the pixels of r that aren't bg with single color rectangles: each one is
+// extended to the right, then down as long as the rows match
+func forEachSubrect(img *image.RGBA, r image.Rectangle, bg color.RGBA, fn func(image.Rectangle, color.RGBA)) {
+	covered := make([]bool, r.Dx()*r.Dy())
+	isCovered := func(x, y int) bool {
+		return covered[(y-r.Min.Y)*r.Dx()+x-r.Min.X]
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := rgbaAt(img, x, y)
+			if c == bg || isCovered(x, y) {
+				continue
+			}
+			right := x + 1
+			for right < r.Max.X && rgbaAt(img, right, y) == c && !isCovered(right, y) {
+				right++
+			}
+			bottom := y + 1
+			for ; bottom < r.Max.Y; bottom++ {
+				same := true
+				for i := x; i < right && same; i++ {
+					same = rgbaAt(img, i, bottom) == c && !isCovered(i, bottom)
+				}
+				if !same {
+					break
+				}
+			}
+			for j := y; j < bottom; j++ {
+				for i := x; i < right; i++ {
+					covered[(j-r.Min.Y)*r.Dx()+i-r.Min.X] = true
+				}
+			}
+			fn(image.Rect(x, y, right, bottom), c)
+		}
+	}
+}
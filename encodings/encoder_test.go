@@ -0,0 +1,175 @@
+package encodings
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"testing"
+
+	"github.com/exoscale/vncproxy/common"
+)
+
+var (
+	red   = color.RGBA{0xff, 0, 0, 0xff}
+	white = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+func testImage(width, height int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Rect, image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestEncoderSet(t *testing.T) {
+	var s EncoderSet
+	if s.Encoder().Type() != common.EncRaw {
+		t.Errorf("expected raw before SetEncodings, got %s", s.Encoder().Type())
+	}
+
+	s.SetEncodings([]common.EncodingType{common.EncCursorPseudo, common.EncZRLE, common.EncRaw})
+	zrle := s.Encoder()
+	if zrle.Type() != common.EncZRLE {
+		t.Errorf("expected zrle, got %s", zrle.Type())
+	}
+	s.SetEncodings([]common.EncodingType{common.EncHextile})
+	s.SetEncodings([]common.EncodingType{common.EncZRLE})
+	if s.Encoder() != zrle {
+		t.Error("the zrle encoder (and its zlib stream) wasn't kept")
+	}
+
+	s = EncoderSet{Allowed: []common.EncodingType{common.EncHextile, common.EncRaw}}
+	s.SetEncodings([]common.EncodingType{common.EncTight, common.EncCopyRect, common.EncHextile})
+	if s.Encoder().Type() != common.EncHextile {
+		t.Errorf("expected hextile, got %s", s.Encoder().Type())
+	}
+	if s.Supports(common.EncCopyRect) {
+		t.Error("copyrect isn't allowed")
+	}
+}
+
+func TestEncodingLevels(t *testing.T) {
+	quality, compression := EncodingLevels([]common.EncodingType{common.EncTight, common.EncJPEGQualityLevelPseudo8, common.EncCompressionLevel2})
+	if quality != 7 || compression != 1 {
+		t.Errorf("unexpected levels %d %d", quality, compression)
+	}
+	quality, compression = EncodingLevels([]common.EncodingType{common.EncTight})
+	if quality != -1 || compression != 6 {
+		t.Errorf("unexpected default levels %d %d", quality, compression)
+	}
+}
+
+func TestRREEncoder(t *testing.T) {
+	img := testImage(8, 8, white)
+	draw.Draw(img, image.Rect(2, 2, 5, 4), image.NewUniform(red), image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if _, err := (&RREEncoder{}).Encode(&buf, img, img.Rect, common.NewPixelFormat(32)); err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{0, 0, 0, 0, 0, 8, 0, 8, 0, 0, 0, 2, // header
+		0, 0, 0, 1, 0xff, 0xff, 0xff, 0, // 1 subrect, background
+		0, 0, 0xff, 0, 0, 2, 0, 2, 0, 3, 0, 2}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("unexpected rre rectangle % x", buf.Bytes())
+	}
+}
+
+func TestZRLEEncoder(t *testing.T) {
+	img := testImage(100, 10, red)
+	draw.Draw(img, image.Rect(64, 0, 100, 10), image.NewUniform(white), image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if _, err := NewZRLEEncoder(6).Encode(&buf, img, img.Rect, common.NewPixelFormat(32)); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()[12:]
+	if length := binary.BigEndian.Uint32(data); int(length) != len(data)-4 {
+		t.Fatalf("unexpected zlib data length %d, %d bytes sent", length, len(data)-4)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(data[4:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles, err := ioutil.ReadAll(zr)
+	if err != nil && len(tiles) == 0 {
+		t.Fatal(err)
+	}
+	// two solid tiles with 3 byte cpixels
+	expected := []byte{zrleSolid, 0, 0, 0xff, zrleSolid, 0xff, 0xff, 0xff}
+	if !bytes.Equal(tiles, expected) {
+		t.Errorf("unexpected tiles % x", tiles)
+	}
+}
+
+func TestTightEncoder(t *testing.T) {
+	pf := common.NewPixelFormat(32)
+	img := testImage(16, 4, red)
+
+	var buf bytes.Buffer
+	if _, err := NewTightEncoder(-1, 6).Encode(&buf, img, img.Rect, pf); err != nil {
+		t.Fatal(err)
+	}
+	if fill := buf.Bytes()[12:]; !bytes.Equal(fill, []byte{TightFill << 4, 0xff, 0, 0}) {
+		t.Errorf("unexpected fill % x", fill)
+	}
+
+	// 2 colors: mono palette, 16 pixels a row in 2 bytes, 8 bytes sent uncompressed
+	draw.Draw(img, image.Rect(0, 0, 8, 4), image.NewUniform(white), image.Point{}, draw.Src)
+	buf.Reset()
+	if _, err := NewTightEncoder(-1, 6).Encode(&buf, img, img.Rect, pf); err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{(tightStreamMono | TightExplicitFilter) << 4, TightFilterPalette, 1, 0xff, 0xff, 0xff, 0xff, 0, 0,
+		0, 0xff, 0, 0xff, 0, 0xff, 0, 0xff}
+	if mono := buf.Bytes()[12:]; !bytes.Equal(mono, expected) {
+		t.Errorf("unexpected mono rectangle % x", mono)
+	}
+
+	// many colors with a quality level: jpeg
+	img = testImage(128, 128, red)
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			img.SetRGBA(x, y, color.RGBA{byte(2 * x), byte(2 * y), 0x80, 0xff})
+		}
+	}
+	buf.Reset()
+	if n, err := NewTightEncoder(5, 6).Encode(&buf, img, img.Rect, pf); err != nil || n != 1 {
+		t.Fatalf("unexpected result %d %v", n, err)
+	}
+	if buf.Bytes()[12] != TightJpeg<<4 {
+		t.Errorf("expected jpeg, got %x", buf.Bytes()[12])
+	}
+}
+
+func TestColorMapPixelFormat(t *testing.T) {
+	pf := &common.PixelFormat{BPP: 8, Depth: 8}
+	palette := ColorMapPalette()
+	for _, c := range []color.RGBA{red, white, {0, 0, 0, 0xff}, {0x20, 0x90, 0xd0, 0xff}} {
+		v := PixelValue(pf, c)
+		p := palette[v]
+		for _, diff := range []int{int(p.R>>8) - int(c.R), int(p.G>>8) - int(c.G), int(p.B>>8) - int(c.B)} {
+			if diff > 0 || diff < -0x55 {
+				t.Errorf("%v: color %d of the palette is %v", c, v, p)
+			}
+		}
+		if PixelColor(pf, v) != (color.RGBA{uint8(p.R >> 8), uint8(p.G >> 8), uint8(p.B >> 8), 0xff}) {
+			t.Errorf("%v: PixelColor doesn't match the palette", c)
+		}
+	}
+
+	var buf bytes.Buffer
+	(&RawEncoder{}).Encode(&buf, testImage(4, 4, red), image.Rect(0, 0, 4, 4), pf)
+	if buf.Len() != 12+16 || buf.Bytes()[12] != byte(PixelValue(pf, red)) {
+		t.Errorf("unexpected raw rectangle: %v", buf.Bytes())
+	}
+
+	buf.Reset()
+	WriteColorMapEntries(&buf, 0, palette)
+	if buf.Len() != 6+6*256 || !bytes.Equal(buf.Bytes()[:6], []byte{1, 0, 0, 0, 1, 0}) {
+		t.Errorf("unexpected SetColourMapEntries: %v", buf.Bytes()[:6])
+	}
+}
@@ -0,0 +1,25 @@
+package font
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDraw(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	r := Draw(img, "I-é", image.Pt(2, 1), 2, white)
+	if r != image.Rect(2, 1, 2+3*GlyphWidth*2, 1+GlyphHeight*2) || r.Size() != Size("I-?", 2) {
+		t.Errorf("unexpected text rectangle: %v", r)
+	}
+	// the bar of the I is the 3rd column, the dash is the 4th row
+	for _, p := range []image.Point{{2 + 2*2, 1 + 2}, {2 + 2*2 + 1, 1 + 7*2 + 1}, {2 + 12 + 2, 1 + 4*2}} {
+		if img.RGBAAt(p.X, p.Y) != white {
+			t.Errorf("%v isn't drawn", p)
+		}
+	}
+	if img.RGBAAt(2, 1+4*2) == white || img.RGBAAt(2+12+2, 1+2) == white {
+		t.Error("unexpected pixels drawn")
+	}
+}
@@ -7,4 +7,5 @@ require (
 	golang.org/x/net v0.0.0-20181129055619-fae4c4e3ad76
 	golang.org/x/sys v0.0.0-20181128092732-4ed8d59d0b35 // indirect
 	gopkg.in/inconshreveable/log15.v2 v2.0.0-20180818164646-67afb5ed74ec
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,382 @@
+package keyboard
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/exoscale/vncproxy/keysym"
+)
+
+// XT (scan code set 1) codes of the keys that aren't part of the layout tables,
+// two byte codes (0xe0 prefix) have the high bit of the first byte set, as in QEMU extended key events
+const (
+	ScancodeShiftL = 0x2a
+	ScancodeCtrlL  = 0x1d
+	ScancodeAltL   = 0x38
+	ScancodeAltGr  = 0xb8
+	ScancodeReturn = 0x1c
+	ScancodeTab    = 0x0f
+	ScancodeSpace  = 0x39
+)
+
+// Stroke is one key press (with its modifiers) on the guest's keyboard
+type Stroke struct {
+	Keysym   keysym.Keysym
+	Scancode uint32
+	Shift    bool
+	AltGr    bool
+}
+
+// KeyEvent is a key press or release, with both the keysym and the scan code of the key
+type KeyEvent struct {
+	Keysym   keysym.Keysym
+	Scancode uint32
+	Down     bool
+}
+
+// Layout knows which keys produce each character on a keyboard layout
+type Layout struct {
+	Name    string
+	strokes map[rune][]Stroke
+	dead    map[keysym.Keysym]Stroke
+	// keys are the keysyms of the main block's keys without modifiers
+	keys map[uint32]keysym.Keysym
+}
+
+var layouts = map[string]*Layout{}
+
+// LayoutNames lists the known layouts
+func LayoutNames() []string {
+	var names []string
+	for name := range layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LayoutByName returns a known layout: us, fr (AZERTY) or de (QWERTZ)
+func LayoutByName(name string) (*Layout, error) {
+	if name == "" {
+		name = "us"
+	}
+	layout, ok := layouts[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown keyboard layout %s (known: %s)", name, strings.Join(LayoutNames(), ", "))
+	}
+	return layout, nil
+}
+
+// Strokes returns the key presses typing r, a dead key followed by the base character for composed ones
+func (l *Layout) Strokes(r rune) ([]Stroke, bool) {
+	strokes, ok := l.strokes[r]
+	return strokes, ok
+}
+
+// DeadStroke returns the key press of a dead key, if the layout has it
+func (l *Layout) DeadStroke(k keysym.Keysym) (Stroke, bool) {
+	s, ok := l.dead[k]
+	return s, ok
+}
+
+// Keysym returns the keysym of the key with the scan code, without modifiers. Two byte codes may
+// have the 0xe0 prefix or the high bit set.
+func (l *Layout) Keysym(scancode uint32) (keysym.Keysym, bool) {
+	if scancode&0xff00 == 0xe000 {
+		scancode = 0x80 | scancode&0x7f
+	}
+	if k, ok := specialKeysyms[scancode]; ok {
+		return k, true
+	}
+	k, ok := l.keys[scancode]
+	return k, ok
+}
+
+// Compose returns the character typed by a dead key followed by base
+func Compose(dead keysym.Keysym, base rune) (rune, bool) {
+	for _, d := range deadKeys {
+		if d.keysym != dead {
+			continue
+		}
+		if base == ' ' {
+			return d.spacing, true
+		}
+		pairs := []rune(d.compose)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			if pairs[i] == base {
+				return pairs[i+1], true
+			}
+		}
+	}
+	return 0, false
+}
+
+// IsDeadKey tells whether the keysym is one of the dead keys known to the layouts
+func IsDeadKey(k keysym.Keysym) bool {
+	for _, d := range deadKeys {
+		if d.keysym == k {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyEvents returns the down/up events typing text, modifiers are pressed & released around each key.
+// CRLF is typed as a single Return, the characters the layout can't type are skipped and returned.
+func (l *Layout) KeyEvents(text string) ([]KeyEvent, []rune) {
+	var events []KeyEvent
+	var missing []rune
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	for _, r := range text {
+		strokes, ok := l.strokes[r]
+		if !ok {
+			missing = append(missing, r)
+			continue
+		}
+		for _, s := range strokes {
+			events = append(events, s.KeyEvents()...)
+		}
+	}
+	return events, missing
+}
+
+// KeyEvents returns the events of the stroke: modifiers down, key down & up, modifiers up
+func (s Stroke) KeyEvents() []KeyEvent {
+	var events []KeyEvent
+	if s.Shift {
+		events = append(events, KeyEvent{Keysym: keysym.ShiftL, Scancode: ScancodeShiftL, Down: true})
+	}
+	if s.AltGr {
+		events = append(events, KeyEvent{Keysym: keysym.ISOLevel3Shift, Scancode: ScancodeAltGr, Down: true})
+	}
+	events = append(events,
+		KeyEvent{Keysym: s.Keysym, Scancode: s.Scancode, Down: true},
+		KeyEvent{Keysym: s.Keysym, Scancode: s.Scancode, Down: false})
+	if s.AltGr {
+		events = append(events, KeyEvent{Keysym: keysym.ISOLevel3Shift, Scancode: ScancodeAltGr})
+	}
+	if s.Shift {
+		events = append(events, KeyEvent{Keysym: keysym.ShiftL, Scancode: ScancodeShiftL})
+	}
+	return events
+}
+
+// dead keys are written as combining characters (U+0300...) in the layout tables
+var deadKeys = map[rune]struct {
+	keysym  keysym.Keysym
+	spacing rune   // typed by the dead key followed by space
+	compose string // pairs of base & composed characters
+}{
+	'\u0300': {keysym.DeadGrave, '`', "aàeèiìoòuùAÀEÈIÌOÒUÙ"},
+	'\u0301': {keysym.DeadAcute, '´', "aáeéiíoóuúyýAÁEÉIÍOÓUÚYÝ"},
+	'\u0302': {keysym.DeadCircumflex, '^', "aâeêiîoôuûAÂEÊIÎOÔUÛ"},
+	'\u0303': {keysym.DeadTilde, '~', "aãnñoõAÃNÑOÕ"},
+	'\u0308': {keysym.DeadDiaeresis, '¨', "aäeëiïoöuüyÿAÄEËIÏOÖUÜ"},
+}
+
+// row describes keys of the main block, normal/shift/altgr hold one character per key, space = nothing
+type row struct {
+	scancodes            []uint32
+	normal, shift, altgr string
+}
+
+func scancodeRange(first, last uint32, extra ...uint32) []uint32 {
+	var codes []uint32
+	for code := first; code <= last; code++ {
+		codes = append(codes, code)
+	}
+	return append(codes, extra...)
+}
+
+func levelChars(s string, n int) []rune {
+	chars := []rune(s)
+	for len(chars) < n {
+		chars = append(chars, ' ')
+	}
+	return chars
+}
+
+func newLayout(name string, rows ...row) *Layout {
+	l := &Layout{Name: name, strokes: map[rune][]Stroke{
+		' ':  {{Keysym: keysym.Space, Scancode: ScancodeSpace}},
+		'\n': {{Keysym: keysym.Return, Scancode: ScancodeReturn}},
+		'\t': {{Keysym: keysym.Tab, Scancode: ScancodeTab}},
+	}, dead: map[keysym.Keysym]Stroke{}, keys: map[uint32]keysym.Keysym{}}
+	dead := map[rune]Stroke{}
+
+	// lower levels first, so that a character reachable in several ways uses the simplest one
+	for level := 0; level < 3; level++ {
+		for _, rw := range rows {
+			chars := levelChars([]string{rw.normal, rw.shift, rw.altgr}[level], len(rw.scancodes))
+			for i, code := range rw.scancodes {
+				r := chars[i]
+				if r == ' ' {
+					continue
+				}
+				stroke := Stroke{Scancode: code, Shift: level == 1, AltGr: level == 2}
+				if _, known := l.keys[code]; !known && level == 0 {
+					if d, ok := deadKeys[r]; ok {
+						l.keys[code] = d.keysym
+					} else {
+						l.keys[code] = keysym.FromRune(r)
+					}
+				}
+				if d, ok := deadKeys[r]; ok {
+					if _, known := dead[r]; !known {
+						stroke.Keysym = d.keysym
+						dead[r] = stroke
+					}
+					continue
+				}
+				if _, known := l.strokes[r]; !known {
+					stroke.Keysym = keysym.FromRune(r)
+					l.strokes[r] = []Stroke{stroke}
+				}
+			}
+		}
+	}
+
+	for r, deadStroke := range dead {
+		d := deadKeys[r]
+		l.dead[d.keysym] = deadStroke
+		if _, known := l.strokes[d.spacing]; !known {
+			l.strokes[d.spacing] = []Stroke{deadStroke, l.strokes[' '][0]}
+		}
+		pairs := []rune(d.compose)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			base, composed := pairs[i], pairs[i+1]
+			if _, known := l.strokes[composed]; known {
+				continue
+			}
+			if baseStrokes, ok := l.strokes[base]; ok && len(baseStrokes) == 1 {
+				l.strokes[composed] = []Stroke{deadStroke, baseStrokes[0]}
+			}
+		}
+	}
+
+	layouts[name] = l
+	return l
+}
+
+// specialKeysyms are the keys of the special scan codes
+var specialKeysyms = map[uint32]keysym.Keysym{}
+
+// specialScancodes are the keys which don't depend on the layout
+var specialScancodes = map[keysym.Keysym]uint32{
+	keysym.Escape:         0x01,
+	keysym.BackSpace:      0x0e,
+	keysym.Tab:            ScancodeTab,
+	keysym.Return:         ScancodeReturn,
+	keysym.ControlL:       ScancodeCtrlL,
+	keysym.ShiftL:         ScancodeShiftL,
+	keysym.ShiftR:         0x36,
+	keysym.AltL:           ScancodeAltL,
+	keysym.MetaL:          ScancodeAltL,
+	keysym.Space:          ScancodeSpace,
+	keysym.CapsLock:       0x3a,
+	0xff7f:                0x45, // Num_Lock
+	0xff14:                0x46, // Scroll_Lock
+	0xff15:                0x54, // Sys_Req
+	0xffaa:                0x37, // KP_Multiply
+	0xffad:                0x4a, // KP_Subtract
+	0xffab:                0x4e, // KP_Add
+	0xff8d:                0x9c, // KP_Enter
+	0xffaf:                0xb5, // KP_Divide
+	keysym.ControlR:       0x9d,
+	keysym.AltR:           ScancodeAltGr,
+	keysym.MetaR:          ScancodeAltGr,
+	keysym.ISOLevel3Shift: ScancodeAltGr,
+	0xff7e:                ScancodeAltGr, // Mode_switch
+	keysym.Print:          0xb7,
+	keysym.Home:           0xc7,
+	keysym.Up:             0xc8,
+	keysym.PageUp:         0xc9,
+	keysym.Left:           0xcb,
+	keysym.Right:          0xcd,
+	keysym.End:            0xcf,
+	keysym.Down:           0xd0,
+	keysym.PageDown:       0xd1,
+	keysym.Insert:         0xd2,
+	keysym.Delete:         0xd3,
+	keysym.SuperL:         0xdb,
+	keysym.SuperR:         0xdc,
+	keysym.Menu:           0xdd,
+}
+
+func init() {
+	// F1-F10, F11 & F12
+	for i := keysym.Keysym(0); i < 10; i++ {
+		specialScancodes[keysym.F1+i] = 0x3b + uint32(i)
+	}
+	specialScancodes[keysym.F1+10] = 0x57
+	specialScancodes[keysym.F12] = 0x58
+
+	// keypad, the same keys with & without num lock
+	keypad := []struct {
+		digit, other keysym.Keysym
+		scancode     uint32
+	}{
+		{0xffb7, 0xff95, 0x47}, {0xffb8, 0xff97, 0x48}, {0xffb9, 0xff9a, 0x49},
+		{0xffb4, 0xff96, 0x4b}, {0xffb5, 0xff9d, 0x4c}, {0xffb6, 0xff98, 0x4d},
+		{0xffb1, 0xff9c, 0x4f}, {0xffb2, 0xff99, 0x50}, {0xffb3, 0xff9b, 0x51},
+		{0xffb0, 0xff9e, 0x52}, {0xffae, 0xff9f, 0x53},
+	}
+	for _, k := range keypad {
+		specialScancodes[k.digit] = k.scancode
+		specialScancodes[k.other] = k.scancode
+	}
+
+	// the lowest keysym of the keys sharing a scan code (KP_Delete rather than KP_Decimal, KP_Home rather than KP_7),
+	// but the alt keys are Alt_L & Alt_R rather than Meta_L & ISO_Level3_Shift
+	for k, code := range specialScancodes {
+		if known, ok := specialKeysyms[code]; !ok || k < known {
+			specialKeysyms[code] = k
+		}
+	}
+	specialKeysyms[ScancodeAltL] = keysym.AltL
+	specialKeysyms[ScancodeAltGr] = keysym.AltR
+}
+
+// SpecialScancode returns the scan code of the keys which don't depend on the layout:
+// modifiers, function & editing keys, keypad...
+func SpecialScancode(k keysym.Keysym) (uint32, bool) {
+	code, ok := specialScancodes[k]
+	return code, ok
+}
+
+// the ISO key between left shift & Z
+const scancodeISO = 0x56
+
+var (
+	numberRow = scancodeRange(0x02, 0x0d)
+	topRow    = scancodeRange(0x10, 0x1b)
+	homeRow   = scancodeRange(0x1e, 0x28, 0x2b)
+	bottomRow = scancodeRange(0x2c, 0x35)
+)
+
+var (
+	US = newLayout("us",
+		row{[]uint32{0x29}, "`", "~", ""},
+		row{numberRow, "1234567890-=", "!@#$%^&*()_+", ""},
+		row{topRow, "qwertyuiop[]", "QWERTYUIOP{}", ""},
+		row{homeRow, "asdfghjkl;'\\", "ASDFGHJKL:\"|", ""},
+		row{bottomRow, "zxcvbnm,./", "ZXCVBNM<>?", ""},
+	)
+
+	FR = newLayout("fr",
+		row{[]uint32{0x29}, "²", "", ""},
+		row{numberRow, "&é\"'(-è_çà)=", "1234567890°+", " ~#{[|`\\^@]}"},
+		row{topRow, "azertyuiop\u0302$", "AZERTYUIOP\u0308£", "  €        ¤"},
+		row{homeRow, "qsdfghjklmù*", "QSDFGHJKLM%µ", ""},
+		row{append([]uint32{scancodeISO}, bottomRow...), "<wxcvbn,;:!", ">WXCVBN?./§", ""},
+	)
+
+	DE = newLayout("de",
+		row{[]uint32{0x29}, "\u0302", "°", ""},
+		row{numberRow, "1234567890ß\u0301", "!\"§$%&/()=?\u0300", " ²³   {[]}\\"},
+		row{topRow, "qwertzuiopü+", "QWERTZUIOPÜ*", "@ €        ~"},
+		row{homeRow, "asdfghjklöä#", "ASDFGHJKLÖÄ'", ""},
+		row{append([]uint32{scancodeISO}, bottomRow...), "<yxcvbnm,.-", ">YXCVBNM;:_", "|      µ"},
+	)
+)
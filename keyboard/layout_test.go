@@ -0,0 +1,108 @@
+package keyboard
+
+import (
+	"testing"
+
+	"github.com/exoscale/vncproxy/keysym"
+)
+
+func TestLayoutStrokes(t *testing.T) {
+	tests := []struct {
+		layout  *Layout
+		char    rune
+		strokes []Stroke
+	}{
+		{US, 'a', []Stroke{{Keysym: 'a', Scancode: 0x1e}}},
+		{US, '?', []Stroke{{Keysym: '?', Scancode: 0x35, Shift: true}}},
+		{FR, 'a', []Stroke{{Keysym: 'a', Scancode: 0x10}}},
+		{FR, '1', []Stroke{{Keysym: '1', Scancode: 0x02, Shift: true}}},
+		{FR, '@', []Stroke{{Keysym: '@', Scancode: 0x0b, AltGr: true}}},
+		{FR, '€', []Stroke{{Keysym: 0x20ac, Scancode: 0x12, AltGr: true}}},
+		{FR, '¤', []Stroke{{Keysym: 0xa4, Scancode: 0x1b, AltGr: true}}},
+		{FR, 'ê', []Stroke{{Keysym: 0xfe52, Scancode: 0x1a}, {Keysym: 'e', Scancode: 0x12}}},
+		{FR, 'Ï', []Stroke{{Keysym: 0xfe57, Scancode: 0x1a, Shift: true}, {Keysym: 'I', Scancode: 0x17, Shift: true}}},
+		{DE, 'z', []Stroke{{Keysym: 'z', Scancode: 0x15}}},
+		{DE, '~', []Stroke{{Keysym: '~', Scancode: 0x1b, AltGr: true}}},
+		{DE, '{', []Stroke{{Keysym: '{', Scancode: 0x08, AltGr: true}}},
+		{DE, 'µ', []Stroke{{Keysym: 0xb5, Scancode: 0x32, AltGr: true}}},
+		{DE, '^', []Stroke{{Keysym: 0xfe52, Scancode: 0x29}, {Keysym: ' ', Scancode: ScancodeSpace}}},
+		{DE, 'é', []Stroke{{Keysym: 0xfe51, Scancode: 0x0d}, {Keysym: 'e', Scancode: 0x12}}},
+	}
+	for _, test := range tests {
+		strokes, ok := test.layout.Strokes(test.char)
+		if !ok {
+			t.Errorf("%s: %c can't be typed", test.layout.Name, test.char)
+			continue
+		}
+		if len(strokes) != len(test.strokes) {
+			t.Errorf("%s: %c: got %+v, expected %+v", test.layout.Name, test.char, strokes, test.strokes)
+			continue
+		}
+		for i := range strokes {
+			if strokes[i] != test.strokes[i] {
+				t.Errorf("%s: %c: got %+v, expected %+v", test.layout.Name, test.char, strokes, test.strokes)
+			}
+		}
+	}
+}
+
+func TestLayoutKeysym(t *testing.T) {
+	tests := []struct {
+		layout   *Layout
+		scancode uint32
+		keysym   keysym.Keysym
+	}{
+		{US, 0x1e, 'a'},
+		{FR, 0x10, 'a'},
+		{FR, 0x1a, keysym.DeadCircumflex},
+		{DE, 0x15, 'z'},
+		{US, 0x1d, keysym.ControlL},
+		{US, 0x38, keysym.AltL},
+		{US, 0xb8, keysym.AltR},
+		{US, 0xd3, keysym.Delete},
+		{US, 0xe053, keysym.Delete},
+		{US, 0x53, 0xff9f}, // KP_Delete
+		{US, 0x3b, keysym.F1},
+		{US, 0x58, keysym.F12},
+	}
+	for _, test := range tests {
+		if k, ok := test.layout.Keysym(test.scancode); !ok || k != test.keysym {
+			t.Errorf("%s: scan code 0x%x is %s, expected %s", test.layout.Name, test.scancode, k, test.keysym)
+		}
+	}
+	if _, ok := US.Keysym(0x7f); ok {
+		t.Errorf("unexpected keysym for an unknown scan code")
+	}
+}
+
+func TestLayoutKeyEvents(t *testing.T) {
+	events, missing := US.KeyEvents("Hi\r\n☃")
+	if len(missing) != 1 || missing[0] != '☃' {
+		t.Errorf("unexpected missing characters: %q", missing)
+	}
+	expected := []KeyEvent{
+		{Keysym: keysym.ShiftL, Scancode: ScancodeShiftL, Down: true},
+		{Keysym: 'H', Scancode: 0x23, Down: true},
+		{Keysym: 'H', Scancode: 0x23},
+		{Keysym: keysym.ShiftL, Scancode: ScancodeShiftL},
+		{Keysym: 'i', Scancode: 0x17, Down: true},
+		{Keysym: 'i', Scancode: 0x17},
+		{Keysym: keysym.Return, Scancode: ScancodeReturn, Down: true},
+		{Keysym: keysym.Return, Scancode: ScancodeReturn},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("got %d events, expected %d: %+v", len(events), len(expected), events)
+	}
+	for i := range events {
+		if events[i] != expected[i] {
+			t.Errorf("event %d: got %+v, expected %+v", i, events[i], expected[i])
+		}
+	}
+
+	if _, err := LayoutByName("FR"); err != nil {
+		t.Errorf("layout names are case insensitive: %s", err)
+	}
+	if _, err := LayoutByName("dvorak"); err == nil {
+		t.Errorf("expected an error for an unknown layout")
+	}
+}
@@ -0,0 +1,73 @@
+//go:build ignore
+// +build ignore
+
+// gen.go generates table.go from the X11 keysymdef.h header (xorgproto):
+//
+//	go run gen.go /usr/include/X11/keysymdef.h
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// #define XK_name 0x1234 /* U+1234 NAME */, /*(U+1234 NAME)*/ or /*<U+1234 NAME>*/ for approximate mappings
+var defineRe = regexp.MustCompile(`^#define XK_([a-zA-Z_0-9]+)\s+0x([0-9a-fA-F]+)\s*(/\*\s*(U\+([0-9A-Fa-f]{4,6}))?(.*)\*/)?\s*$`)
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("usage: go run gen.go keysymdef.h")
+	}
+	f, err := os.Open(os.Args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gen.go from keysymdef.h; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package keysym\n\n")
+	fmt.Fprintf(&buf, "// keysymTable lists all the keysyms of keysymdef.h, in order.\n")
+	fmt.Fprintf(&buf, "// unicode is only set for exact mappings, deprecated names are never returned by Name.\n")
+	fmt.Fprintf(&buf, "var keysymTable = []keysymEntry{\n")
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := defineRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		value, err := strconv.ParseUint(m[2], 16, 32)
+		if err != nil {
+			log.Fatal(err)
+		}
+		unicode := "0"
+		if m[5] != "" {
+			unicode = "0x" + m[5]
+		}
+		deprecated := bytes.Contains([]byte(m[6]), []byte("deprecated"))
+		fmt.Fprintf(&buf, "\t{%q, 0x%x, %s, %t},\n", m[1], value, unicode, deprecated)
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(&buf, "}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("table.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d keysyms", count)
+}
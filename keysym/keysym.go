@@ -0,0 +1,281 @@
+// Package keysym knows the X11 keysyms used by RFB key events: their names, their Unicode
+// characters, and the key event sequences typing a string or a chord.
+package keysym
+
+//go:generate go run gen.go /usr/include/X11/keysymdef.h
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Keysym is an X11 keysym, as sent in KeyEvent messages
+type Keysym uint32
+
+type keysymEntry struct {
+	name       string
+	keysym     Keysym
+	unicode    rune
+	deprecated bool
+}
+
+// the keys used by the helpers & by other packages
+const (
+	BackSpace      Keysym = 0xff08
+	Tab            Keysym = 0xff09
+	Return         Keysym = 0xff0d
+	Pause          Keysym = 0xff13
+	Escape         Keysym = 0xff1b
+	Delete         Keysym = 0xffff
+	Home           Keysym = 0xff50
+	Left           Keysym = 0xff51
+	Up             Keysym = 0xff52
+	Right          Keysym = 0xff53
+	Down           Keysym = 0xff54
+	PageUp         Keysym = 0xff55
+	PageDown       Keysym = 0xff56
+	End            Keysym = 0xff57
+	Print          Keysym = 0xff61
+	Insert         Keysym = 0xff63
+	Menu           Keysym = 0xff67
+	F1             Keysym = 0xffbe
+	F12            Keysym = 0xffc9
+	ShiftL         Keysym = 0xffe1
+	ShiftR         Keysym = 0xffe2
+	ControlL       Keysym = 0xffe3
+	ControlR       Keysym = 0xffe4
+	CapsLock       Keysym = 0xffe5
+	MetaL          Keysym = 0xffe7
+	MetaR          Keysym = 0xffe8
+	AltL           Keysym = 0xffe9
+	AltR           Keysym = 0xffea
+	SuperL         Keysym = 0xffeb
+	SuperR         Keysym = 0xffec
+	ISOLevel3Shift Keysym = 0xfe03 // AltGr
+	DeadGrave      Keysym = 0xfe50
+	DeadAcute      Keysym = 0xfe51
+	DeadCircumflex Keysym = 0xfe52
+	DeadTilde      Keysym = 0xfe53
+	DeadDiaeresis  Keysym = 0xfe57
+	Space          Keysym = 0x0020
+)
+
+// the Unicode keysyms are 0x01000000 + the code point, for the characters without a legacy keysym
+const (
+	unicodeOffset = 0x01000000
+	unicodeFirst  = 0x01000100
+	unicodeLast   = 0x0110ffff
+)
+
+var (
+	byName   = map[string]Keysym{}
+	names    = map[Keysym]string{}
+	toRune   = map[Keysym]rune{}
+	fromRune = map[rune]Keysym{}
+)
+
+func init() {
+	for _, e := range keysymTable {
+		byName[e.name] = e.keysym
+		if e.deprecated {
+			continue
+		}
+		if _, ok := names[e.keysym]; !ok {
+			names[e.keysym] = e.name
+		}
+		if e.unicode == 0 {
+			continue
+		}
+		if _, ok := toRune[e.keysym]; !ok {
+			toRune[e.keysym] = e.unicode
+		}
+		// prefer legacy keysyms, which older servers understand
+		if current, ok := fromRune[e.unicode]; !ok || (current >= unicodeFirst && e.keysym < unicodeFirst) {
+			fromRune[e.unicode] = e.keysym
+		}
+	}
+}
+
+// aliases are the usual short names accepted in chords (lower case)
+var aliases = map[string]Keysym{
+	"ctrl":    ControlL,
+	"control": ControlL,
+	"alt":     AltL,
+	"shift":   ShiftL,
+	"meta":    MetaL,
+	"super":   SuperL,
+	"win":     SuperL,
+	"altgr":   ISOLevel3Shift,
+	"del":     Delete,
+	"esc":     Escape,
+	"enter":   Return,
+	"ins":     Insert,
+	"pgup":    PageUp,
+	"pgdn":    PageDown,
+	"plus":    '+',
+}
+
+// String returns the keysym's name
+func (k Keysym) String() string {
+	return k.Name()
+}
+
+// Name returns the keysym's X11 name (without XK_), U<hex> for other Unicode keysyms & 0x<hex> otherwise
+func (k Keysym) Name() string {
+	if name, ok := names[k]; ok {
+		return name
+	}
+	if k >= unicodeFirst && k <= unicodeLast {
+		return fmt.Sprintf("U%04X", uint32(k-unicodeOffset))
+	}
+	return fmt.Sprintf("0x%x", uint32(k))
+}
+
+// Rune returns the character typed by the keysym, if any
+func (k Keysym) Rune() (rune, bool) {
+	if k >= unicodeFirst && k <= unicodeLast {
+		return rune(k - unicodeOffset), true
+	}
+	if (k >= 0x20 && k <= 0x7e) || (k >= 0xa0 && k <= 0xff) {
+		return rune(k), true
+	}
+	r, ok := toRune[k]
+	return r, ok
+}
+
+// FromRune returns the keysym typing r: the legacy keysym if there is one, else the Unicode keysym.
+// Newline, tab, backspace, escape & delete map to their keys.
+func FromRune(r rune) Keysym {
+	switch r {
+	case '\n', '\r':
+		return Return
+	case '\t':
+		return Tab
+	case '\b':
+		return BackSpace
+	case 0x1b:
+		return Escape
+	case 0x7f:
+		return Delete
+	}
+	if (r >= 0x20 && r <= 0x7e) || (r >= 0xa0 && r <= 0xff) {
+		return Keysym(r)
+	}
+	if k, ok := fromRune[r]; ok {
+		return k
+	}
+	return Keysym(unicodeOffset + r)
+}
+
+// FromName parses a keysym name: an X11 name (with or without XK_), a chord alias (ctrl, alt, del...),
+// U+<hex> / U<hex>, 0x<hex>, or a single character
+func FromName(name string) (Keysym, bool) {
+	name = strings.TrimPrefix(name, "XK_")
+	if k, ok := byName[name]; ok {
+		return k, true
+	}
+	if k, ok := aliases[strings.ToLower(name)]; ok {
+		return k, true
+	}
+	if len(name) > 1 && (name[0] == 'U' || name[0] == 'u') {
+		if cp, err := strconv.ParseUint(strings.TrimPrefix(name[1:], "+"), 16, 32); err == nil && cp <= utf8.MaxRune {
+			return FromRune(rune(cp)), true
+		}
+	}
+	if strings.HasPrefix(name, "0x") {
+		if v, err := strconv.ParseUint(name[2:], 16, 32); err == nil {
+			return Keysym(v), true
+		}
+	}
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		return FromRune(r), true
+	}
+	return 0, false
+}
+
+// ParseChord parses keys joined by +, like ctrl+alt+Delete
+func ParseChord(chord string) ([]Keysym, error) {
+	var keys []Keysym
+	for _, name := range strings.Split(chord, "+") {
+		k, ok := FromName(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q in %q", name, chord)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// IsModifier tells whether the key is a modifier (shift, control, alt, meta, super, AltGr...)
+func (k Keysym) IsModifier() bool {
+	if k == CapsLock || k == 0xffe6 { // Shift_Lock
+		return false
+	}
+	return (k >= ShiftL && k <= 0xffee) || k == ISOLevel3Shift
+}
+
+// Event is a key press or release
+type Event struct {
+	Keysym Keysym
+	Down   bool
+}
+
+// Press returns the down & up events of a key
+func Press(k Keysym) []Event {
+	return []Event{{Keysym: k, Down: true}, {Keysym: k}}
+}
+
+// Chord presses the keys in order and releases them in reverse order
+func Chord(keys ...Keysym) []Event {
+	events := make([]Event, 0, 2*len(keys))
+	for _, k := range keys {
+		events = append(events, Event{Keysym: k, Down: true})
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		events = append(events, Event{Keysym: keys[i]})
+	}
+	return events
+}
+
+// usShifted are the ASCII characters typed with shift on a US keyboard
+const usShifted = `~!@#$%^&*()_+{}|:"<>?`
+
+// NeedsShift tells whether r is typed with shift on a US keyboard, keysym based servers
+// which track the modifier state (QEMU) expect shift to be down for these
+func NeedsShift(r rune) bool {
+	return (r >= 'A' && r <= 'Z') || strings.ContainsRune(usShifted, r)
+}
+
+// StringEvents returns the events typing s on a keysym based server, CRLF is a single Return.
+// Characters needing shift on a US keyboard are surrounded by Shift_L events.
+func StringEvents(s string) []Event {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	var events []Event
+	for _, r := range s {
+		k := FromRune(r)
+		if NeedsShift(r) {
+			events = append(events, Chord(ShiftL, k)...)
+		} else {
+			events = append(events, Press(k)...)
+		}
+	}
+	return events
+}
+
+// KeyEventSender sends key events, like client.ClientConn
+type KeyEventSender interface {
+	KeyEvent(keysym uint32, down bool) error
+}
+
+// Send sends the events in order
+func Send(sender KeyEventSender, events []Event) error {
+	for _, ev := range events {
+		if err := sender.KeyEvent(uint32(ev.Keysym), ev.Down); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package keysym
+
+import (
+	"testing"
+)
+
+func TestKeysymNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		keysym Keysym
+	}{
+		{"a", 'a'},
+		{"XK_EuroSign", 0x20ac},
+		{"Return", Return},
+		{"ctrl", ControlL},
+		{"Del", Delete},
+		{"U+263A", 0x100263a},
+		{"U20AC", 0x20ac},
+		{"0xff51", Left},
+		{"é", 0xe9},
+		{"quoteright", '\''},
+	}
+	for _, test := range tests {
+		k, ok := FromName(test.name)
+		if !ok || k != test.keysym {
+			t.Errorf("%s: got 0x%x (%t), expected 0x%x", test.name, uint32(k), ok, uint32(test.keysym))
+		}
+	}
+	if _, ok := FromName("NoSuchKey"); ok {
+		t.Errorf("NoSuchKey should be unknown")
+	}
+
+	if Delete.Name() != "Delete" || Keysym('\'').Name() != "apostrophe" || Keysym(0x100263a).Name() != "U263A" {
+		t.Errorf("unexpected names: %s %s %s", Delete, Keysym('\''), Keysym(0x100263a))
+	}
+}
+
+func TestKeysymUnicode(t *testing.T) {
+	tests := []struct {
+		r      rune
+		keysym Keysym
+	}{
+		{'a', 'a'},
+		{'ÿ', 0xff},
+		{'€', 0x20ac},
+		{'Ą', 0x1a1},
+		{'α', 0x7e1},
+		{'☺', 0x100263a},
+		{'\n', Return},
+	}
+	for _, test := range tests {
+		if k := FromRune(test.r); k != test.keysym {
+			t.Errorf("%q: got 0x%x, expected 0x%x", test.r, uint32(k), uint32(test.keysym))
+		}
+		if test.r == '\n' {
+			continue
+		}
+		if r, ok := test.keysym.Rune(); !ok || r != test.r {
+			t.Errorf("0x%x: got %q, expected %q", uint32(test.keysym), r, test.r)
+		}
+	}
+	if _, ok := Left.Rune(); ok {
+		t.Errorf("Left isn't a character")
+	}
+}
+
+func TestKeysymEvents(t *testing.T) {
+	keys, err := ParseChord("ctrl+alt+Delete")
+	if err != nil {
+		t.Fatalf("parsing chord: %s", err)
+	}
+	events := Chord(keys...)
+	expected := []Event{{ControlL, true}, {AltL, true}, {Delete, true}, {Delete, false}, {AltL, false}, {ControlL, false}}
+	if len(events) != len(expected) {
+		t.Fatalf("got %v, expected %v", events, expected)
+	}
+	for i := range events {
+		if events[i] != expected[i] {
+			t.Errorf("event %d: got %v, expected %v", i, events[i], expected[i])
+		}
+	}
+
+	events = StringEvents("A€\r\n")
+	expected = []Event{{ShiftL, true}, {'A', true}, {'A', false}, {ShiftL, false}, {0x20ac, true}, {0x20ac, false}, {Return, true}, {Return, false}}
+	if len(events) != len(expected) {
+		t.Fatalf("got %v, expected %v", events, expected)
+	}
+	for i := range events {
+		if events[i] != expected[i] {
+			t.Errorf("event %d: got %v, expected %v", i, events[i], expected[i])
+		}
+	}
+
+	if _, err := ParseChord("ctrl+nope"); err == nil {
+		t.Errorf("expected an error for an unknown key")
+	}
+}
@@ -0,0 +1,2112 @@
+// Code generated by gen.go from keysymdef.h; DO NOT EDIT.
+
+package keysym
+
+// keysymTable lists all the keysyms of keysymdef.h, in order.
+// unicode is only set for exact mappings, deprecated names are never returned by Name.
+var keysymTable = []keysymEntry{
+	{"VoidSymbol", 0xffffff, 0, false},
+	{"BackSpace", 0xff08, 0, false},
+	{"Tab", 0xff09, 0, false},
+	{"Linefeed", 0xff0a, 0, false},
+	{"Clear", 0xff0b, 0, false},
+	{"Return", 0xff0d, 0, false},
+	{"Pause", 0xff13, 0, false},
+	{"Scroll_Lock", 0xff14, 0, false},
+	{"Sys_Req", 0xff15, 0, false},
+	{"Escape", 0xff1b, 0, false},
+	{"Delete", 0xffff, 0, false},
+	{"Multi_key", 0xff20, 0, false},
+	{"Codeinput", 0xff37, 0, false},
+	{"SingleCandidate", 0xff3c, 0, false},
+	{"MultipleCandidate", 0xff3d, 0, false},
+	{"PreviousCandidate", 0xff3e, 0, false},
+	{"Kanji", 0xff21, 0, false},
+	{"Muhenkan", 0xff22, 0, false},
+	{"Henkan_Mode", 0xff23, 0, false},
+	{"Henkan", 0xff23, 0, false},
+	{"Romaji", 0xff24, 0, false},
+	{"Hiragana", 0xff25, 0, false},
+	{"Katakana", 0xff26, 0, false},
+	{"Hiragana_Katakana", 0xff27, 0, false},
+	{"Zenkaku", 0xff28, 0, false},
+	{"Hankaku", 0xff29, 0, false},
+	{"Zenkaku_Hankaku", 0xff2a, 0, false},
+	{"Touroku", 0xff2b, 0, false},
+	{"Massyo", 0xff2c, 0, false},
+	{"Kana_Lock", 0xff2d, 0, false},
+	{"Kana_Shift", 0xff2e, 0, false},
+	{"Eisu_Shift", 0xff2f, 0, false},
+	{"Eisu_toggle", 0xff30, 0, false},
+	{"Kanji_Bangou", 0xff37, 0, false},
+	{"Zen_Koho", 0xff3d, 0, false},
+	{"Mae_Koho", 0xff3e, 0, false},
+	{"Home", 0xff50, 0, false},
+	{"Left", 0xff51, 0, false},
+	{"Up", 0xff52, 0, false},
+	{"Right", 0xff53, 0, false},
+	{"Down", 0xff54, 0, false},
+	{"Prior", 0xff55, 0, false},
+	{"Page_Up", 0xff55, 0, false},
+	{"Next", 0xff56, 0, false},
+	{"Page_Down", 0xff56, 0, false},
+	{"End", 0xff57, 0, false},
+	{"Begin", 0xff58, 0, false},
+	{"Select", 0xff60, 0, false},
+	{"Print", 0xff61, 0, false},
+	{"Execute", 0xff62, 0, false},
+	{"Insert", 0xff63, 0, false},
+	{"Undo", 0xff65, 0, false},
+	{"Redo", 0xff66, 0, false},
+	{"Menu", 0xff67, 0, false},
+	{"Find", 0xff68, 0, false},
+	{"Cancel", 0xff69, 0, false},
+	{"Help", 0xff6a, 0, false},
+	{"Break", 0xff6b, 0, false},
+	{"Mode_switch", 0xff7e, 0, false},
+	{"script_switch", 0xff7e, 0, false},
+	{"Num_Lock", 0xff7f, 0, false},
+	{"KP_Space", 0xff80, 0, false},
+	{"KP_Tab", 0xff89, 0, false},
+	{"KP_Enter", 0xff8d, 0, false},
+	{"KP_F1", 0xff91, 0, false},
+	{"KP_F2", 0xff92, 0, false},
+	{"KP_F3", 0xff93, 0, false},
+	{"KP_F4", 0xff94, 0, false},
+	{"KP_Home", 0xff95, 0, false},
+	{"KP_Left", 0xff96, 0, false},
+	{"KP_Up", 0xff97, 0, false},
+	{"KP_Right", 0xff98, 0, false},
+	{"KP_Down", 0xff99, 0, false},
+	{"KP_Prior", 0xff9a, 0, false},
+	{"KP_Page_Up", 0xff9a, 0, false},
+	{"KP_Next", 0xff9b, 0, false},
+	{"KP_Page_Down", 0xff9b, 0, false},
+	{"KP_End", 0xff9c, 0, false},
+	{"KP_Begin", 0xff9d, 0, false},
+	{"KP_Insert", 0xff9e, 0, false},
+	{"KP_Delete", 0xff9f, 0, false},
+	{"KP_Equal", 0xffbd, 0, false},
+	{"KP_Multiply", 0xffaa, 0, false},
+	{"KP_Add", 0xffab, 0, false},
+	{"KP_Separator", 0xffac, 0, false},
+	{"KP_Subtract", 0xffad, 0, false},
+	{"KP_Decimal", 0xffae, 0, false},
+	{"KP_Divide", 0xffaf, 0, false},
+	{"KP_0", 0xffb0, 0, false},
+	{"KP_1", 0xffb1, 0, false},
+	{"KP_2", 0xffb2, 0, false},
+	{"KP_3", 0xffb3, 0, false},
+	{"KP_4", 0xffb4, 0, false},
+	{"KP_5", 0xffb5, 0, false},
+	{"KP_6", 0xffb6, 0, false},
+	{"KP_7", 0xffb7, 0, false},
+	{"KP_8", 0xffb8, 0, false},
+	{"KP_9", 0xffb9, 0, false},
+	{"F1", 0xffbe, 0, false},
+	{"F2", 0xffbf, 0, false},
+	{"F3", 0xffc0, 0, false},
+	{"F4", 0xffc1, 0, false},
+	{"F5", 0xffc2, 0, false},
+	{"F6", 0xffc3, 0, false},
+	{"F7", 0xffc4, 0, false},
+	{"F8", 0xffc5, 0, false},
+	{"F9", 0xffc6, 0, false},
+	{"F10", 0xffc7, 0, false},
+	{"F11", 0xffc8, 0, false},
+	{"L1", 0xffc8, 0, false},
+	{"F12", 0xffc9, 0, false},
+	{"L2", 0xffc9, 0, false},
+	{"F13", 0xffca, 0, false},
+	{"L3", 0xffca, 0, false},
+	{"F14", 0xffcb, 0, false},
+	{"L4", 0xffcb, 0, false},
+	{"F15", 0xffcc, 0, false},
+	{"L5", 0xffcc, 0, false},
+	{"F16", 0xffcd, 0, false},
+	{"L6", 0xffcd, 0, false},
+	{"F17", 0xffce, 0, false},
+	{"L7", 0xffce, 0, false},
+	{"F18", 0xffcf, 0, false},
+	{"L8", 0xffcf, 0, false},
+	{"F19", 0xffd0, 0, false},
+	{"L9", 0xffd0, 0, false},
+	{"F20", 0xffd1, 0, false},
+	{"L10", 0xffd1, 0, false},
+	{"F21", 0xffd2, 0, false},
+	{"R1", 0xffd2, 0, false},
+	{"F22", 0xffd3, 0, false},
+	{"R2", 0xffd3, 0, false},
+	{"F23", 0xffd4, 0, false},
+	{"R3", 0xffd4, 0, false},
+	{"F24", 0xffd5, 0, false},
+	{"R4", 0xffd5, 0, false},
+	{"F25", 0xffd6, 0, false},
+	{"R5", 0xffd6, 0, false},
+	{"F26", 0xffd7, 0, false},
+	{"R6", 0xffd7, 0, false},
+	{"F27", 0xffd8, 0, false},
+	{"R7", 0xffd8, 0, false},
+	{"F28", 0xffd9, 0, false},
+	{"R8", 0xffd9, 0, false},
+	{"F29", 0xffda, 0, false},
+	{"R9", 0xffda, 0, false},
+	{"F30", 0xffdb, 0, false},
+	{"R10", 0xffdb, 0, false},
+	{"F31", 0xffdc, 0, false},
+	{"R11", 0xffdc, 0, false},
+	{"F32", 0xffdd, 0, false},
+	{"R12", 0xffdd, 0, false},
+	{"F33", 0xffde, 0, false},
+	{"R13", 0xffde, 0, false},
+	{"F34", 0xffdf, 0, false},
+	{"R14", 0xffdf, 0, false},
+	{"F35", 0xffe0, 0, false},
+	{"R15", 0xffe0, 0, false},
+	{"Shift_L", 0xffe1, 0, false},
+	{"Shift_R", 0xffe2, 0, false},
+	{"Control_L", 0xffe3, 0, false},
+	{"Control_R", 0xffe4, 0, false},
+	{"Caps_Lock", 0xffe5, 0, false},
+	{"Shift_Lock", 0xffe6, 0, false},
+	{"Meta_L", 0xffe7, 0, false},
+	{"Meta_R", 0xffe8, 0, false},
+	{"Alt_L", 0xffe9, 0, false},
+	{"Alt_R", 0xffea, 0, false},
+	{"Super_L", 0xffeb, 0, false},
+	{"Super_R", 0xffec, 0, false},
+	{"Hyper_L", 0xffed, 0, false},
+	{"Hyper_R", 0xffee, 0, false},
+	{"ISO_Lock", 0xfe01, 0, false},
+	{"ISO_Level2_Latch", 0xfe02, 0, false},
+	{"ISO_Level3_Shift", 0xfe03, 0, false},
+	{"ISO_Level3_Latch", 0xfe04, 0, false},
+	{"ISO_Level3_Lock", 0xfe05, 0, false},
+	{"ISO_Level5_Shift", 0xfe11, 0, false},
+	{"ISO_Level5_Latch", 0xfe12, 0, false},
+	{"ISO_Level5_Lock", 0xfe13, 0, false},
+	{"ISO_Group_Shift", 0xff7e, 0, false},
+	{"ISO_Group_Latch", 0xfe06, 0, false},
+	{"ISO_Group_Lock", 0xfe07, 0, false},
+	{"ISO_Next_Group", 0xfe08, 0, false},
+	{"ISO_Next_Group_Lock", 0xfe09, 0, false},
+	{"ISO_Prev_Group", 0xfe0a, 0, false},
+	{"ISO_Prev_Group_Lock", 0xfe0b, 0, false},
+	{"ISO_First_Group", 0xfe0c, 0, false},
+	{"ISO_First_Group_Lock", 0xfe0d, 0, false},
+	{"ISO_Last_Group", 0xfe0e, 0, false},
+	{"ISO_Last_Group_Lock", 0xfe0f, 0, false},
+	{"ISO_Left_Tab", 0xfe20, 0, false},
+	{"ISO_Move_Line_Up", 0xfe21, 0, false},
+	{"ISO_Move_Line_Down", 0xfe22, 0, false},
+	{"ISO_Partial_Line_Up", 0xfe23, 0, false},
+	{"ISO_Partial_Line_Down", 0xfe24, 0, false},
+	{"ISO_Partial_Space_Left", 0xfe25, 0, false},
+	{"ISO_Partial_Space_Right", 0xfe26, 0, false},
+	{"ISO_Set_Margin_Left", 0xfe27, 0, false},
+	{"ISO_Set_Margin_Right", 0xfe28, 0, false},
+	{"ISO_Release_Margin_Left", 0xfe29, 0, false},
+	{"ISO_Release_Margin_Right", 0xfe2a, 0, false},
+	{"ISO_Release_Both_Margins", 0xfe2b, 0, false},
+	{"ISO_Fast_Cursor_Left", 0xfe2c, 0, false},
+	{"ISO_Fast_Cursor_Right", 0xfe2d, 0, false},
+	{"ISO_Fast_Cursor_Up", 0xfe2e, 0, false},
+	{"ISO_Fast_Cursor_Down", 0xfe2f, 0, false},
+	{"ISO_Continuous_Underline", 0xfe30, 0, false},
+	{"ISO_Discontinuous_Underline", 0xfe31, 0, false},
+	{"ISO_Emphasize", 0xfe32, 0, false},
+	{"ISO_Center_Object", 0xfe33, 0, false},
+	{"ISO_Enter", 0xfe34, 0, false},
+	{"dead_grave", 0xfe50, 0, false},
+	{"dead_acute", 0xfe51, 0, false},
+	{"dead_circumflex", 0xfe52, 0, false},
+	{"dead_tilde", 0xfe53, 0, false},
+	{"dead_perispomeni", 0xfe53, 0, false},
+	{"dead_macron", 0xfe54, 0, false},
+	{"dead_breve", 0xfe55, 0, false},
+	{"dead_abovedot", 0xfe56, 0, false},
+	{"dead_diaeresis", 0xfe57, 0, false},
+	{"dead_abovering", 0xfe58, 0, false},
+	{"dead_doubleacute", 0xfe59, 0, false},
+	{"dead_caron", 0xfe5a, 0, false},
+	{"dead_cedilla", 0xfe5b, 0, false},
+	{"dead_ogonek", 0xfe5c, 0, false},
+	{"dead_iota", 0xfe5d, 0, false},
+	{"dead_voiced_sound", 0xfe5e, 0, false},
+	{"dead_semivoiced_sound", 0xfe5f, 0, false},
+	{"dead_belowdot", 0xfe60, 0, false},
+	{"dead_hook", 0xfe61, 0, false},
+	{"dead_horn", 0xfe62, 0, false},
+	{"dead_stroke", 0xfe63, 0, false},
+	{"dead_abovecomma", 0xfe64, 0, false},
+	{"dead_psili", 0xfe64, 0, false},
+	{"dead_abovereversedcomma", 0xfe65, 0, false},
+	{"dead_dasia", 0xfe65, 0, false},
+	{"dead_doublegrave", 0xfe66, 0, false},
+	{"dead_belowring", 0xfe67, 0, false},
+	{"dead_belowmacron", 0xfe68, 0, false},
+	{"dead_belowcircumflex", 0xfe69, 0, false},
+	{"dead_belowtilde", 0xfe6a, 0, false},
+	{"dead_belowbreve", 0xfe6b, 0, false},
+	{"dead_belowdiaeresis", 0xfe6c, 0, false},
+	{"dead_invertedbreve", 0xfe6d, 0, false},
+	{"dead_belowcomma", 0xfe6e, 0, false},
+	{"dead_currency", 0xfe6f, 0, false},
+	{"dead_lowline", 0xfe90, 0, false},
+	{"dead_aboveverticalline", 0xfe91, 0, false},
+	{"dead_belowverticalline", 0xfe92, 0, false},
+	{"dead_longsolidusoverlay", 0xfe93, 0, false},
+	{"dead_a", 0xfe80, 0, false},
+	{"dead_A", 0xfe81, 0, false},
+	{"dead_e", 0xfe82, 0, false},
+	{"dead_E", 0xfe83, 0, false},
+	{"dead_i", 0xfe84, 0, false},
+	{"dead_I", 0xfe85, 0, false},
+	{"dead_o", 0xfe86, 0, false},
+	{"dead_O", 0xfe87, 0, false},
+	{"dead_u", 0xfe88, 0, false},
+	{"dead_U", 0xfe89, 0, false},
+	{"dead_small_schwa", 0xfe8a, 0, false},
+	{"dead_capital_schwa", 0xfe8b, 0, false},
+	{"dead_greek", 0xfe8c, 0, false},
+	{"First_Virtual_Screen", 0xfed0, 0, false},
+	{"Prev_Virtual_Screen", 0xfed1, 0, false},
+	{"Next_Virtual_Screen", 0xfed2, 0, false},
+	{"Last_Virtual_Screen", 0xfed4, 0, false},
+	{"Terminate_Server", 0xfed5, 0, false},
+	{"AccessX_Enable", 0xfe70, 0, false},
+	{"AccessX_Feedback_Enable", 0xfe71, 0, false},
+	{"RepeatKeys_Enable", 0xfe72, 0, false},
+	{"SlowKeys_Enable", 0xfe73, 0, false},
+	{"BounceKeys_Enable", 0xfe74, 0, false},
+	{"StickyKeys_Enable", 0xfe75, 0, false},
+	{"MouseKeys_Enable", 0xfe76, 0, false},
+	{"MouseKeys_Accel_Enable", 0xfe77, 0, false},
+	{"Overlay1_Enable", 0xfe78, 0, false},
+	{"Overlay2_Enable", 0xfe79, 0, false},
+	{"AudibleBell_Enable", 0xfe7a, 0, false},
+	{"Pointer_Left", 0xfee0, 0, false},
+	{"Pointer_Right", 0xfee1, 0, false},
+	{"Pointer_Up", 0xfee2, 0, false},
+	{"Pointer_Down", 0xfee3, 0, false},
+	{"Pointer_UpLeft", 0xfee4, 0, false},
+	{"Pointer_UpRight", 0xfee5, 0, false},
+	{"Pointer_DownLeft", 0xfee6, 0, false},
+	{"Pointer_DownRight", 0xfee7, 0, false},
+	{"Pointer_Button_Dflt", 0xfee8, 0, false},
+	{"Pointer_Button1", 0xfee9, 0, false},
+	{"Pointer_Button2", 0xfeea, 0, false},
+	{"Pointer_Button3", 0xfeeb, 0, false},
+	{"Pointer_Button4", 0xfeec, 0, false},
+	{"Pointer_Button5", 0xfeed, 0, false},
+	{"Pointer_DblClick_Dflt", 0xfeee, 0, false},
+	{"Pointer_DblClick1", 0xfeef, 0, false},
+	{"Pointer_DblClick2", 0xfef0, 0, false},
+	{"Pointer_DblClick3", 0xfef1, 0, false},
+	{"Pointer_DblClick4", 0xfef2, 0, false},
+	{"Pointer_DblClick5", 0xfef3, 0, false},
+	{"Pointer_Drag_Dflt", 0xfef4, 0, false},
+	{"Pointer_Drag1", 0xfef5, 0, false},
+	{"Pointer_Drag2", 0xfef6, 0, false},
+	{"Pointer_Drag3", 0xfef7, 0, false},
+	{"Pointer_Drag4", 0xfef8, 0, false},
+	{"Pointer_Drag5", 0xfefd, 0, false},
+	{"Pointer_EnableKeys", 0xfef9, 0, false},
+	{"Pointer_Accelerate", 0xfefa, 0, false},
+	{"Pointer_DfltBtnNext", 0xfefb, 0, false},
+	{"Pointer_DfltBtnPrev", 0xfefc, 0, false},
+	{"ch", 0xfea0, 0, false},
+	{"Ch", 0xfea1, 0, false},
+	{"CH", 0xfea2, 0, false},
+	{"c_h", 0xfea3, 0, false},
+	{"C_h", 0xfea4, 0, false},
+	{"C_H", 0xfea5, 0, false},
+	{"3270_Duplicate", 0xfd01, 0, false},
+	{"3270_FieldMark", 0xfd02, 0, false},
+	{"3270_Right2", 0xfd03, 0, false},
+	{"3270_Left2", 0xfd04, 0, false},
+	{"3270_BackTab", 0xfd05, 0, false},
+	{"3270_EraseEOF", 0xfd06, 0, false},
+	{"3270_EraseInput", 0xfd07, 0, false},
+	{"3270_Reset", 0xfd08, 0, false},
+	{"3270_Quit", 0xfd09, 0, false},
+	{"3270_PA1", 0xfd0a, 0, false},
+	{"3270_PA2", 0xfd0b, 0, false},
+	{"3270_PA3", 0xfd0c, 0, false},
+	{"3270_Test", 0xfd0d, 0, false},
+	{"3270_Attn", 0xfd0e, 0, false},
+	{"3270_CursorBlink", 0xfd0f, 0, false},
+	{"3270_AltCursor", 0xfd10, 0, false},
+	{"3270_KeyClick", 0xfd11, 0, false},
+	{"3270_Jump", 0xfd12, 0, false},
+	{"3270_Ident", 0xfd13, 0, false},
+	{"3270_Rule", 0xfd14, 0, false},
+	{"3270_Copy", 0xfd15, 0, false},
+	{"3270_Play", 0xfd16, 0, false},
+	{"3270_Setup", 0xfd17, 0, false},
+	{"3270_Record", 0xfd18, 0, false},
+	{"3270_ChangeScreen", 0xfd19, 0, false},
+	{"3270_DeleteWord", 0xfd1a, 0, false},
+	{"3270_ExSelect", 0xfd1b, 0, false},
+	{"3270_CursorSelect", 0xfd1c, 0, false},
+	{"3270_PrintScreen", 0xfd1d, 0, false},
+	{"3270_Enter", 0xfd1e, 0, false},
+	{"space", 0x20, 0x0020, false},
+	{"exclam", 0x21, 0x0021, false},
+	{"quotedbl", 0x22, 0x0022, false},
+	{"numbersign", 0x23, 0x0023, false},
+	{"dollar", 0x24, 0x0024, false},
+	{"percent", 0x25, 0x0025, false},
+	{"ampersand", 0x26, 0x0026, false},
+	{"apostrophe", 0x27, 0x0027, false},
+	{"quoteright", 0x27, 0, true},
+	{"parenleft", 0x28, 0x0028, false},
+	{"parenright", 0x29, 0x0029, false},
+	{"asterisk", 0x2a, 0x002A, false},
+	{"plus", 0x2b, 0x002B, false},
+	{"comma", 0x2c, 0x002C, false},
+	{"minus", 0x2d, 0x002D, false},
+	{"period", 0x2e, 0x002E, false},
+	{"slash", 0x2f, 0x002F, false},
+	{"0", 0x30, 0x0030, false},
+	{"1", 0x31, 0x0031, false},
+	{"2", 0x32, 0x0032, false},
+	{"3", 0x33, 0x0033, false},
+	{"4", 0x34, 0x0034, false},
+	{"5", 0x35, 0x0035, false},
+	{"6", 0x36, 0x0036, false},
+	{"7", 0x37, 0x0037, false},
+	{"8", 0x38, 0x0038, false},
+	{"9", 0x39, 0x0039, false},
+	{"colon", 0x3a, 0x003A, false},
+	{"semicolon", 0x3b, 0x003B, false},
+	{"less", 0x3c, 0x003C, false},
+	{"equal", 0x3d, 0x003D, false},
+	{"greater", 0x3e, 0x003E, false},
+	{"question", 0x3f, 0x003F, false},
+	{"at", 0x40, 0x0040, false},
+	{"A", 0x41, 0x0041, false},
+	{"B", 0x42, 0x0042, false},
+	{"C", 0x43, 0x0043, false},
+	{"D", 0x44, 0x0044, false},
+	{"E", 0x45, 0x0045, false},
+	{"F", 0x46, 0x0046, false},
+	{"G", 0x47, 0x0047, false},
+	{"H", 0x48, 0x0048, false},
+	{"I", 0x49, 0x0049, false},
+	{"J", 0x4a, 0x004A, false},
+	{"K", 0x4b, 0x004B, false},
+	{"L", 0x4c, 0x004C, false},
+	{"M", 0x4d, 0x004D, false},
+	{"N", 0x4e, 0x004E, false},
+	{"O", 0x4f, 0x004F, false},
+	{"P", 0x50, 0x0050, false},
+	{"Q", 0x51, 0x0051, false},
+	{"R", 0x52, 0x0052, false},
+	{"S", 0x53, 0x0053, false},
+	{"T", 0x54, 0x0054, false},
+	{"U", 0x55, 0x0055, false},
+	{"V", 0x56, 0x0056, false},
+	{"W", 0x57, 0x0057, false},
+	{"X", 0x58, 0x0058, false},
+	{"Y", 0x59, 0x0059, false},
+	{"Z", 0x5a, 0x005A, false},
+	{"bracketleft", 0x5b, 0x005B, false},
+	{"backslash", 0x5c, 0x005C, false},
+	{"bracketright", 0x5d, 0x005D, false},
+	{"asciicircum", 0x5e, 0x005E, false},
+	{"underscore", 0x5f, 0x005F, false},
+	{"grave", 0x60, 0x0060, false},
+	{"quoteleft", 0x60, 0, true},
+	{"a", 0x61, 0x0061, false},
+	{"b", 0x62, 0x0062, false},
+	{"c", 0x63, 0x0063, false},
+	{"d", 0x64, 0x0064, false},
+	{"e", 0x65, 0x0065, false},
+	{"f", 0x66, 0x0066, false},
+	{"g", 0x67, 0x0067, false},
+	{"h", 0x68, 0x0068, false},
+	{"i", 0x69, 0x0069, false},
+	{"j", 0x6a, 0x006A, false},
+	{"k", 0x6b, 0x006B, false},
+	{"l", 0x6c, 0x006C, false},
+	{"m", 0x6d, 0x006D, false},
+	{"n", 0x6e, 0x006E, false},
+	{"o", 0x6f, 0x006F, false},
+	{"p", 0x70, 0x0070, false},
+	{"q", 0x71, 0x0071, false},
+	{"r", 0x72, 0x0072, false},
+	{"s", 0x73, 0x0073, false},
+	{"t", 0x74, 0x0074, false},
+	{"u", 0x75, 0x0075, false},
+	{"v", 0x76, 0x0076, false},
+	{"w", 0x77, 0x0077, false},
+	{"x", 0x78, 0x0078, false},
+	{"y", 0x79, 0x0079, false},
+	{"z", 0x7a, 0x007A, false},
+	{"braceleft", 0x7b, 0x007B, false},
+	{"bar", 0x7c, 0x007C, false},
+	{"braceright", 0x7d, 0x007D, false},
+	{"asciitilde", 0x7e, 0x007E, false},
+	{"nobreakspace", 0xa0, 0x00A0, false},
+	{"exclamdown", 0xa1, 0x00A1, false},
+	{"cent", 0xa2, 0x00A2, false},
+	{"sterling", 0xa3, 0x00A3, false},
+	{"currency", 0xa4, 0x00A4, false},
+	{"yen", 0xa5, 0x00A5, false},
+	{"brokenbar", 0xa6, 0x00A6, false},
+	{"section", 0xa7, 0x00A7, false},
+	{"diaeresis", 0xa8, 0x00A8, false},
+	{"copyright", 0xa9, 0x00A9, false},
+	{"ordfeminine", 0xaa, 0x00AA, false},
+	{"guillemotleft", 0xab, 0x00AB, false},
+	{"notsign", 0xac, 0x00AC, false},
+	{"hyphen", 0xad, 0x00AD, false},
+	{"registered", 0xae, 0x00AE, false},
+	{"macron", 0xaf, 0x00AF, false},
+	{"degree", 0xb0, 0x00B0, false},
+	{"plusminus", 0xb1, 0x00B1, false},
+	{"twosuperior", 0xb2, 0x00B2, false},
+	{"threesuperior", 0xb3, 0x00B3, false},
+	{"acute", 0xb4, 0x00B4, false},
+	{"mu", 0xb5, 0x00B5, false},
+	{"paragraph", 0xb6, 0x00B6, false},
+	{"periodcentered", 0xb7, 0x00B7, false},
+	{"cedilla", 0xb8, 0x00B8, false},
+	{"onesuperior", 0xb9, 0x00B9, false},
+	{"masculine", 0xba, 0x00BA, false},
+	{"guillemotright", 0xbb, 0x00BB, false},
+	{"onequarter", 0xbc, 0x00BC, false},
+	{"onehalf", 0xbd, 0x00BD, false},
+	{"threequarters", 0xbe, 0x00BE, false},
+	{"questiondown", 0xbf, 0x00BF, false},
+	{"Agrave", 0xc0, 0x00C0, false},
+	{"Aacute", 0xc1, 0x00C1, false},
+	{"Acircumflex", 0xc2, 0x00C2, false},
+	{"Atilde", 0xc3, 0x00C3, false},
+	{"Adiaeresis", 0xc4, 0x00C4, false},
+	{"Aring", 0xc5, 0x00C5, false},
+	{"AE", 0xc6, 0x00C6, false},
+	{"Ccedilla", 0xc7, 0x00C7, false},
+	{"Egrave", 0xc8, 0x00C8, false},
+	{"Eacute", 0xc9, 0x00C9, false},
+	{"Ecircumflex", 0xca, 0x00CA, false},
+	{"Ediaeresis", 0xcb, 0x00CB, false},
+	{"Igrave", 0xcc, 0x00CC, false},
+	{"Iacute", 0xcd, 0x00CD, false},
+	{"Icircumflex", 0xce, 0x00CE, false},
+	{"Idiaeresis", 0xcf, 0x00CF, false},
+	{"ETH", 0xd0, 0x00D0, false},
+	{"Eth", 0xd0, 0, true},
+	{"Ntilde", 0xd1, 0x00D1, false},
+	{"Ograve", 0xd2, 0x00D2, false},
+	{"Oacute", 0xd3, 0x00D3, false},
+	{"Ocircumflex", 0xd4, 0x00D4, false},
+	{"Otilde", 0xd5, 0x00D5, false},
+	{"Odiaeresis", 0xd6, 0x00D6, false},
+	{"multiply", 0xd7, 0x00D7, false},
+	{"Oslash", 0xd8, 0x00D8, false},
+	{"Ooblique", 0xd8, 0x00D8, false},
+	{"Ugrave", 0xd9, 0x00D9, false},
+	{"Uacute", 0xda, 0x00DA, false},
+	{"Ucircumflex", 0xdb, 0x00DB, false},
+	{"Udiaeresis", 0xdc, 0x00DC, false},
+	{"Yacute", 0xdd, 0x00DD, false},
+	{"THORN", 0xde, 0x00DE, false},
+	{"Thorn", 0xde, 0, true},
+	{"ssharp", 0xdf, 0x00DF, false},
+	{"agrave", 0xe0, 0x00E0, false},
+	{"aacute", 0xe1, 0x00E1, false},
+	{"acircumflex", 0xe2, 0x00E2, false},
+	{"atilde", 0xe3, 0x00E3, false},
+	{"adiaeresis", 0xe4, 0x00E4, false},
+	{"aring", 0xe5, 0x00E5, false},
+	{"ae", 0xe6, 0x00E6, false},
+	{"ccedilla", 0xe7, 0x00E7, false},
+	{"egrave", 0xe8, 0x00E8, false},
+	{"eacute", 0xe9, 0x00E9, false},
+	{"ecircumflex", 0xea, 0x00EA, false},
+	{"ediaeresis", 0xeb, 0x00EB, false},
+	{"igrave", 0xec, 0x00EC, false},
+	{"iacute", 0xed, 0x00ED, false},
+	{"icircumflex", 0xee, 0x00EE, false},
+	{"idiaeresis", 0xef, 0x00EF, false},
+	{"eth", 0xf0, 0x00F0, false},
+	{"ntilde", 0xf1, 0x00F1, false},
+	{"ograve", 0xf2, 0x00F2, false},
+	{"oacute", 0xf3, 0x00F3, false},
+	{"ocircumflex", 0xf4, 0x00F4, false},
+	{"otilde", 0xf5, 0x00F5, false},
+	{"odiaeresis", 0xf6, 0x00F6, false},
+	{"division", 0xf7, 0x00F7, false},
+	{"oslash", 0xf8, 0x00F8, false},
+	{"ooblique", 0xf8, 0x00F8, false},
+	{"ugrave", 0xf9, 0x00F9, false},
+	{"uacute", 0xfa, 0x00FA, false},
+	{"ucircumflex", 0xfb, 0x00FB, false},
+	{"udiaeresis", 0xfc, 0x00FC, false},
+	{"yacute", 0xfd, 0x00FD, false},
+	{"thorn", 0xfe, 0x00FE, false},
+	{"ydiaeresis", 0xff, 0x00FF, false},
+	{"Aogonek", 0x1a1, 0x0104, false},
+	{"breve", 0x1a2, 0x02D8, false},
+	{"Lstroke", 0x1a3, 0x0141, false},
+	{"Lcaron", 0x1a5, 0x013D, false},
+	{"Sacute", 0x1a6, 0x015A, false},
+	{"Scaron", 0x1a9, 0x0160, false},
+	{"Scedilla", 0x1aa, 0x015E, false},
+	{"Tcaron", 0x1ab, 0x0164, false},
+	{"Zacute", 0x1ac, 0x0179, false},
+	{"Zcaron", 0x1ae, 0x017D, false},
+	{"Zabovedot", 0x1af, 0x017B, false},
+	{"aogonek", 0x1b1, 0x0105, false},
+	{"ogonek", 0x1b2, 0x02DB, false},
+	{"lstroke", 0x1b3, 0x0142, false},
+	{"lcaron", 0x1b5, 0x013E, false},
+	{"sacute", 0x1b6, 0x015B, false},
+	{"caron", 0x1b7, 0x02C7, false},
+	{"scaron", 0x1b9, 0x0161, false},
+	{"scedilla", 0x1ba, 0x015F, false},
+	{"tcaron", 0x1bb, 0x0165, false},
+	{"zacute", 0x1bc, 0x017A, false},
+	{"doubleacute", 0x1bd, 0x02DD, false},
+	{"zcaron", 0x1be, 0x017E, false},
+	{"zabovedot", 0x1bf, 0x017C, false},
+	{"Racute", 0x1c0, 0x0154, false},
+	{"Abreve", 0x1c3, 0x0102, false},
+	{"Lacute", 0x1c5, 0x0139, false},
+	{"Cacute", 0x1c6, 0x0106, false},
+	{"Ccaron", 0x1c8, 0x010C, false},
+	{"Eogonek", 0x1ca, 0x0118, false},
+	{"Ecaron", 0x1cc, 0x011A, false},
+	{"Dcaron", 0x1cf, 0x010E, false},
+	{"Dstroke", 0x1d0, 0x0110, false},
+	{"Nacute", 0x1d1, 0x0143, false},
+	{"Ncaron", 0x1d2, 0x0147, false},
+	{"Odoubleacute", 0x1d5, 0x0150, false},
+	{"Rcaron", 0x1d8, 0x0158, false},
+	{"Uring", 0x1d9, 0x016E, false},
+	{"Udoubleacute", 0x1db, 0x0170, false},
+	{"Tcedilla", 0x1de, 0x0162, false},
+	{"racute", 0x1e0, 0x0155, false},
+	{"abreve", 0x1e3, 0x0103, false},
+	{"lacute", 0x1e5, 0x013A, false},
+	{"cacute", 0x1e6, 0x0107, false},
+	{"ccaron", 0x1e8, 0x010D, false},
+	{"eogonek", 0x1ea, 0x0119, false},
+	{"ecaron", 0x1ec, 0x011B, false},
+	{"dcaron", 0x1ef, 0x010F, false},
+	{"dstroke", 0x1f0, 0x0111, false},
+	{"nacute", 0x1f1, 0x0144, false},
+	{"ncaron", 0x1f2, 0x0148, false},
+	{"odoubleacute", 0x1f5, 0x0151, false},
+	{"rcaron", 0x1f8, 0x0159, false},
+	{"uring", 0x1f9, 0x016F, false},
+	{"udoubleacute", 0x1fb, 0x0171, false},
+	{"tcedilla", 0x1fe, 0x0163, false},
+	{"abovedot", 0x1ff, 0x02D9, false},
+	{"Hstroke", 0x2a1, 0x0126, false},
+	{"Hcircumflex", 0x2a6, 0x0124, false},
+	{"Iabovedot", 0x2a9, 0x0130, false},
+	{"Gbreve", 0x2ab, 0x011E, false},
+	{"Jcircumflex", 0x2ac, 0x0134, false},
+	{"hstroke", 0x2b1, 0x0127, false},
+	{"hcircumflex", 0x2b6, 0x0125, false},
+	{"idotless", 0x2b9, 0x0131, false},
+	{"gbreve", 0x2bb, 0x011F, false},
+	{"jcircumflex", 0x2bc, 0x0135, false},
+	{"Cabovedot", 0x2c5, 0x010A, false},
+	{"Ccircumflex", 0x2c6, 0x0108, false},
+	{"Gabovedot", 0x2d5, 0x0120, false},
+	{"Gcircumflex", 0x2d8, 0x011C, false},
+	{"Ubreve", 0x2dd, 0x016C, false},
+	{"Scircumflex", 0x2de, 0x015C, false},
+	{"cabovedot", 0x2e5, 0x010B, false},
+	{"ccircumflex", 0x2e6, 0x0109, false},
+	{"gabovedot", 0x2f5, 0x0121, false},
+	{"gcircumflex", 0x2f8, 0x011D, false},
+	{"ubreve", 0x2fd, 0x016D, false},
+	{"scircumflex", 0x2fe, 0x015D, false},
+	{"kra", 0x3a2, 0x0138, false},
+	{"kappa", 0x3a2, 0, true},
+	{"Rcedilla", 0x3a3, 0x0156, false},
+	{"Itilde", 0x3a5, 0x0128, false},
+	{"Lcedilla", 0x3a6, 0x013B, false},
+	{"Emacron", 0x3aa, 0x0112, false},
+	{"Gcedilla", 0x3ab, 0x0122, false},
+	{"Tslash", 0x3ac, 0x0166, false},
+	{"rcedilla", 0x3b3, 0x0157, false},
+	{"itilde", 0x3b5, 0x0129, false},
+	{"lcedilla", 0x3b6, 0x013C, false},
+	{"emacron", 0x3ba, 0x0113, false},
+	{"gcedilla", 0x3bb, 0x0123, false},
+	{"tslash", 0x3bc, 0x0167, false},
+	{"ENG", 0x3bd, 0x014A, false},
+	{"eng", 0x3bf, 0x014B, false},
+	{"Amacron", 0x3c0, 0x0100, false},
+	{"Iogonek", 0x3c7, 0x012E, false},
+	{"Eabovedot", 0x3cc, 0x0116, false},
+	{"Imacron", 0x3cf, 0x012A, false},
+	{"Ncedilla", 0x3d1, 0x0145, false},
+	{"Omacron", 0x3d2, 0x014C, false},
+	{"Kcedilla", 0x3d3, 0x0136, false},
+	{"Uogonek", 0x3d9, 0x0172, false},
+	{"Utilde", 0x3dd, 0x0168, false},
+	{"Umacron", 0x3de, 0x016A, false},
+	{"amacron", 0x3e0, 0x0101, false},
+	{"iogonek", 0x3e7, 0x012F, false},
+	{"eabovedot", 0x3ec, 0x0117, false},
+	{"imacron", 0x3ef, 0x012B, false},
+	{"ncedilla", 0x3f1, 0x0146, false},
+	{"omacron", 0x3f2, 0x014D, false},
+	{"kcedilla", 0x3f3, 0x0137, false},
+	{"uogonek", 0x3f9, 0x0173, false},
+	{"utilde", 0x3fd, 0x0169, false},
+	{"umacron", 0x3fe, 0x016B, false},
+	{"Wcircumflex", 0x1000174, 0x0174, false},
+	{"wcircumflex", 0x1000175, 0x0175, false},
+	{"Ycircumflex", 0x1000176, 0x0176, false},
+	{"ycircumflex", 0x1000177, 0x0177, false},
+	{"Babovedot", 0x1001e02, 0x1E02, false},
+	{"babovedot", 0x1001e03, 0x1E03, false},
+	{"Dabovedot", 0x1001e0a, 0x1E0A, false},
+	{"dabovedot", 0x1001e0b, 0x1E0B, false},
+	{"Fabovedot", 0x1001e1e, 0x1E1E, false},
+	{"fabovedot", 0x1001e1f, 0x1E1F, false},
+	{"Mabovedot", 0x1001e40, 0x1E40, false},
+	{"mabovedot", 0x1001e41, 0x1E41, false},
+	{"Pabovedot", 0x1001e56, 0x1E56, false},
+	{"pabovedot", 0x1001e57, 0x1E57, false},
+	{"Sabovedot", 0x1001e60, 0x1E60, false},
+	{"sabovedot", 0x1001e61, 0x1E61, false},
+	{"Tabovedot", 0x1001e6a, 0x1E6A, false},
+	{"tabovedot", 0x1001e6b, 0x1E6B, false},
+	{"Wgrave", 0x1001e80, 0x1E80, false},
+	{"wgrave", 0x1001e81, 0x1E81, false},
+	{"Wacute", 0x1001e82, 0x1E82, false},
+	{"wacute", 0x1001e83, 0x1E83, false},
+	{"Wdiaeresis", 0x1001e84, 0x1E84, false},
+	{"wdiaeresis", 0x1001e85, 0x1E85, false},
+	{"Ygrave", 0x1001ef2, 0x1EF2, false},
+	{"ygrave", 0x1001ef3, 0x1EF3, false},
+	{"OE", 0x13bc, 0x0152, false},
+	{"oe", 0x13bd, 0x0153, false},
+	{"Ydiaeresis", 0x13be, 0x0178, false},
+	{"overline", 0x47e, 0x203E, false},
+	{"kana_fullstop", 0x4a1, 0x3002, false},
+	{"kana_openingbracket", 0x4a2, 0x300C, false},
+	{"kana_closingbracket", 0x4a3, 0x300D, false},
+	{"kana_comma", 0x4a4, 0x3001, false},
+	{"kana_conjunctive", 0x4a5, 0x30FB, false},
+	{"kana_middledot", 0x4a5, 0, true},
+	{"kana_WO", 0x4a6, 0x30F2, false},
+	{"kana_a", 0x4a7, 0x30A1, false},
+	{"kana_i", 0x4a8, 0x30A3, false},
+	{"kana_u", 0x4a9, 0x30A5, false},
+	{"kana_e", 0x4aa, 0x30A7, false},
+	{"kana_o", 0x4ab, 0x30A9, false},
+	{"kana_ya", 0x4ac, 0x30E3, false},
+	{"kana_yu", 0x4ad, 0x30E5, false},
+	{"kana_yo", 0x4ae, 0x30E7, false},
+	{"kana_tsu", 0x4af, 0x30C3, false},
+	{"kana_tu", 0x4af, 0, true},
+	{"prolongedsound", 0x4b0, 0x30FC, false},
+	{"kana_A", 0x4b1, 0x30A2, false},
+	{"kana_I", 0x4b2, 0x30A4, false},
+	{"kana_U", 0x4b3, 0x30A6, false},
+	{"kana_E", 0x4b4, 0x30A8, false},
+	{"kana_O", 0x4b5, 0x30AA, false},
+	{"kana_KA", 0x4b6, 0x30AB, false},
+	{"kana_KI", 0x4b7, 0x30AD, false},
+	{"kana_KU", 0x4b8, 0x30AF, false},
+	{"kana_KE", 0x4b9, 0x30B1, false},
+	{"kana_KO", 0x4ba, 0x30B3, false},
+	{"kana_SA", 0x4bb, 0x30B5, false},
+	{"kana_SHI", 0x4bc, 0x30B7, false},
+	{"kana_SU", 0x4bd, 0x30B9, false},
+	{"kana_SE", 0x4be, 0x30BB, false},
+	{"kana_SO", 0x4bf, 0x30BD, false},
+	{"kana_TA", 0x4c0, 0x30BF, false},
+	{"kana_CHI", 0x4c1, 0x30C1, false},
+	{"kana_TI", 0x4c1, 0, true},
+	{"kana_TSU", 0x4c2, 0x30C4, false},
+	{"kana_TU", 0x4c2, 0, true},
+	{"kana_TE", 0x4c3, 0x30C6, false},
+	{"kana_TO", 0x4c4, 0x30C8, false},
+	{"kana_NA", 0x4c5, 0x30CA, false},
+	{"kana_NI", 0x4c6, 0x30CB, false},
+	{"kana_NU", 0x4c7, 0x30CC, false},
+	{"kana_NE", 0x4c8, 0x30CD, false},
+	{"kana_NO", 0x4c9, 0x30CE, false},
+	{"kana_HA", 0x4ca, 0x30CF, false},
+	{"kana_HI", 0x4cb, 0x30D2, false},
+	{"kana_FU", 0x4cc, 0x30D5, false},
+	{"kana_HU", 0x4cc, 0, true},
+	{"kana_HE", 0x4cd, 0x30D8, false},
+	{"kana_HO", 0x4ce, 0x30DB, false},
+	{"kana_MA", 0x4cf, 0x30DE, false},
+	{"kana_MI", 0x4d0, 0x30DF, false},
+	{"kana_MU", 0x4d1, 0x30E0, false},
+	{"kana_ME", 0x4d2, 0x30E1, false},
+	{"kana_MO", 0x4d3, 0x30E2, false},
+	{"kana_YA", 0x4d4, 0x30E4, false},
+	{"kana_YU", 0x4d5, 0x30E6, false},
+	{"kana_YO", 0x4d6, 0x30E8, false},
+	{"kana_RA", 0x4d7, 0x30E9, false},
+	{"kana_RI", 0x4d8, 0x30EA, false},
+	{"kana_RU", 0x4d9, 0x30EB, false},
+	{"kana_RE", 0x4da, 0x30EC, false},
+	{"kana_RO", 0x4db, 0x30ED, false},
+	{"kana_WA", 0x4dc, 0x30EF, false},
+	{"kana_N", 0x4dd, 0x30F3, false},
+	{"voicedsound", 0x4de, 0x309B, false},
+	{"semivoicedsound", 0x4df, 0x309C, false},
+	{"kana_switch", 0xff7e, 0, false},
+	{"Farsi_0", 0x10006f0, 0x06F0, false},
+	{"Farsi_1", 0x10006f1, 0x06F1, false},
+	{"Farsi_2", 0x10006f2, 0x06F2, false},
+	{"Farsi_3", 0x10006f3, 0x06F3, false},
+	{"Farsi_4", 0x10006f4, 0x06F4, false},
+	{"Farsi_5", 0x10006f5, 0x06F5, false},
+	{"Farsi_6", 0x10006f6, 0x06F6, false},
+	{"Farsi_7", 0x10006f7, 0x06F7, false},
+	{"Farsi_8", 0x10006f8, 0x06F8, false},
+	{"Farsi_9", 0x10006f9, 0x06F9, false},
+	{"Arabic_percent", 0x100066a, 0x066A, false},
+	{"Arabic_superscript_alef", 0x1000670, 0x0670, false},
+	{"Arabic_tteh", 0x1000679, 0x0679, false},
+	{"Arabic_peh", 0x100067e, 0x067E, false},
+	{"Arabic_tcheh", 0x1000686, 0x0686, false},
+	{"Arabic_ddal", 0x1000688, 0x0688, false},
+	{"Arabic_rreh", 0x1000691, 0x0691, false},
+	{"Arabic_comma", 0x5ac, 0x060C, false},
+	{"Arabic_fullstop", 0x10006d4, 0x06D4, false},
+	{"Arabic_0", 0x1000660, 0x0660, false},
+	{"Arabic_1", 0x1000661, 0x0661, false},
+	{"Arabic_2", 0x1000662, 0x0662, false},
+	{"Arabic_3", 0x1000663, 0x0663, false},
+	{"Arabic_4", 0x1000664, 0x0664, false},
+	{"Arabic_5", 0x1000665, 0x0665, false},
+	{"Arabic_6", 0x1000666, 0x0666, false},
+	{"Arabic_7", 0x1000667, 0x0667, false},
+	{"Arabic_8", 0x1000668, 0x0668, false},
+	{"Arabic_9", 0x1000669, 0x0669, false},
+	{"Arabic_semicolon", 0x5bb, 0x061B, false},
+	{"Arabic_question_mark", 0x5bf, 0x061F, false},
+	{"Arabic_hamza", 0x5c1, 0x0621, false},
+	{"Arabic_maddaonalef", 0x5c2, 0x0622, false},
+	{"Arabic_hamzaonalef", 0x5c3, 0x0623, false},
+	{"Arabic_hamzaonwaw", 0x5c4, 0x0624, false},
+	{"Arabic_hamzaunderalef", 0x5c5, 0x0625, false},
+	{"Arabic_hamzaonyeh", 0x5c6, 0x0626, false},
+	{"Arabic_alef", 0x5c7, 0x0627, false},
+	{"Arabic_beh", 0x5c8, 0x0628, false},
+	{"Arabic_tehmarbuta", 0x5c9, 0x0629, false},
+	{"Arabic_teh", 0x5ca, 0x062A, false},
+	{"Arabic_theh", 0x5cb, 0x062B, false},
+	{"Arabic_jeem", 0x5cc, 0x062C, false},
+	{"Arabic_hah", 0x5cd, 0x062D, false},
+	{"Arabic_khah", 0x5ce, 0x062E, false},
+	{"Arabic_dal", 0x5cf, 0x062F, false},
+	{"Arabic_thal", 0x5d0, 0x0630, false},
+	{"Arabic_ra", 0x5d1, 0x0631, false},
+	{"Arabic_zain", 0x5d2, 0x0632, false},
+	{"Arabic_seen", 0x5d3, 0x0633, false},
+	{"Arabic_sheen", 0x5d4, 0x0634, false},
+	{"Arabic_sad", 0x5d5, 0x0635, false},
+	{"Arabic_dad", 0x5d6, 0x0636, false},
+	{"Arabic_tah", 0x5d7, 0x0637, false},
+	{"Arabic_zah", 0x5d8, 0x0638, false},
+	{"Arabic_ain", 0x5d9, 0x0639, false},
+	{"Arabic_ghain", 0x5da, 0x063A, false},
+	{"Arabic_tatweel", 0x5e0, 0x0640, false},
+	{"Arabic_feh", 0x5e1, 0x0641, false},
+	{"Arabic_qaf", 0x5e2, 0x0642, false},
+	{"Arabic_kaf", 0x5e3, 0x0643, false},
+	{"Arabic_lam", 0x5e4, 0x0644, false},
+	{"Arabic_meem", 0x5e5, 0x0645, false},
+	{"Arabic_noon", 0x5e6, 0x0646, false},
+	{"Arabic_ha", 0x5e7, 0x0647, false},
+	{"Arabic_heh", 0x5e7, 0, true},
+	{"Arabic_waw", 0x5e8, 0x0648, false},
+	{"Arabic_alefmaksura", 0x5e9, 0x0649, false},
+	{"Arabic_yeh", 0x5ea, 0x064A, false},
+	{"Arabic_fathatan", 0x5eb, 0x064B, false},
+	{"Arabic_dammatan", 0x5ec, 0x064C, false},
+	{"Arabic_kasratan", 0x5ed, 0x064D, false},
+	{"Arabic_fatha", 0x5ee, 0x064E, false},
+	{"Arabic_damma", 0x5ef, 0x064F, false},
+	{"Arabic_kasra", 0x5f0, 0x0650, false},
+	{"Arabic_shadda", 0x5f1, 0x0651, false},
+	{"Arabic_sukun", 0x5f2, 0x0652, false},
+	{"Arabic_madda_above", 0x1000653, 0x0653, false},
+	{"Arabic_hamza_above", 0x1000654, 0x0654, false},
+	{"Arabic_hamza_below", 0x1000655, 0x0655, false},
+	{"Arabic_jeh", 0x1000698, 0x0698, false},
+	{"Arabic_veh", 0x10006a4, 0x06A4, false},
+	{"Arabic_keheh", 0x10006a9, 0x06A9, false},
+	{"Arabic_gaf", 0x10006af, 0x06AF, false},
+	{"Arabic_noon_ghunna", 0x10006ba, 0x06BA, false},
+	{"Arabic_heh_doachashmee", 0x10006be, 0x06BE, false},
+	{"Farsi_yeh", 0x10006cc, 0x06CC, false},
+	{"Arabic_farsi_yeh", 0x10006cc, 0x06CC, false},
+	{"Arabic_yeh_baree", 0x10006d2, 0x06D2, false},
+	{"Arabic_heh_goal", 0x10006c1, 0x06C1, false},
+	{"Arabic_switch", 0xff7e, 0, false},
+	{"Cyrillic_GHE_bar", 0x1000492, 0x0492, false},
+	{"Cyrillic_ghe_bar", 0x1000493, 0x0493, false},
+	{"Cyrillic_ZHE_descender", 0x1000496, 0x0496, false},
+	{"Cyrillic_zhe_descender", 0x1000497, 0x0497, false},
+	{"Cyrillic_KA_descender", 0x100049a, 0x049A, false},
+	{"Cyrillic_ka_descender", 0x100049b, 0x049B, false},
+	{"Cyrillic_KA_vertstroke", 0x100049c, 0x049C, false},
+	{"Cyrillic_ka_vertstroke", 0x100049d, 0x049D, false},
+	{"Cyrillic_EN_descender", 0x10004a2, 0x04A2, false},
+	{"Cyrillic_en_descender", 0x10004a3, 0x04A3, false},
+	{"Cyrillic_U_straight", 0x10004ae, 0x04AE, false},
+	{"Cyrillic_u_straight", 0x10004af, 0x04AF, false},
+	{"Cyrillic_U_straight_bar", 0x10004b0, 0x04B0, false},
+	{"Cyrillic_u_straight_bar", 0x10004b1, 0x04B1, false},
+	{"Cyrillic_HA_descender", 0x10004b2, 0x04B2, false},
+	{"Cyrillic_ha_descender", 0x10004b3, 0x04B3, false},
+	{"Cyrillic_CHE_descender", 0x10004b6, 0x04B6, false},
+	{"Cyrillic_che_descender", 0x10004b7, 0x04B7, false},
+	{"Cyrillic_CHE_vertstroke", 0x10004b8, 0x04B8, false},
+	{"Cyrillic_che_vertstroke", 0x10004b9, 0x04B9, false},
+	{"Cyrillic_SHHA", 0x10004ba, 0x04BA, false},
+	{"Cyrillic_shha", 0x10004bb, 0x04BB, false},
+	{"Cyrillic_SCHWA", 0x10004d8, 0x04D8, false},
+	{"Cyrillic_schwa", 0x10004d9, 0x04D9, false},
+	{"Cyrillic_I_macron", 0x10004e2, 0x04E2, false},
+	{"Cyrillic_i_macron", 0x10004e3, 0x04E3, false},
+	{"Cyrillic_O_bar", 0x10004e8, 0x04E8, false},
+	{"Cyrillic_o_bar", 0x10004e9, 0x04E9, false},
+	{"Cyrillic_U_macron", 0x10004ee, 0x04EE, false},
+	{"Cyrillic_u_macron", 0x10004ef, 0x04EF, false},
+	{"Serbian_dje", 0x6a1, 0x0452, false},
+	{"Macedonia_gje", 0x6a2, 0x0453, false},
+	{"Cyrillic_io", 0x6a3, 0x0451, false},
+	{"Ukrainian_ie", 0x6a4, 0x0454, false},
+	{"Ukranian_je", 0x6a4, 0, true},
+	{"Macedonia_dse", 0x6a5, 0x0455, false},
+	{"Ukrainian_i", 0x6a6, 0x0456, false},
+	{"Ukranian_i", 0x6a6, 0, true},
+	{"Ukrainian_yi", 0x6a7, 0x0457, false},
+	{"Ukranian_yi", 0x6a7, 0, true},
+	{"Cyrillic_je", 0x6a8, 0x0458, false},
+	{"Serbian_je", 0x6a8, 0, true},
+	{"Cyrillic_lje", 0x6a9, 0x0459, false},
+	{"Serbian_lje", 0x6a9, 0, true},
+	{"Cyrillic_nje", 0x6aa, 0x045A, false},
+	{"Serbian_nje", 0x6aa, 0, true},
+	{"Serbian_tshe", 0x6ab, 0x045B, false},
+	{"Macedonia_kje", 0x6ac, 0x045C, false},
+	{"Ukrainian_ghe_with_upturn", 0x6ad, 0x0491, false},
+	{"Byelorussian_shortu", 0x6ae, 0x045E, false},
+	{"Cyrillic_dzhe", 0x6af, 0x045F, false},
+	{"Serbian_dze", 0x6af, 0, true},
+	{"numerosign", 0x6b0, 0x2116, false},
+	{"Serbian_DJE", 0x6b1, 0x0402, false},
+	{"Macedonia_GJE", 0x6b2, 0x0403, false},
+	{"Cyrillic_IO", 0x6b3, 0x0401, false},
+	{"Ukrainian_IE", 0x6b4, 0x0404, false},
+	{"Ukranian_JE", 0x6b4, 0, true},
+	{"Macedonia_DSE", 0x6b5, 0x0405, false},
+	{"Ukrainian_I", 0x6b6, 0x0406, false},
+	{"Ukranian_I", 0x6b6, 0, true},
+	{"Ukrainian_YI", 0x6b7, 0x0407, false},
+	{"Ukranian_YI", 0x6b7, 0, true},
+	{"Cyrillic_JE", 0x6b8, 0x0408, false},
+	{"Serbian_JE", 0x6b8, 0, true},
+	{"Cyrillic_LJE", 0x6b9, 0x0409, false},
+	{"Serbian_LJE", 0x6b9, 0, true},
+	{"Cyrillic_NJE", 0x6ba, 0x040A, false},
+	{"Serbian_NJE", 0x6ba, 0, true},
+	{"Serbian_TSHE", 0x6bb, 0x040B, false},
+	{"Macedonia_KJE", 0x6bc, 0x040C, false},
+	{"Ukrainian_GHE_WITH_UPTURN", 0x6bd, 0x0490, false},
+	{"Byelorussian_SHORTU", 0x6be, 0x040E, false},
+	{"Cyrillic_DZHE", 0x6bf, 0x040F, false},
+	{"Serbian_DZE", 0x6bf, 0, true},
+	{"Cyrillic_yu", 0x6c0, 0x044E, false},
+	{"Cyrillic_a", 0x6c1, 0x0430, false},
+	{"Cyrillic_be", 0x6c2, 0x0431, false},
+	{"Cyrillic_tse", 0x6c3, 0x0446, false},
+	{"Cyrillic_de", 0x6c4, 0x0434, false},
+	{"Cyrillic_ie", 0x6c5, 0x0435, false},
+	{"Cyrillic_ef", 0x6c6, 0x0444, false},
+	{"Cyrillic_ghe", 0x6c7, 0x0433, false},
+	{"Cyrillic_ha", 0x6c8, 0x0445, false},
+	{"Cyrillic_i", 0x6c9, 0x0438, false},
+	{"Cyrillic_shorti", 0x6ca, 0x0439, false},
+	{"Cyrillic_ka", 0x6cb, 0x043A, false},
+	{"Cyrillic_el", 0x6cc, 0x043B, false},
+	{"Cyrillic_em", 0x6cd, 0x043C, false},
+	{"Cyrillic_en", 0x6ce, 0x043D, false},
+	{"Cyrillic_o", 0x6cf, 0x043E, false},
+	{"Cyrillic_pe", 0x6d0, 0x043F, false},
+	{"Cyrillic_ya", 0x6d1, 0x044F, false},
+	{"Cyrillic_er", 0x6d2, 0x0440, false},
+	{"Cyrillic_es", 0x6d3, 0x0441, false},
+	{"Cyrillic_te", 0x6d4, 0x0442, false},
+	{"Cyrillic_u", 0x6d5, 0x0443, false},
+	{"Cyrillic_zhe", 0x6d6, 0x0436, false},
+	{"Cyrillic_ve", 0x6d7, 0x0432, false},
+	{"Cyrillic_softsign", 0x6d8, 0x044C, false},
+	{"Cyrillic_yeru", 0x6d9, 0x044B, false},
+	{"Cyrillic_ze", 0x6da, 0x0437, false},
+	{"Cyrillic_sha", 0x6db, 0x0448, false},
+	{"Cyrillic_e", 0x6dc, 0x044D, false},
+	{"Cyrillic_shcha", 0x6dd, 0x0449, false},
+	{"Cyrillic_che", 0x6de, 0x0447, false},
+	{"Cyrillic_hardsign", 0x6df, 0x044A, false},
+	{"Cyrillic_YU", 0x6e0, 0x042E, false},
+	{"Cyrillic_A", 0x6e1, 0x0410, false},
+	{"Cyrillic_BE", 0x6e2, 0x0411, false},
+	{"Cyrillic_TSE", 0x6e3, 0x0426, false},
+	{"Cyrillic_DE", 0x6e4, 0x0414, false},
+	{"Cyrillic_IE", 0x6e5, 0x0415, false},
+	{"Cyrillic_EF", 0x6e6, 0x0424, false},
+	{"Cyrillic_GHE", 0x6e7, 0x0413, false},
+	{"Cyrillic_HA", 0x6e8, 0x0425, false},
+	{"Cyrillic_I", 0x6e9, 0x0418, false},
+	{"Cyrillic_SHORTI", 0x6ea, 0x0419, false},
+	{"Cyrillic_KA", 0x6eb, 0x041A, false},
+	{"Cyrillic_EL", 0x6ec, 0x041B, false},
+	{"Cyrillic_EM", 0x6ed, 0x041C, false},
+	{"Cyrillic_EN", 0x6ee, 0x041D, false},
+	{"Cyrillic_O", 0x6ef, 0x041E, false},
+	{"Cyrillic_PE", 0x6f0, 0x041F, false},
+	{"Cyrillic_YA", 0x6f1, 0x042F, false},
+	{"Cyrillic_ER", 0x6f2, 0x0420, false},
+	{"Cyrillic_ES", 0x6f3, 0x0421, false},
+	{"Cyrillic_TE", 0x6f4, 0x0422, false},
+	{"Cyrillic_U", 0x6f5, 0x0423, false},
+	{"Cyrillic_ZHE", 0x6f6, 0x0416, false},
+	{"Cyrillic_VE", 0x6f7, 0x0412, false},
+	{"Cyrillic_SOFTSIGN", 0x6f8, 0x042C, false},
+	{"Cyrillic_YERU", 0x6f9, 0x042B, false},
+	{"Cyrillic_ZE", 0x6fa, 0x0417, false},
+	{"Cyrillic_SHA", 0x6fb, 0x0428, false},
+	{"Cyrillic_E", 0x6fc, 0x042D, false},
+	{"Cyrillic_SHCHA", 0x6fd, 0x0429, false},
+	{"Cyrillic_CHE", 0x6fe, 0x0427, false},
+	{"Cyrillic_HARDSIGN", 0x6ff, 0x042A, false},
+	{"Greek_ALPHAaccent", 0x7a1, 0x0386, false},
+	{"Greek_EPSILONaccent", 0x7a2, 0x0388, false},
+	{"Greek_ETAaccent", 0x7a3, 0x0389, false},
+	{"Greek_IOTAaccent", 0x7a4, 0x038A, false},
+	{"Greek_IOTAdieresis", 0x7a5, 0x03AA, false},
+	{"Greek_IOTAdiaeresis", 0x7a5, 0, false},
+	{"Greek_OMICRONaccent", 0x7a7, 0x038C, false},
+	{"Greek_UPSILONaccent", 0x7a8, 0x038E, false},
+	{"Greek_UPSILONdieresis", 0x7a9, 0x03AB, false},
+	{"Greek_OMEGAaccent", 0x7ab, 0x038F, false},
+	{"Greek_accentdieresis", 0x7ae, 0x0385, false},
+	{"Greek_horizbar", 0x7af, 0x2015, false},
+	{"Greek_alphaaccent", 0x7b1, 0x03AC, false},
+	{"Greek_epsilonaccent", 0x7b2, 0x03AD, false},
+	{"Greek_etaaccent", 0x7b3, 0x03AE, false},
+	{"Greek_iotaaccent", 0x7b4, 0x03AF, false},
+	{"Greek_iotadieresis", 0x7b5, 0x03CA, false},
+	{"Greek_iotaaccentdieresis", 0x7b6, 0x0390, false},
+	{"Greek_omicronaccent", 0x7b7, 0x03CC, false},
+	{"Greek_upsilonaccent", 0x7b8, 0x03CD, false},
+	{"Greek_upsilondieresis", 0x7b9, 0x03CB, false},
+	{"Greek_upsilonaccentdieresis", 0x7ba, 0x03B0, false},
+	{"Greek_omegaaccent", 0x7bb, 0x03CE, false},
+	{"Greek_ALPHA", 0x7c1, 0x0391, false},
+	{"Greek_BETA", 0x7c2, 0x0392, false},
+	{"Greek_GAMMA", 0x7c3, 0x0393, false},
+	{"Greek_DELTA", 0x7c4, 0x0394, false},
+	{"Greek_EPSILON", 0x7c5, 0x0395, false},
+	{"Greek_ZETA", 0x7c6, 0x0396, false},
+	{"Greek_ETA", 0x7c7, 0x0397, false},
+	{"Greek_THETA", 0x7c8, 0x0398, false},
+	{"Greek_IOTA", 0x7c9, 0x0399, false},
+	{"Greek_KAPPA", 0x7ca, 0x039A, false},
+	{"Greek_LAMDA", 0x7cb, 0x039B, false},
+	{"Greek_LAMBDA", 0x7cb, 0x039B, false},
+	{"Greek_MU", 0x7cc, 0x039C, false},
+	{"Greek_NU", 0x7cd, 0x039D, false},
+	{"Greek_XI", 0x7ce, 0x039E, false},
+	{"Greek_OMICRON", 0x7cf, 0x039F, false},
+	{"Greek_PI", 0x7d0, 0x03A0, false},
+	{"Greek_RHO", 0x7d1, 0x03A1, false},
+	{"Greek_SIGMA", 0x7d2, 0x03A3, false},
+	{"Greek_TAU", 0x7d4, 0x03A4, false},
+	{"Greek_UPSILON", 0x7d5, 0x03A5, false},
+	{"Greek_PHI", 0x7d6, 0x03A6, false},
+	{"Greek_CHI", 0x7d7, 0x03A7, false},
+	{"Greek_PSI", 0x7d8, 0x03A8, false},
+	{"Greek_OMEGA", 0x7d9, 0x03A9, false},
+	{"Greek_alpha", 0x7e1, 0x03B1, false},
+	{"Greek_beta", 0x7e2, 0x03B2, false},
+	{"Greek_gamma", 0x7e3, 0x03B3, false},
+	{"Greek_delta", 0x7e4, 0x03B4, false},
+	{"Greek_epsilon", 0x7e5, 0x03B5, false},
+	{"Greek_zeta", 0x7e6, 0x03B6, false},
+	{"Greek_eta", 0x7e7, 0x03B7, false},
+	{"Greek_theta", 0x7e8, 0x03B8, false},
+	{"Greek_iota", 0x7e9, 0x03B9, false},
+	{"Greek_kappa", 0x7ea, 0x03BA, false},
+	{"Greek_lamda", 0x7eb, 0x03BB, false},
+	{"Greek_lambda", 0x7eb, 0x03BB, false},
+	{"Greek_mu", 0x7ec, 0x03BC, false},
+	{"Greek_nu", 0x7ed, 0x03BD, false},
+	{"Greek_xi", 0x7ee, 0x03BE, false},
+	{"Greek_omicron", 0x7ef, 0x03BF, false},
+	{"Greek_pi", 0x7f0, 0x03C0, false},
+	{"Greek_rho", 0x7f1, 0x03C1, false},
+	{"Greek_sigma", 0x7f2, 0x03C3, false},
+	{"Greek_finalsmallsigma", 0x7f3, 0x03C2, false},
+	{"Greek_tau", 0x7f4, 0x03C4, false},
+	{"Greek_upsilon", 0x7f5, 0x03C5, false},
+	{"Greek_phi", 0x7f6, 0x03C6, false},
+	{"Greek_chi", 0x7f7, 0x03C7, false},
+	{"Greek_psi", 0x7f8, 0x03C8, false},
+	{"Greek_omega", 0x7f9, 0x03C9, false},
+	{"Greek_switch", 0xff7e, 0, false},
+	{"leftradical", 0x8a1, 0x23B7, false},
+	{"topleftradical", 0x8a2, 0, false},
+	{"horizconnector", 0x8a3, 0, false},
+	{"topintegral", 0x8a4, 0x2320, false},
+	{"botintegral", 0x8a5, 0x2321, false},
+	{"vertconnector", 0x8a6, 0, false},
+	{"topleftsqbracket", 0x8a7, 0x23A1, false},
+	{"botleftsqbracket", 0x8a8, 0x23A3, false},
+	{"toprightsqbracket", 0x8a9, 0x23A4, false},
+	{"botrightsqbracket", 0x8aa, 0x23A6, false},
+	{"topleftparens", 0x8ab, 0x239B, false},
+	{"botleftparens", 0x8ac, 0x239D, false},
+	{"toprightparens", 0x8ad, 0x239E, false},
+	{"botrightparens", 0x8ae, 0x23A0, false},
+	{"leftmiddlecurlybrace", 0x8af, 0x23A8, false},
+	{"rightmiddlecurlybrace", 0x8b0, 0x23AC, false},
+	{"topleftsummation", 0x8b1, 0, false},
+	{"botleftsummation", 0x8b2, 0, false},
+	{"topvertsummationconnector", 0x8b3, 0, false},
+	{"botvertsummationconnector", 0x8b4, 0, false},
+	{"toprightsummation", 0x8b5, 0, false},
+	{"botrightsummation", 0x8b6, 0, false},
+	{"rightmiddlesummation", 0x8b7, 0, false},
+	{"lessthanequal", 0x8bc, 0x2264, false},
+	{"notequal", 0x8bd, 0x2260, false},
+	{"greaterthanequal", 0x8be, 0x2265, false},
+	{"integral", 0x8bf, 0x222B, false},
+	{"therefore", 0x8c0, 0x2234, false},
+	{"variation", 0x8c1, 0x221D, false},
+	{"infinity", 0x8c2, 0x221E, false},
+	{"nabla", 0x8c5, 0x2207, false},
+	{"approximate", 0x8c8, 0x223C, false},
+	{"similarequal", 0x8c9, 0x2243, false},
+	{"ifonlyif", 0x8cd, 0x21D4, false},
+	{"implies", 0x8ce, 0x21D2, false},
+	{"identical", 0x8cf, 0x2261, false},
+	{"radical", 0x8d6, 0x221A, false},
+	{"includedin", 0x8da, 0x2282, false},
+	{"includes", 0x8db, 0x2283, false},
+	{"intersection", 0x8dc, 0x2229, false},
+	{"union", 0x8dd, 0x222A, false},
+	{"logicaland", 0x8de, 0x2227, false},
+	{"logicalor", 0x8df, 0x2228, false},
+	{"partialderivative", 0x8ef, 0x2202, false},
+	{"function", 0x8f6, 0x0192, false},
+	{"leftarrow", 0x8fb, 0x2190, false},
+	{"uparrow", 0x8fc, 0x2191, false},
+	{"rightarrow", 0x8fd, 0x2192, false},
+	{"downarrow", 0x8fe, 0x2193, false},
+	{"blank", 0x9df, 0, false},
+	{"soliddiamond", 0x9e0, 0x25C6, false},
+	{"checkerboard", 0x9e1, 0x2592, false},
+	{"ht", 0x9e2, 0x2409, false},
+	{"ff", 0x9e3, 0x240C, false},
+	{"cr", 0x9e4, 0x240D, false},
+	{"lf", 0x9e5, 0x240A, false},
+	{"nl", 0x9e8, 0x2424, false},
+	{"vt", 0x9e9, 0x240B, false},
+	{"lowrightcorner", 0x9ea, 0x2518, false},
+	{"uprightcorner", 0x9eb, 0x2510, false},
+	{"upleftcorner", 0x9ec, 0x250C, false},
+	{"lowleftcorner", 0x9ed, 0x2514, false},
+	{"crossinglines", 0x9ee, 0x253C, false},
+	{"horizlinescan1", 0x9ef, 0x23BA, false},
+	{"horizlinescan3", 0x9f0, 0x23BB, false},
+	{"horizlinescan5", 0x9f1, 0x2500, false},
+	{"horizlinescan7", 0x9f2, 0x23BC, false},
+	{"horizlinescan9", 0x9f3, 0x23BD, false},
+	{"leftt", 0x9f4, 0x251C, false},
+	{"rightt", 0x9f5, 0x2524, false},
+	{"bott", 0x9f6, 0x2534, false},
+	{"topt", 0x9f7, 0x252C, false},
+	{"vertbar", 0x9f8, 0x2502, false},
+	{"emspace", 0xaa1, 0x2003, false},
+	{"enspace", 0xaa2, 0x2002, false},
+	{"em3space", 0xaa3, 0x2004, false},
+	{"em4space", 0xaa4, 0x2005, false},
+	{"digitspace", 0xaa5, 0x2007, false},
+	{"punctspace", 0xaa6, 0x2008, false},
+	{"thinspace", 0xaa7, 0x2009, false},
+	{"hairspace", 0xaa8, 0x200A, false},
+	{"emdash", 0xaa9, 0x2014, false},
+	{"endash", 0xaaa, 0x2013, false},
+	{"signifblank", 0xaac, 0, false},
+	{"ellipsis", 0xaae, 0x2026, false},
+	{"doubbaselinedot", 0xaaf, 0x2025, false},
+	{"onethird", 0xab0, 0x2153, false},
+	{"twothirds", 0xab1, 0x2154, false},
+	{"onefifth", 0xab2, 0x2155, false},
+	{"twofifths", 0xab3, 0x2156, false},
+	{"threefifths", 0xab4, 0x2157, false},
+	{"fourfifths", 0xab5, 0x2158, false},
+	{"onesixth", 0xab6, 0x2159, false},
+	{"fivesixths", 0xab7, 0x215A, false},
+	{"careof", 0xab8, 0x2105, false},
+	{"figdash", 0xabb, 0x2012, false},
+	{"leftanglebracket", 0xabc, 0, false},
+	{"decimalpoint", 0xabd, 0, false},
+	{"rightanglebracket", 0xabe, 0, false},
+	{"marker", 0xabf, 0, false},
+	{"oneeighth", 0xac3, 0x215B, false},
+	{"threeeighths", 0xac4, 0x215C, false},
+	{"fiveeighths", 0xac5, 0x215D, false},
+	{"seveneighths", 0xac6, 0x215E, false},
+	{"trademark", 0xac9, 0x2122, false},
+	{"signaturemark", 0xaca, 0, false},
+	{"trademarkincircle", 0xacb, 0, false},
+	{"leftopentriangle", 0xacc, 0, false},
+	{"rightopentriangle", 0xacd, 0, false},
+	{"emopencircle", 0xace, 0, false},
+	{"emopenrectangle", 0xacf, 0, false},
+	{"leftsinglequotemark", 0xad0, 0x2018, false},
+	{"rightsinglequotemark", 0xad1, 0x2019, false},
+	{"leftdoublequotemark", 0xad2, 0x201C, false},
+	{"rightdoublequotemark", 0xad3, 0x201D, false},
+	{"prescription", 0xad4, 0x211E, false},
+	{"permille", 0xad5, 0x2030, false},
+	{"minutes", 0xad6, 0x2032, false},
+	{"seconds", 0xad7, 0x2033, false},
+	{"latincross", 0xad9, 0x271D, false},
+	{"hexagram", 0xada, 0, false},
+	{"filledrectbullet", 0xadb, 0, false},
+	{"filledlefttribullet", 0xadc, 0, false},
+	{"filledrighttribullet", 0xadd, 0, false},
+	{"emfilledcircle", 0xade, 0, false},
+	{"emfilledrect", 0xadf, 0, false},
+	{"enopencircbullet", 0xae0, 0, false},
+	{"enopensquarebullet", 0xae1, 0, false},
+	{"openrectbullet", 0xae2, 0, false},
+	{"opentribulletup", 0xae3, 0, false},
+	{"opentribulletdown", 0xae4, 0, false},
+	{"openstar", 0xae5, 0, false},
+	{"enfilledcircbullet", 0xae6, 0, false},
+	{"enfilledsqbullet", 0xae7, 0, false},
+	{"filledtribulletup", 0xae8, 0, false},
+	{"filledtribulletdown", 0xae9, 0, false},
+	{"leftpointer", 0xaea, 0, false},
+	{"rightpointer", 0xaeb, 0, false},
+	{"club", 0xaec, 0x2663, false},
+	{"diamond", 0xaed, 0x2666, false},
+	{"heart", 0xaee, 0x2665, false},
+	{"maltesecross", 0xaf0, 0x2720, false},
+	{"dagger", 0xaf1, 0x2020, false},
+	{"doubledagger", 0xaf2, 0x2021, false},
+	{"checkmark", 0xaf3, 0x2713, false},
+	{"ballotcross", 0xaf4, 0x2717, false},
+	{"musicalsharp", 0xaf5, 0x266F, false},
+	{"musicalflat", 0xaf6, 0x266D, false},
+	{"malesymbol", 0xaf7, 0x2642, false},
+	{"femalesymbol", 0xaf8, 0x2640, false},
+	{"telephone", 0xaf9, 0x260E, false},
+	{"telephonerecorder", 0xafa, 0x2315, false},
+	{"phonographcopyright", 0xafb, 0x2117, false},
+	{"caret", 0xafc, 0x2038, false},
+	{"singlelowquotemark", 0xafd, 0x201A, false},
+	{"doublelowquotemark", 0xafe, 0x201E, false},
+	{"cursor", 0xaff, 0, false},
+	{"leftcaret", 0xba3, 0, false},
+	{"rightcaret", 0xba6, 0, false},
+	{"downcaret", 0xba8, 0, false},
+	{"upcaret", 0xba9, 0, false},
+	{"overbar", 0xbc0, 0, false},
+	{"downtack", 0xbc2, 0x22A4, false},
+	{"upshoe", 0xbc3, 0, false},
+	{"downstile", 0xbc4, 0x230A, false},
+	{"underbar", 0xbc6, 0, false},
+	{"jot", 0xbca, 0x2218, false},
+	{"quad", 0xbcc, 0x2395, false},
+	{"uptack", 0xbce, 0x22A5, false},
+	{"circle", 0xbcf, 0x25CB, false},
+	{"upstile", 0xbd3, 0x2308, false},
+	{"downshoe", 0xbd6, 0, false},
+	{"rightshoe", 0xbd8, 0, false},
+	{"leftshoe", 0xbda, 0, false},
+	{"lefttack", 0xbdc, 0x22A3, false},
+	{"righttack", 0xbfc, 0x22A2, false},
+	{"hebrew_doublelowline", 0xcdf, 0x2017, false},
+	{"hebrew_aleph", 0xce0, 0x05D0, false},
+	{"hebrew_bet", 0xce1, 0x05D1, false},
+	{"hebrew_beth", 0xce1, 0, true},
+	{"hebrew_gimel", 0xce2, 0x05D2, false},
+	{"hebrew_gimmel", 0xce2, 0, true},
+	{"hebrew_dalet", 0xce3, 0x05D3, false},
+	{"hebrew_daleth", 0xce3, 0, true},
+	{"hebrew_he", 0xce4, 0x05D4, false},
+	{"hebrew_waw", 0xce5, 0x05D5, false},
+	{"hebrew_zain", 0xce6, 0x05D6, false},
+	{"hebrew_zayin", 0xce6, 0, true},
+	{"hebrew_chet", 0xce7, 0x05D7, false},
+	{"hebrew_het", 0xce7, 0, true},
+	{"hebrew_tet", 0xce8, 0x05D8, false},
+	{"hebrew_teth", 0xce8, 0, true},
+	{"hebrew_yod", 0xce9, 0x05D9, false},
+	{"hebrew_finalkaph", 0xcea, 0x05DA, false},
+	{"hebrew_kaph", 0xceb, 0x05DB, false},
+	{"hebrew_lamed", 0xcec, 0x05DC, false},
+	{"hebrew_finalmem", 0xced, 0x05DD, false},
+	{"hebrew_mem", 0xcee, 0x05DE, false},
+	{"hebrew_finalnun", 0xcef, 0x05DF, false},
+	{"hebrew_nun", 0xcf0, 0x05E0, false},
+	{"hebrew_samech", 0xcf1, 0x05E1, false},
+	{"hebrew_samekh", 0xcf1, 0, true},
+	{"hebrew_ayin", 0xcf2, 0x05E2, false},
+	{"hebrew_finalpe", 0xcf3, 0x05E3, false},
+	{"hebrew_pe", 0xcf4, 0x05E4, false},
+	{"hebrew_finalzade", 0xcf5, 0x05E5, false},
+	{"hebrew_finalzadi", 0xcf5, 0, true},
+	{"hebrew_zade", 0xcf6, 0x05E6, false},
+	{"hebrew_zadi", 0xcf6, 0, true},
+	{"hebrew_qoph", 0xcf7, 0x05E7, false},
+	{"hebrew_kuf", 0xcf7, 0, true},
+	{"hebrew_resh", 0xcf8, 0x05E8, false},
+	{"hebrew_shin", 0xcf9, 0x05E9, false},
+	{"hebrew_taw", 0xcfa, 0x05EA, false},
+	{"hebrew_taf", 0xcfa, 0, true},
+	{"Hebrew_switch", 0xff7e, 0, false},
+	{"Thai_kokai", 0xda1, 0x0E01, false},
+	{"Thai_khokhai", 0xda2, 0x0E02, false},
+	{"Thai_khokhuat", 0xda3, 0x0E03, false},
+	{"Thai_khokhwai", 0xda4, 0x0E04, false},
+	{"Thai_khokhon", 0xda5, 0x0E05, false},
+	{"Thai_khorakhang", 0xda6, 0x0E06, false},
+	{"Thai_ngongu", 0xda7, 0x0E07, false},
+	{"Thai_chochan", 0xda8, 0x0E08, false},
+	{"Thai_choching", 0xda9, 0x0E09, false},
+	{"Thai_chochang", 0xdaa, 0x0E0A, false},
+	{"Thai_soso", 0xdab, 0x0E0B, false},
+	{"Thai_chochoe", 0xdac, 0x0E0C, false},
+	{"Thai_yoying", 0xdad, 0x0E0D, false},
+	{"Thai_dochada", 0xdae, 0x0E0E, false},
+	{"Thai_topatak", 0xdaf, 0x0E0F, false},
+	{"Thai_thothan", 0xdb0, 0x0E10, false},
+	{"Thai_thonangmontho", 0xdb1, 0x0E11, false},
+	{"Thai_thophuthao", 0xdb2, 0x0E12, false},
+	{"Thai_nonen", 0xdb3, 0x0E13, false},
+	{"Thai_dodek", 0xdb4, 0x0E14, false},
+	{"Thai_totao", 0xdb5, 0x0E15, false},
+	{"Thai_thothung", 0xdb6, 0x0E16, false},
+	{"Thai_thothahan", 0xdb7, 0x0E17, false},
+	{"Thai_thothong", 0xdb8, 0x0E18, false},
+	{"Thai_nonu", 0xdb9, 0x0E19, false},
+	{"Thai_bobaimai", 0xdba, 0x0E1A, false},
+	{"Thai_popla", 0xdbb, 0x0E1B, false},
+	{"Thai_phophung", 0xdbc, 0x0E1C, false},
+	{"Thai_fofa", 0xdbd, 0x0E1D, false},
+	{"Thai_phophan", 0xdbe, 0x0E1E, false},
+	{"Thai_fofan", 0xdbf, 0x0E1F, false},
+	{"Thai_phosamphao", 0xdc0, 0x0E20, false},
+	{"Thai_moma", 0xdc1, 0x0E21, false},
+	{"Thai_yoyak", 0xdc2, 0x0E22, false},
+	{"Thai_rorua", 0xdc3, 0x0E23, false},
+	{"Thai_ru", 0xdc4, 0x0E24, false},
+	{"Thai_loling", 0xdc5, 0x0E25, false},
+	{"Thai_lu", 0xdc6, 0x0E26, false},
+	{"Thai_wowaen", 0xdc7, 0x0E27, false},
+	{"Thai_sosala", 0xdc8, 0x0E28, false},
+	{"Thai_sorusi", 0xdc9, 0x0E29, false},
+	{"Thai_sosua", 0xdca, 0x0E2A, false},
+	{"Thai_hohip", 0xdcb, 0x0E2B, false},
+	{"Thai_lochula", 0xdcc, 0x0E2C, false},
+	{"Thai_oang", 0xdcd, 0x0E2D, false},
+	{"Thai_honokhuk", 0xdce, 0x0E2E, false},
+	{"Thai_paiyannoi", 0xdcf, 0x0E2F, false},
+	{"Thai_saraa", 0xdd0, 0x0E30, false},
+	{"Thai_maihanakat", 0xdd1, 0x0E31, false},
+	{"Thai_saraaa", 0xdd2, 0x0E32, false},
+	{"Thai_saraam", 0xdd3, 0x0E33, false},
+	{"Thai_sarai", 0xdd4, 0x0E34, false},
+	{"Thai_saraii", 0xdd5, 0x0E35, false},
+	{"Thai_saraue", 0xdd6, 0x0E36, false},
+	{"Thai_sarauee", 0xdd7, 0x0E37, false},
+	{"Thai_sarau", 0xdd8, 0x0E38, false},
+	{"Thai_sarauu", 0xdd9, 0x0E39, false},
+	{"Thai_phinthu", 0xdda, 0x0E3A, false},
+	{"Thai_maihanakat_maitho", 0xdde, 0, false},
+	{"Thai_baht", 0xddf, 0x0E3F, false},
+	{"Thai_sarae", 0xde0, 0x0E40, false},
+	{"Thai_saraae", 0xde1, 0x0E41, false},
+	{"Thai_sarao", 0xde2, 0x0E42, false},
+	{"Thai_saraaimaimuan", 0xde3, 0x0E43, false},
+	{"Thai_saraaimaimalai", 0xde4, 0x0E44, false},
+	{"Thai_lakkhangyao", 0xde5, 0x0E45, false},
+	{"Thai_maiyamok", 0xde6, 0x0E46, false},
+	{"Thai_maitaikhu", 0xde7, 0x0E47, false},
+	{"Thai_maiek", 0xde8, 0x0E48, false},
+	{"Thai_maitho", 0xde9, 0x0E49, false},
+	{"Thai_maitri", 0xdea, 0x0E4A, false},
+	{"Thai_maichattawa", 0xdeb, 0x0E4B, false},
+	{"Thai_thanthakhat", 0xdec, 0x0E4C, false},
+	{"Thai_nikhahit", 0xded, 0x0E4D, false},
+	{"Thai_leksun", 0xdf0, 0x0E50, false},
+	{"Thai_leknung", 0xdf1, 0x0E51, false},
+	{"Thai_leksong", 0xdf2, 0x0E52, false},
+	{"Thai_leksam", 0xdf3, 0x0E53, false},
+	{"Thai_leksi", 0xdf4, 0x0E54, false},
+	{"Thai_lekha", 0xdf5, 0x0E55, false},
+	{"Thai_lekhok", 0xdf6, 0x0E56, false},
+	{"Thai_lekchet", 0xdf7, 0x0E57, false},
+	{"Thai_lekpaet", 0xdf8, 0x0E58, false},
+	{"Thai_lekkao", 0xdf9, 0x0E59, false},
+	{"Hangul", 0xff31, 0, false},
+	{"Hangul_Start", 0xff32, 0, false},
+	{"Hangul_End", 0xff33, 0, false},
+	{"Hangul_Hanja", 0xff34, 0, false},
+	{"Hangul_Jamo", 0xff35, 0, false},
+	{"Hangul_Romaja", 0xff36, 0, false},
+	{"Hangul_Codeinput", 0xff37, 0, false},
+	{"Hangul_Jeonja", 0xff38, 0, false},
+	{"Hangul_Banja", 0xff39, 0, false},
+	{"Hangul_PreHanja", 0xff3a, 0, false},
+	{"Hangul_PostHanja", 0xff3b, 0, false},
+	{"Hangul_SingleCandidate", 0xff3c, 0, false},
+	{"Hangul_MultipleCandidate", 0xff3d, 0, false},
+	{"Hangul_PreviousCandidate", 0xff3e, 0, false},
+	{"Hangul_Special", 0xff3f, 0, false},
+	{"Hangul_switch", 0xff7e, 0, false},
+	{"Hangul_Kiyeog", 0xea1, 0x3131, false},
+	{"Hangul_SsangKiyeog", 0xea2, 0x3132, false},
+	{"Hangul_KiyeogSios", 0xea3, 0x3133, false},
+	{"Hangul_Nieun", 0xea4, 0x3134, false},
+	{"Hangul_NieunJieuj", 0xea5, 0x3135, false},
+	{"Hangul_NieunHieuh", 0xea6, 0x3136, false},
+	{"Hangul_Dikeud", 0xea7, 0x3137, false},
+	{"Hangul_SsangDikeud", 0xea8, 0x3138, false},
+	{"Hangul_Rieul", 0xea9, 0x3139, false},
+	{"Hangul_RieulKiyeog", 0xeaa, 0x313A, false},
+	{"Hangul_RieulMieum", 0xeab, 0x313B, false},
+	{"Hangul_RieulPieub", 0xeac, 0x313C, false},
+	{"Hangul_RieulSios", 0xead, 0x313D, false},
+	{"Hangul_RieulTieut", 0xeae, 0x313E, false},
+	{"Hangul_RieulPhieuf", 0xeaf, 0x313F, false},
+	{"Hangul_RieulHieuh", 0xeb0, 0x3140, false},
+	{"Hangul_Mieum", 0xeb1, 0x3141, false},
+	{"Hangul_Pieub", 0xeb2, 0x3142, false},
+	{"Hangul_SsangPieub", 0xeb3, 0x3143, false},
+	{"Hangul_PieubSios", 0xeb4, 0x3144, false},
+	{"Hangul_Sios", 0xeb5, 0x3145, false},
+	{"Hangul_SsangSios", 0xeb6, 0x3146, false},
+	{"Hangul_Ieung", 0xeb7, 0x3147, false},
+	{"Hangul_Jieuj", 0xeb8, 0x3148, false},
+	{"Hangul_SsangJieuj", 0xeb9, 0x3149, false},
+	{"Hangul_Cieuc", 0xeba, 0x314A, false},
+	{"Hangul_Khieuq", 0xebb, 0x314B, false},
+	{"Hangul_Tieut", 0xebc, 0x314C, false},
+	{"Hangul_Phieuf", 0xebd, 0x314D, false},
+	{"Hangul_Hieuh", 0xebe, 0x314E, false},
+	{"Hangul_A", 0xebf, 0x314F, false},
+	{"Hangul_AE", 0xec0, 0x3150, false},
+	{"Hangul_YA", 0xec1, 0x3151, false},
+	{"Hangul_YAE", 0xec2, 0x3152, false},
+	{"Hangul_EO", 0xec3, 0x3153, false},
+	{"Hangul_E", 0xec4, 0x3154, false},
+	{"Hangul_YEO", 0xec5, 0x3155, false},
+	{"Hangul_YE", 0xec6, 0x3156, false},
+	{"Hangul_O", 0xec7, 0x3157, false},
+	{"Hangul_WA", 0xec8, 0x3158, false},
+	{"Hangul_WAE", 0xec9, 0x3159, false},
+	{"Hangul_OE", 0xeca, 0x315A, false},
+	{"Hangul_YO", 0xecb, 0x315B, false},
+	{"Hangul_U", 0xecc, 0x315C, false},
+	{"Hangul_WEO", 0xecd, 0x315D, false},
+	{"Hangul_WE", 0xece, 0x315E, false},
+	{"Hangul_WI", 0xecf, 0x315F, false},
+	{"Hangul_YU", 0xed0, 0x3160, false},
+	{"Hangul_EU", 0xed1, 0x3161, false},
+	{"Hangul_YI", 0xed2, 0x3162, false},
+	{"Hangul_I", 0xed3, 0x3163, false},
+	{"Hangul_J_Kiyeog", 0xed4, 0x11A8, false},
+	{"Hangul_J_SsangKiyeog", 0xed5, 0x11A9, false},
+	{"Hangul_J_KiyeogSios", 0xed6, 0x11AA, false},
+	{"Hangul_J_Nieun", 0xed7, 0x11AB, false},
+	{"Hangul_J_NieunJieuj", 0xed8, 0x11AC, false},
+	{"Hangul_J_NieunHieuh", 0xed9, 0x11AD, false},
+	{"Hangul_J_Dikeud", 0xeda, 0x11AE, false},
+	{"Hangul_J_Rieul", 0xedb, 0x11AF, false},
+	{"Hangul_J_RieulKiyeog", 0xedc, 0x11B0, false},
+	{"Hangul_J_RieulMieum", 0xedd, 0x11B1, false},
+	{"Hangul_J_RieulPieub", 0xede, 0x11B2, false},
+	{"Hangul_J_RieulSios", 0xedf, 0x11B3, false},
+	{"Hangul_J_RieulTieut", 0xee0, 0x11B4, false},
+	{"Hangul_J_RieulPhieuf", 0xee1, 0x11B5, false},
+	{"Hangul_J_RieulHieuh", 0xee2, 0x11B6, false},
+	{"Hangul_J_Mieum", 0xee3, 0x11B7, false},
+	{"Hangul_J_Pieub", 0xee4, 0x11B8, false},
+	{"Hangul_J_PieubSios", 0xee5, 0x11B9, false},
+	{"Hangul_J_Sios", 0xee6, 0x11BA, false},
+	{"Hangul_J_SsangSios", 0xee7, 0x11BB, false},
+	{"Hangul_J_Ieung", 0xee8, 0x11BC, false},
+	{"Hangul_J_Jieuj", 0xee9, 0x11BD, false},
+	{"Hangul_J_Cieuc", 0xeea, 0x11BE, false},
+	{"Hangul_J_Khieuq", 0xeeb, 0x11BF, false},
+	{"Hangul_J_Tieut", 0xeec, 0x11C0, false},
+	{"Hangul_J_Phieuf", 0xeed, 0x11C1, false},
+	{"Hangul_J_Hieuh", 0xeee, 0x11C2, false},
+	{"Hangul_RieulYeorinHieuh", 0xeef, 0x316D, false},
+	{"Hangul_SunkyeongeumMieum", 0xef0, 0x3171, false},
+	{"Hangul_SunkyeongeumPieub", 0xef1, 0x3178, false},
+	{"Hangul_PanSios", 0xef2, 0x317F, false},
+	{"Hangul_KkogjiDalrinIeung", 0xef3, 0x3181, false},
+	{"Hangul_SunkyeongeumPhieuf", 0xef4, 0x3184, false},
+	{"Hangul_YeorinHieuh", 0xef5, 0x3186, false},
+	{"Hangul_AraeA", 0xef6, 0x318D, false},
+	{"Hangul_AraeAE", 0xef7, 0x318E, false},
+	{"Hangul_J_PanSios", 0xef8, 0x11EB, false},
+	{"Hangul_J_KkogjiDalrinIeung", 0xef9, 0x11F0, false},
+	{"Hangul_J_YeorinHieuh", 0xefa, 0x11F9, false},
+	{"Korean_Won", 0xeff, 0, false},
+	{"Armenian_ligature_ew", 0x1000587, 0x0587, false},
+	{"Armenian_full_stop", 0x1000589, 0x0589, false},
+	{"Armenian_verjaket", 0x1000589, 0x0589, false},
+	{"Armenian_separation_mark", 0x100055d, 0x055D, false},
+	{"Armenian_but", 0x100055d, 0x055D, false},
+	{"Armenian_hyphen", 0x100058a, 0x058A, false},
+	{"Armenian_yentamna", 0x100058a, 0x058A, false},
+	{"Armenian_exclam", 0x100055c, 0x055C, false},
+	{"Armenian_amanak", 0x100055c, 0x055C, false},
+	{"Armenian_accent", 0x100055b, 0x055B, false},
+	{"Armenian_shesht", 0x100055b, 0x055B, false},
+	{"Armenian_question", 0x100055e, 0x055E, false},
+	{"Armenian_paruyk", 0x100055e, 0x055E, false},
+	{"Armenian_AYB", 0x1000531, 0x0531, false},
+	{"Armenian_ayb", 0x1000561, 0x0561, false},
+	{"Armenian_BEN", 0x1000532, 0x0532, false},
+	{"Armenian_ben", 0x1000562, 0x0562, false},
+	{"Armenian_GIM", 0x1000533, 0x0533, false},
+	{"Armenian_gim", 0x1000563, 0x0563, false},
+	{"Armenian_DA", 0x1000534, 0x0534, false},
+	{"Armenian_da", 0x1000564, 0x0564, false},
+	{"Armenian_YECH", 0x1000535, 0x0535, false},
+	{"Armenian_yech", 0x1000565, 0x0565, false},
+	{"Armenian_ZA", 0x1000536, 0x0536, false},
+	{"Armenian_za", 0x1000566, 0x0566, false},
+	{"Armenian_E", 0x1000537, 0x0537, false},
+	{"Armenian_e", 0x1000567, 0x0567, false},
+	{"Armenian_AT", 0x1000538, 0x0538, false},
+	{"Armenian_at", 0x1000568, 0x0568, false},
+	{"Armenian_TO", 0x1000539, 0x0539, false},
+	{"Armenian_to", 0x1000569, 0x0569, false},
+	{"Armenian_ZHE", 0x100053a, 0x053A, false},
+	{"Armenian_zhe", 0x100056a, 0x056A, false},
+	{"Armenian_INI", 0x100053b, 0x053B, false},
+	{"Armenian_ini", 0x100056b, 0x056B, false},
+	{"Armenian_LYUN", 0x100053c, 0x053C, false},
+	{"Armenian_lyun", 0x100056c, 0x056C, false},
+	{"Armenian_KHE", 0x100053d, 0x053D, false},
+	{"Armenian_khe", 0x100056d, 0x056D, false},
+	{"Armenian_TSA", 0x100053e, 0x053E, false},
+	{"Armenian_tsa", 0x100056e, 0x056E, false},
+	{"Armenian_KEN", 0x100053f, 0x053F, false},
+	{"Armenian_ken", 0x100056f, 0x056F, false},
+	{"Armenian_HO", 0x1000540, 0x0540, false},
+	{"Armenian_ho", 0x1000570, 0x0570, false},
+	{"Armenian_DZA", 0x1000541, 0x0541, false},
+	{"Armenian_dza", 0x1000571, 0x0571, false},
+	{"Armenian_GHAT", 0x1000542, 0x0542, false},
+	{"Armenian_ghat", 0x1000572, 0x0572, false},
+	{"Armenian_TCHE", 0x1000543, 0x0543, false},
+	{"Armenian_tche", 0x1000573, 0x0573, false},
+	{"Armenian_MEN", 0x1000544, 0x0544, false},
+	{"Armenian_men", 0x1000574, 0x0574, false},
+	{"Armenian_HI", 0x1000545, 0x0545, false},
+	{"Armenian_hi", 0x1000575, 0x0575, false},
+	{"Armenian_NU", 0x1000546, 0x0546, false},
+	{"Armenian_nu", 0x1000576, 0x0576, false},
+	{"Armenian_SHA", 0x1000547, 0x0547, false},
+	{"Armenian_sha", 0x1000577, 0x0577, false},
+	{"Armenian_VO", 0x1000548, 0x0548, false},
+	{"Armenian_vo", 0x1000578, 0x0578, false},
+	{"Armenian_CHA", 0x1000549, 0x0549, false},
+	{"Armenian_cha", 0x1000579, 0x0579, false},
+	{"Armenian_PE", 0x100054a, 0x054A, false},
+	{"Armenian_pe", 0x100057a, 0x057A, false},
+	{"Armenian_JE", 0x100054b, 0x054B, false},
+	{"Armenian_je", 0x100057b, 0x057B, false},
+	{"Armenian_RA", 0x100054c, 0x054C, false},
+	{"Armenian_ra", 0x100057c, 0x057C, false},
+	{"Armenian_SE", 0x100054d, 0x054D, false},
+	{"Armenian_se", 0x100057d, 0x057D, false},
+	{"Armenian_VEV", 0x100054e, 0x054E, false},
+	{"Armenian_vev", 0x100057e, 0x057E, false},
+	{"Armenian_TYUN", 0x100054f, 0x054F, false},
+	{"Armenian_tyun", 0x100057f, 0x057F, false},
+	{"Armenian_RE", 0x1000550, 0x0550, false},
+	{"Armenian_re", 0x1000580, 0x0580, false},
+	{"Armenian_TSO", 0x1000551, 0x0551, false},
+	{"Armenian_tso", 0x1000581, 0x0581, false},
+	{"Armenian_VYUN", 0x1000552, 0x0552, false},
+	{"Armenian_vyun", 0x1000582, 0x0582, false},
+	{"Armenian_PYUR", 0x1000553, 0x0553, false},
+	{"Armenian_pyur", 0x1000583, 0x0583, false},
+	{"Armenian_KE", 0x1000554, 0x0554, false},
+	{"Armenian_ke", 0x1000584, 0x0584, false},
+	{"Armenian_O", 0x1000555, 0x0555, false},
+	{"Armenian_o", 0x1000585, 0x0585, false},
+	{"Armenian_FE", 0x1000556, 0x0556, false},
+	{"Armenian_fe", 0x1000586, 0x0586, false},
+	{"Armenian_apostrophe", 0x100055a, 0x055A, false},
+	{"Georgian_an", 0x10010d0, 0x10D0, false},
+	{"Georgian_ban", 0x10010d1, 0x10D1, false},
+	{"Georgian_gan", 0x10010d2, 0x10D2, false},
+	{"Georgian_don", 0x10010d3, 0x10D3, false},
+	{"Georgian_en", 0x10010d4, 0x10D4, false},
+	{"Georgian_vin", 0x10010d5, 0x10D5, false},
+	{"Georgian_zen", 0x10010d6, 0x10D6, false},
+	{"Georgian_tan", 0x10010d7, 0x10D7, false},
+	{"Georgian_in", 0x10010d8, 0x10D8, false},
+	{"Georgian_kan", 0x10010d9, 0x10D9, false},
+	{"Georgian_las", 0x10010da, 0x10DA, false},
+	{"Georgian_man", 0x10010db, 0x10DB, false},
+	{"Georgian_nar", 0x10010dc, 0x10DC, false},
+	{"Georgian_on", 0x10010dd, 0x10DD, false},
+	{"Georgian_par", 0x10010de, 0x10DE, false},
+	{"Georgian_zhar", 0x10010df, 0x10DF, false},
+	{"Georgian_rae", 0x10010e0, 0x10E0, false},
+	{"Georgian_san", 0x10010e1, 0x10E1, false},
+	{"Georgian_tar", 0x10010e2, 0x10E2, false},
+	{"Georgian_un", 0x10010e3, 0x10E3, false},
+	{"Georgian_phar", 0x10010e4, 0x10E4, false},
+	{"Georgian_khar", 0x10010e5, 0x10E5, false},
+	{"Georgian_ghan", 0x10010e6, 0x10E6, false},
+	{"Georgian_qar", 0x10010e7, 0x10E7, false},
+	{"Georgian_shin", 0x10010e8, 0x10E8, false},
+	{"Georgian_chin", 0x10010e9, 0x10E9, false},
+	{"Georgian_can", 0x10010ea, 0x10EA, false},
+	{"Georgian_jil", 0x10010eb, 0x10EB, false},
+	{"Georgian_cil", 0x10010ec, 0x10EC, false},
+	{"Georgian_char", 0x10010ed, 0x10ED, false},
+	{"Georgian_xan", 0x10010ee, 0x10EE, false},
+	{"Georgian_jhan", 0x10010ef, 0x10EF, false},
+	{"Georgian_hae", 0x10010f0, 0x10F0, false},
+	{"Georgian_he", 0x10010f1, 0x10F1, false},
+	{"Georgian_hie", 0x10010f2, 0x10F2, false},
+	{"Georgian_we", 0x10010f3, 0x10F3, false},
+	{"Georgian_har", 0x10010f4, 0x10F4, false},
+	{"Georgian_hoe", 0x10010f5, 0x10F5, false},
+	{"Georgian_fi", 0x10010f6, 0x10F6, false},
+	{"Xabovedot", 0x1001e8a, 0x1E8A, false},
+	{"Ibreve", 0x100012c, 0x012C, false},
+	{"Zstroke", 0x10001b5, 0x01B5, false},
+	{"Gcaron", 0x10001e6, 0x01E6, false},
+	{"Ocaron", 0x10001d1, 0x01D1, false},
+	{"Obarred", 0x100019f, 0x019F, false},
+	{"xabovedot", 0x1001e8b, 0x1E8B, false},
+	{"ibreve", 0x100012d, 0x012D, false},
+	{"zstroke", 0x10001b6, 0x01B6, false},
+	{"gcaron", 0x10001e7, 0x01E7, false},
+	{"ocaron", 0x10001d2, 0x01D2, false},
+	{"obarred", 0x1000275, 0x0275, false},
+	{"SCHWA", 0x100018f, 0x018F, false},
+	{"schwa", 0x1000259, 0x0259, false},
+	{"EZH", 0x10001b7, 0x01B7, false},
+	{"ezh", 0x1000292, 0x0292, false},
+	{"Lbelowdot", 0x1001e36, 0x1E36, false},
+	{"lbelowdot", 0x1001e37, 0x1E37, false},
+	{"Abelowdot", 0x1001ea0, 0x1EA0, false},
+	{"abelowdot", 0x1001ea1, 0x1EA1, false},
+	{"Ahook", 0x1001ea2, 0x1EA2, false},
+	{"ahook", 0x1001ea3, 0x1EA3, false},
+	{"Acircumflexacute", 0x1001ea4, 0x1EA4, false},
+	{"acircumflexacute", 0x1001ea5, 0x1EA5, false},
+	{"Acircumflexgrave", 0x1001ea6, 0x1EA6, false},
+	{"acircumflexgrave", 0x1001ea7, 0x1EA7, false},
+	{"Acircumflexhook", 0x1001ea8, 0x1EA8, false},
+	{"acircumflexhook", 0x1001ea9, 0x1EA9, false},
+	{"Acircumflextilde", 0x1001eaa, 0x1EAA, false},
+	{"acircumflextilde", 0x1001eab, 0x1EAB, false},
+	{"Acircumflexbelowdot", 0x1001eac, 0x1EAC, false},
+	{"acircumflexbelowdot", 0x1001ead, 0x1EAD, false},
+	{"Abreveacute", 0x1001eae, 0x1EAE, false},
+	{"abreveacute", 0x1001eaf, 0x1EAF, false},
+	{"Abrevegrave", 0x1001eb0, 0x1EB0, false},
+	{"abrevegrave", 0x1001eb1, 0x1EB1, false},
+	{"Abrevehook", 0x1001eb2, 0x1EB2, false},
+	{"abrevehook", 0x1001eb3, 0x1EB3, false},
+	{"Abrevetilde", 0x1001eb4, 0x1EB4, false},
+	{"abrevetilde", 0x1001eb5, 0x1EB5, false},
+	{"Abrevebelowdot", 0x1001eb6, 0x1EB6, false},
+	{"abrevebelowdot", 0x1001eb7, 0x1EB7, false},
+	{"Ebelowdot", 0x1001eb8, 0x1EB8, false},
+	{"ebelowdot", 0x1001eb9, 0x1EB9, false},
+	{"Ehook", 0x1001eba, 0x1EBA, false},
+	{"ehook", 0x1001ebb, 0x1EBB, false},
+	{"Etilde", 0x1001ebc, 0x1EBC, false},
+	{"etilde", 0x1001ebd, 0x1EBD, false},
+	{"Ecircumflexacute", 0x1001ebe, 0x1EBE, false},
+	{"ecircumflexacute", 0x1001ebf, 0x1EBF, false},
+	{"Ecircumflexgrave", 0x1001ec0, 0x1EC0, false},
+	{"ecircumflexgrave", 0x1001ec1, 0x1EC1, false},
+	{"Ecircumflexhook", 0x1001ec2, 0x1EC2, false},
+	{"ecircumflexhook", 0x1001ec3, 0x1EC3, false},
+	{"Ecircumflextilde", 0x1001ec4, 0x1EC4, false},
+	{"ecircumflextilde", 0x1001ec5, 0x1EC5, false},
+	{"Ecircumflexbelowdot", 0x1001ec6, 0x1EC6, false},
+	{"ecircumflexbelowdot", 0x1001ec7, 0x1EC7, false},
+	{"Ihook", 0x1001ec8, 0x1EC8, false},
+	{"ihook", 0x1001ec9, 0x1EC9, false},
+	{"Ibelowdot", 0x1001eca, 0x1ECA, false},
+	{"ibelowdot", 0x1001ecb, 0x1ECB, false},
+	{"Obelowdot", 0x1001ecc, 0x1ECC, false},
+	{"obelowdot", 0x1001ecd, 0x1ECD, false},
+	{"Ohook", 0x1001ece, 0x1ECE, false},
+	{"ohook", 0x1001ecf, 0x1ECF, false},
+	{"Ocircumflexacute", 0x1001ed0, 0x1ED0, false},
+	{"ocircumflexacute", 0x1001ed1, 0x1ED1, false},
+	{"Ocircumflexgrave", 0x1001ed2, 0x1ED2, false},
+	{"ocircumflexgrave", 0x1001ed3, 0x1ED3, false},
+	{"Ocircumflexhook", 0x1001ed4, 0x1ED4, false},
+	{"ocircumflexhook", 0x1001ed5, 0x1ED5, false},
+	{"Ocircumflextilde", 0x1001ed6, 0x1ED6, false},
+	{"ocircumflextilde", 0x1001ed7, 0x1ED7, false},
+	{"Ocircumflexbelowdot", 0x1001ed8, 0x1ED8, false},
+	{"ocircumflexbelowdot", 0x1001ed9, 0x1ED9, false},
+	{"Ohornacute", 0x1001eda, 0x1EDA, false},
+	{"ohornacute", 0x1001edb, 0x1EDB, false},
+	{"Ohorngrave", 0x1001edc, 0x1EDC, false},
+	{"ohorngrave", 0x1001edd, 0x1EDD, false},
+	{"Ohornhook", 0x1001ede, 0x1EDE, false},
+	{"ohornhook", 0x1001edf, 0x1EDF, false},
+	{"Ohorntilde", 0x1001ee0, 0x1EE0, false},
+	{"ohorntilde", 0x1001ee1, 0x1EE1, false},
+	{"Ohornbelowdot", 0x1001ee2, 0x1EE2, false},
+	{"ohornbelowdot", 0x1001ee3, 0x1EE3, false},
+	{"Ubelowdot", 0x1001ee4, 0x1EE4, false},
+	{"ubelowdot", 0x1001ee5, 0x1EE5, false},
+	{"Uhook", 0x1001ee6, 0x1EE6, false},
+	{"uhook", 0x1001ee7, 0x1EE7, false},
+	{"Uhornacute", 0x1001ee8, 0x1EE8, false},
+	{"uhornacute", 0x1001ee9, 0x1EE9, false},
+	{"Uhorngrave", 0x1001eea, 0x1EEA, false},
+	{"uhorngrave", 0x1001eeb, 0x1EEB, false},
+	{"Uhornhook", 0x1001eec, 0x1EEC, false},
+	{"uhornhook", 0x1001eed, 0x1EED, false},
+	{"Uhorntilde", 0x1001eee, 0x1EEE, false},
+	{"uhorntilde", 0x1001eef, 0x1EEF, false},
+	{"Uhornbelowdot", 0x1001ef0, 0x1EF0, false},
+	{"uhornbelowdot", 0x1001ef1, 0x1EF1, false},
+	{"Ybelowdot", 0x1001ef4, 0x1EF4, false},
+	{"ybelowdot", 0x1001ef5, 0x1EF5, false},
+	{"Yhook", 0x1001ef6, 0x1EF6, false},
+	{"yhook", 0x1001ef7, 0x1EF7, false},
+	{"Ytilde", 0x1001ef8, 0x1EF8, false},
+	{"ytilde", 0x1001ef9, 0x1EF9, false},
+	{"Ohorn", 0x10001a0, 0x01A0, false},
+	{"ohorn", 0x10001a1, 0x01A1, false},
+	{"Uhorn", 0x10001af, 0x01AF, false},
+	{"uhorn", 0x10001b0, 0x01B0, false},
+	{"combining_tilde", 0x1000303, 0x0303, false},
+	{"combining_grave", 0x1000300, 0x0300, false},
+	{"combining_acute", 0x1000301, 0x0301, false},
+	{"combining_hook", 0x1000309, 0x0309, false},
+	{"combining_belowdot", 0x1000323, 0x0323, false},
+	{"EcuSign", 0x10020a0, 0x20A0, false},
+	{"ColonSign", 0x10020a1, 0x20A1, false},
+	{"CruzeiroSign", 0x10020a2, 0x20A2, false},
+	{"FFrancSign", 0x10020a3, 0x20A3, false},
+	{"LiraSign", 0x10020a4, 0x20A4, false},
+	{"MillSign", 0x10020a5, 0x20A5, false},
+	{"NairaSign", 0x10020a6, 0x20A6, false},
+	{"PesetaSign", 0x10020a7, 0x20A7, false},
+	{"RupeeSign", 0x10020a8, 0x20A8, false},
+	{"WonSign", 0x10020a9, 0x20A9, false},
+	{"NewSheqelSign", 0x10020aa, 0x20AA, false},
+	{"DongSign", 0x10020ab, 0x20AB, false},
+	{"EuroSign", 0x20ac, 0x20AC, false},
+	{"zerosuperior", 0x1002070, 0x2070, false},
+	{"foursuperior", 0x1002074, 0x2074, false},
+	{"fivesuperior", 0x1002075, 0x2075, false},
+	{"sixsuperior", 0x1002076, 0x2076, false},
+	{"sevensuperior", 0x1002077, 0x2077, false},
+	{"eightsuperior", 0x1002078, 0x2078, false},
+	{"ninesuperior", 0x1002079, 0x2079, false},
+	{"zerosubscript", 0x1002080, 0x2080, false},
+	{"onesubscript", 0x1002081, 0x2081, false},
+	{"twosubscript", 0x1002082, 0x2082, false},
+	{"threesubscript", 0x1002083, 0x2083, false},
+	{"foursubscript", 0x1002084, 0x2084, false},
+	{"fivesubscript", 0x1002085, 0x2085, false},
+	{"sixsubscript", 0x1002086, 0x2086, false},
+	{"sevensubscript", 0x1002087, 0x2087, false},
+	{"eightsubscript", 0x1002088, 0x2088, false},
+	{"ninesubscript", 0x1002089, 0x2089, false},
+	{"partdifferential", 0x1002202, 0x2202, false},
+	{"emptyset", 0x1002205, 0x2205, false},
+	{"elementof", 0x1002208, 0x2208, false},
+	{"notelementof", 0x1002209, 0x2209, false},
+	{"containsas", 0x100220b, 0x220B, false},
+	{"squareroot", 0x100221a, 0x221A, false},
+	{"cuberoot", 0x100221b, 0x221B, false},
+	{"fourthroot", 0x100221c, 0x221C, false},
+	{"dintegral", 0x100222c, 0x222C, false},
+	{"tintegral", 0x100222d, 0x222D, false},
+	{"because", 0x1002235, 0x2235, false},
+	{"approxeq", 0x1002248, 0, false},
+	{"notapproxeq", 0x1002247, 0, false},
+	{"notidentical", 0x1002262, 0x2262, false},
+	{"stricteq", 0x1002263, 0x2263, false},
+	{"braille_dot_1", 0xfff1, 0, false},
+	{"braille_dot_2", 0xfff2, 0, false},
+	{"braille_dot_3", 0xfff3, 0, false},
+	{"braille_dot_4", 0xfff4, 0, false},
+	{"braille_dot_5", 0xfff5, 0, false},
+	{"braille_dot_6", 0xfff6, 0, false},
+	{"braille_dot_7", 0xfff7, 0, false},
+	{"braille_dot_8", 0xfff8, 0, false},
+	{"braille_dot_9", 0xfff9, 0, false},
+	{"braille_dot_10", 0xfffa, 0, false},
+	{"braille_blank", 0x1002800, 0x2800, false},
+	{"braille_dots_1", 0x1002801, 0x2801, false},
+	{"braille_dots_2", 0x1002802, 0x2802, false},
+	{"braille_dots_12", 0x1002803, 0x2803, false},
+	{"braille_dots_3", 0x1002804, 0x2804, false},
+	{"braille_dots_13", 0x1002805, 0x2805, false},
+	{"braille_dots_23", 0x1002806, 0x2806, false},
+	{"braille_dots_123", 0x1002807, 0x2807, false},
+	{"braille_dots_4", 0x1002808, 0x2808, false},
+	{"braille_dots_14", 0x1002809, 0x2809, false},
+	{"braille_dots_24", 0x100280a, 0x280a, false},
+	{"braille_dots_124", 0x100280b, 0x280b, false},
+	{"braille_dots_34", 0x100280c, 0x280c, false},
+	{"braille_dots_134", 0x100280d, 0x280d, false},
+	{"braille_dots_234", 0x100280e, 0x280e, false},
+	{"braille_dots_1234", 0x100280f, 0x280f, false},
+	{"braille_dots_5", 0x1002810, 0x2810, false},
+	{"braille_dots_15", 0x1002811, 0x2811, false},
+	{"braille_dots_25", 0x1002812, 0x2812, false},
+	{"braille_dots_125", 0x1002813, 0x2813, false},
+	{"braille_dots_35", 0x1002814, 0x2814, false},
+	{"braille_dots_135", 0x1002815, 0x2815, false},
+	{"braille_dots_235", 0x1002816, 0x2816, false},
+	{"braille_dots_1235", 0x1002817, 0x2817, false},
+	{"braille_dots_45", 0x1002818, 0x2818, false},
+	{"braille_dots_145", 0x1002819, 0x2819, false},
+	{"braille_dots_245", 0x100281a, 0x281a, false},
+	{"braille_dots_1245", 0x100281b, 0x281b, false},
+	{"braille_dots_345", 0x100281c, 0x281c, false},
+	{"braille_dots_1345", 0x100281d, 0x281d, false},
+	{"braille_dots_2345", 0x100281e, 0x281e, false},
+	{"braille_dots_12345", 0x100281f, 0x281f, false},
+	{"braille_dots_6", 0x1002820, 0x2820, false},
+	{"braille_dots_16", 0x1002821, 0x2821, false},
+	{"braille_dots_26", 0x1002822, 0x2822, false},
+	{"braille_dots_126", 0x1002823, 0x2823, false},
+	{"braille_dots_36", 0x1002824, 0x2824, false},
+	{"braille_dots_136", 0x1002825, 0x2825, false},
+	{"braille_dots_236", 0x1002826, 0x2826, false},
+	{"braille_dots_1236", 0x1002827, 0x2827, false},
+	{"braille_dots_46", 0x1002828, 0x2828, false},
+	{"braille_dots_146", 0x1002829, 0x2829, false},
+	{"braille_dots_246", 0x100282a, 0x282a, false},
+	{"braille_dots_1246", 0x100282b, 0x282b, false},
+	{"braille_dots_346", 0x100282c, 0x282c, false},
+	{"braille_dots_1346", 0x100282d, 0x282d, false},
+	{"braille_dots_2346", 0x100282e, 0x282e, false},
+	{"braille_dots_12346", 0x100282f, 0x282f, false},
+	{"braille_dots_56", 0x1002830, 0x2830, false},
+	{"braille_dots_156", 0x1002831, 0x2831, false},
+	{"braille_dots_256", 0x1002832, 0x2832, false},
+	{"braille_dots_1256", 0x1002833, 0x2833, false},
+	{"braille_dots_356", 0x1002834, 0x2834, false},
+	{"braille_dots_1356", 0x1002835, 0x2835, false},
+	{"braille_dots_2356", 0x1002836, 0x2836, false},
+	{"braille_dots_12356", 0x1002837, 0x2837, false},
+	{"braille_dots_456", 0x1002838, 0x2838, false},
+	{"braille_dots_1456", 0x1002839, 0x2839, false},
+	{"braille_dots_2456", 0x100283a, 0x283a, false},
+	{"braille_dots_12456", 0x100283b, 0x283b, false},
+	{"braille_dots_3456", 0x100283c, 0x283c, false},
+	{"braille_dots_13456", 0x100283d, 0x283d, false},
+	{"braille_dots_23456", 0x100283e, 0x283e, false},
+	{"braille_dots_123456", 0x100283f, 0x283f, false},
+	{"braille_dots_7", 0x1002840, 0x2840, false},
+	{"braille_dots_17", 0x1002841, 0x2841, false},
+	{"braille_dots_27", 0x1002842, 0x2842, false},
+	{"braille_dots_127", 0x1002843, 0x2843, false},
+	{"braille_dots_37", 0x1002844, 0x2844, false},
+	{"braille_dots_137", 0x1002845, 0x2845, false},
+	{"braille_dots_237", 0x1002846, 0x2846, false},
+	{"braille_dots_1237", 0x1002847, 0x2847, false},
+	{"braille_dots_47", 0x1002848, 0x2848, false},
+	{"braille_dots_147", 0x1002849, 0x2849, false},
+	{"braille_dots_247", 0x100284a, 0x284a, false},
+	{"braille_dots_1247", 0x100284b, 0x284b, false},
+	{"braille_dots_347", 0x100284c, 0x284c, false},
+	{"braille_dots_1347", 0x100284d, 0x284d, false},
+	{"braille_dots_2347", 0x100284e, 0x284e, false},
+	{"braille_dots_12347", 0x100284f, 0x284f, false},
+	{"braille_dots_57", 0x1002850, 0x2850, false},
+	{"braille_dots_157", 0x1002851, 0x2851, false},
+	{"braille_dots_257", 0x1002852, 0x2852, false},
+	{"braille_dots_1257", 0x1002853, 0x2853, false},
+	{"braille_dots_357", 0x1002854, 0x2854, false},
+	{"braille_dots_1357", 0x1002855, 0x2855, false},
+	{"braille_dots_2357", 0x1002856, 0x2856, false},
+	{"braille_dots_12357", 0x1002857, 0x2857, false},
+	{"braille_dots_457", 0x1002858, 0x2858, false},
+	{"braille_dots_1457", 0x1002859, 0x2859, false},
+	{"braille_dots_2457", 0x100285a, 0x285a, false},
+	{"braille_dots_12457", 0x100285b, 0x285b, false},
+	{"braille_dots_3457", 0x100285c, 0x285c, false},
+	{"braille_dots_13457", 0x100285d, 0x285d, false},
+	{"braille_dots_23457", 0x100285e, 0x285e, false},
+	{"braille_dots_123457", 0x100285f, 0x285f, false},
+	{"braille_dots_67", 0x1002860, 0x2860, false},
+	{"braille_dots_167", 0x1002861, 0x2861, false},
+	{"braille_dots_267", 0x1002862, 0x2862, false},
+	{"braille_dots_1267", 0x1002863, 0x2863, false},
+	{"braille_dots_367", 0x1002864, 0x2864, false},
+	{"braille_dots_1367", 0x1002865, 0x2865, false},
+	{"braille_dots_2367", 0x1002866, 0x2866, false},
+	{"braille_dots_12367", 0x1002867, 0x2867, false},
+	{"braille_dots_467", 0x1002868, 0x2868, false},
+	{"braille_dots_1467", 0x1002869, 0x2869, false},
+	{"braille_dots_2467", 0x100286a, 0x286a, false},
+	{"braille_dots_12467", 0x100286b, 0x286b, false},
+	{"braille_dots_3467", 0x100286c, 0x286c, false},
+	{"braille_dots_13467", 0x100286d, 0x286d, false},
+	{"braille_dots_23467", 0x100286e, 0x286e, false},
+	{"braille_dots_123467", 0x100286f, 0x286f, false},
+	{"braille_dots_567", 0x1002870, 0x2870, false},
+	{"braille_dots_1567", 0x1002871, 0x2871, false},
+	{"braille_dots_2567", 0x1002872, 0x2872, false},
+	{"braille_dots_12567", 0x1002873, 0x2873, false},
+	{"braille_dots_3567", 0x1002874, 0x2874, false},
+	{"braille_dots_13567", 0x1002875, 0x2875, false},
+	{"braille_dots_23567", 0x1002876, 0x2876, false},
+	{"braille_dots_123567", 0x1002877, 0x2877, false},
+	{"braille_dots_4567", 0x1002878, 0x2878, false},
+	{"braille_dots_14567", 0x1002879, 0x2879, false},
+	{"braille_dots_24567", 0x100287a, 0x287a, false},
+	{"braille_dots_124567", 0x100287b, 0x287b, false},
+	{"braille_dots_34567", 0x100287c, 0x287c, false},
+	{"braille_dots_134567", 0x100287d, 0x287d, false},
+	{"braille_dots_234567", 0x100287e, 0x287e, false},
+	{"braille_dots_1234567", 0x100287f, 0x287f, false},
+	{"braille_dots_8", 0x1002880, 0x2880, false},
+	{"braille_dots_18", 0x1002881, 0x2881, false},
+	{"braille_dots_28", 0x1002882, 0x2882, false},
+	{"braille_dots_128", 0x1002883, 0x2883, false},
+	{"braille_dots_38", 0x1002884, 0x2884, false},
+	{"braille_dots_138", 0x1002885, 0x2885, false},
+	{"braille_dots_238", 0x1002886, 0x2886, false},
+	{"braille_dots_1238", 0x1002887, 0x2887, false},
+	{"braille_dots_48", 0x1002888, 0x2888, false},
+	{"braille_dots_148", 0x1002889, 0x2889, false},
+	{"braille_dots_248", 0x100288a, 0x288a, false},
+	{"braille_dots_1248", 0x100288b, 0x288b, false},
+	{"braille_dots_348", 0x100288c, 0x288c, false},
+	{"braille_dots_1348", 0x100288d, 0x288d, false},
+	{"braille_dots_2348", 0x100288e, 0x288e, false},
+	{"braille_dots_12348", 0x100288f, 0x288f, false},
+	{"braille_dots_58", 0x1002890, 0x2890, false},
+	{"braille_dots_158", 0x1002891, 0x2891, false},
+	{"braille_dots_258", 0x1002892, 0x2892, false},
+	{"braille_dots_1258", 0x1002893, 0x2893, false},
+	{"braille_dots_358", 0x1002894, 0x2894, false},
+	{"braille_dots_1358", 0x1002895, 0x2895, false},
+	{"braille_dots_2358", 0x1002896, 0x2896, false},
+	{"braille_dots_12358", 0x1002897, 0x2897, false},
+	{"braille_dots_458", 0x1002898, 0x2898, false},
+	{"braille_dots_1458", 0x1002899, 0x2899, false},
+	{"braille_dots_2458", 0x100289a, 0x289a, false},
+	{"braille_dots_12458", 0x100289b, 0x289b, false},
+	{"braille_dots_3458", 0x100289c, 0x289c, false},
+	{"braille_dots_13458", 0x100289d, 0x289d, false},
+	{"braille_dots_23458", 0x100289e, 0x289e, false},
+	{"braille_dots_123458", 0x100289f, 0x289f, false},
+	{"braille_dots_68", 0x10028a0, 0x28a0, false},
+	{"braille_dots_168", 0x10028a1, 0x28a1, false},
+	{"braille_dots_268", 0x10028a2, 0x28a2, false},
+	{"braille_dots_1268", 0x10028a3, 0x28a3, false},
+	{"braille_dots_368", 0x10028a4, 0x28a4, false},
+	{"braille_dots_1368", 0x10028a5, 0x28a5, false},
+	{"braille_dots_2368", 0x10028a6, 0x28a6, false},
+	{"braille_dots_12368", 0x10028a7, 0x28a7, false},
+	{"braille_dots_468", 0x10028a8, 0x28a8, false},
+	{"braille_dots_1468", 0x10028a9, 0x28a9, false},
+	{"braille_dots_2468", 0x10028aa, 0x28aa, false},
+	{"braille_dots_12468", 0x10028ab, 0x28ab, false},
+	{"braille_dots_3468", 0x10028ac, 0x28ac, false},
+	{"braille_dots_13468", 0x10028ad, 0x28ad, false},
+	{"braille_dots_23468", 0x10028ae, 0x28ae, false},
+	{"braille_dots_123468", 0x10028af, 0x28af, false},
+	{"braille_dots_568", 0x10028b0, 0x28b0, false},
+	{"braille_dots_1568", 0x10028b1, 0x28b1, false},
+	{"braille_dots_2568", 0x10028b2, 0x28b2, false},
+	{"braille_dots_12568", 0x10028b3, 0x28b3, false},
+	{"braille_dots_3568", 0x10028b4, 0x28b4, false},
+	{"braille_dots_13568", 0x10028b5, 0x28b5, false},
+	{"braille_dots_23568", 0x10028b6, 0x28b6, false},
+	{"braille_dots_123568", 0x10028b7, 0x28b7, false},
+	{"braille_dots_4568", 0x10028b8, 0x28b8, false},
+	{"braille_dots_14568", 0x10028b9, 0x28b9, false},
+	{"braille_dots_24568", 0x10028ba, 0x28ba, false},
+	{"braille_dots_124568", 0x10028bb, 0x28bb, false},
+	{"braille_dots_34568", 0x10028bc, 0x28bc, false},
+	{"braille_dots_134568", 0x10028bd, 0x28bd, false},
+	{"braille_dots_234568", 0x10028be, 0x28be, false},
+	{"braille_dots_1234568", 0x10028bf, 0x28bf, false},
+	{"braille_dots_78", 0x10028c0, 0x28c0, false},
+	{"braille_dots_178", 0x10028c1, 0x28c1, false},
+	{"braille_dots_278", 0x10028c2, 0x28c2, false},
+	{"braille_dots_1278", 0x10028c3, 0x28c3, false},
+	{"braille_dots_378", 0x10028c4, 0x28c4, false},
+	{"braille_dots_1378", 0x10028c5, 0x28c5, false},
+	{"braille_dots_2378", 0x10028c6, 0x28c6, false},
+	{"braille_dots_12378", 0x10028c7, 0x28c7, false},
+	{"braille_dots_478", 0x10028c8, 0x28c8, false},
+	{"braille_dots_1478", 0x10028c9, 0x28c9, false},
+	{"braille_dots_2478", 0x10028ca, 0x28ca, false},
+	{"braille_dots_12478", 0x10028cb, 0x28cb, false},
+	{"braille_dots_3478", 0x10028cc, 0x28cc, false},
+	{"braille_dots_13478", 0x10028cd, 0x28cd, false},
+	{"braille_dots_23478", 0x10028ce, 0x28ce, false},
+	{"braille_dots_123478", 0x10028cf, 0x28cf, false},
+	{"braille_dots_578", 0x10028d0, 0x28d0, false},
+	{"braille_dots_1578", 0x10028d1, 0x28d1, false},
+	{"braille_dots_2578", 0x10028d2, 0x28d2, false},
+	{"braille_dots_12578", 0x10028d3, 0x28d3, false},
+	{"braille_dots_3578", 0x10028d4, 0x28d4, false},
+	{"braille_dots_13578", 0x10028d5, 0x28d5, false},
+	{"braille_dots_23578", 0x10028d6, 0x28d6, false},
+	{"braille_dots_123578", 0x10028d7, 0x28d7, false},
+	{"braille_dots_4578", 0x10028d8, 0x28d8, false},
+	{"braille_dots_14578", 0x10028d9, 0x28d9, false},
+	{"braille_dots_24578", 0x10028da, 0x28da, false},
+	{"braille_dots_124578", 0x10028db, 0x28db, false},
+	{"braille_dots_34578", 0x10028dc, 0x28dc, false},
+	{"braille_dots_134578", 0x10028dd, 0x28dd, false},
+	{"braille_dots_234578", 0x10028de, 0x28de, false},
+	{"braille_dots_1234578", 0x10028df, 0x28df, false},
+	{"braille_dots_678", 0x10028e0, 0x28e0, false},
+	{"braille_dots_1678", 0x10028e1, 0x28e1, false},
+	{"braille_dots_2678", 0x10028e2, 0x28e2, false},
+	{"braille_dots_12678", 0x10028e3, 0x28e3, false},
+	{"braille_dots_3678", 0x10028e4, 0x28e4, false},
+	{"braille_dots_13678", 0x10028e5, 0x28e5, false},
+	{"braille_dots_23678", 0x10028e6, 0x28e6, false},
+	{"braille_dots_123678", 0x10028e7, 0x28e7, false},
+	{"braille_dots_4678", 0x10028e8, 0x28e8, false},
+	{"braille_dots_14678", 0x10028e9, 0x28e9, false},
+	{"braille_dots_24678", 0x10028ea, 0x28ea, false},
+	{"braille_dots_124678", 0x10028eb, 0x28eb, false},
+	{"braille_dots_34678", 0x10028ec, 0x28ec, false},
+	{"braille_dots_134678", 0x10028ed, 0x28ed, false},
+	{"braille_dots_234678", 0x10028ee, 0x28ee, false},
+	{"braille_dots_1234678", 0x10028ef, 0x28ef, false},
+	{"braille_dots_5678", 0x10028f0, 0x28f0, false},
+	{"braille_dots_15678", 0x10028f1, 0x28f1, false},
+	{"braille_dots_25678", 0x10028f2, 0x28f2, false},
+	{"braille_dots_125678", 0x10028f3, 0x28f3, false},
+	{"braille_dots_35678", 0x10028f4, 0x28f4, false},
+	{"braille_dots_135678", 0x10028f5, 0x28f5, false},
+	{"braille_dots_235678", 0x10028f6, 0x28f6, false},
+	{"braille_dots_1235678", 0x10028f7, 0x28f7, false},
+	{"braille_dots_45678", 0x10028f8, 0x28f8, false},
+	{"braille_dots_145678", 0x10028f9, 0x28f9, false},
+	{"braille_dots_245678", 0x10028fa, 0x28fa, false},
+	{"braille_dots_1245678", 0x10028fb, 0x28fb, false},
+	{"braille_dots_345678", 0x10028fc, 0x28fc, false},
+	{"braille_dots_1345678", 0x10028fd, 0x28fd, false},
+	{"braille_dots_2345678", 0x10028fe, 0x28fe, false},
+	{"braille_dots_12345678", 0x10028ff, 0x28ff, false},
+	{"Sinh_ng", 0x1000d82, 0x0D82, false},
+	{"Sinh_h2", 0x1000d83, 0x0D83, false},
+	{"Sinh_a", 0x1000d85, 0x0D85, false},
+	{"Sinh_aa", 0x1000d86, 0x0D86, false},
+	{"Sinh_ae", 0x1000d87, 0x0D87, false},
+	{"Sinh_aee", 0x1000d88, 0x0D88, false},
+	{"Sinh_i", 0x1000d89, 0x0D89, false},
+	{"Sinh_ii", 0x1000d8a, 0x0D8A, false},
+	{"Sinh_u", 0x1000d8b, 0x0D8B, false},
+	{"Sinh_uu", 0x1000d8c, 0x0D8C, false},
+	{"Sinh_ri", 0x1000d8d, 0x0D8D, false},
+	{"Sinh_rii", 0x1000d8e, 0x0D8E, false},
+	{"Sinh_lu", 0x1000d8f, 0x0D8F, false},
+	{"Sinh_luu", 0x1000d90, 0x0D90, false},
+	{"Sinh_e", 0x1000d91, 0x0D91, false},
+	{"Sinh_ee", 0x1000d92, 0x0D92, false},
+	{"Sinh_ai", 0x1000d93, 0x0D93, false},
+	{"Sinh_o", 0x1000d94, 0x0D94, false},
+	{"Sinh_oo", 0x1000d95, 0x0D95, false},
+	{"Sinh_au", 0x1000d96, 0x0D96, false},
+	{"Sinh_ka", 0x1000d9a, 0x0D9A, false},
+	{"Sinh_kha", 0x1000d9b, 0x0D9B, false},
+	{"Sinh_ga", 0x1000d9c, 0x0D9C, false},
+	{"Sinh_gha", 0x1000d9d, 0x0D9D, false},
+	{"Sinh_ng2", 0x1000d9e, 0x0D9E, false},
+	{"Sinh_nga", 0x1000d9f, 0x0D9F, false},
+	{"Sinh_ca", 0x1000da0, 0x0DA0, false},
+	{"Sinh_cha", 0x1000da1, 0x0DA1, false},
+	{"Sinh_ja", 0x1000da2, 0x0DA2, false},
+	{"Sinh_jha", 0x1000da3, 0x0DA3, false},
+	{"Sinh_nya", 0x1000da4, 0x0DA4, false},
+	{"Sinh_jnya", 0x1000da5, 0x0DA5, false},
+	{"Sinh_nja", 0x1000da6, 0x0DA6, false},
+	{"Sinh_tta", 0x1000da7, 0x0DA7, false},
+	{"Sinh_ttha", 0x1000da8, 0x0DA8, false},
+	{"Sinh_dda", 0x1000da9, 0x0DA9, false},
+	{"Sinh_ddha", 0x1000daa, 0x0DAA, false},
+	{"Sinh_nna", 0x1000dab, 0x0DAB, false},
+	{"Sinh_ndda", 0x1000dac, 0x0DAC, false},
+	{"Sinh_tha", 0x1000dad, 0x0DAD, false},
+	{"Sinh_thha", 0x1000dae, 0x0DAE, false},
+	{"Sinh_dha", 0x1000daf, 0x0DAF, false},
+	{"Sinh_dhha", 0x1000db0, 0x0DB0, false},
+	{"Sinh_na", 0x1000db1, 0x0DB1, false},
+	{"Sinh_ndha", 0x1000db3, 0x0DB3, false},
+	{"Sinh_pa", 0x1000db4, 0x0DB4, false},
+	{"Sinh_pha", 0x1000db5, 0x0DB5, false},
+	{"Sinh_ba", 0x1000db6, 0x0DB6, false},
+	{"Sinh_bha", 0x1000db7, 0x0DB7, false},
+	{"Sinh_ma", 0x1000db8, 0x0DB8, false},
+	{"Sinh_mba", 0x1000db9, 0x0DB9, false},
+	{"Sinh_ya", 0x1000dba, 0x0DBA, false},
+	{"Sinh_ra", 0x1000dbb, 0x0DBB, false},
+	{"Sinh_la", 0x1000dbd, 0x0DBD, false},
+	{"Sinh_va", 0x1000dc0, 0x0DC0, false},
+	{"Sinh_sha", 0x1000dc1, 0x0DC1, false},
+	{"Sinh_ssha", 0x1000dc2, 0x0DC2, false},
+	{"Sinh_sa", 0x1000dc3, 0x0DC3, false},
+	{"Sinh_ha", 0x1000dc4, 0x0DC4, false},
+	{"Sinh_lla", 0x1000dc5, 0x0DC5, false},
+	{"Sinh_fa", 0x1000dc6, 0x0DC6, false},
+	{"Sinh_al", 0x1000dca, 0x0DCA, false},
+	{"Sinh_aa2", 0x1000dcf, 0x0DCF, false},
+	{"Sinh_ae2", 0x1000dd0, 0x0DD0, false},
+	{"Sinh_aee2", 0x1000dd1, 0x0DD1, false},
+	{"Sinh_i2", 0x1000dd2, 0x0DD2, false},
+	{"Sinh_ii2", 0x1000dd3, 0x0DD3, false},
+	{"Sinh_u2", 0x1000dd4, 0x0DD4, false},
+	{"Sinh_uu2", 0x1000dd6, 0x0DD6, false},
+	{"Sinh_ru2", 0x1000dd8, 0x0DD8, false},
+	{"Sinh_e2", 0x1000dd9, 0x0DD9, false},
+	{"Sinh_ee2", 0x1000dda, 0x0DDA, false},
+	{"Sinh_ai2", 0x1000ddb, 0x0DDB, false},
+	{"Sinh_o2", 0x1000ddc, 0x0DDC, false},
+	{"Sinh_oo2", 0x1000ddd, 0x0DDD, false},
+	{"Sinh_au2", 0x1000dde, 0x0DDE, false},
+	{"Sinh_lu2", 0x1000ddf, 0x0DDF, false},
+	{"Sinh_ruu2", 0x1000df2, 0x0DF2, false},
+	{"Sinh_luu2", 0x1000df3, 0x0DF3, false},
+	{"Sinh_kunddaliya", 0x1000df4, 0x0DF4, false},
+}
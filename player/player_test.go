@@ -1,55 +1,128 @@
 package player
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/exoscale/vncproxy/automation"
 	"github.com/exoscale/vncproxy/common"
 	"github.com/exoscale/vncproxy/encodings"
-	"github.com/exoscale/vncproxy/logger"
 	"github.com/exoscale/vncproxy/server"
 )
 
-func TestServer(t *testing.T) {
-	encs := []common.IEncoding{
-		&encodings.RawEncoding{},
-		&encodings.TightEncoding{},
-		&encodings.EncCursorPseudo{},
-		&encodings.EncLedStatePseudo{},
-		&encodings.RREEncoding{},
-		&encodings.ZLibEncoding{},
-		&encodings.ZRLEEncoding{},
-		&encodings.CopyRectEncoding{},
-		&encodings.CoRREEncoding{},
-		&encodings.HextileEncoding{},
+// fbsBlock appends an FBS block: the size, the data padded to 4 bytes & the timestamp
+func fbsBlock(buf *bytes.Buffer, data []byte, timestamp uint32) {
+	binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	buf.Write(data)
+	buf.Write(make([]byte, (4-len(data)%4)%4))
+	binary.Write(buf, binary.BigEndian, timestamp)
+}
+
+// rawUpdate is a FramebufferUpdate with a raw rectangle of a single color
+func rawUpdate(pf *common.PixelFormat, r image.Rectangle, c color.RGBA) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{byte(common.FramebufferUpdate), 0})
+	binary.Write(&buf, binary.BigEndian, []uint16{1, uint16(r.Min.X), uint16(r.Min.Y), uint16(r.Dx()), uint16(r.Dy())})
+	binary.Write(&buf, binary.BigEndian, int32(common.EncRaw))
+	pixel := make([]byte, 4)
+	if pf.BigEndian != 0 {
+		binary.BigEndian.PutUint32(pixel, encodings.PixelValue(pf, c))
+	} else {
+		binary.LittleEndian.PutUint32(pixel, encodings.PixelValue(pf, c))
+	}
+	for i := 0; i < r.Dx()*r.Dy(); i++ {
+		buf.Write(pixel)
+	}
+	return buf.Bytes()
+}
+
+// writeFbs writes a recording of a 64x48 screen: red, then a blue square 100ms later
+func writeFbs(t *testing.T, pf *common.PixelFormat) string {
+	var init bytes.Buffer
+	init.WriteString(server.ProtoVersion38)
+	binary.Write(&init, binary.BigEndian, uint32(server.SecTypeNone))
+	binary.Write(&init, binary.BigEndian, []uint16{64, 48})
+	binary.Write(&init, binary.BigEndian, pf)
+	init.Write(make([]byte, 3))
+	binary.Write(&init, binary.BigEndian, uint32(len("recorded")))
+	init.WriteString("recorded")
+
+	var fbs bytes.Buffer
+	fbs.WriteString("FBS 001.000\n")
+	fbsBlock(&fbs, init.Bytes(), 0)
+	fbsBlock(&fbs, rawUpdate(pf, image.Rect(0, 0, 64, 48), color.RGBA{0xff, 0, 0, 0xff}), 0)
+	fbsBlock(&fbs, rawUpdate(pf, image.Rect(16, 16, 32, 32), color.RGBA{0, 0, 0xff, 0xff}), 100)
+
+	path := filepath.Join(t.TempDir(), "recording.rbs")
+	if err := ioutil.WriteFile(path, fbs.Bytes(), 0644); err != nil {
+		t.Fatal(err)
 	}
+	return path
+}
+
+func TestServer(t *testing.T) {
+	pf := common.NewPixelFormat(32)
+	fbsFile := writeFbs(t, pf)
 
 	cfg := &server.ServerConfig{
 		SecurityHandlers: []server.SecurityHandler{&server.ServerAuthNone{}},
-		Encodings:        encs,
-		PixelFormat:      common.NewPixelFormat(32),
+		Encodings:        []common.IEncoding{&encodings.RawEncoding{}},
+		PixelFormat:      pf,
 		ClientMessages:   server.DefaultClientMessages,
 		DesktopName:      []byte("workDesk"),
 		Height:           uint16(768),
 		Width:            uint16(1024),
 	}
-
 	cfg.NewConnHandler = func(cfg *server.ServerConfig, conn *server.ServerConn) error {
-		fbs, err := ConnectFbsFile("/Users/amitbet/vncRec/recording.rbs", conn)
-
+		fbs, err := ConnectFbsFile(fbsFile, conn)
 		if err != nil {
-			logger.Error("TestServer.NewConnHandler: Error in loading FBS: ", err)
 			return err
 		}
 		conn.Listeners.AddListener(NewFBSPlayListener(conn, fbs))
 		return nil
 	}
 
-	url := "http://localhost:7777/"
-	go server.WsServe(url, cfg)
-	go server.TcpServe(":5904", cfg)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+	go server.TcpServe("127.0.0.1:"+port, cfg)
 
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var c *automation.Client
 	for {
-		time.Sleep(time.Minute)
+		c, err = automation.Dial(ctx, "127.0.0.1:"+port, nil)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("connecting to the player: %s", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer c.Close()
+
+	// the size is the recording's one
+	if w, h := c.Size(); w != 64 || h != 48 {
+		t.Fatalf("unexpected size %dx%d", w, h)
+	}
+	expected := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	draw.Draw(expected, expected.Rect, image.NewUniform(color.RGBA{0xff, 0, 0, 0xff}), image.Point{}, draw.Src)
+	draw.Draw(expected, image.Rect(16, 16, 32, 32), image.NewUniform(color.RGBA{0, 0, 0xff, 0xff}), image.Point{}, draw.Src)
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, expected, 0); err != nil {
+		t.Fatalf("replayed screen: %s", err)
 	}
 }
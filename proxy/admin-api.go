@@ -0,0 +1,398 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/encodings"
+	"github.com/exoscale/vncproxy/keysym"
+	"github.com/exoscale/vncproxy/logger"
+	"github.com/exoscale/vncproxy/server"
+)
+
+// The admin api injects input into a session, as if a vnc-client had sent it:
+//
+//	POST /sessions/{id}/keys         {"keys": ["ctrl+alt+F2", "Return"], "delay_ms": 10}
+//	POST /sessions/{id}/type         {"text": "linux single\n", "delay_ms": 10}
+//	POST /sessions/{id}/pointer      {"x": 10, "y": 20, "buttons": 1, "click": true}
+//	POST /sessions/{id}/ctrl-alt-del
+//
+// The input goes through the upstream of the last vnc-client connected to the session, or through
+// an upstream connection opened by the proxy when no vnc-client is there.
+
+// adminIdleTimeout is how long the proxy keeps an upstream opened by the admin api
+const adminIdleTimeout = time.Minute
+
+// maxAdminBodySize limits the admin api's request bodies
+const maxAdminBodySize = 1 << 20
+
+// maxAdminDelay bounds the pause between the events of a request
+const maxAdminDelay = time.Second
+
+// singleSessionId is the session id of the vnc-clients when the proxy isn't using sessions
+const singleSessionId = "dummySession"
+
+// adminUpstream is an upstream connection opened by the admin api, it is closed after adminIdleTimeout
+// without use, or when a vnc-client connects to the session
+type adminUpstream struct {
+	updater *ClientUpdater
+	timer   *time.Timer
+}
+
+// adminError is an admin api error with its http status
+type adminError struct {
+	status int
+	msg    string
+}
+
+func (e *adminError) Error() string {
+	return e.msg
+}
+
+func adminErrorf(status int, format string, v ...interface{}) error {
+	return &adminError{status: status, msg: fmt.Sprintf(format, v...)}
+}
+
+// liveSessionId returns the admin api's id of a vnc-client's session: without sessions, the websocket
+// vnc-clients' ids are their URL path (or token claim) until the ServerInit, while all of them are in the
+// single session, unless the path also picks the vnc server (dynamic lookup)
+func (vp *VncProxy) liveSessionId(sessionId string) string {
+	if !vp.UsingSessions && !vp.DynamicLookup {
+		return singleSessionId
+	}
+	return sessionId
+}
+
+// addLiveSession makes the vnc-client's upstream usable by the admin api until the vnc-client leaves,
+// liveSessionCloser must be listening to the vnc-client
+func (vp *VncProxy) addLiveSession(sconn *server.ServerConn, updater *ClientUpdater) {
+	sessionId := vp.liveSessionId(sconn.SessionId)
+	vp.liveLock.Lock()
+	if vp.liveSessions == nil {
+		vp.liveSessions = make(map[string][]*ClientUpdater)
+	}
+	vp.liveSessions[sessionId] = append(vp.liveSessions[sessionId], updater)
+	admin := vp.adminUpstreams[sessionId]
+	delete(vp.adminUpstreams, sessionId)
+	vp.liveLock.Unlock()
+
+	if admin != nil {
+		logger.Debugf("admin api: session=%q vnc-client connected, closing the admin upstream", sessionId)
+		admin.timer.Stop()
+		admin.updater.conn.Close()
+	}
+}
+
+func (vp *VncProxy) removeLiveSession(sessionId string, updater *ClientUpdater) {
+	sessionId = vp.liveSessionId(sessionId)
+	vp.liveLock.Lock()
+	defer vp.liveLock.Unlock()
+	updaters := vp.liveSessions[sessionId]
+	for i, u := range updaters {
+		if u == updater {
+			updaters = append(updaters[:i:i], updaters[i+1:]...)
+			break
+		}
+	}
+	if len(updaters) == 0 {
+		delete(vp.liveSessions, sessionId)
+	} else {
+		vp.liveSessions[sessionId] = updaters
+	}
+}
+
+// liveSessionCloser forgets the vnc-client's upstream when the vnc-client leaves
+type liveSessionCloser struct {
+	vp        *VncProxy
+	sessionId string
+	updater   *ClientUpdater
+}
+
+func (l *liveSessionCloser) Consume(seg *common.RfbSegment) error {
+	if seg.SegmentType == common.SegmentConnectionClosed {
+		l.vp.removeLiveSession(l.sessionId, l.updater)
+	}
+	return nil
+}
+
+// adminUpstreamCloser forgets the admin upstream when the vnc server closes it
+type adminUpstreamCloser struct {
+	vp        *VncProxy
+	sessionId string
+	updater   *ClientUpdater
+}
+
+func (a *adminUpstreamCloser) Consume(seg *common.RfbSegment) error {
+	if seg.SegmentType == common.SegmentConnectionClosed {
+		a.vp.liveLock.Lock()
+		if admin := a.vp.adminUpstreams[a.sessionId]; admin != nil && admin.updater == a.updater {
+			admin.timer.Stop()
+			delete(a.vp.adminUpstreams, a.sessionId)
+		}
+		a.vp.liveLock.Unlock()
+	}
+	return nil
+}
+
+// sessionInput returns where to write the session's input, and whether a vnc-client is connected
+func (vp *VncProxy) sessionInput(sessionId string) (*ClientUpdater, bool, error) {
+	vp.liveLock.Lock()
+	if updaters := vp.liveSessions[sessionId]; len(updaters) > 0 {
+		vp.liveLock.Unlock()
+		return updaters[len(updaters)-1], true, nil
+	}
+	if admin := vp.adminUpstreams[sessionId]; admin != nil {
+		admin.timer.Reset(adminIdleTimeout)
+		vp.liveLock.Unlock()
+		return admin.updater, false, nil
+	}
+	vp.liveLock.Unlock()
+
+	updater, err := vp.openAdminUpstream(sessionId)
+	if err != nil {
+		return nil, false, err
+	}
+
+	vp.liveLock.Lock()
+	defer vp.liveLock.Unlock()
+	// another request or a vnc-client may have connected meanwhile
+	if updaters := vp.liveSessions[sessionId]; len(updaters) > 0 {
+		updater.conn.Close()
+		return updaters[len(updaters)-1], true, nil
+	}
+	if admin := vp.adminUpstreams[sessionId]; admin != nil {
+		updater.conn.Close()
+		admin.timer.Reset(adminIdleTimeout)
+		return admin.updater, false, nil
+	}
+	if vp.adminUpstreams == nil {
+		vp.adminUpstreams = make(map[string]*adminUpstream)
+	}
+	vp.adminUpstreams[sessionId] = &adminUpstream{
+		updater: updater,
+		timer: time.AfterFunc(adminIdleTimeout, func() {
+			logger.Debugf("admin api: session=%q idle, closing the admin upstream", sessionId)
+			updater.conn.Close()
+		}),
+	}
+	return updater, false, nil
+}
+
+// openAdminUpstream connects to the session's vnc server, as a shared client which never asks for updates
+func (vp *VncProxy) openAdminUpstream(sessionId string) (*ClientUpdater, error) {
+	session, err := vp.getProxySession(sessionId)
+	if err != nil || session == nil {
+		return nil, adminErrorf(http.StatusNotFound, "unknown session")
+	}
+	if session.Type != SessionTypeProxyPass && session.Type != SessionTypeRecordingProxy {
+		return nil, adminErrorf(http.StatusConflict, "not a proxied session")
+	}
+	if vp.RelayAuth {
+		return nil, adminErrorf(http.StatusConflict, "no vnc-client connected, the proxy can't authenticate with relayed auth")
+	}
+
+	keyRemapper, err := vp.sessionKeyRemapper(session)
+	if err != nil {
+		return nil, adminErrorf(http.StatusInternalServerError, "%s", err)
+	}
+	cconn, err := vp.connectUpstream(session, sessionId, false)
+	if err != nil {
+		return nil, adminErrorf(http.StatusBadGateway, "%s", err)
+	}
+	updater := &ClientUpdater{conn: cconn, sessionId: sessionId, keyRemapper: keyRemapper}
+	cconn.Listeners.AddListener(&adminUpstreamCloser{vp, sessionId, updater})
+	cconn.Encs = []common.IEncoding{&encodings.RawEncoding{}}
+	if err := cconn.Connect(); err != nil {
+		return nil, adminErrorf(http.StatusBadGateway, "vnc server: %s", err)
+	}
+	logger.Infof("admin api: session=%q no vnc-client, opened an upstream connection", sessionId)
+	return updater, nil
+}
+
+func (vp *VncProxy) serveAdminAPI() {
+	logger.Infof("running admin api on %s", vp.AdminListeningUrl)
+	if vp.AdminToken == "" {
+		logger.Warn("admin api without token, anyone reaching it can type into the sessions")
+	}
+	if err := http.ListenAndServe(vp.AdminListeningUrl, vp.AdminHandler()); err != nil {
+		logger.Errorf("admin api: %s", err)
+	}
+}
+
+// AdminHandler returns the http handler of the admin api
+func (vp *VncProxy) AdminHandler() http.Handler {
+	return http.HandlerFunc(vp.serveAdmin)
+}
+
+// adminRequest is the body of all the admin api requests, each action uses its own fields
+type adminRequest struct {
+	Keys    []string `json:"keys"`
+	Text    string   `json:"text"`
+	X       int      `json:"x"`
+	Y       int      `json:"y"`
+	Buttons uint8    `json:"buttons"`
+	Click   bool     `json:"click"`
+	// DelayMs is the pause between events, 0-1000, defaults to 10ms
+	DelayMs *int `json:"delay_ms"`
+}
+
+type adminResponse struct {
+	Session string `json:"session"`
+	Events  int    `json:"events"`
+	// Viewer tells whether the input went through a vnc-client's connection
+	Viewer bool   `json:"viewer"`
+	Error  string `json:"error,omitempty"`
+}
+
+func writeAdminResponse(w http.ResponseWriter, status int, resp *adminResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (vp *VncProxy) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	if vp.AdminToken != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(vp.AdminToken)) != 1 {
+			logger.Warnf("admin api: rejecting %s %s from %s: bad token", r.Method, r.URL.Path, r.RemoteAddr)
+			writeAdminResponse(w, http.StatusUnauthorized, &adminResponse{Error: "unauthorized"})
+			return
+		}
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "sessions" || parts[1] == "" {
+		writeAdminResponse(w, http.StatusNotFound, &adminResponse{Error: "not found"})
+		return
+	}
+	sessionId, action := parts[1], parts[2]
+	resp := &adminResponse{Session: sessionId}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		resp.Error = "method not allowed"
+		writeAdminResponse(w, http.StatusMethodNotAllowed, resp)
+		return
+	}
+
+	req := &adminRequest{}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxAdminBodySize))
+	if err == nil && len(body) > 0 {
+		err = json.Unmarshal(body, req)
+	}
+	if err != nil {
+		resp.Error = "bad request: " + err.Error()
+		writeAdminResponse(w, http.StatusBadRequest, resp)
+		return
+	}
+
+	msgs, err := adminMessages(action, req)
+	var delay time.Duration
+	if err == nil {
+		delay, err = adminDelay(req)
+	}
+	if err == nil {
+		sessionId = vp.liveSessionId(sessionId)
+		resp.Events = len(msgs)
+		err = vp.injectInput(sessionId, msgs, delay, resp)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if adminErr, ok := err.(*adminError); ok {
+			status = adminErr.status
+		}
+		logger.Warnf("admin api: session=%q %s from %s failed: %s", resp.Session, action, r.RemoteAddr, err)
+		resp.Error = err.Error()
+		writeAdminResponse(w, status, resp)
+		return
+	}
+	// the typed text is never logged, it may hold secrets
+	logger.Infof("admin api: session=%q %s from %s: %d events (viewer connected: %t)", resp.Session, action, r.RemoteAddr, resp.Events, resp.Viewer)
+	writeAdminResponse(w, http.StatusOK, resp)
+}
+
+// adminDelay returns the pause between the events, from 0 to maxAdminDelay
+func adminDelay(req *adminRequest) (time.Duration, error) {
+	if req.DelayMs == nil {
+		return defaultPasteDelay, nil
+	}
+	if max := int(maxAdminDelay / time.Millisecond); *req.DelayMs < 0 || *req.DelayMs > max {
+		return 0, adminErrorf(http.StatusBadRequest, "delay_ms %d out of 0-%d", *req.DelayMs, max)
+	}
+	return time.Duration(*req.DelayMs) * time.Millisecond, nil
+}
+
+// adminMessages returns the client messages of an admin action
+func adminMessages(action string, req *adminRequest) ([]common.ClientMessage, error) {
+	var events []keysym.Event
+	switch action {
+	case "keys":
+		if len(req.Keys) == 0 {
+			return nil, adminErrorf(http.StatusBadRequest, "no keys")
+		}
+		for _, chord := range req.Keys {
+			keys, err := keysym.ParseChord(chord)
+			if err != nil {
+				return nil, adminErrorf(http.StatusBadRequest, "%s", err)
+			}
+			events = append(events, keysym.Chord(keys...)...)
+		}
+	case "type":
+		if req.Text == "" {
+			return nil, adminErrorf(http.StatusBadRequest, "no text")
+		}
+		if n := len([]rune(req.Text)); n > defaultPasteMaxLength {
+			return nil, adminErrorf(http.StatusBadRequest, "text longer than %d characters", defaultPasteMaxLength)
+		}
+		events = keysym.StringEvents(req.Text)
+	case "ctrl-alt-del":
+		events = keysym.Chord(keysym.ControlL, keysym.AltL, keysym.Delete)
+	case "pointer":
+		if req.X < 0 || req.X > 0xffff || req.Y < 0 || req.Y > 0xffff {
+			return nil, adminErrorf(http.StatusBadRequest, "bad position %d,%d", req.X, req.Y)
+		}
+		msgs := []common.ClientMessage{&server.MsgPointerEvent{Mask: req.Buttons, X: uint16(req.X), Y: uint16(req.Y)}}
+		if req.Click {
+			msgs = append(msgs, &server.MsgPointerEvent{X: uint16(req.X), Y: uint16(req.Y)})
+		}
+		return msgs, nil
+	default:
+		return nil, adminErrorf(http.StatusNotFound, "unknown action %s", action)
+	}
+
+	msgs := make([]common.ClientMessage, len(events))
+	for i, ev := range events {
+		var down uint8
+		if ev.Down {
+			down = 1
+		}
+		msgs[i] = &server.MsgKeyEvent{Down: down, Key: server.Key(ev.Keysym)}
+	}
+	return msgs, nil
+}
+
+// injectInput writes the messages to the session's vnc server, pausing delay between them
+func (vp *VncProxy) injectInput(sessionId string, msgs []common.ClientMessage, delay time.Duration, resp *adminResponse) error {
+	updater, viewer, err := vp.sessionInput(sessionId)
+	if err != nil {
+		return err
+	}
+	resp.Viewer = viewer
+	for i, msg := range msgs {
+		if i > 0 && delay > 0 {
+			time.Sleep(delay)
+		}
+		if err := updater.inject(msg); err == errUpstreamNotConnected {
+			return adminErrorf(http.StatusServiceUnavailable, "%s", err)
+		} else if err != nil {
+			return adminErrorf(http.StatusBadGateway, "writing to the vnc server: %s", err)
+		}
+	}
+	return nil
+}
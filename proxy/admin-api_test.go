@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/exoscale/vncproxy/client"
+	"github.com/exoscale/vncproxy/server"
+)
+
+func TestAdminAPI(t *testing.T) {
+	vp := &VncProxy{UsingSessions: true, AdminToken: "secret"}
+
+	// a vnc-client is connected to session s1, its upstream is a pipe
+	upstream, vncServer := net.Pipe()
+	defer vncServer.Close()
+	cconn, _ := client.NewClientConn(upstream, &client.ClientConfig{})
+	vp.liveSessions = map[string][]*ClientUpdater{"s1": {{conn: cconn, sessionId: "s1"}}}
+	// the vnc-client of s3 waits for its vnc server (placeholder, reconnection)
+	vp.liveSessions["s3"] = []*ClientUpdater{{sessionId: "s3"}}
+	received := make(chan []byte, 1)
+	go func() {
+		// ctrl+alt+Delete: 6 key events of 8 bytes
+		buf := make([]byte, 48)
+		io.ReadFull(vncServer, buf)
+		received <- buf
+	}()
+
+	ts := httptest.NewServer(vp.AdminHandler())
+	defer ts.Close()
+	post := func(path, token, body string) (int, *adminResponse) {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST %s: %s", path, err)
+		}
+		defer res.Body.Close()
+		resp := &adminResponse{}
+		json.NewDecoder(res.Body).Decode(resp)
+		return res.StatusCode, resp
+	}
+
+	if status, _ := post("/sessions/s1/ctrl-alt-del", "wrong", ""); status != http.StatusUnauthorized {
+		t.Errorf("bad token: got status %d", status)
+	}
+	if status, resp := post("/sessions/s1/keys", "secret", `{"keys": ["ctrl+nokey"]}`); status != http.StatusBadRequest {
+		t.Errorf("bad chord: got status %d (%+v)", status, resp)
+	}
+	if status, resp := post("/sessions/s2/ctrl-alt-del", "secret", ""); status != http.StatusNotFound {
+		t.Errorf("unknown session: got status %d (%+v)", status, resp)
+	}
+	if status, resp := post("/sessions/s3/ctrl-alt-del", "secret", ""); status != http.StatusServiceUnavailable {
+		t.Errorf("vnc server not connected: got status %d (%+v)", status, resp)
+	}
+	for _, delay := range []string{"-1", "1001", "9223372036854775807"} {
+		if status, resp := post("/sessions/s1/ctrl-alt-del", "secret", `{"delay_ms": `+delay+`}`); status != http.StatusBadRequest {
+			t.Errorf("delay_ms %s: got status %d (%+v)", delay, status, resp)
+		}
+	}
+
+	status, resp := post("/sessions/s1/ctrl-alt-del", "secret", `{"delay_ms": 0}`)
+	if status != http.StatusOK || resp.Events != 6 || !resp.Viewer {
+		t.Fatalf("ctrl-alt-del: got status %d (%+v)", status, resp)
+	}
+	data := <-received
+	expected := []server.MsgKeyEvent{{Down: 1, Key: 0xffe3}, {Down: 1, Key: 0xffe9}, {Down: 1, Key: 0xffff},
+		{Key: 0xffff}, {Key: 0xffe9}, {Key: 0xffe3}}
+	for i, ev := range expected {
+		msg, err := new(server.MsgKeyEvent).Read(bytes.NewReader(data[8*i+1 : 8*i+8]))
+		if err != nil {
+			t.Fatalf("reading event %d: %s", i, err)
+		}
+		if got := *msg.(*server.MsgKeyEvent); data[8*i] != 4 || got != ev {
+			t.Errorf("event %d: got %+v, expected %+v", i, got, ev)
+		}
+	}
+}
+
+func TestAdminAPISingleSession(t *testing.T) {
+	vp := &VncProxy{AdminToken: "secret", SingleSession: &VncSession{ID: singleSessionId, Type: SessionTypeProxyPass}}
+
+	// a websocket vnc-client's session id is its URL path when it connects
+	sconnPipe, _ := net.Pipe()
+	sconn, err := server.NewServerConn(sconnPipe, &server.ServerConfig{ClientMessages: server.DefaultClientMessages}, "/vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream, vncServer := net.Pipe()
+	defer vncServer.Close()
+	go io.Copy(io.Discard, vncServer)
+	cconn, _ := client.NewClientConn(upstream, &client.ClientConfig{})
+	updater := &ClientUpdater{conn: cconn, sessionId: sconn.SessionId}
+	vp.addLiveSession(sconn, updater)
+
+	ts := httptest.NewServer(vp.AdminHandler())
+	defer ts.Close()
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/sessions/any/ctrl-alt-del", bytes.NewBufferString(`{"delay_ms": 0}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	resp := &adminResponse{}
+	json.NewDecoder(res.Body).Decode(resp)
+	if res.StatusCode != http.StatusOK || !resp.Viewer {
+		t.Errorf("the connected vnc-client wasn't used: got status %d (%+v)", res.StatusCode, resp)
+	}
+
+	vp.removeLiveSession(sconn.SessionId, updater)
+	if len(vp.liveSessions) != 0 {
+		t.Errorf("the vnc-client wasn't forgotten: %v", vp.liveSessions)
+	}
+}
+
+func TestAdminMessages(t *testing.T) {
+	msgs, err := adminMessages("pointer", &adminRequest{X: 10, Y: 20, Buttons: 1, Click: true})
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("click: got %v, %s", msgs, err)
+	}
+	if press := msgs[0].(*server.MsgPointerEvent); press.Mask != 1 || press.X != 10 || press.Y != 20 {
+		t.Errorf("unexpected press: %+v", press)
+	}
+	if release := msgs[1].(*server.MsgPointerEvent); release.Mask != 0 {
+		t.Errorf("unexpected release: %+v", release)
+	}
+
+	// A is typed with shift
+	if msgs, err := adminMessages("type", &adminRequest{Text: "A"}); err != nil || len(msgs) != 4 {
+		t.Errorf("type: got %d messages, %v", len(msgs), err)
+	}
+	if _, err := adminMessages("reboot", &adminRequest{}); err == nil {
+		t.Errorf("expected an error for an unknown action")
+	}
+	if _, err := adminMessages("pointer", &adminRequest{X: -1}); err == nil {
+		t.Errorf("expected an error for a bad position")
+	}
+}
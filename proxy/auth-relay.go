@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"errors"
+
+	"github.com/exoscale/vncproxy/client"
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/logger"
+	"github.com/exoscale/vncproxy/server"
+)
+
+// relayUpstreamAuth dials the session's vnc server and runs its security handshake with the
+// vnc-client's credentials: the upstream challenge is passed down to the vnc-client, and its
+// DES response is sent back up, so the proxy never learns the VNC password.
+func (vp *VncProxy) relayUpstreamAuth(c common.IServerConn) ([]byte, func([]byte) error, error) {
+	sconn := c.(*server.ServerConn)
+	session, err := vp.getProxySession(sconn.SessionId)
+	if err != nil || session == nil {
+		logger.Errorf("Proxy.relayUpstreamAuth can't get session: %s", sconn.SessionId)
+		return nil, nil, errors.New("unknown session")
+	}
+
+	challenges := make(chan []byte, 1)
+	responses := make(chan []byte, 1)
+	relayedAuth := &client.RelayedPasswordAuth{
+		Respond: func(challenge []byte) ([]byte, error) {
+			challenges <- challenge
+			response := <-responses
+			if response == nil {
+				return nil, errors.New("vnc-client aborted the relayed authentication")
+			}
+			return response, nil
+		},
+	}
+
+	cconn, err := vp.dialClientConnection(vp.sessionTarget(session, sconn.SessionId), []client.ClientAuth{relayedAuth}, true)
+	if err != nil {
+		session.Status = SessionStatusError
+		return nil, nil, errors.New("unable to connect to the vnc server")
+	}
+
+	authResult := make(chan error, 1)
+	go func() {
+		authResult <- cconn.Authenticate()
+	}()
+
+	var challenge []byte
+	select {
+	case challenge = <-challenges:
+	case err := <-authResult:
+		//the upstream server didn't ask for VNC auth (or failed before sending a challenge)
+		if err == nil {
+			cconn.Close()
+			err = errors.New("vnc server does not use VNC authentication")
+		}
+		logger.Errorf("Proxy.relayUpstreamAuth: upstream handshake failed: %s", err)
+		return nil, nil, err
+	}
+
+	respond := func(response []byte) error {
+		responses <- response
+		err := <-authResult
+		if err != nil {
+			logger.Warnf("Proxy.relayUpstreamAuth: upstream rejected the relayed authentication: %s", err)
+			return err
+		}
+		vp.addAuthenticatedUpstream(sconn, cconn)
+		return nil
+	}
+	return challenge, respond, nil
+}
@@ -0,0 +1,342 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/exoscale/vncproxy/auth"
+	"github.com/exoscale/vncproxy/client"
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/logger"
+	"github.com/exoscale/vncproxy/server"
+)
+
+// ClipboardDirection is the way clipboard content flows through the proxy
+type ClipboardDirection int
+
+const (
+	// ClipboardToServer is the viewer pasting into the console (ClientCutText)
+	ClipboardToServer ClipboardDirection = iota
+	// ClipboardToClient is the console's clipboard sent to the viewer (ServerCutText)
+	ClipboardToClient
+)
+
+func (d ClipboardDirection) String() string {
+	if d == ClipboardToServer {
+		return "vnc-client -> vnc-server"
+	}
+	return "vnc-server -> vnc-client"
+}
+
+// clipboard rule actions
+const (
+	ClipboardRuleRedact = "redact"
+	ClipboardRuleBlock  = "block"
+)
+
+const defaultClipboardReplacement = "[REDACTED]"
+
+// ClipboardRule matches clipboard text with a regular expression, and either replaces the matches
+// or blocks the whole transfer.
+type ClipboardRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	// Action is "redact" (default) or "block"
+	Action string `json:"action"`
+	// Replacement of redacted matches, defaults to [REDACTED]
+	Replacement string `json:"replacement"`
+	// Direction limits the rule to "to_server" or "to_client", empty = both
+	Direction string `json:"direction"`
+
+	re *regexp.Regexp
+}
+
+func (r *ClipboardRule) applies(dir ClipboardDirection) bool {
+	switch r.Direction {
+	case "to_server":
+		return dir == ClipboardToServer
+	case "to_client":
+		return dir == ClipboardToClient
+	}
+	return true
+}
+
+// ClipboardPolicy decides which clipboard content may go through the proxy. The zero value allows everything.
+type ClipboardPolicy struct {
+	DenyToServer bool `json:"deny_to_server"`
+	DenyToClient bool `json:"deny_to_client"`
+	// MaxSize is the largest clipboard content in bytes (all formats together), 0 = unlimited
+	MaxSize int             `json:"max_size"`
+	Rules   []ClipboardRule `json:"rules"`
+}
+
+// LoadClipboardPolicy reads a JSON ClipboardPolicy
+func LoadClipboardPolicy(path string) (*ClipboardPolicy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &ClipboardPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("clipboard policy %s: %v", path, err)
+	}
+	if err := policy.Compile(); err != nil {
+		return nil, fmt.Errorf("clipboard policy %s: %v", path, err)
+	}
+	return policy, nil
+}
+
+// Compile checks the rules and compiles their patterns, it must be called before using a policy with rules
+func (p *ClipboardPolicy) Compile() error {
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		switch rule.Action {
+		case "":
+			rule.Action = ClipboardRuleRedact
+		case ClipboardRuleRedact, ClipboardRuleBlock:
+		default:
+			return fmt.Errorf("rule %s: unknown action %s", rule.Name, rule.Action)
+		}
+		switch rule.Direction {
+		case "", "to_server", "to_client":
+		default:
+			return fmt.Errorf("rule %s: unknown direction %s", rule.Name, rule.Direction)
+		}
+		if rule.Replacement == "" {
+			rule.Replacement = defaultClipboardReplacement
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("rule %s: %v", rule.Name, err)
+		}
+		rule.re = re
+	}
+	return nil
+}
+
+// withClaims returns the policy narrowed by the clipboard rights of the viewer's token
+func (p *ClipboardPolicy) withClaims(claims *auth.Claims) *ClipboardPolicy {
+	narrowed := &ClipboardPolicy{}
+	if p != nil {
+		*narrowed = *p
+	}
+	if claims != nil {
+		narrowed.DenyToServer = narrowed.DenyToServer || !claims.CanWriteClipboard()
+		narrowed.DenyToClient = narrowed.DenyToClient || !claims.CanReadClipboard()
+	}
+	return narrowed
+}
+
+// denies tells whether all content is refused in this direction
+func (p *ClipboardPolicy) denies(dir ClipboardDirection) bool {
+	if dir == ClipboardToServer {
+		return p.DenyToServer
+	}
+	return p.DenyToClient
+}
+
+// inspects tells whether the content matters to the policy (content that can't be parsed is blocked then)
+func (p *ClipboardPolicy) inspects(dir ClipboardDirection) bool {
+	if p.denies(dir) || p.MaxSize > 0 {
+		return true
+	}
+	for i := range p.Rules {
+		if p.Rules[i].applies(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClipboardVerdict is the outcome of a clipboard policy check
+type ClipboardVerdict int
+
+const (
+	ClipboardAllowed ClipboardVerdict = iota
+	ClipboardRedacted
+	ClipboardBlocked
+)
+
+func (v ClipboardVerdict) String() string {
+	switch v {
+	case ClipboardRedacted:
+		return "redacted"
+	case ClipboardBlocked:
+		return "blocked"
+	}
+	return "allowed"
+}
+
+// filter applies the rules to text, returning the text to forward, the verdict and its reason
+func (p *ClipboardPolicy) filter(dir ClipboardDirection, text string) (string, ClipboardVerdict, string) {
+	if p.denies(dir) {
+		return "", ClipboardBlocked, "direction denied"
+	}
+	if p.MaxSize > 0 && len(text) > p.MaxSize {
+		return "", ClipboardBlocked, fmt.Sprintf("larger than %d bytes", p.MaxSize)
+	}
+
+	verdict := ClipboardAllowed
+	reason := ""
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !rule.applies(dir) || rule.re == nil || !rule.re.MatchString(text) {
+			continue
+		}
+		if rule.Action == ClipboardRuleBlock {
+			return "", ClipboardBlocked, "rule " + rule.Name
+		}
+		text = rule.re.ReplaceAllLiteralString(text, rule.Replacement)
+		verdict = ClipboardRedacted
+		if reason != "" {
+			reason += ", "
+		}
+		reason += "rule " + rule.Name
+	}
+	return text, verdict, reason
+}
+
+// logClipboardDecision logs every clipboard transfer going through the proxy (never its content)
+func logClipboardDecision(sessionId string, dir ClipboardDirection, kind string, size int, verdict ClipboardVerdict, reason string) {
+	if reason != "" {
+		reason = " (" + reason + ")"
+	}
+	logger.Infof("clipboard: session=%q %s: %s, %d bytes %s%s", sessionId, dir, kind, size, verdict, reason)
+}
+
+// filterExtended applies the policy to an extended clipboard message: the text is filtered, while RTF, HTML
+// & other formats are only kept when the text needed no change and they pass the rules & size limit themselves.
+// Messages without content (caps, request, peek & notify) are left alone.
+func (p *ClipboardPolicy) filterExtended(dir ClipboardDirection, ext *common.ExtendedClipboard) (*common.ExtendedClipboard, ClipboardVerdict, string) {
+	if ext.Action() != common.ClipboardProvide {
+		return ext, ClipboardAllowed, ""
+	}
+
+	text, verdict, reason := p.filter(dir, ext.Text())
+	if verdict == ClipboardBlocked {
+		return nil, verdict, reason
+	}
+
+	total := 0
+	keepOthers := verdict == ClipboardAllowed
+	hasOthers := false
+	for format, data := range ext.Data {
+		total += len(data)
+		if format == common.ClipboardText {
+			continue
+		}
+		hasOthers = true
+		if _, v, _ := p.filter(dir, string(data)); v != ClipboardAllowed {
+			keepOthers = false
+		}
+	}
+	if p.MaxSize > 0 && total > p.MaxSize {
+		keepOthers = false
+	}
+
+	if keepOthers || (!hasOthers && verdict == ClipboardAllowed) {
+		return ext, ClipboardAllowed, ""
+	}
+	if hasOthers {
+		if reason != "" {
+			reason += ", "
+		}
+		reason += "non-text formats dropped"
+	}
+	return common.NewClipboardProvide(text, "", ""), ClipboardRedacted, reason
+}
+
+// filterClientCutText applies the policy to the vnc-client's cut text, updating the message in place,
+// it returns false if the message must be dropped
+func (p *ClipboardPolicy) filterClientCutText(sessionId string, msg *server.MsgClientCutText) bool {
+	dir := ClipboardToServer
+	if !msg.IsExtended() {
+		text, verdict, reason := p.filter(dir, msg.TextContent())
+		logClipboardDecision(sessionId, dir, "text", len(msg.Text), verdict, reason)
+		if verdict == ClipboardRedacted {
+			msg.Text, _ = common.StringToLatin1(text)
+			msg.Length = uint32(len(msg.Text))
+		}
+		return verdict != ClipboardBlocked
+	}
+
+	if msg.Extended == nil {
+		if p.inspects(dir) {
+			logClipboardDecision(sessionId, dir, "extended (unparsed)", len(msg.Text), ClipboardBlocked, "can't be inspected")
+			return false
+		}
+		logClipboardDecision(sessionId, dir, "extended (unparsed)", len(msg.Text), ClipboardAllowed, "")
+		return true
+	}
+
+	ext, verdict, reason := p.filterExtended(dir, msg.Extended)
+	logExtendedClipboardDecision(sessionId, dir, msg.Extended, len(msg.Text), verdict, reason)
+	if verdict == ClipboardRedacted {
+		msg.Extended = ext
+		msg.Text = nil
+	}
+	return verdict != ClipboardBlocked
+}
+
+// filterServerCutText applies the policy to the vnc server's cut text, raw is the message as received.
+// It returns the bytes to send to the vnc-client, nil if the message must be dropped.
+func (p *ClipboardPolicy) filterServerCutText(sessionId string, msg *client.MsgServerCutText, raw []byte) []byte {
+	dir := ClipboardToClient
+	extended := len(raw) >= 8 && int32(binary.BigEndian.Uint32(raw[4:8])) < 0
+	if !extended {
+		text, verdict, reason := p.filter(dir, msg.Text)
+		logClipboardDecision(sessionId, dir, "text", len(raw)-8, verdict, reason)
+		switch verdict {
+		case ClipboardBlocked:
+			return nil
+		case ClipboardRedacted:
+			payload, _ := common.StringToLatin1(text)
+			return serverCutTextBytes(payload, false)
+		}
+		return raw
+	}
+
+	if msg.Extended == nil {
+		if p.inspects(dir) {
+			logClipboardDecision(sessionId, dir, "extended (unparsed)", len(raw)-8, ClipboardBlocked, "can't be inspected")
+			return nil
+		}
+		logClipboardDecision(sessionId, dir, "extended (unparsed)", len(raw)-8, ClipboardAllowed, "")
+		return raw
+	}
+
+	ext, verdict, reason := p.filterExtended(dir, msg.Extended)
+	logExtendedClipboardDecision(sessionId, dir, msg.Extended, len(raw)-8, verdict, reason)
+	switch verdict {
+	case ClipboardBlocked:
+		return nil
+	case ClipboardRedacted:
+		payload, err := ext.Bytes()
+		if err != nil {
+			logger.Errorf("ClipboardPolicy.filterServerCutText: error encoding the redacted clipboard: %s", err)
+			return nil
+		}
+		return serverCutTextBytes(payload, true)
+	}
+	return raw
+}
+
+// serverCutTextBytes builds a ServerCutText message
+func serverCutTextBytes(payload []byte, extended bool) []byte {
+	buf := make([]byte, 8, 8+len(payload))
+	buf[0] = uint8(common.ServerCutText)
+	binary.BigEndian.PutUint32(buf[4:], common.CutTextLength(payload, extended))
+	return append(buf, payload...)
+}
+
+// logExtendedClipboardDecision logs content transfers like logClipboardDecision, the other actions at debug level
+func logExtendedClipboardDecision(sessionId string, dir ClipboardDirection, ext *common.ExtendedClipboard, size int, verdict ClipboardVerdict, reason string) {
+	if ext.Action() != common.ClipboardProvide {
+		logger.Debugf("clipboard: session=%q %s: extended %s", sessionId, dir, ext)
+		return
+	}
+	logClipboardDecision(sessionId, dir, "extended "+ext.String(), size, verdict, reason)
+}
@@ -1,9 +1,20 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/exoscale/vncproxy/auth"
+	"github.com/exoscale/vncproxy/keyboard"
 	"github.com/exoscale/vncproxy/logger"
 	"github.com/exoscale/vncproxy/proxy"
 )
@@ -18,7 +29,44 @@ func main() {
 	var targetVncPort = flag.String("targPort", "", "target vnc server port (deprecated, use -target)")
 	var targetVncHost = flag.String("targHost", "", "target vnc server host (deprecated, use -target)")
 	var targetVncPass = flag.String("targPass", "", "target vnc password")
+	var targetVncUser = flag.String("targUser", "", "target username, for Apple Remote Desktop auth (macOS Screen Sharing)")
 	var dynamicLookup = flag.Bool("dynamicLookup", false, "lookup target UNIX socket path based on WebSocket URI")
+	var jwksFiles = flag.String("jwks", "", "comma separated JWKS files, when set ws connections require a JWT signed by one of their keys")
+	var jwtIssuer = flag.String("jwtIssuer", "", "required JWT issuer (iss claim)")
+	var jwtAudience = flag.String("jwtAudience", "", "required JWT audience (aud claim)")
+	var jwtCookie = flag.String("jwtCookie", "", "cookie holding the JWT when there is no Authorization header")
+	var jwtParam = flag.String("jwtParam", "token", "query parameter holding the JWT when there is no Authorization header")
+	var usersFile = flag.String("users", "", "JSON users file, enables VeNCrypt username/password auth on incoming vnc connections")
+	var tlsCert = flag.String("tlsCert", "", "certificate file for VeNCrypt TLS")
+	var tlsKey = flag.String("tlsKey", "", "key file for VeNCrypt TLS")
+	var rsaKeyFile = flag.String("rsaKey", "", "PEM RSA private key for RSA-AES auth (offered with -users or -vncPass), a key is generated at startup if not set")
+	var noRsaAes = flag.Bool("noRsaAes", false, "don't offer RSA-AES auth")
+	var totpRequired = flag.Bool("totp", false, "require a TOTP code (appended to the password) from all users")
+	var totpSkew = flag.Int("totpSkew", 1, "number of 30s TOTP steps accepted before and after the current one")
+	var vncTotpUser = flag.String("vncTotpUser", "", "user (from -users) whose TOTP code must follow -vncPass in classic VNC auth, -vncPass and the code must fit in 8 chars")
+	var relayAuth = flag.Bool("relayAuth", false, "relay VNC auth between the vnc-client and the target, the proxy never knows the password (replaces -vncPass/-targPass)")
+	var clipboardPolicyFile = flag.String("clipboardPolicy", "", "JSON clipboard policy: allowed directions, max size & redaction/blocking rules")
+	var pasteAsKeys = flag.Bool("pasteAsKeys", false, "type the vnc-client's clipboard as key events, for consoles without clipboard support")
+	var pasteLayout = flag.String("pasteLayout", "us", "keyboard layout of the target for -pasteAsKeys: us, fr or de")
+	var pasteDelay = flag.Duration("pasteDelay", 10*time.Millisecond, "pause between the key events typed by -pasteAsKeys")
+	var pasteQemu = flag.Bool("pasteQemu", false, "type with QEMU extended key events (scan codes) instead of keysyms")
+	var inputPolicyFile = flag.String("inputPolicy", "", "JSON input policy: blocked key chords, rate limits & regions where clicks are ignored")
+	var keyboardLayout = flag.String("keyboardLayout", "", "keyboard layout of the target (us, fr or de): key events are remapped to its scan codes with QEMU extended key events")
+	var adminListen = flag.String("adminListen", "", "address of the admin http api injecting keys, text & pointer events into the sessions (e.g. 127.0.0.1:8090)")
+	var adminToken = flag.String("adminToken", "", "bearer token required by the admin api")
+	var transcode = flag.Bool("transcode", false, "decode the target's updates and encode them again in the best encoding each vnc-client supports")
+	var transcodeEncodings = flag.String("transcodeEncodings", "", "comma separated encodings sent to the vnc-clients with -transcode (tight, zrle, hextile, rre, raw, copyrect), all by default")
+	var scaleMaxWidth = flag.Int("scaleMaxWidth", 0, "downscale the screen sent to the vnc-clients to this width at most (implies -transcode), ws clients may ask for their own with ?maxWidth=")
+	var scaleMaxHeight = flag.Int("scaleMaxHeight", 0, "downscale the screen sent to the vnc-clients to this height at most (implies -transcode), ws clients may ask for their own with ?maxHeight=")
+	var maskPolicyFile = flag.String("maskPolicy", "", "JSON privacy masks: screen rectangles or template images painted over for the vnc-clients and/or the recordings (implies -transcode)")
+	var watermark = flag.Bool("watermark", false, "tile a faint watermark with the viewer's user, the session & the connection time over the screen (implies -transcode)")
+	var banner = flag.String("banner", "", "banner shown at the top of the screen (implies -transcode)")
+	var recordingBanner = flag.Bool("recordingBanner", false, "show a \"this session is being recorded\" banner on the recorded sessions (implies -transcode)")
+	var placeholder = flag.Bool("placeholder", false, "show a status screen to the vnc-clients while the target is connecting or unavailable, retrying the connection (implies -transcode)")
+	var placeholderRetryDelay = flag.Duration("placeholderRetryDelay", 5*time.Second, "wait between the connection attempts with -placeholder")
+	var placeholderRetries = flag.Int("placeholderRetries", 0, "connection attempts after the first one before giving up with -placeholder, 0 = until the vnc-client leaves")
+	var reconnect = flag.Bool("reconnect", false, "keep the vnc-clients connected when the target connection is lost (VM reboot, migration), reconnecting with a backoff")
+	var reconnectTimeout = flag.Duration("reconnectTimeout", 2*time.Minute, "how long the vnc-clients wait for the target with -reconnect")
 	var logLevel = flag.String("logLevel", "info", "change logging level")
 
 	flag.Parse()
@@ -41,8 +89,174 @@ func main() {
 		os.Exit(1)
 	}
 
-	if *vncPass == "" {
-		logger.Warn("proxy will have no password")
+	var jwtVerifier *auth.JwtVerifier
+	if *jwksFiles != "" {
+		if *wsPort == "" {
+			logger.Error("JWT authentication requires specifying -wsPort")
+			os.Exit(1)
+		}
+		keys, err := auth.LoadKeySet(strings.Split(*jwksFiles, ",")...)
+		if err != nil {
+			logger.Errorf("unable to load JWKS files: %s", err)
+			os.Exit(1)
+		}
+		jwtVerifier = &auth.JwtVerifier{
+			Keys:       keys,
+			Issuer:     *jwtIssuer,
+			Audience:   *jwtAudience,
+			Leeway:     30 * time.Second,
+			CookieName: *jwtCookie,
+			QueryParam: *jwtParam,
+		}
+	}
+
+	if *relayAuth && (*vncPass != "" || *targetVncPass != "" || *targetVncUser != "" || *usersFile != "") {
+		logger.Error("-relayAuth can't be combined with -vncPass, -targPass, -targUser or -users")
+		os.Exit(1)
+	}
+
+	var users *auth.StaticUserStore
+	var totp *auth.TotpVerifier
+	var tlsConfig *tls.Config
+	if *usersFile != "" {
+		var err error
+		users, err = auth.LoadUserFile(*usersFile)
+		if err != nil {
+			logger.Errorf("unable to load users file: %s", err)
+			os.Exit(1)
+		}
+		totp = &auth.TotpVerifier{Users: users, Required: *totpRequired, Skew: *totpSkew}
+	} else if *totpRequired || *vncTotpUser != "" {
+		logger.Error("TOTP requires a users file (-users) holding the secrets")
+		os.Exit(1)
+	}
+
+	if jwtVerifier != nil && *tcpPort != "" && *vncPass == "" && users == nil && !*relayAuth {
+		logger.Error("-jwks only protects the websocket listener, -tcpPort requires -vncPass, -users or -relayAuth")
+		os.Exit(1)
+	}
+
+	if *vncTotpUser != "" && len(*vncPass)+totp.CodeLength() > 8 {
+		// classic VNC auth only carries 8 chars, VeNCrypt (-users) is the way to use TOTP with real passwords
+		logger.Errorf("-vncPass (%d chars) and the %d digit TOTP code don't fit in the 8 chars of classic VNC auth, use -users for TOTP",
+			len(*vncPass), totp.CodeLength())
+		os.Exit(1)
+	}
+	if *totpRequired && *vncPass != "" && *vncTotpUser == "" {
+		logger.Error("TOTP is required, but classic VNC auth has no -vncTotpUser")
+		os.Exit(1)
+	}
+
+	if *tlsCert != "" || *tlsKey != "" {
+		cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+		if err != nil {
+			logger.Errorf("unable to load TLS certificate: %s", err)
+			os.Exit(1)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	var rsaKey *rsa.PrivateKey
+	if !*noRsaAes && (users != nil || *vncPass != "") {
+		var err error
+		if *rsaKeyFile != "" {
+			rsaKey, err = loadRSAKey(*rsaKeyFile)
+		} else {
+			logger.Warn("no -rsaKey, generating an RSA key: RSA-AES clients will see a new server key on every restart")
+			rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		}
+		if err != nil {
+			logger.Errorf("unable to get the RSA key: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	var clipboardPolicy *proxy.ClipboardPolicy
+	if *clipboardPolicyFile != "" {
+		var err error
+		clipboardPolicy, err = proxy.LoadClipboardPolicy(*clipboardPolicyFile)
+		if err != nil {
+			logger.Errorf("unable to load clipboard policy: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	var inputPolicy *proxy.InputPolicy
+	if *inputPolicyFile != "" {
+		var err error
+		inputPolicy, err = proxy.LoadInputPolicy(*inputPolicyFile)
+		if err != nil {
+			logger.Errorf("unable to load input policy: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	var maskPolicy *proxy.MaskPolicy
+	if *maskPolicyFile != "" {
+		var err error
+		maskPolicy, err = proxy.LoadMaskPolicy(*maskPolicyFile)
+		if err != nil {
+			logger.Errorf("unable to load mask policy: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	if *keyboardLayout != "" {
+		if _, err := keyboard.LayoutByName(*keyboardLayout); err != nil {
+			logger.Errorf("bad -keyboardLayout: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	var pasteConfig *proxy.PasteConfig
+	if *pasteAsKeys {
+		if _, err := keyboard.LayoutByName(*pasteLayout); err != nil {
+			logger.Errorf("bad -pasteLayout: %s", err)
+			os.Exit(1)
+		}
+		pasteConfig = &proxy.PasteConfig{Layout: *pasteLayout, Delay: *pasteDelay, QEMUKeys: *pasteQemu}
+	}
+
+	var transcodeConfig *proxy.TranscodeConfig
+	if *transcode {
+		encs, err := proxy.ParseEncodings(*transcodeEncodings)
+		if err != nil {
+			logger.Errorf("bad -transcodeEncodings: %s", err)
+			os.Exit(1)
+		}
+		transcodeConfig = &proxy.TranscodeConfig{Encodings: encs}
+	}
+
+	var scaleConfig *proxy.ScaleConfig
+	if *scaleMaxWidth > 0 || *scaleMaxHeight > 0 {
+		scaleConfig = &proxy.ScaleConfig{MaxWidth: *scaleMaxWidth, MaxHeight: *scaleMaxHeight}
+	}
+
+	var overlayConfig *proxy.OverlayConfig
+	if *watermark || *banner != "" || *recordingBanner {
+		overlayConfig = &proxy.OverlayConfig{Watermark: *watermark, Banner: *banner, RecordingBanner: *recordingBanner}
+	}
+
+	var placeholderConfig *proxy.PlaceholderConfig
+	if *placeholder {
+		placeholderConfig = &proxy.PlaceholderConfig{RetryDelay: *placeholderRetryDelay, Retries: *placeholderRetries}
+	}
+
+	var reconnectConfig *proxy.ReconnectConfig
+	if *reconnect {
+		if *relayAuth {
+			logger.Warn("-reconnect isn't available with -relayAuth")
+		}
+		reconnectConfig = &proxy.ReconnectConfig{Timeout: *reconnectTimeout}
+	}
+
+	if *vncPass == "" && users == nil && !*relayAuth {
+		if *tcpPort != "" {
+			logger.Warn("proxy will have no password on the tcp listener")
+		}
+		if *wsPort != "" && jwtVerifier == nil {
+			logger.Warn("proxy will have no password on the websocket listener")
+		}
 	}
 
 	tcpUrl := ""
@@ -59,12 +273,35 @@ func main() {
 			TargetHostname: *targetVncHost,
 			TargetPort:     *targetVncPort,
 			TargetPassword: *targetVncPass, //"vncPass",
+			TargetUser:     *targetVncUser,
 			ID:             "",
 			Status:         proxy.SessionStatusInit,
 			Type:           proxy.SessionTypeProxyPass,
 		}, // to be used when not using sessions
-		DynamicLookup: *dynamicLookup,
-		UsingSessions: false, //false = single session - defined in the var above
+		DynamicLookup:     *dynamicLookup,
+		JwtVerifier:       jwtVerifier,
+		Totp:              totp,
+		VncTotpUser:       *vncTotpUser,
+		RelayAuth:         *relayAuth,
+		ProxyRSAKey:       rsaKey,
+		ClipboardPolicy:   clipboardPolicy,
+		InputPolicy:       inputPolicy,
+		PasteAsKeys:       pasteConfig,
+		KeyboardLayout:    *keyboardLayout,
+		AdminListeningUrl: *adminListen,
+		AdminToken:        *adminToken,
+		Transcode:         transcodeConfig,
+		Scale:             scaleConfig,
+		MaskPolicy:        maskPolicy,
+		Overlay:           overlayConfig,
+		Placeholder:       placeholderConfig,
+		Reconnect:         reconnectConfig,
+		UsingSessions:     false, //false = single session - defined in the var above
+	}
+
+	if users != nil {
+		vncProxy.ProxyUsers = users
+		vncProxy.ProxyTLSConfig = tlsConfig
 	}
 
 	if *recordDir != "" {
@@ -75,3 +312,27 @@ func main() {
 
 	vncProxy.StartListening()
 }
+
+// loadRSAKey reads a PKCS#1 or PKCS#8 PEM RSA private key
+func loadRSAKey(path string) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an RSA key", path)
+	}
+	return rsaKey, nil
+}
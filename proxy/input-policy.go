@@ -0,0 +1,320 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/keyboard"
+	"github.com/exoscale/vncproxy/keysym"
+	"github.com/exoscale/vncproxy/logger"
+	"github.com/exoscale/vncproxy/server"
+)
+
+// KioskChords are the chords blocked by the kiosk setting: rebooting the VM, the magic SysRq key,
+// switching to another virtual terminal & killing the X server
+var KioskChords = []string{"ctrl+alt+Delete", "alt+Print", "ctrl+alt+BackSpace",
+	"ctrl+alt+F1", "ctrl+alt+F2", "ctrl+alt+F3", "ctrl+alt+F4", "ctrl+alt+F5", "ctrl+alt+F6",
+	"ctrl+alt+F7", "ctrl+alt+F8", "ctrl+alt+F9", "ctrl+alt+F10", "ctrl+alt+F11", "ctrl+alt+F12"}
+
+// InputRegion is a framebuffer rectangle where the vnc-client's clicks are ignored
+type InputRegion struct {
+	Name   string `json:"name"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+func (r *InputRegion) contains(x, y int) bool {
+	return x >= r.X && x < r.X+r.Width && y >= r.Y && y < r.Y+r.Height
+}
+
+// InputPolicy filters the key & pointer events sent by the vnc-client. The zero value allows everything.
+type InputPolicy struct {
+	// Kiosk blocks the KioskChords on top of BlockedChords
+	Kiosk bool `json:"kiosk"`
+	// BlockedChords are keys joined by +, like ctrl+alt+Delete (see keysym.ParseChord).
+	// Left & right modifiers are the same, meta is alt, Sys_Req is Print & letters ignore case.
+	BlockedChords []string `json:"blocked_chords"`
+	// MaxKeyEventsPerSecond & MaxPointerEventsPerSecond limit the input rate, 0 = unlimited
+	MaxKeyEventsPerSecond     int `json:"max_key_events_per_second"`
+	MaxPointerEventsPerSecond int `json:"max_pointer_events_per_second"`
+	// IgnoredClickRegions are the rectangles where button presses are dropped (the pointer still moves there)
+	IgnoredClickRegions []InputRegion `json:"ignored_click_regions"`
+
+	chords [][]keysym.Keysym
+}
+
+// LoadInputPolicy reads a JSON InputPolicy
+func LoadInputPolicy(path string) (*InputPolicy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &InputPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("input policy %s: %v", path, err)
+	}
+	if err := policy.Compile(); err != nil {
+		return nil, fmt.Errorf("input policy %s: %v", path, err)
+	}
+	return policy, nil
+}
+
+// Compile parses the blocked chords, it must be called before using a policy
+func (p *InputPolicy) Compile() error {
+	chords := p.BlockedChords
+	if p.Kiosk {
+		chords = append(append([]string{}, KioskChords...), chords...)
+	}
+	p.chords = nil
+	for _, chord := range chords {
+		keys, err := keysym.ParseChord(chord)
+		if err != nil {
+			return err
+		}
+		for i, k := range keys {
+			keys[i] = normalizeChordKey(k)
+		}
+		p.chords = append(p.chords, keys)
+	}
+	for _, region := range p.IgnoredClickRegions {
+		if region.Width <= 0 || region.Height <= 0 {
+			return fmt.Errorf("region %s: empty rectangle", region.Name)
+		}
+	}
+	if p.MaxKeyEventsPerSecond < 0 || p.MaxPointerEventsPerSecond < 0 {
+		return fmt.Errorf("negative rate limit")
+	}
+	return nil
+}
+
+// normalizeChordKey maps the keys which are the same for chords to a single keysym
+func normalizeChordKey(k keysym.Keysym) keysym.Keysym {
+	switch k {
+	case keysym.ControlR:
+		return keysym.ControlL
+	case keysym.ShiftR:
+		return keysym.ShiftL
+	case keysym.AltR, keysym.MetaL, keysym.MetaR:
+		return keysym.AltL
+	case keysym.SuperR:
+		return keysym.SuperL
+	case 0xff15: // Sys_Req
+		return keysym.Print
+	case 0xff9f: // KP_Delete
+		return keysym.Delete
+	}
+	if r, ok := k.Rune(); ok && unicode.IsUpper(r) {
+		return keysym.FromRune(unicode.ToLower(r))
+	}
+	return k
+}
+
+// rateLimiter is a token bucket allowing bursts of one second of events
+type rateLimiter struct {
+	rate    float64
+	tokens  float64
+	last    time.Time
+	dropped int
+	// lastLog is when the dropped events were last logged
+	lastLog time.Time
+}
+
+func newRateLimiter(perSecond int) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: float64(perSecond), tokens: float64(perSecond)}
+}
+
+func (l *rateLimiter) allow(now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.rate {
+			l.tokens = l.rate
+		}
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// inputFilter applies an InputPolicy to the events of one session
+type inputFilter struct {
+	policy    *InputPolicy
+	sessionId string
+	now       func() time.Time
+	// layout is the guest's keyboard layout, for the scan codes of the QEMU extended key events
+	layout *keyboard.Layout
+
+	keyLimiter     *rateLimiter
+	pointerLimiter *rateLimiter
+
+	// held are the keys held by the vnc-client (normalized), forwarded those whose key down was sent
+	// and dropped those whose key up must be dropped too
+	held      map[keysym.Keysym]bool
+	forwarded map[keysym.Keysym]bool
+	dropped   map[keysym.Keysym]bool
+
+	// clientButtons is the vnc-client's button mask, suppressed the buttons pressed in an ignored region
+	// and sentButtons the mask last forwarded
+	clientButtons uint8
+	suppressed    uint8
+	sentButtons   uint8
+}
+
+// newFilter returns the session's filter, nil when the policy has nothing to filter.
+// The layout is the guest's one (nil = us).
+func (p *InputPolicy) newFilter(sessionId string, layout *keyboard.Layout) *inputFilter {
+	if p == nil || (len(p.chords) == 0 && len(p.IgnoredClickRegions) == 0 &&
+		p.MaxKeyEventsPerSecond == 0 && p.MaxPointerEventsPerSecond == 0) {
+		return nil
+	}
+	if layout == nil {
+		layout = keyboard.US
+	}
+	return &inputFilter{
+		policy:         p,
+		sessionId:      sessionId,
+		now:            time.Now,
+		layout:         layout,
+		keyLimiter:     newRateLimiter(p.MaxKeyEventsPerSecond),
+		pointerLimiter: newRateLimiter(p.MaxPointerEventsPerSecond),
+		held:           map[keysym.Keysym]bool{},
+		forwarded:      map[keysym.Keysym]bool{},
+		dropped:        map[keysym.Keysym]bool{},
+	}
+}
+
+func (f *inputFilter) logBlocked(format string, v ...interface{}) {
+	logger.Infof("input: session=%q blocked %s", f.sessionId, fmt.Sprintf(format, v...))
+}
+
+// logRateLimited logs the events dropped by a limiter, at most once per second
+func (f *inputFilter) logRateLimited(l *rateLimiter, kind string, now time.Time) {
+	if l == nil || l.dropped == 0 || now.Sub(l.lastLog) < time.Second {
+		return
+	}
+	f.logBlocked("%d %s events (rate limited)", l.dropped, kind)
+	l.dropped = 0
+	l.lastLog = now
+}
+
+// blockedChord returns the chord completed by pressing k, if it is blocked
+func (f *inputFilter) blockedChord(k keysym.Keysym) []keysym.Keysym {
+	for _, chord := range f.policy.chords {
+		member := false
+		complete := true
+		for _, key := range chord {
+			if key == k {
+				member = true
+			} else if !f.held[key] {
+				complete = false
+			}
+		}
+		if member && complete {
+			return chord
+		}
+	}
+	return nil
+}
+
+// allowKey tells whether a key event may be forwarded
+func (f *inputFilter) allowKey(k keysym.Keysym, down bool) bool {
+	k = normalizeChordKey(k)
+	now := f.now()
+	defer f.logRateLimited(f.keyLimiter, "key", now)
+
+	if !down {
+		delete(f.held, k)
+		if f.dropped[k] {
+			delete(f.dropped, k)
+			return false
+		}
+		// releases always go through, the guest would see a stuck key otherwise
+		delete(f.forwarded, k)
+		return true
+	}
+
+	f.held[k] = true
+	if chord := f.blockedChord(k); chord != nil {
+		names := make([]string, len(chord))
+		for i, key := range chord {
+			names[i] = key.Name()
+		}
+		f.logBlocked("chord %s", strings.Join(names, "+"))
+		if !f.forwarded[k] {
+			f.dropped[k] = true
+		}
+		return false
+	}
+	if !f.keyLimiter.allow(now) {
+		f.keyLimiter.dropped++
+		if !f.forwarded[k] {
+			f.dropped[k] = true
+		}
+		return false
+	}
+	f.forwarded[k] = true
+	delete(f.dropped, k)
+	return true
+}
+
+// allowKeyMessage tells whether a KeyEvent or QEMU extended key event may be forwarded
+func (f *inputFilter) allowKeyMessage(msg common.ClientMessage) bool {
+	switch key := msg.(type) {
+	case *server.MsgKeyEvent:
+		return f.allowKey(keysym.Keysym(key.Key), key.Down != 0)
+	case *server.MsgClientQemuExtendedKey:
+		// QEMU presses the key of the scan code, the keysym only counts when the scan code is unknown
+		k := keysym.Keysym(key.KeySym)
+		if scancodeKey, ok := f.layout.Keysym(key.KeyCode); ok {
+			k = scancodeKey
+		}
+		return f.allowKey(k, key.IsDown != 0)
+	}
+	return true
+}
+
+// filterPointer clears the buttons pressed in ignored regions from the event, it returns false
+// if the event must be dropped
+func (f *inputFilter) filterPointer(msg *server.MsgPointerEvent) bool {
+	now := f.now()
+	defer f.logRateLimited(f.pointerLimiter, "pointer", now)
+
+	pressed := msg.Mask &^ f.clientButtons
+	f.clientButtons = msg.Mask
+	f.suppressed &= msg.Mask
+	if pressed != 0 {
+		for i := range f.policy.IgnoredClickRegions {
+			region := &f.policy.IgnoredClickRegions[i]
+			if region.contains(int(msg.X), int(msg.Y)) {
+				f.logBlocked("click (buttons 0x%x) at %d,%d in region %s", pressed, msg.X, msg.Y, region.Name)
+				f.suppressed |= pressed
+				break
+			}
+		}
+	}
+	msg.Mask &^= f.suppressed
+
+	// button releases always go through, the guest would see a stuck button otherwise
+	released := f.sentButtons&^msg.Mask != 0
+	if !f.pointerLimiter.allow(now) && !released {
+		f.pointerLimiter.dropped++
+		return false
+	}
+	f.sentButtons = msg.Mask
+	return true
+}
@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/exoscale/vncproxy/keysym"
+	"github.com/exoscale/vncproxy/server"
+)
+
+func TestInputPolicyChords(t *testing.T) {
+	policy := &InputPolicy{Kiosk: true, BlockedChords: []string{"super+L"}}
+	if err := policy.Compile(); err != nil {
+		t.Fatalf("compiling policy: %s", err)
+	}
+	filter := policy.newFilter("s", nil)
+
+	events := []struct {
+		key     keysym.Keysym
+		down    bool
+		allowed bool
+	}{
+		{keysym.ControlR, true, true},
+		{keysym.AltL, true, true},
+		{0xff9f, true, false}, // KP_Delete
+		{0xff9f, false, false},
+		{keysym.Delete, true, false},
+		{keysym.AltL, false, true},
+		{keysym.Delete, true, true}, // no longer a chord without alt
+		{keysym.Delete, false, true},
+		{keysym.ControlR, false, true},
+		// the key is already down when the chord is completed
+		{'l', true, true},
+		{keysym.SuperR, true, false},
+		{'L', false, true},
+		{keysym.SuperR, false, false},
+		{keysym.F1, true, true},
+		{keysym.F1, false, true},
+	}
+	for i, ev := range events {
+		if allowed := filter.allowKey(ev.key, ev.down); allowed != ev.allowed {
+			t.Errorf("event %d (%s down=%t): allowed=%t, expected %t", i, ev.key, ev.down, allowed, ev.allowed)
+		}
+	}
+
+	if err := (&InputPolicy{BlockedChords: []string{"ctrl+nokey"}}).Compile(); err == nil {
+		t.Errorf("expected an error for an unknown key")
+	}
+	if (&InputPolicy{}).newFilter("s", nil) != nil {
+		t.Errorf("an empty policy needs no filter")
+	}
+}
+
+func TestInputPolicyQemuExtendedKeys(t *testing.T) {
+	policy := &InputPolicy{Kiosk: true}
+	if err := policy.Compile(); err != nil {
+		t.Fatalf("compiling policy: %s", err)
+	}
+	filter := policy.newFilter("s", nil)
+
+	// the scan codes are pressed by QEMU, whatever the keysyms say
+	events := []struct {
+		keysym   keysym.Keysym
+		scancode uint32
+		down     bool
+		allowed  bool
+	}{
+		{keysym.ControlL, 0x1d, true, true},
+		{keysym.AltL, 0x38, true, true},
+		{'a', 0xd3, true, false}, // Delete
+		{'a', 0xd3, false, false},
+		{'a', 0xe053, true, false},
+		{'a', 0xe053, false, false},
+		{'b', 0x3b, true, false}, // F1
+		{'b', 0x3b, false, false},
+		{keysym.Delete, 0x1e, true, true}, // a
+		{keysym.Delete, 0x1e, false, true},
+		{keysym.AltL, 0x38, false, true},
+		{keysym.ControlL, 0x1d, false, true},
+	}
+	for i, ev := range events {
+		msg := &server.MsgClientQemuExtendedKey{KeySym: uint32(ev.keysym), KeyCode: ev.scancode}
+		if ev.down {
+			msg.IsDown = 1
+		}
+		if allowed := filter.allowKeyMessage(msg); allowed != ev.allowed {
+			t.Errorf("event %d (%s/0x%x down=%t): allowed=%t, expected %t", i, ev.keysym, ev.scancode, ev.down, allowed, ev.allowed)
+		}
+	}
+}
+
+func TestInputPolicyPointer(t *testing.T) {
+	policy := &InputPolicy{
+		MaxPointerEventsPerSecond: 2,
+		IgnoredClickRegions:       []InputRegion{{Name: "menu", X: 0, Y: 700, Width: 100, Height: 68}},
+	}
+	if err := policy.Compile(); err != nil {
+		t.Fatalf("compiling policy: %s", err)
+	}
+	filter := policy.newFilter("s", nil)
+	now := time.Unix(0, 0)
+	filter.now = func() time.Time { return now }
+
+	events := []struct {
+		msg     server.MsgPointerEvent
+		allowed bool
+		mask    uint8
+	}{
+		// click in the region: moves but no button
+		{server.MsgPointerEvent{Mask: 1, X: 10, Y: 710}, true, 0},
+		// dragged out of the region, the button stays up
+		{server.MsgPointerEvent{Mask: 1, X: 200, Y: 200}, true, 0},
+		// rate limited
+		{server.MsgPointerEvent{Mask: 0, X: 201, Y: 200}, false, 0},
+		{server.MsgPointerEvent{Mask: 1, X: 201, Y: 200}, false, 1},
+	}
+	for i, ev := range events {
+		msg := ev.msg
+		if allowed := filter.filterPointer(&msg); allowed != ev.allowed || (allowed && msg.Mask != ev.mask) {
+			t.Errorf("event %d: allowed=%t mask=%d, expected %t %d", i, allowed, msg.Mask, ev.allowed, ev.mask)
+		}
+	}
+
+	// the click outside the region goes through once tokens are back, and its release despite the limit
+	now = now.Add(time.Second)
+	msg := server.MsgPointerEvent{Mask: 1, X: 201, Y: 200}
+	if !filter.filterPointer(&msg) || msg.Mask != 1 {
+		t.Errorf("click not forwarded: %+v", msg)
+	}
+	filter.filterPointer(&server.MsgPointerEvent{Mask: 1, X: 202, Y: 200})
+	msg = server.MsgPointerEvent{Mask: 0, X: 202, Y: 200}
+	if !filter.filterPointer(&msg) {
+		t.Errorf("release dropped by the rate limit")
+	}
+}
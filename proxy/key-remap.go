@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"io"
+	"unicode"
+
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/keyboard"
+	"github.com/exoscale/vncproxy/keysym"
+	"github.com/exoscale/vncproxy/server"
+)
+
+// keyRemapper turns the vnc-client's key events into QEMU extended key events carrying the scan codes
+// of the guest's keyboard layout: the characters typed by the vnc-client are what the guest gets, whatever
+// the vnc-client's layout. Shift & AltGr are pressed or released around keys needing another modifier state
+// than the one held by the vnc-client, dead keys the guest layout lacks are composed by the proxy.
+type keyRemapper struct {
+	layout *keyboard.Layout
+
+	// modifiers held by the vnc-client, with the scan code sent for them
+	shift map[keysym.Keysym]uint32
+	altGr map[keysym.Keysym]uint32
+	// pressed are the keys sent for each key down (see pressID), released on the matching key up
+	pressed map[uint32]keyboard.KeyEvent
+	// pendingDead is a dead key the guest layout lacks, waiting for the next character
+	pendingDead keysym.Keysym
+}
+
+func newKeyRemapper(layoutName string) (*keyRemapper, error) {
+	layout, err := keyboard.LayoutByName(layoutName)
+	if err != nil {
+		return nil, err
+	}
+	return &keyRemapper{
+		layout:  layout,
+		shift:   map[keysym.Keysym]uint32{},
+		altGr:   map[keysym.Keysym]uint32{},
+		pressed: map[uint32]keyboard.KeyEvent{},
+	}, nil
+}
+
+// pressID identifies a vnc-client key: by scan code when it sent one, else by keysym
+func pressID(k keysym.Keysym, clientScancode uint32) uint32 {
+	if clientScancode != 0 {
+		return clientScancode | 0x80000000
+	}
+	return uint32(k)
+}
+
+// findPressed looks up the key being released, viewers may release 'a' after pressing 'A' with shift
+func (m *keyRemapper) findPressed(k keysym.Keysym, clientScancode uint32) (uint32, bool) {
+	id := pressID(k, clientScancode)
+	if _, ok := m.pressed[id]; ok || clientScancode != 0 {
+		return id, ok
+	}
+	r, ok := k.Rune()
+	if !ok {
+		return id, false
+	}
+	for other := range m.pressed {
+		if other&0x80000000 != 0 {
+			continue
+		}
+		if otherRune, ok := keysym.Keysym(other).Rune(); ok && unicode.ToLower(otherRune) == unicode.ToLower(r) {
+			return other, true
+		}
+	}
+	return id, false
+}
+
+func isShift(k keysym.Keysym) bool {
+	return k == keysym.ShiftL || k == keysym.ShiftR
+}
+
+func isAltGr(k keysym.Keysym) bool {
+	return k == keysym.ISOLevel3Shift || k == 0xff7e // Mode_switch
+}
+
+// translate returns the events to send for a vnc-client key event, clientScancode is 0 for plain KeyEvents.
+// Events with a 0 scan code are sent as plain KeyEvents (keys the proxy can't place on the guest's keyboard).
+func (m *keyRemapper) translate(k keysym.Keysym, clientScancode uint32, down bool) []keyboard.KeyEvent {
+	if code, ok := keyboard.SpecialScancode(k); ok {
+		if isShift(k) {
+			setHeld(m.shift, k, code, down)
+		} else if isAltGr(k) {
+			setHeld(m.altGr, k, code, down)
+		}
+		return []keyboard.KeyEvent{{Keysym: k, Scancode: code, Down: down}}
+	}
+
+	if !down {
+		if id, ok := m.findPressed(k, clientScancode); ok {
+			ev := m.pressed[id]
+			delete(m.pressed, id)
+			if ev.Keysym == 0 && ev.Scancode == 0 {
+				// the key was fully typed on key down
+				return nil
+			}
+			ev.Down = false
+			return []keyboard.KeyEvent{ev}
+		}
+		return []keyboard.KeyEvent{{Keysym: k, Scancode: clientScancode}}
+	}
+
+	id := pressID(k, clientScancode)
+	var strokes []keyboard.Stroke
+	if keyboard.IsDeadKey(k) {
+		stroke, ok := m.layout.DeadStroke(k)
+		if !ok {
+			m.pendingDead = k
+			m.pressed[id] = keyboard.KeyEvent{}
+			return nil
+		}
+		strokes = []keyboard.Stroke{stroke}
+	} else {
+		r, ok := k.Rune()
+		if !ok {
+			// not a character (e.g. multimedia keys), the vnc-client's scan code doesn't depend on its layout
+			m.pressed[id] = keyboard.KeyEvent{Keysym: k, Scancode: clientScancode}
+			return []keyboard.KeyEvent{{Keysym: k, Scancode: clientScancode, Down: true}}
+		}
+		if m.pendingDead != 0 {
+			if composed, ok := keyboard.Compose(m.pendingDead, r); ok {
+				r = composed
+			}
+			m.pendingDead = 0
+		}
+		strokes, ok = m.layout.Strokes(r)
+		if !ok {
+			// not on the guest's keyboard, let the vnc server map the keysym
+			ks := keysym.FromRune(r)
+			m.pressed[id] = keyboard.KeyEvent{Keysym: ks}
+			return []keyboard.KeyEvent{{Keysym: ks, Down: true}}
+		}
+	}
+
+	// all the strokes but the last (dead key compositions) are typed right away,
+	// the last one stays down until the vnc-client releases its key
+	var events []keyboard.KeyEvent
+	for i, stroke := range strokes {
+		adjust, restore := m.modifierEvents(stroke)
+		events = append(events, adjust...)
+		events = append(events, keyboard.KeyEvent{Keysym: stroke.Keysym, Scancode: stroke.Scancode, Down: true})
+		if i < len(strokes)-1 {
+			events = append(events, keyboard.KeyEvent{Keysym: stroke.Keysym, Scancode: stroke.Scancode})
+		} else {
+			m.pressed[id] = keyboard.KeyEvent{Keysym: stroke.Keysym, Scancode: stroke.Scancode}
+		}
+		events = append(events, restore...)
+	}
+	return events
+}
+
+func setHeld(held map[keysym.Keysym]uint32, k keysym.Keysym, code uint32, down bool) {
+	if down {
+		held[k] = code
+	} else {
+		delete(held, k)
+	}
+}
+
+// modifierEvents returns the events switching from the vnc-client's modifiers to the stroke's, and back
+func (m *keyRemapper) modifierEvents(stroke keyboard.Stroke) ([]keyboard.KeyEvent, []keyboard.KeyEvent) {
+	var adjust, restore []keyboard.KeyEvent
+	toggle := func(held map[keysym.Keysym]uint32, wanted bool, k keysym.Keysym, code uint32) {
+		if wanted && len(held) == 0 {
+			adjust = append(adjust, keyboard.KeyEvent{Keysym: k, Scancode: code, Down: true})
+			restore = append(restore, keyboard.KeyEvent{Keysym: k, Scancode: code})
+		}
+		if !wanted {
+			for heldKey, heldCode := range held {
+				adjust = append(adjust, keyboard.KeyEvent{Keysym: heldKey, Scancode: heldCode})
+				restore = append(restore, keyboard.KeyEvent{Keysym: heldKey, Scancode: heldCode, Down: true})
+			}
+		}
+	}
+	toggle(m.shift, stroke.Shift, keysym.ShiftL, keyboard.ScancodeShiftL)
+	toggle(m.altGr, stroke.AltGr, keysym.ISOLevel3Shift, keyboard.ScancodeAltGr)
+	return adjust, restore
+}
+
+// remapKeyMessage translates a KeyEvent or QEMU extended key event and writes the result
+func (m *keyRemapper) remapKeyMessage(msg common.ClientMessage, w io.Writer) error {
+	var events []keyboard.KeyEvent
+	switch key := msg.(type) {
+	case *server.MsgKeyEvent:
+		events = m.translate(keysym.Keysym(key.Key), 0, key.Down != 0)
+	case *server.MsgClientQemuExtendedKey:
+		events = m.translate(keysym.Keysym(key.KeySym), key.KeyCode, key.IsDown != 0)
+	default:
+		return msg.Write(w)
+	}
+
+	for _, ev := range events {
+		var down uint8
+		if ev.Down {
+			down = 1
+		}
+		var out common.ClientMessage
+		if ev.Scancode != 0 {
+			out = &server.MsgClientQemuExtendedKey{IsDown: uint16(down), KeySym: uint32(ev.Keysym), KeyCode: ev.Scancode}
+		} else {
+			out = &server.MsgKeyEvent{Down: down, Key: server.Key(ev.Keysym)}
+		}
+		if err := out.Write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
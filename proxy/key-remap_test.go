@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/exoscale/vncproxy/keyboard"
+	"github.com/exoscale/vncproxy/keysym"
+	"github.com/exoscale/vncproxy/server"
+)
+
+func checkKeyEvents(t *testing.T, name string, got, expected []keyboard.KeyEvent) {
+	if len(got) != len(expected) {
+		t.Errorf("%s: got %+v, expected %+v", name, got, expected)
+		return
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Errorf("%s: got %+v, expected %+v", name, got, expected)
+			return
+		}
+	}
+}
+
+func TestKeyRemapper(t *testing.T) {
+	fr, err := newKeyRemapper("fr")
+	if err != nil {
+		t.Fatalf("creating remapper: %s", err)
+	}
+
+	// a US viewer types 1 without shift, it is shifted on a french keyboard
+	checkKeyEvents(t, "fr 1 down", fr.translate('1', 0, true), []keyboard.KeyEvent{
+		{Keysym: keysym.ShiftL, Scancode: keyboard.ScancodeShiftL, Down: true},
+		{Keysym: '1', Scancode: 0x02, Down: true},
+		{Keysym: keysym.ShiftL, Scancode: keyboard.ScancodeShiftL},
+	})
+	checkKeyEvents(t, "fr 1 up", fr.translate('1', 0, false), []keyboard.KeyEvent{{Keysym: '1', Scancode: 0x02}})
+
+	// shift held by the viewer is released around keys typed without it
+	checkKeyEvents(t, "fr shift down", fr.translate(keysym.ShiftR, 0, true), []keyboard.KeyEvent{
+		{Keysym: keysym.ShiftR, Scancode: 0x36, Down: true},
+	})
+	checkKeyEvents(t, "fr & down", fr.translate('&', 0, true), []keyboard.KeyEvent{
+		{Keysym: keysym.ShiftR, Scancode: 0x36},
+		{Keysym: '&', Scancode: 0x02, Down: true},
+		{Keysym: keysym.ShiftR, Scancode: 0x36, Down: true},
+	})
+	fr.translate('&', 0, false)
+
+	// shift + a pressed, released as a after shift
+	checkKeyEvents(t, "fr A down", fr.translate('A', 0, true), []keyboard.KeyEvent{{Keysym: 'A', Scancode: 0x10, Down: true}})
+	fr.translate(keysym.ShiftR, 0, false)
+	checkKeyEvents(t, "fr a up", fr.translate('a', 0, false), []keyboard.KeyEvent{{Keysym: 'A', Scancode: 0x10}})
+
+	us, err := newKeyRemapper("us")
+	if err != nil {
+		t.Fatalf("creating remapper: %s", err)
+	}
+	// no dead keys on a US keyboard: the proxy composes ê, which is sent as a keysym
+	if events := us.translate(keysym.DeadCircumflex, 0, true); len(events) != 0 {
+		t.Errorf("dead key sent: %+v", events)
+	}
+	if events := us.translate(keysym.DeadCircumflex, 0, false); len(events) != 0 {
+		t.Errorf("dead key release sent: %+v", events)
+	}
+	checkKeyEvents(t, "us ê down", us.translate('e', 0x12, true), []keyboard.KeyEvent{{Keysym: 0xea, Down: true}})
+	checkKeyEvents(t, "us ê up", us.translate('e', 0x12, false), []keyboard.KeyEvent{{Keysym: 0xea}})
+
+	// remapped events are written as QEMU extended key events
+	var out bytes.Buffer
+	if err := us.remapKeyMessage(&server.MsgKeyEvent{Down: 1, Key: 'q'}, &out); err != nil {
+		t.Fatalf("remapping: %s", err)
+	}
+	msg, err := new(server.MsgQEMUExtKeyEvent).Read(bytes.NewReader(out.Bytes()[1:]))
+	if err != nil {
+		t.Fatalf("reading qemu event: %s", err)
+	}
+	if got := msg.(*server.MsgQEMUExtKeyEvent); out.Bytes()[0] != 255 || got.DownFlag != 1 || got.KeySym != 'q' || got.KeyCode != 0x10 {
+		t.Errorf("unexpected qemu event: %+v", got)
+	}
+}
@@ -0,0 +1,361 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/png"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// PrivacyMask hides a part of the screen, painted over with a color or pixelated: a rectangle, or the places
+// where a template image is found (within the rectangle if there is one)
+type PrivacyMask struct {
+	Name   string `json:"name"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	// Template is the path of a PNG image, the mask covers each place it is found on the screen
+	Template string `json:"template"`
+	// Tolerance is the difference allowed between the template & the screen, per color component (0-255)
+	Tolerance int `json:"tolerance"`
+	// Style is fill (the default) or pixelate
+	Style string `json:"style"`
+	// Color is the fill color (#rrggbb), black by default
+	Color string `json:"color"`
+	// PixelSize is the size of the pixelated blocks, 16 by default
+	PixelSize int `json:"pixel_size"`
+	// Apply is viewers, recording or both (the default)
+	Apply string `json:"apply"`
+
+	targets  maskTarget
+	color    color.RGBA
+	template *image.RGBA
+}
+
+// MaskPolicy hides the masked parts of the screen from the vnc-clients and/or the recordings, it implies transcoding
+type MaskPolicy struct {
+	Masks []PrivacyMask `json:"masks"`
+
+	compiled bool
+}
+
+// maskCompileLock guards the policies compiled by the sessions, when the code setting them up didn't
+var maskCompileLock sync.Mutex
+
+// maskTarget are the outputs a mask applies to
+type maskTarget int
+
+const (
+	maskViewers maskTarget = 1 << iota
+	maskRecording
+)
+
+// LoadMaskPolicy reads a JSON MaskPolicy
+func LoadMaskPolicy(path string) (*MaskPolicy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &MaskPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("mask policy %s: %v", path, err)
+	}
+	if err := policy.Compile(); err != nil {
+		return nil, fmt.Errorf("mask policy %s: %v", path, err)
+	}
+	return policy, nil
+}
+
+// Compile checks the masks & loads their templates, it should be called before using a policy
+// (the sessions compile the policies which weren't, and refuse the vnc-clients if it fails)
+func (p *MaskPolicy) Compile() error {
+	p.compiled = false
+	for i := range p.Masks {
+		m := &p.Masks[i]
+		if m.Width < 0 || m.Height < 0 || (m.Template == "" && (m.Width == 0 || m.Height == 0)) {
+			return fmt.Errorf("mask %s: empty rectangle", m.Name)
+		}
+		switch m.Apply {
+		case "", "both":
+			m.targets = maskViewers | maskRecording
+		case "viewers":
+			m.targets = maskViewers
+		case "recording":
+			m.targets = maskRecording
+		default:
+			return fmt.Errorf("mask %s: unknown apply: %s", m.Name, m.Apply)
+		}
+		switch m.Style {
+		case "", "fill", "pixelate":
+		default:
+			return fmt.Errorf("mask %s: unknown style: %s", m.Name, m.Style)
+		}
+		if m.PixelSize <= 0 {
+			m.PixelSize = 16
+		}
+		m.color = color.RGBA{A: 0xff}
+		if m.Color != "" {
+			if _, err := fmt.Sscanf(m.Color, "#%02x%02x%02x", &m.color.R, &m.color.G, &m.color.B); err != nil {
+				return fmt.Errorf("mask %s: bad color: %s", m.Name, m.Color)
+			}
+		}
+		if m.Template != "" {
+			template, err := loadTemplate(m.Template)
+			if err != nil {
+				return fmt.Errorf("mask %s: %v", m.Name, err)
+			}
+			m.template = template
+		}
+	}
+	p.compiled = true
+	return nil
+}
+
+func loadTemplate(path string) (*image.RGBA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	template := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(template, template.Rect, img, img.Bounds().Min, draw.Src)
+	if template.Rect.Empty() {
+		return nil, fmt.Errorf("empty template %s", path)
+	}
+	return template, nil
+}
+
+func (m *PrivacyMask) rect() image.Rectangle {
+	return image.Rect(m.X, m.Y, m.X+m.Width, m.Y+m.Height)
+}
+
+// masker paints the masks of a session over copies of the canvas, one for the vnc-clients & one for the recording
+type masker struct {
+	masks []*maskState
+	views map[maskTarget]*image.RGBA
+}
+
+// maskState is a mask with the places its template was found
+type maskState struct {
+	*PrivacyMask
+	matches []image.Rectangle
+}
+
+func newMasker(policy *MaskPolicy) (*masker, error) {
+	if policy == nil || len(policy.Masks) == 0 {
+		return nil, nil
+	}
+	// an uncompiled policy would mask nothing
+	maskCompileLock.Lock()
+	var err error
+	if !policy.compiled {
+		err = policy.Compile()
+	}
+	maskCompileLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	m := &masker{views: map[maskTarget]*image.RGBA{}}
+	for i := range policy.Masks {
+		m.masks = append(m.masks, &maskState{PrivacyMask: &policy.Masks[i]})
+	}
+	return m, nil
+}
+
+func (s *maskState) rects() []image.Rectangle {
+	if s.template == nil {
+		return []image.Rectangle{s.rect()}
+	}
+	return s.matches
+}
+
+// resize follows the canvas size, the whole canvas is searched & masked again
+func (m *masker) resize(canvas *image.RGBA) {
+	for _, target := range []maskTarget{maskViewers, maskRecording} {
+		m.views[target] = image.NewRGBA(canvas.Rect)
+	}
+	for _, s := range m.masks {
+		s.matches = nil
+	}
+	r := m.update(canvas, canvas.Rect)
+	m.paint(canvas, r, maskViewers)
+	m.paint(canvas, r, maskRecording)
+}
+
+// update follows a painted canvas rectangle: the templates are searched around it, and the returned rectangle
+// has what must be sent again, with the places where a template appeared or went away & the whole pixelated blocks
+func (m *masker) update(canvas *image.RGBA, r image.Rectangle) image.Rectangle {
+	out := r
+	for _, s := range m.masks {
+		if s.template == nil {
+			continue
+		}
+		// the matches overlapping the rectangle are searched again
+		kept := s.matches[:0]
+		for _, match := range s.matches {
+			if match.Overlaps(r) {
+				out = out.Union(match)
+			} else {
+				kept = append(kept, match)
+			}
+		}
+		s.matches = kept
+		for _, match := range s.search(canvas, r) {
+			s.matches = append(s.matches, match)
+			out = out.Union(match)
+		}
+	}
+	for grown := true; grown; {
+		grown = false
+		for _, s := range m.masks {
+			if s.Style != "pixelate" {
+				continue
+			}
+			for _, mr := range s.rects() {
+				blocks := s.blocks(mr, out.Intersect(mr))
+				if !blocks.In(out) {
+					out = out.Union(blocks)
+					grown = true
+				}
+			}
+		}
+	}
+	return out.Intersect(canvas.Rect)
+}
+
+// search returns the places of the template which overlap the rectangle
+func (s *maskState) search(canvas *image.RGBA, r image.Rectangle) []image.Rectangle {
+	area := canvas.Rect
+	if s.Width > 0 && s.Height > 0 {
+		area = area.Intersect(s.rect())
+	}
+	size := s.template.Rect.Size()
+	// the top left corners of the places within the area overlapping r
+	corners := image.Rect(r.Min.X-size.X+1, r.Min.Y-size.Y+1, r.Max.X, r.Max.Y).
+		Intersect(image.Rect(area.Min.X, area.Min.Y, area.Max.X-size.X+1, area.Max.Y-size.Y+1))
+
+	var matches []image.Rectangle
+	for y := corners.Min.Y; y < corners.Max.Y; y++ {
+		for x := corners.Min.X; x < corners.Max.X; x++ {
+			if s.matchAt(canvas, x, y) {
+				matches = append(matches, image.Rectangle{image.Pt(x, y), image.Pt(x, y).Add(size)})
+				x += size.X - 1
+			}
+		}
+	}
+	return matches
+}
+
+func (s *maskState) matchAt(canvas *image.RGBA, x, y int) bool {
+	tolerance := s.Tolerance
+	for ty := 0; ty < s.template.Rect.Dy(); ty++ {
+		i := canvas.PixOffset(x, y+ty)
+		j := s.template.PixOffset(0, ty)
+		for n := 0; n < 4*s.template.Rect.Dx(); n++ {
+			if n%4 == 3 {
+				continue
+			}
+			diff := int(canvas.Pix[i+n]) - int(s.template.Pix[j+n])
+			if diff > tolerance || diff < -tolerance {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// blocks returns the pixelated blocks of a mask rectangle covering r
+func (s *maskState) blocks(mr, r image.Rectangle) image.Rectangle {
+	if r.Empty() {
+		return r
+	}
+	size := s.PixelSize
+	return image.Rect(
+		mr.Min.X+(r.Min.X-mr.Min.X)/size*size, mr.Min.Y+(r.Min.Y-mr.Min.Y)/size*size,
+		mr.Min.X+ceilDiv(r.Max.X-mr.Min.X, size)*size, mr.Min.Y+ceilDiv(r.Max.Y-mr.Min.Y, size)*size,
+	).Intersect(mr)
+}
+
+// applies tells whether some masks are for the target
+func (m *masker) applies(target maskTarget) bool {
+	for _, s := range m.masks {
+		if s.targets&target != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// masked tells whether a mask of the target overlaps the rectangle
+func (m *masker) masked(r image.Rectangle, target maskTarget) bool {
+	for _, s := range m.masks {
+		if s.targets&target == 0 {
+			continue
+		}
+		for _, mr := range s.rects() {
+			if mr.Overlaps(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// paint updates a rectangle of the target's view of the canvas and returns the view, the canvas itself
+// when no mask is for the target
+func (m *masker) paint(canvas *image.RGBA, r image.Rectangle, target maskTarget) *image.RGBA {
+	if !m.applies(target) {
+		return canvas
+	}
+	view := m.views[target]
+	draw.Draw(view, r, canvas, r.Min, draw.Src)
+	for _, s := range m.masks {
+		if s.targets&target == 0 {
+			continue
+		}
+		for _, mr := range s.rects() {
+			if !mr.Overlaps(r) {
+				continue
+			}
+			if s.Style != "pixelate" {
+				draw.Draw(view, mr.Intersect(r), image.NewUniform(s.color), image.Point{}, draw.Src)
+				continue
+			}
+			blocks := s.blocks(mr, mr.Intersect(r))
+			for y := blocks.Min.Y; y < blocks.Max.Y; y += s.PixelSize {
+				for x := blocks.Min.X; x < blocks.Max.X; x += s.PixelSize {
+					block := image.Rect(x, y, x+s.PixelSize, y+s.PixelSize).Intersect(mr).Intersect(canvas.Rect)
+					draw.Draw(view, block.Intersect(r), image.NewUniform(averageColor(canvas, block)), image.Point{}, draw.Src)
+				}
+			}
+		}
+	}
+	return view
+}
+
+func averageColor(img *image.RGBA, r image.Rectangle) color.RGBA {
+	var sum [3]int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			sum[0] += int(c.R)
+			sum[1] += int(c.G)
+			sum[2] += int(c.B)
+		}
+	}
+	n := r.Dx() * r.Dy()
+	if n == 0 {
+		return color.RGBA{A: 0xff}
+	}
+	return color.RGBA{uint8(sum[0] / n), uint8(sum[1] / n), uint8(sum[2] / n), 0xff}
+}
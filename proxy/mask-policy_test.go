@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	maskWhite = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	maskGreen = color.RGBA{0, 0xff, 0, 0xff}
+)
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// writeTemplate saves a green square with a white dot as a PNG
+func writeTemplate(t *testing.T) string {
+	template := image.NewRGBA(image.Rect(0, 0, 6, 6))
+	fillRect(template, template.Rect, maskGreen)
+	template.SetRGBA(2, 2, maskWhite)
+	path := filepath.Join(t.TempDir(), "template.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, template); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMaskPolicyCompile(t *testing.T) {
+	for _, mask := range []PrivacyMask{
+		{Name: "empty", X: 1, Y: 1},
+		{Name: "color", Width: 1, Height: 1, Color: "red"},
+		{Name: "apply", Width: 1, Height: 1, Apply: "nobody"},
+		{Name: "style", Width: 1, Height: 1, Style: "blur"},
+		{Name: "template", Template: "/nonexistent.png"},
+	} {
+		policy := &MaskPolicy{Masks: []PrivacyMask{mask}}
+		if err := policy.Compile(); err == nil {
+			t.Errorf("mask %s: no error", mask.Name)
+		}
+	}
+
+	policy := &MaskPolicy{Masks: []PrivacyMask{{Width: 1, Height: 1, Color: "#102030", Apply: "recording"}}}
+	if err := policy.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	if m := policy.Masks[0]; m.color != (color.RGBA{0x10, 0x20, 0x30, 0xff}) || m.targets != maskRecording || m.PixelSize != 16 {
+		t.Errorf("unexpected compiled mask: %+v", m)
+	}
+
+	// a policy set up without Compile is compiled by the session, or refused
+	policy = &MaskPolicy{Masks: []PrivacyMask{{Width: 1, Height: 1}}}
+	if m, err := newMasker(policy); err != nil || m == nil || policy.Masks[0].targets != maskViewers|maskRecording {
+		t.Errorf("uncompiled policy: %v, %+v", err, policy.Masks[0])
+	}
+	if _, err := newMasker(&MaskPolicy{Masks: []PrivacyMask{{Name: "empty"}}}); err == nil {
+		t.Errorf("invalid uncompiled policy accepted")
+	}
+}
+
+func TestMasker(t *testing.T) {
+	policy := &MaskPolicy{Masks: []PrivacyMask{
+		{Name: "pixelated", X: 0, Y: 0, Width: 8, Height: 8, Style: "pixelate", PixelSize: 4, Apply: "viewers"},
+		{Name: "logo", Template: writeTemplate(t), Color: "#ff0000", Apply: "recording"},
+	}}
+	if err := policy.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	fillRect(canvas, canvas.Rect, maskWhite)
+	m, err := newMasker(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.resize(canvas)
+
+	// a change in a pixelated block sends the whole block, averaged
+	canvas.SetRGBA(1, 1, color.RGBA{0, 0, 0, 0xff})
+	r := m.update(canvas, image.Rect(1, 1, 2, 2))
+	if r != image.Rect(0, 0, 4, 4) {
+		t.Errorf("unexpected pixelated rectangle: %v", r)
+	}
+	view := m.paint(canvas, r, maskViewers)
+	if c := view.RGBAAt(3, 3); c.R != 0xff*15/16 {
+		t.Errorf("unexpected pixelated color: %v", c)
+	}
+	if m.paint(canvas, r, maskRecording).RGBAAt(1, 1) != canvas.RGBAAt(1, 1) {
+		t.Error("the recording is pixelated")
+	}
+
+	// the template appears: the place it covers is masked in the recording only
+	fillRect(canvas, image.Rect(20, 20, 26, 26), maskGreen)
+	canvas.SetRGBA(22, 22, maskWhite)
+	r = m.update(canvas, image.Rect(20, 20, 26, 23))
+	if r != image.Rect(20, 20, 26, 26) || !m.masked(r, maskRecording) || m.masked(r, maskViewers) {
+		t.Fatalf("template not found: %v", r)
+	}
+	if c := m.paint(canvas, r, maskRecording).RGBAAt(25, 25); c != (color.RGBA{0xff, 0, 0, 0xff}) {
+		t.Errorf("the template isn't masked: %v", c)
+	}
+
+	// and goes away
+	canvas.SetRGBA(22, 22, maskGreen)
+	r = m.update(canvas, image.Rect(22, 22, 23, 23))
+	if r != image.Rect(20, 20, 26, 26) || m.masked(r, maskRecording) {
+		t.Errorf("template still masked: %v", r)
+	}
+}
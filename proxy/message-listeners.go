@@ -1,14 +1,71 @@
 package proxy
 
 import (
+	"bytes"
+	"fmt"
+	"sync"
+
 	"github.com/exoscale/vncproxy/client"
 	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/encodings"
 	"github.com/exoscale/vncproxy/logger"
 	"github.com/exoscale/vncproxy/server"
 )
 
 type ClientUpdater struct {
+	// conn is nil until the vnc server is connected (placeholder), guarded by writeLock
 	conn *client.ClientConn
+
+	// viewOnly drops all input events coming from the vnc-client
+	viewOnly bool
+	// input filters the key & pointer events (nil = forward all)
+	input *inputFilter
+	// clipboard filters the vnc-client's cut text
+	clipboard *ClipboardPolicy
+	sessionId string
+	// typist types the vnc-client's cut text as keys instead of forwarding it (nil = forward)
+	typist *keyTypist
+	// keyRemapper translates the key events to the guest's layout (nil = forward)
+	keyRemapper *keyRemapper
+	// reconnect is set when the vnc server is connected again once lost, see writeFailed
+	reconnect bool
+	// transcoder gets the vnc-client's pixel format & encodings instead of the vnc server (nil = forward)
+	transcoder *transcoder
+	// writeLock keeps the typed key events from interleaving with the forwarded messages
+	writeLock sync.Mutex
+	// placeholder (nil = none) answers the update requests until the vnc server is connected, guarded by writeLock
+	placeholder *placeholder
+	// encodings & pixelFormat (forwarded updates only) are the vnc-client's, sent again to a vnc server
+	// connected later; guarded by writeLock
+	encodings   []common.EncodingType
+	pixelFormat *common.PixelFormat
+	// connected is set once a vnc server was connected, closed once the vnc-client left; guarded by writeLock
+	connected bool
+	closed    bool
+	// zlibStale is set when a vnc server is connected after another one with forwarded updates: the vnc-client's
+	// zlib streams are the previous vnc server's, the zlib based encodings aren't asked for anymore;
+	// guarded by writeLock
+	zlibStale bool
+}
+
+// zlibEncodings are the encodings whose zlib streams last as long as the connection
+var zlibEncodings = map[common.EncodingType]bool{
+	common.EncZlib:     true,
+	common.EncZlibHex:  true,
+	common.EncZRLE:     true,
+	common.EncTight:    true,
+	common.EncTightPng: true,
+}
+
+// withoutZlib returns the encodings which don't keep zlib streams
+func withoutZlib(encs []common.EncodingType) []common.EncodingType {
+	kept := make([]common.EncodingType, 0, len(encs))
+	for _, enc := range encs {
+		if !zlibEncodings[enc] {
+			kept = append(kept, enc)
+		}
+	}
+	return kept
 }
 
 // Consume recieves vnc-server-bound messages (Client messages) and updates the server part of the proxy
@@ -20,27 +77,261 @@ func (cc *ClientUpdater) Consume(seg *common.RfbSegment) error {
 		case common.SetPixelFormatMsgType:
 			// update pixel format
 			pixFmtMsg := clientMsg.(*server.MsgSetPixelFormat)
-			cc.conn.PixelFormat = pixFmtMsg.PF
+			if cc.transcoder != nil {
+				cc.transcoder.setPixelFormat(&pixFmtMsg.PF)
+				return nil
+			}
+			cc.writeLock.Lock()
+			cc.pixelFormat = &pixFmtMsg.PF
+			if cc.conn != nil {
+				cc.conn.PixelFormat = pixFmtMsg.PF
+			}
+			cc.writeLock.Unlock()
+		case common.SetEncodingsMsgType:
+			encsMsg := clientMsg.(*server.MsgSetEncodings)
+			cc.writeLock.Lock()
+			cc.encodings = encsMsg.Encodings
+			zlibStale := cc.zlibStale
+			cc.writeLock.Unlock()
+			if cc.transcoder != nil {
+				clientMsg = &server.MsgSetEncodings{Encodings: cc.transcoder.setEncodings(encsMsg.Encodings)}
+			} else if zlibStale {
+				clientMsg = &server.MsgSetEncodings{Encodings: withoutZlib(encsMsg.Encodings)}
+			}
+		case common.KeyEventMsgType, common.QEMUExtendedKeyEventMsgType:
+			if cc.viewOnly {
+				return nil
+			}
+			if cc.input != nil && !cc.input.allowKeyMessage(clientMsg) {
+				return nil
+			}
+			if cc.keyRemapper != nil {
+				cc.writeLock.Lock()
+				defer cc.writeLock.Unlock()
+				err := cc.keyRemapper.remapKeyMessage(clientMsg, cc)
+				if err != nil {
+					logger.Errorf("ClientUpdater.Consume (vnc-server-bound, remapped key): problem writing to port: %s", err)
+					return cc.writeFailed(err)
+				}
+				return nil
+			}
+		case common.FramebufferUpdateRequestMsgType:
+			if cc.transcoder != nil {
+				cc.transcoder.scaleUpdateRequest(clientMsg.(*server.MsgFramebufferUpdateRequest))
+			}
+		case common.PointerEventMsgType:
+			if cc.viewOnly {
+				return nil
+			}
+			if cc.transcoder != nil {
+				cc.transcoder.scalePointer(clientMsg.(*server.MsgPointerEvent))
+			}
+			if cc.input != nil && !cc.input.filterPointer(clientMsg.(*server.MsgPointerEvent)) {
+				return nil
+			}
+		case common.ClientCutTextMsgType:
+			if cc.viewOnly {
+				logger.Debugf("ClientUpdater.Consume: dropping client cut text")
+				return nil
+			}
+			cutText := clientMsg.(*server.MsgClientCutText)
+			if !cc.clipboard.filterClientCutText(cc.sessionId, cutText) {
+				return nil
+			}
+			if cc.typist != nil {
+				if !cutText.IsExtended() || (cutText.Extended != nil && cutText.Extended.Action() == common.ClipboardProvide) {
+					cc.typist.Type(cutText.TextContent())
+					return nil
+				}
+			}
 		}
 
-		err := clientMsg.Write(cc.conn)
+		cc.writeLock.Lock()
+		if req, ok := clientMsg.(*server.MsgFramebufferUpdateRequest); ok && cc.placeholder != nil {
+			cc.placeholder.request(req.Inc == 0)
+		}
+		defer cc.writeLock.Unlock()
+		err := clientMsg.Write(cc)
 		if err != nil {
 			logger.Errorf("ClientUpdater.Consume (vnc-server-bound, SegmentFullyParsedClientMessage): problem writing to port: %s", err)
+			return cc.writeFailed(err)
 		}
+		return nil
+
+	case common.SegmentConnectionClosed:
+		// the vnc-client is gone, no reason to keep the vnc-server connection open
+		if cc.typist != nil {
+			cc.typist.Close()
+		}
+		cc.writeLock.Lock()
+		defer cc.writeLock.Unlock()
+		cc.closed = true
+		if cc.placeholder != nil {
+			cc.placeholder.stop()
+			cc.placeholder = nil
+		}
+		if cc.conn == nil {
+			return nil
+		}
+		return cc.conn.Close()
+	}
+	return nil
+}
+
+// Write writes to the vnc server, with the writeLock held: nothing is written until it is connected
+func (cc *ClientUpdater) Write(p []byte) (int, error) {
+	if cc.conn == nil {
+		return len(p), nil
+	}
+	return cc.conn.Write(p)
+}
+
+// writeFailed returns the error closing the vnc-client after a failed write to the vnc server, with the writeLock held.
+// The vnc server may be gone before its connection is found closed: when it is connected again, the connection
+// is closed instead and the vnc-client stays, the message is lost.
+func (cc *ClientUpdater) writeFailed(err error) error {
+	if !cc.reconnect || cc.conn == nil {
 		return err
 	}
+	cc.conn.Close()
 	return nil
 }
 
+// connect makes conn the vnc server of the vnc-client, setup (run with the writeLock held) sets the connection up.
+// When the vnc-client's messages went to a placeholder or a previous vnc server, the placeholder is stopped first,
+// and the vnc server is then sent the vnc-client's pixel format & encodings and asked for the whole screen.
+// After a previous vnc server whose updates were forwarded, the zlib based encodings are left out: the vnc-client
+// would inflate the new vnc server's streams with the previous one's dictionary.
+func (cc *ClientUpdater) connect(conn *client.ClientConn, setup func() error) error {
+	cc.writeLock.Lock()
+	defer cc.writeLock.Unlock()
+	if cc.closed {
+		conn.Close()
+		return errClientGone
+	}
+	resumed := cc.connected || cc.placeholder != nil
+	if cc.connected && cc.transcoder == nil {
+		cc.zlibStale = true
+	}
+	if cc.placeholder != nil {
+		cc.placeholder.stop()
+		cc.placeholder = nil
+	}
+	cc.conn = conn
+	if err := setup(); err != nil {
+		cc.conn = nil
+		return err
+	}
+	cc.connected = true
+	if !resumed {
+		if cc.transcoder == nil {
+			// the vnc-client keeps the pixel format of the first ServerInit
+			pf := conn.PixelFormat
+			cc.pixelFormat = &pf
+		}
+		return nil
+	}
+
+	if cc.pixelFormat != nil {
+		if err := conn.SetPixelFormat(cc.pixelFormat); err != nil {
+			return err
+		}
+		conn.PixelFormat = *cc.pixelFormat
+	}
+	if cc.encodings != nil {
+		encs := cc.encodings
+		if cc.transcoder != nil {
+			encs = cc.transcoder.setEncodings(encs)
+		} else if cc.zlibStale {
+			encs = withoutZlib(encs)
+		}
+		if err := (&server.MsgSetEncodings{Encodings: encs}).Write(conn); err != nil {
+			return err
+		}
+	}
+	return conn.FramebufferUpdateRequest(false, 0, 0, conn.FrameBufferWidth, conn.FrameBufferHeight)
+}
+
+// announced tells whether the vnc-client announced the encoding, with the writeLock held
+func (cc *ClientUpdater) announced(enc common.EncodingType) bool {
+	for _, e := range cc.encodings {
+		if e == enc {
+			return true
+		}
+	}
+	return false
+}
+
+// disconnected forgets the vnc server connection which was closed, it tells whether the vnc-client is
+// still there and was using it
+func (cc *ClientUpdater) disconnected(conn *client.ClientConn) bool {
+	cc.writeLock.Lock()
+	defer cc.writeLock.Unlock()
+	if cc.closed || cc.conn != conn {
+		return false
+	}
+	cc.conn = nil
+	return true
+}
+
+func (cc *ClientUpdater) isClosed() bool {
+	cc.writeLock.Lock()
+	defer cc.writeLock.Unlock()
+	return cc.closed
+}
+
+// inject writes a message which doesn't come from the vnc-client (admin api), the input policy doesn't apply to it.
+// Unlike the vnc-client's, it isn't dropped while the vnc server isn't connected (placeholder, reconnection).
+func (cc *ClientUpdater) inject(msg common.ClientMessage) error {
+	cc.writeLock.Lock()
+	defer cc.writeLock.Unlock()
+	if cc.conn == nil {
+		return errUpstreamNotConnected
+	}
+	if cc.keyRemapper != nil {
+		return cc.keyRemapper.remapKeyMessage(msg, cc)
+	}
+	return msg.Write(cc)
+}
+
 type ServerUpdater struct {
 	conn *server.ServerConn
+
+	// clipboard filters the server's cut text, which is held back until fully parsed
+	clipboard *ClipboardPolicy
+	sessionId string
+	holding   bool
+	held      []byte
+
+	// transcoder re-encodes the framebuffer updates, which aren't forwarded, like the vnc server's color map (nil = forward)
+	transcoder  *transcoder
+	transcoding bool
+	// initialized is set once the vnc-client's connection has its size & pixel format, a vnc server
+	// connected later (placeholder, reconnection) doesn't change them
+	initialized bool
+	// partial is set while a forwarded message is incomplete
+	partial bool
 }
 
 func (p *ServerUpdater) Consume(seg *common.RfbSegment) error {
 	switch seg.SegmentType {
 	case common.SegmentMessageStart:
+		p.holding = common.ServerMessageType(seg.UpcomingObjectType) == common.ServerCutText
+		p.held = nil
+		switch common.ServerMessageType(seg.UpcomingObjectType) {
+		case common.FramebufferUpdate, common.SetColourMapEntries:
+			p.transcoding = p.transcoder != nil
+		default:
+			p.transcoding = false
+		}
+		p.partial = !p.transcoding
+	case common.SegmentMessageEnd:
 	case common.SegmentRectSeparator:
 	case common.SegmentServerInitMessage:
+		if p.initialized {
+			return nil
+		}
+		p.initialized = true
 		serverInitMessage := seg.Message.(*common.ServerInit)
 		p.conn.SetHeight(serverInitMessage.FBHeight)
 		p.conn.SetWidth(serverInitMessage.FBWidth)
@@ -48,6 +339,13 @@ func (p *ServerUpdater) Consume(seg *common.RfbSegment) error {
 		p.conn.SetPixelFormat(&serverInitMessage.PixelFormat)
 
 	case common.SegmentBytes:
+		if p.holding {
+			p.held = append(p.held, seg.Bytes...)
+			return nil
+		}
+		if p.transcoding {
+			return nil
+		}
 		_, err := p.conn.Write(seg.Bytes)
 		if err != nil {
 			logger.Errorf("WriteTo.Consume (ServerUpdater SegmentBytes): problem writing to port: %s", err)
@@ -62,7 +360,54 @@ func (p *ServerUpdater) Consume(seg *common.RfbSegment) error {
 		}
 		return err
 
+	case common.SegmentFullyParsedServerMessage:
+		p.partial = false
+		if update, ok := seg.Message.(*client.MsgFramebufferUpdate); ok && p.transcoding {
+			p.transcoding = false
+			err := p.transcoder.flush(update)
+			if err != nil {
+				logger.Errorf("WriteTo.Consume (ServerUpdater transcoded update): problem writing to port: %s", err)
+			}
+			return err
+		}
+		cutText, ok := seg.Message.(*client.MsgServerCutText)
+		if !ok || !p.holding {
+			return nil
+		}
+		p.holding = false
+		msg := p.clipboard.filterServerCutText(p.sessionId, cutText, p.held)
+		p.held = nil
+		if msg == nil {
+			return nil
+		}
+		_, err := p.conn.Write(msg)
+		if err != nil {
+			logger.Errorf("WriteTo.Consume (ServerUpdater SegmentFullyParsedServerMessage): problem writing to port: %s", err)
+		}
+		return err
+
 	default:
 	}
 	return nil
 }
+
+// resize sends the vnc-client a DesktopSize when a vnc server connected again has another size (forwarded updates),
+// the recorder (nil = none) gets it as well
+func (p *ServerUpdater) resize(width, height uint16, supported bool, recorder common.SegmentConsumer) error {
+	if width == p.conn.Width() && height == p.conn.Height() {
+		return nil
+	}
+	if !supported {
+		return fmt.Errorf("the vnc-client can't be resized from %dx%d to %dx%d", p.conn.Width(), p.conn.Height(), width, height)
+	}
+	var rect bytes.Buffer
+	encodings.WriteDesktopSize(&rect, int(width), int(height))
+	msg := updateMessage(1, &rect)
+	p.conn.SetWidth(width)
+	p.conn.SetHeight(height)
+	if recorder != nil {
+		recorder.Consume(&common.RfbSegment{SegmentType: common.SegmentBytes, Bytes: msg})
+	}
+	_, err := p.conn.Write(msg)
+	return err
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"time"
+
+	"github.com/exoscale/vncproxy/font"
+)
+
+// RecordingBannerText is the banner of the recorded sessions, with OverlayConfig.RecordingBanner
+const RecordingBannerText = "This session is being recorded"
+
+// OverlayConfig draws over the screen sent to each vnc-client: a faint watermark identifying the viewer, so leaked
+// screenshots can be traced back, and a banner. It implies transcoding.
+type OverlayConfig struct {
+	// Watermark tiles the viewer's user, the session id & the connection time over the screen
+	Watermark bool
+	// WatermarkOpacity is the opacity of the watermark (1-255), 32 by default
+	WatermarkOpacity uint8
+	// Banner is shown at the top of the screen, empty = no banner
+	Banner string
+	// RecordingBanner shows RecordingBannerText at the top of the screen of the recorded sessions (unless Banner is set)
+	RecordingBanner bool
+}
+
+const (
+	watermarkScale = 2
+	bannerScale    = 2
+	bannerPadding  = 4
+)
+
+var (
+	watermarkColor   = color.RGBA{0x80, 0x80, 0x80, 0xff}
+	bannerBackground = color.NRGBA{0xb0, 0x10, 0x10, 0xd0}
+	bannerColor      = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// overlay keeps the overlay of a vnc-client: a transparent layer with the watermark & the banner,
+// and the screen with the layer drawn over it
+type overlay struct {
+	watermark string
+	opacity   uint8
+	banner    string
+
+	layer      *image.RGBA
+	bannerRect image.Rectangle
+	view       *image.RGBA
+}
+
+// newOverlay returns the overlay of a vnc-client, nil when there is nothing to draw
+func newOverlay(cfg *OverlayConfig, recorded bool, user, sessionId string, connected time.Time) *overlay {
+	if cfg == nil {
+		return nil
+	}
+	o := &overlay{banner: cfg.Banner, opacity: cfg.WatermarkOpacity}
+	if o.banner == "" && cfg.RecordingBanner && recorded {
+		o.banner = RecordingBannerText
+	}
+	if cfg.Watermark {
+		var parts []string
+		for _, part := range []string{user, sessionId, connected.UTC().Format("2006-01-02 15:04 UTC")} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		o.watermark = strings.Join(parts, " | ")
+	}
+	if o.opacity == 0 {
+		o.opacity = 32
+	}
+	if o.watermark == "" && o.banner == "" {
+		return nil
+	}
+	return o
+}
+
+// resize renders the layer for the vnc-client's screen size
+func (o *overlay) resize(width, height int) {
+	o.layer = image.NewRGBA(image.Rect(0, 0, width, height))
+	o.view = image.NewRGBA(o.layer.Rect)
+	o.bannerRect = image.Rectangle{}
+
+	if o.watermark != "" {
+		// staggered rows of the text, with a gap
+		text := image.NewRGBA(image.Rectangle{Max: font.Size(o.watermark, watermarkScale)})
+		font.Draw(text, o.watermark, image.Point{}, watermarkScale, watermarkColor)
+		mask := image.NewUniform(color.Alpha{o.opacity})
+		stepX, stepY := text.Rect.Dx()+8*font.GlyphWidth, 6*font.GlyphHeight
+		for row, y := 0, font.GlyphHeight; y < height; row, y = row+1, y+stepY {
+			for x := -(row % 2) * stepX / 2; x < width; x += stepX {
+				r := text.Rect.Add(image.Pt(x, y))
+				draw.DrawMask(o.layer, r, text, image.Point{}, mask, image.Point{}, draw.Over)
+			}
+		}
+	}
+
+	if o.banner != "" {
+		size := font.Size(o.banner, bannerScale)
+		o.bannerRect = image.Rect(0, 0, width, size.Y+2*bannerPadding)
+		draw.Draw(o.layer, o.bannerRect, image.NewUniform(bannerBackground), image.Point{}, draw.Src)
+		font.Draw(o.layer, o.banner, image.Pt((width-size.X)/2, bannerPadding), bannerScale, bannerColor)
+	}
+}
+
+// covers tells whether the layer draws over the rectangle
+func (o *overlay) covers(r image.Rectangle) bool {
+	return (o.watermark != "" && r.Overlaps(o.layer.Rect)) || r.Overlaps(o.bannerRect)
+}
+
+// paint updates a rectangle of the view from the screen, and returns the view
+func (o *overlay) paint(src *image.RGBA, r image.Rectangle) *image.RGBA {
+	draw.Draw(o.view, r, src, r.Min, draw.Src)
+	draw.Draw(o.view, r, o.layer, r.Min, draw.Over)
+	return o.view
+}
@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/keyboard"
+	"github.com/exoscale/vncproxy/logger"
+	"github.com/exoscale/vncproxy/server"
+)
+
+// PasteConfig makes the proxy type the vnc-client's clipboard into the console instead of forwarding it,
+// for vnc servers ignoring ClientCutText (QEMU text consoles, BIOS, installers...)
+type PasteConfig struct {
+	// Layout is the guest's keyboard layout: us (default), fr or de
+	Layout string
+	// Delay is the pause between key events, defaults to 10ms
+	Delay time.Duration
+	// QEMUKeys sends QEMU extended key events (with XT scan codes) instead of plain keysyms
+	QEMUKeys bool
+	// MaxLength is the longest text typed, in characters, defaults to 4096
+	MaxLength int
+}
+
+const (
+	defaultPasteDelay     = 10 * time.Millisecond
+	defaultPasteMaxLength = 4096
+)
+
+// keyTypist types pasted texts in the background, one at a time
+type keyTypist struct {
+	conn      io.Writer
+	writeLock *sync.Mutex // shared with the ClientUpdater so events don't interleave
+	layout    *keyboard.Layout
+	delay     time.Duration
+	qemuKeys  bool
+	maxLength int
+	sessionId string
+
+	texts chan string
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newKeyTypist(cfg *PasteConfig, conn io.Writer, writeLock *sync.Mutex, sessionId string) (*keyTypist, error) {
+	layout, err := keyboard.LayoutByName(cfg.Layout)
+	if err != nil {
+		return nil, err
+	}
+	t := &keyTypist{
+		conn:      conn,
+		writeLock: writeLock,
+		layout:    layout,
+		delay:     cfg.Delay,
+		qemuKeys:  cfg.QEMUKeys,
+		maxLength: cfg.MaxLength,
+		sessionId: sessionId,
+		texts:     make(chan string, 4),
+		done:      make(chan struct{}),
+	}
+	if t.delay <= 0 {
+		t.delay = defaultPasteDelay
+	}
+	if t.maxLength <= 0 {
+		t.maxLength = defaultPasteMaxLength
+	}
+	go t.run()
+	return t, nil
+}
+
+// Type queues the text, it is dropped if too many pastes are waiting
+func (t *keyTypist) Type(text string) {
+	if runes := []rune(text); len(runes) > t.maxLength {
+		logger.Warnf("keyTypist.Type: session=%q paste of %d characters truncated to %d", t.sessionId, len(runes), t.maxLength)
+		text = string(runes[:t.maxLength])
+	}
+	select {
+	case t.texts <- text:
+	case <-t.done:
+	default:
+		logger.Warnf("keyTypist.Type: session=%q still typing, dropping paste", t.sessionId)
+	}
+}
+
+// Close stops typing, the current text is abandoned
+func (t *keyTypist) Close() {
+	t.once.Do(func() { close(t.done) })
+}
+
+func (t *keyTypist) run() {
+	for {
+		select {
+		case text := <-t.texts:
+			if err := t.typeText(text); err != nil {
+				logger.Errorf("keyTypist.run: session=%q error typing: %s", t.sessionId, err)
+				return
+			}
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *keyTypist) typeText(text string) error {
+	events, missing := t.layout.KeyEvents(text)
+	if len(missing) > 0 {
+		logger.Warnf("keyTypist.typeText: session=%q skipping %d characters that can't be typed with the %s layout",
+			t.sessionId, len(missing), t.layout.Name)
+	}
+	logger.Infof("keyTypist.typeText: session=%q typing %d key events", t.sessionId, len(events))
+
+	for _, ev := range events {
+		select {
+		case <-t.done:
+			return nil
+		case <-time.After(t.delay):
+		}
+		if err := t.send(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *keyTypist) send(ev keyboard.KeyEvent) error {
+	var msg common.ClientMessage
+	var down uint8
+	if ev.Down {
+		down = 1
+	}
+	if t.qemuKeys {
+		msg = &server.MsgClientQemuExtendedKey{IsDown: uint16(down), KeySym: uint32(ev.Keysym), KeyCode: ev.Scancode}
+	} else {
+		msg = &server.MsgKeyEvent{Down: down, Key: server.Key(ev.Keysym)}
+	}
+
+	t.writeLock.Lock()
+	defer t.writeLock.Unlock()
+	return msg.Write(t.conn)
+}
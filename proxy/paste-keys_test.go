@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/exoscale/vncproxy/server"
+)
+
+// lockedBuffer is written by the typist goroutine & read by the test
+type lockedBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.Lock()
+	defer b.Unlock()
+	return append([]byte{}, b.buf.Bytes()...)
+}
+
+func waitForBytes(t *testing.T, out *lockedBuffer, size int) []byte {
+	deadline := time.Now().Add(5 * time.Second)
+	for len(out.Bytes()) < size {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %d bytes, got %d", size, len(out.Bytes()))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return out.Bytes()
+}
+
+func TestKeyTypist(t *testing.T) {
+	out := &lockedBuffer{}
+	var lock sync.Mutex
+	typist, err := newKeyTypist(&PasteConfig{Layout: "fr", Delay: time.Microsecond}, out, &lock, "s")
+	if err != nil {
+		t.Fatalf("creating typist: %s", err)
+	}
+	defer typist.Close()
+
+	// A = shift + q key, 4 events of 8 bytes
+	typist.Type("A")
+	data := waitForBytes(t, out, 32)
+	expected := []server.MsgKeyEvent{{Down: 1, Key: 0xffe1}, {Down: 1, Key: 'A'}, {Key: 'A'}, {Key: 0xffe1}}
+	for i, ev := range expected {
+		msg, err := new(server.MsgKeyEvent).Read(bytes.NewReader(data[8*i+1 : 8*i+8]))
+		if err != nil {
+			t.Fatalf("reading event %d: %s", i, err)
+		}
+		if got := *msg.(*server.MsgKeyEvent); data[8*i] != 4 || got != ev {
+			t.Errorf("event %d: got %+v, expected %+v", i, got, ev)
+		}
+	}
+
+	qemuOut := &lockedBuffer{}
+	qemuTypist, err := newKeyTypist(&PasteConfig{Layout: "fr", Delay: time.Microsecond, QEMUKeys: true}, qemuOut, &lock, "s")
+	if err != nil {
+		t.Fatalf("creating typist: %s", err)
+	}
+	defer qemuTypist.Close()
+
+	// a is on the q key of a US keyboard
+	qemuTypist.Type("a")
+	data = waitForBytes(t, qemuOut, 24)
+	msg, err := new(server.MsgClientQemuExtendedKey).Read(bytes.NewReader(data[1:12]))
+	if err != nil {
+		t.Fatalf("reading qemu event: %s", err)
+	}
+	if got := msg.(*server.MsgClientQemuExtendedKey); data[0] != 255 || got.IsDown != 1 || got.KeySym != 'a' || got.KeyCode != 0x10 {
+		t.Errorf("unexpected qemu event: %+v", got)
+	}
+}
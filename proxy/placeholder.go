@@ -0,0 +1,303 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/exoscale/vncproxy/client"
+	"github.com/exoscale/vncproxy/font"
+	"github.com/exoscale/vncproxy/logger"
+	"github.com/exoscale/vncproxy/server"
+)
+
+// PlaceholderConfig makes the proxy show its own status screens to the vnc-clients while the vnc server is
+// connecting or unavailable ("Connecting to VM...", "VM is powered off" & "Retrying in 5s"), instead of a black
+// window or a dropped connection. The vnc-client gets the placeholder's ServerInit, and the vnc server's screen
+// once connected (with a DesktopSize if the size differs). It implies transcoding.
+type PlaceholderConfig struct {
+	// Width & Height are the size of the placeholder screen, the proxy's default size (1024x768) when 0
+	Width  int
+	Height int
+	// RetryDelay is the wait between the connection attempts, 5s by default
+	RetryDelay time.Duration
+	// Retries is the number of attempts after the first one before giving up, 0 = until the vnc-client leaves
+	Retries int
+}
+
+const (
+	defaultPlaceholderRetryDelay = 5 * time.Second
+	// placeholderFrame is the spinner's frame duration
+	placeholderFrame = 150 * time.Millisecond
+	spinnerDots      = 8
+	spinnerRadius    = 20
+	spinnerDot       = 6
+)
+
+var (
+	placeholderBackground = color.RGBA{0x20, 0x24, 0x2c, 0xff}
+	placeholderColor      = color.RGBA{0xe8, 0xe8, 0xe8, 0xff}
+	placeholderDim        = color.RGBA{0x70, 0x76, 0x80, 0xff}
+
+	errClientGone           = errors.New("the vnc-client left")
+	errUpstreamNotConnected = errors.New("upstream not connected")
+)
+
+// placeholder paints the status screens on the transcoder's canvas, and sends them when the vnc-client
+// asks for updates
+type placeholder struct {
+	transcoder *transcoder
+	sessionId  string
+
+	lock      sync.Mutex
+	title     string
+	detail    string
+	spinner   bool
+	requested bool // the vnc-client waits for an update
+	full      bool // ...a non incremental one
+
+	// ready is closed on the vnc-client's first update request, once it got the ServerInit
+	ready     chan struct{}
+	readyOnce sync.Once
+	wake      chan struct{}
+	quit      chan struct{}
+	quitOnce  sync.Once
+	done      chan struct{}
+
+	// screen is the last screen sent, used by the goroutine painting
+	screen *image.RGBA
+}
+
+// newPlaceholder sets the transcoder up for the placeholder screens (before the vnc-client's ServerInit),
+// and starts painting them
+func newPlaceholder(cfg *PlaceholderConfig, t *transcoder) *placeholder {
+	width, height := cfg.Width, cfg.Height
+	if width <= 0 || height <= 0 {
+		width, height = int(t.conn.Width()), int(t.conn.Height())
+	}
+	t.showPlaceholder(width, height)
+
+	p := &placeholder{
+		transcoder: t,
+		sessionId:  t.sessionId,
+		ready:      make(chan struct{}),
+		wake:       make(chan struct{}, 1),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+		screen:     image.NewRGBA(image.Rect(0, 0, width, height)),
+	}
+	go p.run()
+	return p
+}
+
+// setStatus changes the screen, the spinner shows that something is going on
+func (p *placeholder) setStatus(title, detail string, spinner bool) {
+	p.lock.Lock()
+	p.title, p.detail, p.spinner = title, detail, spinner
+	p.lock.Unlock()
+	p.wakeUp()
+}
+
+// request takes a FramebufferUpdateRequest of the vnc-client
+func (p *placeholder) request(full bool) {
+	p.lock.Lock()
+	p.requested = true
+	p.full = p.full || full
+	p.lock.Unlock()
+	p.readyOnce.Do(func() { close(p.ready) })
+	p.wakeUp()
+}
+
+func (p *placeholder) wakeUp() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// stop stops painting, the transcoder is free once it returns
+func (p *placeholder) stop() {
+	p.quitOnce.Do(func() { close(p.quit) })
+	<-p.done
+}
+
+func (p *placeholder) stopped() bool {
+	select {
+	case <-p.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *placeholder) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(placeholderFrame)
+	defer ticker.Stop()
+	frame := 0
+	for {
+		select {
+		case <-p.quit:
+			return
+		case <-ticker.C:
+			frame++
+		case <-p.wake:
+		}
+		if err := p.send(frame); err != nil {
+			logger.Errorf("placeholder (session %s): %s", p.sessionId, err)
+			return
+		}
+	}
+}
+
+// send paints the screen and sends what changed, if the vnc-client asked for it
+func (p *placeholder) send(frame int) error {
+	p.lock.Lock()
+	if !p.requested {
+		p.lock.Unlock()
+		return nil
+	}
+	full := p.full
+	p.requested, p.full = false, false
+	title, detail, spinner := p.title, p.detail, p.spinner
+	p.lock.Unlock()
+
+	screen := image.NewRGBA(p.screen.Rect)
+	paintPlaceholder(screen, title, detail, spinner, frame)
+	r := screen.Rect
+	if !full {
+		r = changedRect(p.screen, screen)
+	}
+	p.screen = screen
+	if r.Empty() {
+		// the incremental request waits for a change
+		p.lock.Lock()
+		p.requested = true
+		p.lock.Unlock()
+		return nil
+	}
+	return p.transcoder.paintScreen(screen, r)
+}
+
+// paintPlaceholder draws a status screen: the spinner, the title and the detail line, centered
+func paintPlaceholder(img *image.RGBA, title, detail string, spinner bool, frame int) {
+	draw.Draw(img, img.Rect, image.NewUniform(placeholderBackground), image.Point{}, draw.Src)
+	center := image.Pt(img.Rect.Dx()/2, img.Rect.Dy()/2)
+
+	if spinner {
+		for i := 0; i < spinnerDots; i++ {
+			angle := 2 * math.Pi * float64(i) / spinnerDots
+			at := center.Add(image.Pt(int(spinnerRadius*math.Sin(angle)), -2*spinnerRadius-int(spinnerRadius*math.Cos(angle))))
+			c := placeholderDim
+			if i == frame%spinnerDots {
+				c = placeholderColor
+			}
+			dot := image.Rect(at.X-spinnerDot/2, at.Y-spinnerDot/2, at.X+spinnerDot/2, at.Y+spinnerDot/2)
+			draw.Draw(img, dot, image.NewUniform(c), image.Point{}, draw.Src)
+		}
+	}
+
+	scale := fitScale(title, img.Rect.Dx(), 3)
+	size := font.Size(title, scale)
+	font.Draw(img, title, image.Pt(center.X-size.X/2, center.Y), scale, placeholderColor)
+	scale = fitScale(detail, img.Rect.Dx(), 2)
+	size = font.Size(detail, scale)
+	font.Draw(img, detail, image.Pt(center.X-size.X/2, center.Y+4*font.GlyphHeight), scale, placeholderDim)
+}
+
+// fitScale returns the largest scale up to max at which the text fits in width
+func fitScale(text string, width, max int) int {
+	scale := max
+	for scale > 1 && font.Size(text, scale).X > width {
+		scale--
+	}
+	return scale
+}
+
+// changedRect returns the bounds of the pixels which differ between two images of the same size
+func changedRect(a, b *image.RGBA) image.Rectangle {
+	var r image.Rectangle
+	for y := b.Rect.Min.Y; y < b.Rect.Max.Y; y++ {
+		i := b.PixOffset(b.Rect.Min.X, y)
+		for x := b.Rect.Min.X; x < b.Rect.Max.X; x, i = x+1, i+4 {
+			if a.Pix[i] != b.Pix[i] || a.Pix[i+1] != b.Pix[i+1] || a.Pix[i+2] != b.Pix[i+2] {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+// placeholderTitle is what the vnc-client is told when connecting to the vnc server failed
+func placeholderTitle(err error) string {
+	switch {
+	case errors.Is(err, errUpstreamUnreachable):
+		return "VM is powered off"
+	case errors.Is(err, errUpstreamAuth):
+		return "VM console authentication failed"
+	default:
+		return "VM console is not available"
+	}
+}
+
+// connectWithPlaceholder connects to the vnc server of a vnc-client shown the placeholder, retrying as configured,
+// attach hands the vnc-client over to the connection. The vnc-client is closed when giving up.
+func (vp *VncProxy) connectWithPlaceholder(cfg *PlaceholderConfig, session *VncSession, sconn *server.ServerConn,
+	p *placeholder, attach func(*client.ClientConn) error) {
+	delay := cfg.RetryDelay
+	if delay <= 0 {
+		delay = defaultPlaceholderRetryDelay
+	}
+	for attempt := 0; ; attempt++ {
+		p.setStatus("Connecting to VM...", "", true)
+		cconn, err := vp.connectUpstream(session, sconn.SessionId, true)
+		if err == nil {
+			// the vnc-client must be initialized before it is switched to the vnc server
+			select {
+			case <-p.ready:
+			case <-p.quit:
+				cconn.Close()
+				return
+			}
+			err = attach(cconn)
+			if err == nil {
+				return
+			}
+		}
+		if p.stopped() {
+			// the vnc-client left, or couldn't be switched
+			sconn.Close()
+			return
+		}
+
+		title := placeholderTitle(err)
+		if cfg.Retries > 0 && attempt >= cfg.Retries {
+			logger.Errorf("Proxy.connectWithPlaceholder giving up on session %s after %d attempts: %s", sconn.SessionId, attempt+1, err)
+			p.setStatus(title, "", false)
+			select {
+			case <-time.After(delay):
+			case <-p.quit:
+			}
+			sconn.Close()
+			return
+		}
+		logger.Infof("Proxy.connectWithPlaceholder session %s: %s, retrying in %s", sconn.SessionId, err, delay)
+		for left := delay; left > 0; left -= time.Second {
+			p.setStatus(title, fmt.Sprintf("Retrying in %ds", int((left+time.Second-1)/time.Second)), false)
+			wait := time.Second
+			if left < wait {
+				wait = left
+			}
+			select {
+			case <-time.After(wait):
+			case <-p.quit:
+				return
+			}
+		}
+	}
+}
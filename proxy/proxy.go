@@ -1,14 +1,19 @@
 package proxy
 
 import (
+	"crypto/rsa"
+	"crypto/tls"
 	"net"
 	"path"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/exoscale/vncproxy/auth"
 	"github.com/exoscale/vncproxy/client"
 	"github.com/exoscale/vncproxy/common"
 	"github.com/exoscale/vncproxy/encodings"
+	"github.com/exoscale/vncproxy/keyboard"
 	"github.com/exoscale/vncproxy/logger"
 	"github.com/exoscale/vncproxy/player"
 	listeners "github.com/exoscale/vncproxy/recorder"
@@ -16,17 +21,53 @@ import (
 )
 
 type VncProxy struct {
-	TcpListeningUrl  string      // empty = not listening on tcp
-	WsListeningUrl   string      // empty = not listening on ws
-	RecordingDir     string      // empty = no recording
-	ProxyVncPassword string      //empty = no auth
-	SingleSession    *VncSession // to be used when not using sessions
-	UsingSessions    bool        //false = single session - defined in the var above
-	DynamicLookup    bool
-	sessionManager   *SessionManager
+	TcpListeningUrl   string      // empty = not listening on tcp
+	WsListeningUrl    string      // empty = not listening on ws
+	RecordingDir      string      // empty = no recording
+	ProxyVncPassword  string      //empty = no auth
+	SingleSession     *VncSession // to be used when not using sessions
+	UsingSessions     bool        //false = single session - defined in the var above
+	DynamicLookup     bool
+	JwtVerifier       *auth.JwtVerifier // nil = no token required on ws connections
+	ProxyUsers        auth.UserStore    // nil = no VeNCrypt username/password auth
+	ProxyTLSConfig    *tls.Config       // nil = VeNCrypt without TLS
+	ProxyRSAKey       *rsa.PrivateKey   // nil = no RSA-AES auth
+	Totp              *auth.TotpVerifier
+	VncTotpUser       string             // user whose TOTP secret is used with ProxyVncPassword
+	RelayAuth         bool               // relay VNC auth to the target, the proxy doesn't know the password
+	ClipboardPolicy   *ClipboardPolicy   // nil = clipboard allowed both ways, sessions may have their own
+	InputPolicy       *InputPolicy       // nil = all input forwarded, sessions may have their own
+	PasteAsKeys       *PasteConfig       // nil = forward the vnc-client's clipboard, else type it as keys
+	KeyboardLayout    string             // guest layout the key events are remapped to (QEMU extended key events), empty = no remapping
+	AdminListeningUrl string             // empty = no admin api (see admin-api.go)
+	AdminToken        string             // bearer token required by the admin api, empty = no auth
+	Transcode         *TranscodeConfig   // nil = updates forwarded as they are, sessions may have their own
+	Scale             *ScaleConfig       // nil = no downscaling (unless a vnc-client asks for it), sessions may have their own
+	MaskPolicy        *MaskPolicy        // nil = no privacy masks, sessions may have their own
+	Overlay           *OverlayConfig     // nil = no watermark nor banner, sessions may have their own
+	Placeholder       *PlaceholderConfig // nil = the vnc-client waits for the vnc server, sessions may have their own
+	Reconnect         *ReconnectConfig   // nil = the vnc-clients are disconnected with the vnc server, sessions may have their own
+	sessionManager    *SessionManager
+
+	upstreamsLock sync.Mutex
+	upstreams     map[*server.ServerConn]*client.ClientConn // upstreams authenticated during the security handshake
+
+	liveLock       sync.Mutex
+	liveSessions   map[string][]*ClientUpdater // upstreams of the connected vnc-clients, by session id
+	adminUpstreams map[string]*adminUpstream   // upstreams opened by the admin api for sessions without vnc-client
 }
 
-func (vp *VncProxy) createClientConnection(target string, vncPass string) (*client.ClientConn, error) {
+func (vp *VncProxy) createClientConnection(target string, vncUser string, vncPass string, exclusive bool) (*client.ClientConn, error) {
+	var noauth client.ClientAuthNone
+	authArr := []client.ClientAuth{&client.PasswordAuth{Password: vncPass}, &noauth}
+	if vncUser != "" {
+		authArr = append([]client.ClientAuth{&client.ARDAuth{Username: vncUser, Password: vncPass}}, authArr...)
+	}
+
+	return vp.dialClientConnection(target, authArr, exclusive)
+}
+
+func (vp *VncProxy) dialClientConnection(target string, authArr []client.ClientAuth, exclusive bool) (*client.ClientConn, error) {
 	var (
 		nc  net.Conn
 		err error
@@ -43,13 +84,10 @@ func (vp *VncProxy) createClientConnection(target string, vncPass string) (*clie
 		return nil, err
 	}
 
-	var noauth client.ClientAuthNone
-	authArr := []client.ClientAuth{&client.PasswordAuth{Password: vncPass}, &noauth}
-
 	clientConn, err := client.NewClientConn(nc,
 		&client.ClientConfig{
 			Auth:      authArr,
-			Exclusive: true,
+			Exclusive: exclusive,
 		})
 
 	if err != nil {
@@ -72,6 +110,86 @@ func (vp *VncProxy) getProxySession(sessionId string) (*VncSession, error) {
 	return vp.sessionManager.GetSession(sessionId)
 }
 
+// sessionKeyRemapper returns the key remapper for the session's guest layout, nil when keys are forwarded as they are
+func (vp *VncProxy) sessionKeyRemapper(session *VncSession) (*keyRemapper, error) {
+	layout := session.KeyboardLayout
+	if layout == "" {
+		layout = vp.KeyboardLayout
+	}
+	if layout == "" {
+		return nil, nil
+	}
+	return newKeyRemapper(layout)
+}
+
+// sessionTranscoder returns the transcoder of a vnc-client, nil when the updates are forwarded as they are:
+// scaling, privacy masks, overlays & placeholders imply transcoding
+func (vp *VncProxy) sessionTranscoder(session *VncSession, sconn *server.ServerConn, recorded bool) (*transcoder, error) {
+	transcode := session.Transcode
+	if transcode == nil {
+		transcode = vp.Transcode
+	}
+	scale := session.Scale
+	if scale == nil {
+		scale = vp.Scale
+	}
+	if query, err := ParseScaleQuery(sconn.Query); err != nil {
+		logger.Warnf("Proxy.sessionTranscoder ignoring the scaling asked for: %s", err)
+	} else if query != nil {
+		scale = query
+	}
+	masks := session.MaskPolicy
+	if masks == nil {
+		masks = vp.MaskPolicy
+	}
+	overlayCfg := session.Overlay
+	if overlayCfg == nil {
+		overlayCfg = vp.Overlay
+	}
+	user := sconn.User
+	if user == "" && sconn.Claims != nil {
+		user = sconn.Claims.Subject
+	}
+	overlay := newOverlay(overlayCfg, recorded, user, sconn.SessionId, time.Now())
+
+	if transcode == nil && scale == nil && masks == nil && overlay == nil && vp.sessionPlaceholder(session) == nil {
+		return nil, nil
+	}
+	if transcode == nil {
+		transcode = &TranscodeConfig{}
+	}
+	t := newTranscoder(transcode, sconn)
+	t.scaler = newScaler(scale)
+	masker, err := newMasker(masks)
+	if err != nil {
+		return nil, err
+	}
+	t.masker = masker
+	t.overlay = overlay
+	return t, nil
+}
+
+// sessionPlaceholder returns the placeholder configuration of the session, nil when there is none
+func (vp *VncProxy) sessionPlaceholder(session *VncSession) *PlaceholderConfig {
+	if session.Placeholder != nil {
+		return session.Placeholder
+	}
+	return vp.Placeholder
+}
+
+// sessionTarget returns the address (host:port or unix socket path) of the session's vnc server
+func (vp *VncProxy) sessionTarget(session *VncSession, sessionId string) string {
+	target := session.Target
+	if session.TargetHostname != "" && session.TargetPort != "" {
+		target = session.TargetHostname + ":" + session.TargetPort
+	}
+
+	if vp.DynamicLookup {
+		target += "/" + sessionId + ".sock"
+	}
+	return target
+}
+
 func (vp *VncProxy) newServerConnHandler(cfg *server.ServerConfig, sconn *server.ServerConn) error {
 	var err error
 	session, err := vp.getProxySession(sconn.SessionId)
@@ -80,9 +198,15 @@ func (vp *VncProxy) newServerConnHandler(cfg *server.ServerConfig, sconn *server
 		return err
 	}
 
+	sessionType, err := vp.connSessionType(session, sconn)
+	if err != nil {
+		return err
+	}
+	claims := sconn.Claims
+
 	var rec *listeners.Recorder
 
-	if session.Type == SessionTypeRecordingProxy {
+	if sessionType == SessionTypeRecordingProxy {
 		recFile := "recording" + strconv.FormatInt(time.Now().Unix(), 10) + ".rbs"
 		recPath := path.Join(vp.RecordingDir, recFile)
 		rec, err = listeners.NewRecorder(recPath)
@@ -90,73 +214,165 @@ func (vp *VncProxy) newServerConnHandler(cfg *server.ServerConfig, sconn *server
 			logger.Errorf("Proxy.newServerConnHandler can't open recorder save path: %s", recPath)
 			return err
 		}
-
-		sconn.Listeners.AddListener(rec)
 	}
 
 	session.Status = SessionStatusInit
-	if session.Type == SessionTypeProxyPass || session.Type == SessionTypeRecordingProxy {
-		target := session.Target
-		if session.TargetHostname != "" && session.TargetPort != "" {
-			target = session.TargetHostname + ":" + session.TargetPort
-		}
-
-		if vp.DynamicLookup {
-			target += "/" + sconn.SessionId + ".sock"
-		}
-
-		cconn, err := vp.createClientConnection(target, session.TargetPassword)
-		if err != nil {
-			session.Status = SessionStatusError
-			logger.Errorf("Proxy.newServerConnHandler error creating connection: %s", err)
-			return err
-		}
-		if session.Type == SessionTypeRecordingProxy {
-			cconn.Listeners.AddListener(rec)
+	if sessionType == SessionTypeProxyPass || sessionType == SessionTypeRecordingProxy {
+		//the upstream connection is usually dialed & authenticated during the security handshake (see upstreamHandler),
+		//unless the placeholder is shown while connecting
+		cconn := vp.takeAuthenticatedUpstream(sconn)
+		placeholderCfg := vp.sessionPlaceholder(session)
+		if cconn == nil && placeholderCfg == nil {
+			cconn, err = vp.connectUpstream(session, sconn.SessionId, true)
+			if err != nil {
+				return err
+			}
 		}
 
 		//creating cross-listeners between server and client parts to pass messages through the proxy:
 
 		// gets the bytes from the actual vnc server on the env (client part of the proxy)
 		// and writes them through the server socket to the vnc-client
-		serverUpdater := &ServerUpdater{sconn}
-		cconn.Listeners.AddListener(serverUpdater)
+		clipboard := session.ClipboardPolicy
+		if clipboard == nil {
+			clipboard = vp.ClipboardPolicy
+		}
+		clipboard = clipboard.withClaims(claims)
+		serverUpdater := &ServerUpdater{conn: sconn, clipboard: clipboard, sessionId: sconn.SessionId}
 
 		// gets the messages from the server part (from vnc-client),
 		// and write through the client to the actual vnc-server
-		clientUpdater := &ClientUpdater{cconn}
+		clientUpdater := &ClientUpdater{clipboard: clipboard, sessionId: sconn.SessionId}
 		sconn.Listeners.AddListener(clientUpdater)
+		// the vnc-client may be connected to the vnc server later on, see addLiveSession
+		sconn.Listeners.AddListener(&liveSessionCloser{vp, sconn.SessionId, clientUpdater})
 
-		err = cconn.Connect()
+		transcoder, err := vp.sessionTranscoder(session, sconn, rec != nil)
 		if err != nil {
-			session.Status = SessionStatusError
-			logger.Errorf("Proxy.newServerConnHandler error connecting to client: %s", err)
+			logger.Errorf("Proxy.newServerConnHandler can't mask the screen: %s", err)
 			return err
 		}
+		serverUpdater.transcoder = transcoder
+		clientUpdater.transcoder = transcoder
+
+		var recording common.SegmentConsumer
+		if rec != nil {
+			recording = rec
+			if transcoder != nil {
+				// recorded in a fixed format, whatever the vnc-client asks for
+				recording = transcoder.record(rec)
+			}
+			sconn.Listeners.AddListener(recording)
+		}
 
-		encs := []common.IEncoding{
-			&encodings.RawEncoding{},
-			&encodings.TightEncoding{},
-			&encodings.EncCursorPseudo{},
-			&encodings.EncLedStatePseudo{},
-			&encodings.TightPngEncoding{},
-			&encodings.RREEncoding{},
-			&encodings.ZLibEncoding{},
-			&encodings.ZRLEEncoding{},
-			&encodings.CopyRectEncoding{},
-			&encodings.CoRREEncoding{},
-			&encodings.HextileEncoding{},
+		if claims != nil {
+			clientUpdater.viewOnly = claims.ViewOnly
 		}
-		cconn.Encs = encs
 
+		clientUpdater.keyRemapper, err = vp.sessionKeyRemapper(session)
 		if err != nil {
-			session.Status = SessionStatusError
-			logger.Errorf("Proxy.newServerConnHandler error connecting to client: %s", err)
+			logger.Errorf("Proxy.newServerConnHandler can't remap keys: %s", err)
+			return err
+		}
+
+		input := session.InputPolicy
+		if input == nil {
+			input = vp.InputPolicy
+		}
+		var layout *keyboard.Layout
+		if clientUpdater.keyRemapper != nil {
+			layout = clientUpdater.keyRemapper.layout
+		}
+		clientUpdater.input = input.newFilter(sconn.SessionId, layout)
+
+		paste := session.PasteAsKeys
+		if paste == nil {
+			paste = vp.PasteAsKeys
+		}
+		if paste != nil {
+			clientUpdater.typist, err = newKeyTypist(paste, clientUpdater, &clientUpdater.writeLock, sconn.SessionId)
+			if err != nil {
+				logger.Errorf("Proxy.newServerConnHandler can't type pasted text: %s", err)
+				return err
+			}
+		}
+
+		// attach connects the vnc-client to the vnc server, again after a reconnection
+		reconnect := vp.sessionReconnect(session)
+		clientUpdater.reconnect = reconnect != nil
+		attached := false
+		var attach func(cconn *client.ClientConn) error
+		attach = func(cconn *client.ClientConn) error {
+			cconn.Listeners.AddListener(serverUpdater)
+			if recording != nil {
+				cconn.Listeners.AddListener(recording)
+			}
+			if reconnect != nil {
+				cconn.Listeners.AddListener(&upstreamWatcher{
+					conn:          cconn,
+					sconn:         sconn,
+					clientUpdater: clientUpdater,
+					serverUpdater: serverUpdater,
+					reconnect:     func() { vp.reconnectUpstream(reconnect, session, sconn, clientUpdater, attach) },
+				})
+			}
+			err := cconn.Connect()
+			if err != nil {
+				session.Status = SessionStatusError
+				logger.Errorf("Proxy.newServerConnHandler error connecting to client: %s", err)
+				return err
+			}
+
+			err = clientUpdater.connect(cconn, func() error {
+				// registered with the writeLock held, so the vnc-client can't leave meanwhile
+				if !attached {
+					attached = true
+					vp.addLiveSession(sconn, clientUpdater)
+				}
+				if transcoder != nil {
+					// the transcoder sets the pixel format & the decoders up
+					return transcoder.start(cconn)
+				}
+				cconn.Encs = []common.IEncoding{
+					&encodings.RawEncoding{},
+					&encodings.TightEncoding{},
+					&encodings.EncCursorPseudo{},
+					&encodings.EncLedStatePseudo{},
+					&encodings.TightPngEncoding{},
+					&encodings.RREEncoding{},
+					&encodings.ZLibEncoding{},
+					&encodings.ZRLEEncoding{},
+					&encodings.CopyRectEncoding{},
+					&encodings.CoRREEncoding{},
+					&encodings.HextileEncoding{},
+				}
+				// a vnc server connected again may have another size
+				return serverUpdater.resize(cconn.FrameBufferWidth, cconn.FrameBufferHeight,
+					clientUpdater.announced(common.EncDesktopSizePseudo), recording)
+			})
+			if err != nil {
+				session.Status = SessionStatusError
+				logger.Errorf("Proxy.newServerConnHandler error connecting to client: %s", err)
+				cconn.Close()
+				return err
+			}
+			session.Status = SessionStatusActive
+			return nil
+		}
+
+		if cconn == nil {
+			// the vnc-client gets the placeholder's ServerInit & screens until the vnc server is connected
+			serverUpdater.initialized = true
+			clientUpdater.placeholder = newPlaceholder(placeholderCfg, transcoder)
+			go vp.connectWithPlaceholder(placeholderCfg, session, sconn, clientUpdater.placeholder, attach)
+			return nil
+		}
+		if err = attach(cconn); err != nil {
 			return err
 		}
 	}
 
-	if session.Type == SessionTypeReplayServer {
+	if sessionType == SessionTypeReplayServer {
 		fbs, err := player.ConnectFbsFile(session.ReplayFilePath, sconn)
 
 		if err != nil {
@@ -176,9 +392,42 @@ func (vp *VncProxy) StartListening() {
 
 	secHandlers := []server.SecurityHandler{&server.ServerAuthNone{}}
 
+	if vp.RelayAuth {
+		secHandlers = []server.SecurityHandler{&server.ServerAuthVNCRelay{Upstream: vp.relayUpstreamAuth}}
+	}
+
 	if vp.ProxyVncPassword != "" {
-		secHandlers = []server.SecurityHandler{&server.ServerAuthVNC{vp.ProxyVncPassword}}
+		vncAuth := &server.ServerAuthVNC{Pass: vp.ProxyVncPassword}
+		if vp.VncTotpUser != "" {
+			vncAuth.Totp = vp.Totp
+			vncAuth.TotpUser = vp.VncTotpUser
+		}
+		secHandlers = []server.SecurityHandler{vncAuth}
+	}
+	if vp.ProxyUsers != nil {
+		veNCrypt := &server.ServerAuthVeNCrypt{Users: vp.ProxyUsers, Totp: vp.Totp, TLSConfig: vp.ProxyTLSConfig}
+		if vp.ProxyVncPassword != "" {
+			secHandlers = append([]server.SecurityHandler{veNCrypt}, secHandlers...)
+		} else {
+			secHandlers = []server.SecurityHandler{veNCrypt}
+		}
+	}
+	if vp.ProxyRSAKey != nil {
+		// RSA-AES encrypts the whole session, with the same credentials as VeNCrypt or classic VNC auth
+		var rsaAes []server.SecurityHandler
+		for _, secType := range []server.SecurityType{server.SecTypeRA256, server.SecTypeRA2} {
+			if vp.ProxyUsers != nil {
+				rsaAes = append(rsaAes, &server.ServerAuthRSAAES{SecType: secType, Key: vp.ProxyRSAKey, Users: vp.ProxyUsers, Totp: vp.Totp})
+			} else if vp.ProxyVncPassword != "" && vp.VncTotpUser == "" {
+				rsaAes = append(rsaAes, &server.ServerAuthRSAAES{SecType: secType, Key: vp.ProxyRSAKey, Password: vp.ProxyVncPassword})
+			}
+		}
+		secHandlers = append(rsaAes, secHandlers...)
 	}
+
+	// TightVNC viewers pick the Tight security type, which wraps the same auth types
+	secHandlers = append([]server.SecurityHandler{&server.ServerAuthTight{AuthTypes: secHandlers}}, secHandlers...)
+
 	cfg := &server.ServerConfig{
 		SecurityHandlers: secHandlers,
 		Encodings:        []common.IEncoding{&encodings.RawEncoding{}, &encodings.TightEncoding{}, &encodings.CopyRectEncoding{}},
@@ -188,7 +437,15 @@ func (vp *VncProxy) StartListening() {
 		Height:           uint16(768),
 		Width:            uint16(1024),
 		NewConnHandler:   vp.newServerConnHandler,
+		UpstreamHandler:  vp.upstreamHandler,
 		UseDummySession:  !vp.UsingSessions,
+		JwtVerifier:      vp.JwtVerifier,
+		// the path picks the session (or the socket of the dynamic lookup), the token must name it
+		JwtSessionRequired: vp.UsingSessions || vp.DynamicLookup,
+	}
+
+	if vp.AdminListeningUrl != "" {
+		go vp.serveAdminAPI()
 	}
 
 	if vp.TcpListeningUrl != "" && vp.WsListeningUrl != "" {
@@ -1,24 +1,817 @@
 package proxy
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/exoscale/vncproxy/auth"
+	"github.com/exoscale/vncproxy/automation"
+	"github.com/exoscale/vncproxy/client"
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/encodings"
+	"github.com/exoscale/vncproxy/font"
+	"github.com/exoscale/vncproxy/server"
+	"github.com/exoscale/vncproxy/vnctest"
+)
+
+// freePort returns a tcp port nobody listens on, for the proxy (which can't listen on port 0)
+func freePort(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+}
+
+// startSession starts a vnc server, and a single session proxy with the password 1234 in front of it on a free port:
+// option completes the proxy & its session before it starts listening. Everything stops at the end of the test
+func startSession(t *testing.T, cfg *vnctest.Config, option func(*VncProxy)) (*vnctest.Server, *VncProxy, context.Context) {
+	vncServer := vnctest.NewServer(cfg)
+	if err := vncServer.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { vncServer.Close() })
+
+	proxy := &VncProxy{
+		TcpListeningUrl:  freePort(t),
+		ProxyVncPassword: "1234",
+		SingleSession: &VncSession{
+			Target: vncServer.Addr(),
+			ID:     "dummySession",
+			Type:   SessionTypeProxyPass,
+		},
+	}
+	if option != nil {
+		option(proxy)
+	}
+	go proxy.StartListening()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return vncServer, proxy, ctx
+}
+
+// dialProxy connects an automation client to the proxy with the password 1234
+func dialProxy(ctx context.Context, t *testing.T, proxy *VncProxy) *automation.Client {
+	for {
+		c, err := automation.Dial(ctx, "127.0.0.1:"+proxy.TcpListeningUrl, &automation.Options{Password: "1234"})
+		if err == nil {
+			return c
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("connecting to the proxy: %s", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
 
 func TestProxy(t *testing.T) {
-	//create default session if required
+	vncServer, proxy, ctx := startSession(t, &vnctest.Config{Password: "123456", Width: 64, Height: 48}, func(proxy *VncProxy) {
+		proxy.SingleSession.TargetPassword = "123456"
+	})
+	vncServer.Fill(image.Rect(8, 8, 24, 24), color.RGBA{0xff, 0, 0, 0xff})
+	c := dialProxy(ctx, t, proxy)
+	defer c.Close()
+
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, vncServer.Screen(), 0); err != nil {
+		t.Fatalf("initial screen: %s", err)
+	}
+	vncServer.Fill(image.Rect(30, 30, 40, 40), color.RGBA{0, 0, 0xff, 0xff})
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, vncServer.Screen(), 0); err != nil {
+		t.Fatalf("updated screen: %s", err)
+	}
+
+	if err := c.TypeString(ctx, "ok"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vncServer.WaitForMessage(5*time.Second, func(msg common.ClientMessage) bool {
+		key, ok := msg.(*server.MsgKeyEvent)
+		return ok && key.Key == 'k' && key.Down == 0
+	}); err != nil {
+		t.Errorf("keys not forwarded: %s", err)
+	}
+}
+
+func TestProxyHandshake37(t *testing.T) {
+	// nobody listens on the vnc server's address
+	proxy := &VncProxy{
+		SingleSession: &VncSession{
+			Target: "127.0.0.1:" + freePort(t),
+			ID:     "dummySession",
+			Type:   SessionTypeProxyPass,
+		},
+	}
+	port := freePort(t)
+	proxy.TcpListeningUrl = port
+	go proxy.StartListening()
+
+	var nc net.Conn
+	var err error
+	for i := 0; i < 100; i++ {
+		if nc, err = net.Dial("tcp", "127.0.0.1:"+port); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("connecting to the proxy: %s", err)
+	}
+	defer nc.Close()
+	nc.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// None (offered as is & through Tight) has no SecurityResult in 3.7: the reason comes instead of the security types
+	if _, err := io.ReadFull(nc, make([]byte, server.ProtoVersionLength)); err != nil {
+		t.Fatal(err)
+	}
+	nc.Write([]byte(server.ProtoVersion37))
+	received, _ := ioutil.ReadAll(nc)
+	reason := errUpstreamUnreachable.Error()
+	expected := append([]byte{0, 0, 0, 0, byte(len(reason))}, reason...)
+	if !bytes.Equal(received, expected) {
+		t.Errorf("client received %q, want %q", received, expected)
+	}
+}
+
+func TestProxyTranscoding(t *testing.T) {
+	// the automation client doesn't decode Tight, the only encoding of the vnc server
+	vncServer, proxy, ctx := startSession(t, &vnctest.Config{
+		Width:     96,
+		Height:    64,
+		Encodings: []common.EncodingType{common.EncTight, common.EncCopyRect, common.EncDesktopSizePseudo},
+	}, func(proxy *VncProxy) {
+		proxy.SingleSession.Transcode = &TranscodeConfig{}
+	})
+	gradient := image.NewRGBA(image.Rect(0, 0, 48, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 48; x++ {
+			gradient.SetRGBA(x, y, color.RGBA{byte(5 * x), byte(7 * y), 0x80, 0xff})
+		}
+	}
+	vncServer.Draw(gradient.Rect, gradient, image.Point{})
+	vncServer.Fill(image.Rect(60, 8, 90, 24), color.RGBA{0xff, 0, 0, 0xff})
+	c := dialProxy(ctx, t, proxy)
+	defer c.Close()
+
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, vncServer.Screen(), 0); err != nil {
+		t.Fatalf("initial screen: %s", err)
+	}
+	if _, err := vncServer.WaitForMessage(time.Second, func(msg common.ClientMessage) bool {
+		encs, ok := msg.(*server.MsgSetEncodings)
+		return ok && len(encs.Encodings) > 1 && encs.Encodings[1] == common.EncTight
+	}); err != nil {
+		t.Errorf("the proxy didn't ask for tight: %s", err)
+	}
+
+	vncServer.CopyRect(image.Rect(0, 0, 48, 32), image.Pt(40, 30))
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, vncServer.Screen(), 0); err != nil {
+		t.Fatalf("copied rectangle: %s", err)
+	}
+
+	vncServer.Resize(80, 80)
+	vncServer.Fill(image.Rect(0, 64, 80, 80), color.RGBA{0, 0xff, 0, 0xff})
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, vncServer.Screen(), 0); err != nil {
+		t.Fatalf("resized screen: %s", err)
+	}
+	if w, h := c.Size(); w != 80 || h != 80 {
+		t.Errorf("unexpected size after resize %dx%d", w, h)
+	}
+
+	// the extended clipboard doesn't go through the transcoder, it is announced as the vnc-client does
+	nc, _ := net.Pipe()
+	sconn, err := server.NewServerConn(nc, &server.ServerConfig{ClientMessages: server.DefaultClientMessages}, "dummySession")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := newTranscoder(&TranscodeConfig{Encodings: []common.EncodingType{common.EncHextile}}, sconn)
+	upstream := tc.setEncodings([]common.EncodingType{common.EncRaw, common.EncExtendedClipboardPseudo})
+	if !encodingListed(upstream, common.EncExtendedClipboardPseudo) {
+		t.Errorf("the extended clipboard isn't announced to the vnc server: %v", upstream)
+	}
+	if upstream = tc.setEncodings([]common.EncodingType{common.EncRaw}); encodingListed(upstream, common.EncExtendedClipboardPseudo) {
+		t.Errorf("the extended clipboard is announced without the vnc-client: %v", upstream)
+	}
+}
+
+func encodingListed(encs []common.EncodingType, enc common.EncodingType) bool {
+	for _, e := range encs {
+		if e == enc {
+			return true
+		}
+	}
+	return false
+}
+
+// rawHandshake connects to a proxy without password with the RFB 3.8 handshake, and reads the ServerInit
+func rawHandshake(t *testing.T, ctx context.Context, addr string) (net.Conn, *common.ServerInit) {
+	var nc net.Conn
+	for {
+		var err error
+		nc, err = net.Dial("tcp", addr)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("connecting to the proxy: %s", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+
+	version := make([]byte, 12)
+	read := func(v interface{}) {
+		if err := binary.Read(nc, binary.BigEndian, v); err != nil {
+			t.Fatalf("handshake: %s", err)
+		}
+	}
+	read(version)
+	nc.Write([]byte("RFB 003.008\n"))
+	var secTypeCount uint8
+	read(&secTypeCount)
+	read(make([]byte, secTypeCount))
+	nc.Write([]byte{1, 1}) // no auth, then shared ClientInit after the security result
+	var secResult uint32
+	read(&secResult)
+
+	var serverInit common.ServerInit
+	read(&serverInit.FBWidth)
+	read(&serverInit.FBHeight)
+	read(&serverInit.PixelFormat)
+	read(make([]byte, 3))
+	var nameLength uint32
+	read(&nameLength)
+	serverInit.NameText = make([]byte, nameLength)
+	read(serverInit.NameText)
+	return nc, &serverInit
+}
+
+func TestProxyPixelFormatTranslation(t *testing.T) {
+	// a vnc server in 16 bits (RGB 565), which the proxy keeps
+	recordingDir := t.TempDir()
+	vncServer, proxy, ctx := startSession(t, &vnctest.Config{
+		Width:  64,
+		Height: 48,
+		PixelFormat: &common.PixelFormat{
+			BPP: 16, Depth: 16, TrueColor: 1,
+			RedMax: 31, GreenMax: 63, BlueMax: 31,
+			RedShift: 11, GreenShift: 5, BlueShift: 0,
+		},
+	}, func(proxy *VncProxy) {
+		proxy.ProxyVncPassword = ""
+		proxy.RecordingDir = recordingDir
+		proxy.SingleSession.Type = SessionTypeRecordingProxy
+		proxy.SingleSession.Transcode = &TranscodeConfig{}
+	})
+	vncServer.Fill(image.Rect(0, 0, 32, 48), color.RGBA{0xff, 0, 0, 0xff})
+	vncServer.Fill(image.Rect(32, 0, 64, 48), color.RGBA{0, 0, 0xff, 0xff})
+	nc, serverInit := rawHandshake(t, ctx, "127.0.0.1:"+proxy.TcpListeningUrl)
+	defer nc.Close()
+	if serverInit.PixelFormat.BPP != 16 {
+		t.Errorf("unexpected pixel format in ServerInit: %+v", serverInit.PixelFormat)
+	}
+
+	// an 8 bit color map client, with raw pixels
+	colorMapPF := common.PixelFormat{BPP: 8, Depth: 8}
+	var msgs bytes.Buffer
+	msgs.Write([]byte{byte(common.SetPixelFormatMsgType), 0, 0, 0})
+	binary.Write(&msgs, binary.BigEndian, colorMapPF)
+	msgs.Write([]byte{0, 0, 0})
+	msgs.Write([]byte{byte(common.SetEncodingsMsgType), 0, 0, 1, 0, 0, 0, 0})
+	msgs.Write([]byte{byte(common.FramebufferUpdateRequestMsgType), 0, 0, 0, 0, 0, 0, 64, 0, 48})
+	if _, err := nc.Write(msgs.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	var header [6]byte
+	if _, err := io.ReadFull(nc, header[:]); err != nil || header[0] != byte(common.SetColourMapEntries) {
+		t.Fatalf("no palette before the update: %v %s", header, err)
+	}
+	colors := make([]byte, 6*int(binary.BigEndian.Uint16(header[4:])))
+	io.ReadFull(nc, colors)
+	if len(colors) != 6*256 {
+		t.Errorf("unexpected palette size: %d", len(colors)/6)
+	}
+
+	screen := make([]byte, 64*48)
+	if _, err := io.ReadFull(nc, header[:4]); err != nil || header[0] != byte(common.FramebufferUpdate) {
+		t.Fatalf("no update: %v %s", header, err)
+	}
+	for i := 0; i < int(binary.BigEndian.Uint16(header[2:])); i++ {
+		var rect struct {
+			X, Y, Width, Height uint16
+			Enc                 int32
+		}
+		if err := binary.Read(nc, binary.BigEndian, &rect); err != nil || rect.Enc != int32(common.EncRaw) {
+			t.Fatalf("unexpected rectangle %+v: %s", rect, err)
+		}
+		for y := int(rect.Y); y < int(rect.Y+rect.Height); y++ {
+			io.ReadFull(nc, screen[64*y+int(rect.X):64*y+int(rect.X+rect.Width)])
+		}
+	}
+	red, blue := encodings.PixelValue(&colorMapPF, color.RGBA{0xff, 0, 0, 0xff}), encodings.PixelValue(&colorMapPF, color.RGBA{0, 0, 0xff, 0xff})
+	if uint32(screen[64*10+10]) != red || uint32(screen[64*40+50]) != blue {
+		t.Errorf("unexpected colors: %d, %d", screen[64*10+10], screen[64*40+50])
+	}
+	if _, err := vncServer.WaitForMessage(100*time.Millisecond, func(msg common.ClientMessage) bool {
+		_, ok := msg.(*server.MsgSetPixelFormat)
+		return ok
+	}); err == nil {
+		t.Error("the pixel format was forwarded to the vnc server")
+	}
+
+	// the recording is in the proxy's format
+	nc.Close()
+	for {
+		files, _ := filepath.Glob(filepath.Join(recordingDir, "*.rbs"))
+		if len(files) == 1 {
+			data, _ := ioutil.ReadFile(files[0])
+			// FBS header, block size, version, security type & size
+			if len(data) > 36+16 {
+				if data[36] != RecordingPixelFormat.BPP || data[36+3] == 0 {
+					t.Errorf("unexpected recording pixel format: %v", data[36:36+16])
+				}
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			t.Fatal("nothing recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProxyScaling(t *testing.T) {
+	vncServer, proxy, ctx := startSession(t, &vnctest.Config{Width: 200, Height: 100}, func(proxy *VncProxy) {
+		proxy.SingleSession.Scale = &ScaleConfig{MaxWidth: 100}
+	})
+	vncServer.Fill(image.Rect(100, 50, 200, 100), color.RGBA{0xff, 0, 0, 0xff})
+	c := dialProxy(ctx, t, proxy)
+	defer c.Close()
+
+	if w, h := c.Size(); w != 100 || h != 50 {
+		t.Fatalf("unexpected scaled size %dx%d", w, h)
+	}
+	quarter := image.NewRGBA(image.Rect(0, 0, 50, 25))
+	draw.Draw(quarter, quarter.Rect, image.NewUniform(color.RGBA{0xff, 0, 0, 0xff}), image.Point{}, draw.Src)
+	if err := c.WaitForRegionMatch(ctx, image.Pt(50, 25), quarter, 0); err != nil {
+		t.Fatalf("scaled screen: %s", err)
+	}
+
+	// a 2x2 checkerboard averages to grey
+	vncServer.Fill(image.Rect(0, 0, 2, 2), color.RGBA{0xff, 0xff, 0xff, 0xff})
+	vncServer.Fill(image.Rect(0, 0, 1, 1), color.RGBA{0, 0, 0, 0xff})
+	vncServer.Fill(image.Rect(1, 1, 2, 2), color.RGBA{0, 0, 0, 0xff})
+	grey := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	grey.SetRGBA(0, 0, color.RGBA{0x7f, 0x7f, 0x7f, 0xff})
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, grey, 0); err != nil {
+		t.Fatalf("averaged pixel: %s", err)
+	}
+
+	if err := c.Click(ctx, 60, 30); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vncServer.WaitForMessage(time.Second, func(msg common.ClientMessage) bool {
+		pointer, ok := msg.(*server.MsgPointerEvent)
+		return ok && pointer.X == 120 && pointer.Y == 60
+	}); err != nil {
+		t.Errorf("the pointer event wasn't scaled back: %s", err)
+	}
+}
+
+func TestProxyPrivacyMasks(t *testing.T) {
+	white, red := color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{0xff, 0, 0, 0xff}
+	masks := &MaskPolicy{Masks: []PrivacyMask{
+		{Name: "secret", Width: 40, Height: 30, Color: "#0000ff"},
+		{Name: "logo", Template: writeTemplate(t)},
+	}}
+	if err := masks.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	vncServer, proxy, ctx := startSession(t, &vnctest.Config{Width: 96, Height: 64}, func(proxy *VncProxy) {
+		proxy.SingleSession.MaskPolicy = masks
+	})
+	vncServer.Fill(image.Rect(0, 0, 96, 64), white)
+	vncServer.Fill(image.Rect(0, 0, 20, 20), red)
+	c := dialProxy(ctx, t, proxy)
+	defer c.Close()
+
+	expected := image.NewRGBA(image.Rect(0, 0, 96, 64))
+	draw.Draw(expected, expected.Rect, vncServer.Screen(), image.Point{}, draw.Src)
+	fillRect(expected, image.Rect(0, 0, 40, 30), color.RGBA{0, 0, 0xff, 0xff})
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, expected, 0); err != nil {
+		t.Fatalf("masked rectangle: %s", err)
+	}
+
+	// the copy of the masked place isn't
+	vncServer.CopyRect(image.Rect(0, 0, 20, 20), image.Pt(50, 5))
+	fillRect(expected, image.Rect(50, 5, 70, 25), red)
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, expected, 0); err != nil {
+		t.Fatalf("copied rectangle: %s", err)
+	}
+
+	vncServer.Fill(image.Rect(60, 40, 66, 46), maskGreen)
+	vncServer.Fill(image.Rect(62, 42, 63, 43), maskWhite)
+	fillRect(expected, image.Rect(60, 40, 66, 46), color.RGBA{0, 0, 0, 0xff})
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, expected, 0); err != nil {
+		t.Fatalf("masked template: %s", err)
+	}
+}
+
+func TestProxyRecordedMasks(t *testing.T) {
+	white, blue := color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{0, 0, 0xff, 0xff}
+	masks := &MaskPolicy{Masks: []PrivacyMask{
+		{Name: "secret", X: 10, Y: 10, Width: 40, Height: 30, Color: "#0000ff", Apply: "recording"},
+	}}
+	if err := masks.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	recordingDir := t.TempDir()
+	vncServer, proxy, ctx := startSession(t, &vnctest.Config{Width: 96, Height: 64}, func(proxy *VncProxy) {
+		proxy.RecordingDir = recordingDir
+		proxy.SingleSession.Type = SessionTypeRecordingProxy
+		proxy.SingleSession.MaskPolicy = masks
+	})
+	vncServer.Fill(image.Rect(0, 0, 96, 64), white)
+	vncServer.Fill(image.Rect(20, 20, 40, 30), color.RGBA{0xff, 0, 0, 0xff})
+	c := dialProxy(ctx, t, proxy)
+
+	// the viewer sees what's under the mask
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, vncServer.Screen(), 0); err != nil {
+		t.Fatalf("viewer screen: %s", err)
+	}
+	c.Close()
+
+	expected := image.NewRGBA(image.Rect(0, 0, 96, 64))
+	draw.Draw(expected, expected.Rect, vncServer.Screen(), image.Point{}, draw.Src)
+	fillRect(expected, image.Rect(10, 10, 50, 40), blue)
+	replay := replayRecording(ctx, t, recordingDir)
+	defer replay.Close()
+	if err := replay.WaitForRegionMatch(ctx, image.Point{}, expected, 0); err != nil {
+		t.Fatalf("replayed screen: %s", err)
+	}
+}
+
+// replayRecording waits for the recording of dir to be written, and connects an automation client to a proxy replaying it
+func replayRecording(ctx context.Context, t *testing.T, dir string) *automation.Client {
+	var recording string
+	for recording == "" {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.rbs"))
+		if len(files) == 1 {
+			data, _ := ioutil.ReadFile(files[0])
+			if fbsComplete(data) {
+				recording = files[0]
+			}
+		}
+		if recording == "" && ctx.Err() != nil {
+			t.Fatal("nothing recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
 	proxy := &VncProxy{
-		WsListeningUrl:   "http://0.0.0.0:7778/", // empty = not listening on ws
-		RecordingDir:     "d:\\",                 // empty = no recording
-		TcpListeningUrl:  ":5904",
-		ProxyVncPassword: "1234", //empty = no auth
+		TcpListeningUrl:  freePort(t),
+		ProxyVncPassword: "1234",
 		SingleSession: &VncSession{
-			TargetHostname: "192.168.1.101",
-			TargetPort:     "5901",
-			TargetPassword: "123456",
 			ID:             "dummySession",
-			Status:         SessionStatusInit,
-			Type:           SessionTypeRecordingProxy,
-		}, // to be used when not using sessions
-		UsingSessions: false, //false = single session - defined in the var above
+			Type:           SessionTypeReplayServer,
+			ReplayFilePath: recording,
+		},
+	}
+	go proxy.StartListening()
+	return dialProxy(ctx, t, proxy)
+}
+
+// fbsComplete tells whether an FBS file has blocks and isn't being written: the last block ends with the file
+func fbsComplete(data []byte) bool {
+	if len(data) <= 12 {
+		return false
+	}
+	for data = data[12:]; len(data) >= 4; {
+		// size, data padded to 4 bytes & timestamp
+		size := 4 + (int(binary.BigEndian.Uint32(data))+3)&^3 + 4
+		if size > len(data) {
+			return false
+		}
+		data = data[size:]
+	}
+	return len(data) == 0
+}
+
+func TestProxyOverlay(t *testing.T) {
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := auth.NewStaticUserStore([]auth.UserEntry{{Username: "alice", Password: "secret"}, {Username: "bob", Password: "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordingDir := t.TempDir()
+	vncServer, proxy, ctx := startSession(t, &vnctest.Config{Width: 320, Height: 120}, func(proxy *VncProxy) {
+		proxy.ProxyRSAKey = rsaKey
+		proxy.ProxyUsers = users
+		proxy.RecordingDir = recordingDir
+		proxy.SingleSession.Type = SessionTypeRecordingProxy
+		proxy.SingleSession.Overlay = &OverlayConfig{Watermark: true, RecordingBanner: true}
+	})
+	vncServer.Fill(image.Rect(0, 0, 320, 120), white)
+	c := dialProxy(ctx, t, proxy)
+
+	bannerHeight := font.GlyphHeight*bannerScale + 2*bannerPadding
+	// the screen is white under the banner, with a faint watermark
+	if err := c.WaitForRegionMatch(ctx, image.Pt(0, bannerHeight), vncServer.Screen().SubImage(image.Rect(0, bannerHeight, 320, 120)), 0.05); err != nil {
+		t.Fatalf("screen under the banner: %s", err)
+	}
+	screen := c.Screenshot()
+	if c := screen.RGBAAt(1, 1); int(c.R)-int(c.G) < 0x60 {
+		t.Errorf("no banner: %v", c)
+	}
+	watermarked := 0
+	for y := bannerHeight; y < 120; y++ {
+		for x := 0; x < 320; x++ {
+			if c := screen.RGBAAt(x, y); c != white {
+				watermarked++
+				if c.R < 0xff-0x20 {
+					t.Fatalf("the watermark isn't faint: %v", c)
+				}
+			}
+		}
+	}
+	if watermarked == 0 {
+		t.Error("no watermark")
 	}
 
-	proxy.StartListening()
+	// the recording has neither the banner nor the watermark
+	c.Close()
+	replay := replayRecording(ctx, t, recordingDir)
+	defer replay.Close()
+	if err := replay.WaitForRegionMatch(ctx, image.Point{}, vncServer.Screen(), 0); err != nil {
+		t.Fatalf("replayed screen: %s", err)
+	}
+
+	// the watermark tells the viewers apart
+	var watermarks []*image.RGBA
+	for _, user := range []string{"alice", "bob"} {
+		cconn, canvas := dialDecoding(ctx, t, "127.0.0.1:"+proxy.TcpListeningUrl,
+			&client.RsaAesAuth{Type: common.SecTypeRA256, Username: user, Password: "secret", KeyBits: 1024})
+		defer cconn.Close()
+		watermark := image.NewRGBA(image.Rect(0, bannerHeight, 320, 120))
+		err := waitForCanvasFunc(ctx, cconn, canvas, func(img *image.RGBA) bool {
+			draw.Draw(watermark, watermark.Rect, img, watermark.Rect.Min, draw.Src)
+			marked := false
+			for i := 0; i < len(watermark.Pix); i += 4 {
+				if watermark.Pix[i] < 0xff-0x20 {
+					return false
+				}
+				marked = marked || watermark.Pix[i] != 0xff
+			}
+			return marked
+		})
+		if err != nil {
+			t.Fatalf("%s's watermark: %s", user, err)
+		}
+		watermarks = append(watermarks, watermark)
+	}
+	if bytes.Equal(watermarks[0].Pix, watermarks[1].Pix) {
+		t.Error("alice & bob have the same watermark")
+	}
+}
+
+// serveOn makes the vnc server accept the connections on addr, until the returned listener is closed
+func serveOn(t *testing.T, addr string, vncServer *vnctest.Server) net.Listener {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go vncServer.Serve(nc)
+		}
+	}()
+	return ln
+}
+
+func TestProxyPlaceholder(t *testing.T) {
+	// nobody listens on the session's target yet
+	target := "127.0.0.1:" + freePort(t)
+	vncServer, proxy, ctx := startSession(t, &vnctest.Config{Width: 64, Height: 48}, func(proxy *VncProxy) {
+		proxy.SingleSession.Target = target
+		proxy.SingleSession.Placeholder = &PlaceholderConfig{Width: 320, Height: 200, RetryDelay: 200 * time.Millisecond}
+	})
+	vncServer.Fill(image.Rect(8, 8, 24, 24), color.RGBA{0xff, 0, 0, 0xff})
+	c := dialProxy(ctx, t, proxy)
+	defer c.Close()
+
+	if w, h := c.Size(); w != 320 || h != 200 {
+		t.Fatalf("unexpected placeholder size %dx%d", w, h)
+	}
+	expected := image.NewRGBA(image.Rect(0, 0, 320, 200))
+	paintPlaceholder(expected, "VM is powered off", "Retrying in 1s", false, 0)
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, expected, 0); err != nil {
+		t.Fatalf("placeholder screen: %s", err)
+	}
+
+	ln := serveOn(t, target, vncServer)
+	defer ln.Close()
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, vncServer.Screen(), 0); err != nil {
+		t.Fatalf("vnc server screen: %s", err)
+	}
+	if w, h := c.Size(); w != 64 || h != 48 {
+		t.Errorf("unexpected size after the placeholder %dx%d", w, h)
+	}
+
+	vncServer.Fill(image.Rect(30, 30, 40, 40), color.RGBA{0, 0, 0xff, 0xff})
+	if err := c.WaitForRegionMatch(ctx, image.Point{}, vncServer.Screen(), 0); err != nil {
+		t.Fatalf("updated screen: %s", err)
+	}
+}
+
+func TestProxyReconnect(t *testing.T) {
+	for name, transcode := range map[string]*TranscodeConfig{"forwarded": nil, "transcoded": {}} {
+		t.Run(name, func(t *testing.T) {
+			target := "127.0.0.1:" + freePort(t)
+			vncServer, proxy, ctx := startSession(t, &vnctest.Config{Width: 64, Height: 48}, func(proxy *VncProxy) {
+				proxy.SingleSession.Target = target
+				proxy.SingleSession.Transcode = transcode
+				proxy.SingleSession.Reconnect = &ReconnectConfig{Delay: 50 * time.Millisecond}
+			})
+			vncServer.Fill(image.Rect(8, 8, 24, 24), color.RGBA{0xff, 0, 0, 0xff})
+			ln := serveOn(t, target, vncServer)
+			c := dialProxy(ctx, t, proxy)
+			defer c.Close()
+
+			if err := c.WaitForRegionMatch(ctx, image.Point{}, vncServer.Screen(), 0); err != nil {
+				t.Fatalf("initial screen: %s", err)
+			}
+			setEncodings := func() int {
+				n := 0
+				for _, msg := range vncServer.Messages() {
+					if _, ok := msg.(*server.MsgSetEncodings); ok {
+						n++
+					}
+				}
+				return n
+			}
+			if setEncodings() != 1 {
+				t.Fatalf("unexpected SetEncodings count before reconnecting: %d", setEncodings())
+			}
+
+			// the VM goes away for a while, and comes back with another resolution
+			ln.Close()
+			vncServer.Disconnect()
+			vncServer.Resize(80, 60)
+			vncServer.Fill(image.Rect(40, 40, 80, 60), color.RGBA{0, 0xff, 0, 0xff})
+			time.Sleep(200 * time.Millisecond)
+			ln = serveOn(t, target, vncServer)
+			defer ln.Close()
+			if err := c.WaitForRegionMatch(ctx, image.Point{}, vncServer.Screen(), 0); err != nil {
+				t.Fatalf("screen after reconnecting: %s", err)
+			}
+			if w, h := c.Size(); w != 80 || h != 60 {
+				t.Errorf("unexpected size after reconnecting %dx%d", w, h)
+			}
+			if conns := vncServer.Conns(); len(conns) != 2 {
+				t.Errorf("unexpected vnc server connections: %+v", conns)
+			}
+			if setEncodings() != 2 {
+				t.Errorf("the encodings weren't sent again: %d SetEncodings", setEncodings())
+			}
+
+			if err := c.TypeString(ctx, "ok"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := vncServer.WaitForMessage(5*time.Second, func(msg common.ClientMessage) bool {
+				key, ok := msg.(*server.MsgKeyEvent)
+				return ok && key.Key == 'k' && key.Down == 0
+			}); err != nil {
+				t.Errorf("keys not forwarded after reconnecting: %s", err)
+			}
+		})
+	}
+
+	// the vnc-client's ZRLE stream belongs to the first vnc server, the second one mustn't use ZRLE
+	t.Run("forwarded zrle", func(t *testing.T) {
+		target := "127.0.0.1:" + freePort(t)
+		vncServer, proxy, ctx := startSession(t, &vnctest.Config{
+			Width: 64, Height: 48,
+			Encodings: []common.EncodingType{common.EncZRLE, common.EncDesktopSizePseudo},
+		}, func(proxy *VncProxy) {
+			proxy.SingleSession.Target = target
+			proxy.SingleSession.Reconnect = &ReconnectConfig{Delay: 50 * time.Millisecond}
+		})
+		vncServer.Fill(image.Rect(8, 8, 24, 24), color.RGBA{0xff, 0, 0, 0xff})
+		ln := serveOn(t, target, vncServer)
+		cconn, canvas := dialDecoding(ctx, t, "127.0.0.1:"+proxy.TcpListeningUrl, &client.PasswordAuth{Password: "1234"})
+		defer cconn.Close()
+
+		if err := waitForCanvas(ctx, cconn, canvas, vncServer.Screen()); err != nil {
+			t.Fatalf("initial screen: %s", err)
+		}
+
+		ln.Close()
+		vncServer.Disconnect()
+		vncServer.Resize(80, 60)
+		vncServer.Fill(image.Rect(40, 40, 80, 60), color.RGBA{0, 0xff, 0, 0xff})
+		time.Sleep(200 * time.Millisecond)
+		ln = serveOn(t, target, vncServer)
+		defer ln.Close()
+		if err := waitForCanvas(ctx, cconn, canvas, vncServer.Screen()); err != nil {
+			t.Fatalf("screen after reconnecting: %s", err)
+		}
+
+		var sent []*server.MsgSetEncodings
+		for _, msg := range vncServer.Messages() {
+			if encs, ok := msg.(*server.MsgSetEncodings); ok {
+				sent = append(sent, encs)
+			}
+		}
+		if len(sent) != 2 {
+			t.Fatalf("unexpected SetEncodings count: %d", len(sent))
+		}
+		if !encodingListed(sent[0].Encodings, common.EncZRLE) {
+			t.Errorf("ZRLE wasn't asked for at first: %v", sent[0].Encodings)
+		}
+		for _, enc := range []common.EncodingType{common.EncZRLE, common.EncTight, common.EncZlib} {
+			if encodingListed(sent[1].Encodings, enc) {
+				t.Errorf("%s asked for after reconnecting: %v", enc, sent[1].Encodings)
+			}
+		}
+	})
+}
+
+// dialDecoding connects to the proxy with a vnc client which decodes every encoding of encodings.NewDecoders
+// (ZRLE, Tight... unlike the automation client) into the returned canvas
+func dialDecoding(ctx context.Context, t *testing.T, addr string, clientAuth client.ClientAuth) (*client.ClientConn, *encodings.Canvas) {
+	var nc net.Conn
+	for {
+		var err error
+		nc, err = net.Dial("tcp", addr)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("connecting to the proxy: %s", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cconn, _ := client.NewClientConn(nc, &client.ClientConfig{Auth: []client.ClientAuth{clientAuth}})
+	if err := cconn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	canvas := encodings.NewCanvas(int(cconn.FrameBufferWidth), int(cconn.FrameBufferHeight))
+	if err := cconn.SetEncodings(encodings.NewDecoders(canvas)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cconn.FramebufferUpdateRequest(false, 0, 0, cconn.FrameBufferWidth, cconn.FrameBufferHeight); err != nil {
+		t.Fatal(err)
+	}
+	return cconn, canvas
+}
+
+// waitForCanvas asks for updates until the canvas is the expected screen
+func waitForCanvas(ctx context.Context, cconn *client.ClientConn, canvas *encodings.Canvas, want *image.RGBA) error {
+	return waitForCanvasFunc(ctx, cconn, canvas, func(img *image.RGBA) bool {
+		return img.Rect == want.Rect && bytes.Equal(img.Pix, want.Pix)
+	})
+}
+
+// waitForCanvasFunc asks for updates until done accepts the canvas, which is locked during the call
+func waitForCanvasFunc(ctx context.Context, cconn *client.ClientConn, canvas *encodings.Canvas, done func(img *image.RGBA) bool) error {
+	for {
+		canvas.Lock()
+		img := canvas.Image()
+		ok := done(img)
+		canvas.Unlock()
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(20 * time.Millisecond):
+		}
+		if err := cconn.FramebufferUpdateRequest(true, 0, 0, uint16(img.Rect.Dx()), uint16(img.Rect.Dy())); err != nil {
+			return err
+		}
+	}
 }
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/exoscale/vncproxy/client"
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/logger"
+	"github.com/exoscale/vncproxy/server"
+)
+
+// ReconnectConfig keeps the vnc-clients connected when the vnc server connection is lost (VM reboot, QEMU migration):
+// the vnc server is connected again with an exponential backoff and sent the vnc-client's pixel format & encodings,
+// the vnc-client gets a DesktopSize if the size changed, then the whole screen. It isn't available with relayed auth,
+// as the proxy doesn't know the vnc server's password.
+type ReconnectConfig struct {
+	// Delay is the wait after the first failed attempt, doubled after each one up to MaxDelay (500ms & 10s by default)
+	Delay    time.Duration
+	MaxDelay time.Duration
+	// Timeout is how long the vnc-client waits for the vnc server before being disconnected, 2 minutes by default
+	Timeout time.Duration
+}
+
+const (
+	defaultReconnectDelay    = 500 * time.Millisecond
+	defaultReconnectMaxDelay = 10 * time.Second
+	defaultReconnectTimeout  = 2 * time.Minute
+)
+
+// sessionReconnect returns the reconnection configuration of the session, nil when the vnc-clients are disconnected
+// with the vnc server
+func (vp *VncProxy) sessionReconnect(session *VncSession) *ReconnectConfig {
+	if vp.RelayAuth {
+		return nil
+	}
+	if session.Reconnect != nil {
+		return session.Reconnect
+	}
+	return vp.Reconnect
+}
+
+// upstreamWatcher reconnects the vnc server when its connection is closed while the vnc-client is still there
+type upstreamWatcher struct {
+	conn          *client.ClientConn
+	sconn         *server.ServerConn
+	clientUpdater *ClientUpdater
+	serverUpdater *ServerUpdater
+	reconnect     func()
+}
+
+func (w *upstreamWatcher) Consume(seg *common.RfbSegment) error {
+	if seg.SegmentType != common.SegmentConnectionClosed || !w.clientUpdater.disconnected(w.conn) {
+		return nil
+	}
+	if w.serverUpdater.partial {
+		// the vnc-client got a part of a message, it can't go on
+		logger.Errorf("upstreamWatcher: session %s: the vnc server left in the middle of a message, closing the vnc-client", w.sconn.SessionId)
+		return w.sconn.Close()
+	}
+	go w.reconnect()
+	return nil
+}
+
+// reconnectUpstream connects the vnc server of a vnc-client again, attach hands the vnc-client over to the connection.
+// The vnc-client is closed when giving up.
+func (vp *VncProxy) reconnectUpstream(cfg *ReconnectConfig, session *VncSession, sconn *server.ServerConn,
+	clientUpdater *ClientUpdater, attach func(*client.ClientConn) error) {
+	delay, maxDelay, timeout := cfg.Delay, cfg.MaxDelay, cfg.Timeout
+	if delay <= 0 {
+		delay = defaultReconnectDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = defaultReconnectMaxDelay
+	}
+	if timeout <= 0 {
+		timeout = defaultReconnectTimeout
+	}
+	logger.Infof("Proxy.reconnectUpstream: session %s: the vnc server connection was lost, reconnecting", sconn.SessionId)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		cconn, err := vp.connectUpstream(session, sconn.SessionId, true)
+		if err == nil {
+			if err = attach(cconn); err == nil {
+				logger.Infof("Proxy.reconnectUpstream: session %s: reconnected", sconn.SessionId)
+				return
+			}
+		}
+		if clientUpdater.isClosed() {
+			return
+		}
+		if time.Now().Add(delay).After(deadline) {
+			logger.Errorf("Proxy.reconnectUpstream: session %s: giving up after %s: %s", sconn.SessionId, timeout, err)
+			sconn.Close()
+			return
+		}
+		time.Sleep(delay)
+		if clientUpdater.isClosed() {
+			return
+		}
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
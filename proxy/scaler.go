@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"fmt"
+	"image"
+	"net/url"
+	"strconv"
+)
+
+// ScaleConfig makes the proxy downscale the screen for the vnc-clients, to fit in MaxWidth x MaxHeight keeping the
+// aspect ratio (0 = no limit), the pointer events are scaled back. It implies transcoding.
+type ScaleConfig struct {
+	MaxWidth  int
+	MaxHeight int
+}
+
+// ParseScaleQuery reads the scaling asked for in a websocket url (?maxWidth=800&maxHeight=600), nil without it
+func ParseScaleQuery(query url.Values) (*ScaleConfig, error) {
+	if query.Get("maxWidth") == "" && query.Get("maxHeight") == "" {
+		return nil, nil
+	}
+	cfg := &ScaleConfig{}
+	for name, v := range map[string]*int{"maxWidth": &cfg.MaxWidth, "maxHeight": &cfg.MaxHeight} {
+		if query.Get(name) == "" {
+			continue
+		}
+		n, err := strconv.Atoi(query.Get(name))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("bad %s: %s", name, query.Get(name))
+		}
+		*v = n
+	}
+	return cfg, nil
+}
+
+// scaler keeps the downscaled screen, each pixel is the average of the canvas pixels it covers
+type scaler struct {
+	maxWidth, maxHeight int
+
+	// the canvas size, & the scaled screen (nil when not scaling)
+	width, height int
+	img           *image.RGBA
+}
+
+func newScaler(cfg *ScaleConfig) *scaler {
+	s := &scaler{}
+	if cfg != nil {
+		s.maxWidth, s.maxHeight = cfg.MaxWidth, cfg.MaxHeight
+	}
+	return s
+}
+
+// resize follows the canvas size, and returns the size of the vnc-clients' screen
+func (s *scaler) resize(width, height int) (int, int) {
+	s.width, s.height = width, height
+	scaledWidth, scaledHeight := width, height
+	if s.maxWidth > 0 && scaledWidth > s.maxWidth {
+		scaledWidth, scaledHeight = s.maxWidth, height*s.maxWidth/width
+	}
+	if s.maxHeight > 0 && scaledHeight > s.maxHeight {
+		scaledWidth, scaledHeight = width*s.maxHeight/height, s.maxHeight
+	}
+	if scaledWidth < 1 {
+		scaledWidth = 1
+	}
+	if scaledHeight < 1 {
+		scaledHeight = 1
+	}
+
+	s.img = nil
+	if scaledWidth != width || scaledHeight != height {
+		s.img = image.NewRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+	}
+	return scaledWidth, scaledHeight
+}
+
+func (s *scaler) scaling() bool {
+	return s.img != nil
+}
+
+// rect returns the scaled pixels covering a canvas rectangle
+func (s *scaler) rect(r image.Rectangle) image.Rectangle {
+	w, h := s.img.Rect.Dx(), s.img.Rect.Dy()
+	return image.Rect(
+		r.Min.X*w/s.width, r.Min.Y*h/s.height,
+		ceilDiv(r.Max.X*w, s.width), ceilDiv(r.Max.Y*h, s.height),
+	).Intersect(s.img.Rect)
+}
+
+// canvasRect returns the canvas pixels of a scaled rectangle
+func (s *scaler) canvasRect(r image.Rectangle) image.Rectangle {
+	w, h := s.img.Rect.Dx(), s.img.Rect.Dy()
+	return image.Rect(
+		r.Min.X*s.width/w, r.Min.Y*s.height/h,
+		ceilDiv(r.Max.X*s.width, w), ceilDiv(r.Max.Y*s.height, h),
+	).Intersect(image.Rect(0, 0, s.width, s.height))
+}
+
+// paint resamples a painted canvas rectangle, and returns the scaled rectangle
+func (s *scaler) paint(canvas *image.RGBA, r image.Rectangle) image.Rectangle {
+	sr := s.rect(r)
+	for y := sr.Min.Y; y < sr.Max.Y; y++ {
+		for x := sr.Min.X; x < sr.Max.X; x++ {
+			src := s.canvasRect(image.Rect(x, y, x+1, y+1)).Intersect(canvas.Rect)
+			var sum [3]int
+			for sy := src.Min.Y; sy < src.Max.Y; sy++ {
+				i := canvas.PixOffset(src.Min.X, sy)
+				for sx := src.Min.X; sx < src.Max.X; sx++ {
+					sum[0] += int(canvas.Pix[i])
+					sum[1] += int(canvas.Pix[i+1])
+					sum[2] += int(canvas.Pix[i+2])
+					i += 4
+				}
+			}
+			n := src.Dx() * src.Dy()
+			if n == 0 {
+				continue
+			}
+			i := s.img.PixOffset(x, y)
+			s.img.Pix[i] = uint8(sum[0] / n)
+			s.img.Pix[i+1] = uint8(sum[1] / n)
+			s.img.Pix[i+2] = uint8(sum[2] / n)
+			s.img.Pix[i+3] = 0xff
+		}
+	}
+	return sr
+}
+
+// point maps a point of the vnc-clients' screen to the canvas
+func (s *scaler) point(x, y int) (int, int) {
+	x, y = x*s.width/s.img.Rect.Dx(), y*s.height/s.img.Rect.Dy()
+	if x >= s.width {
+		x = s.width - 1
+	}
+	if y >= s.height {
+		y = s.height - 1
+	}
+	return x, y
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
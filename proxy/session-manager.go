@@ -5,6 +5,9 @@ type SessionManager struct {
 }
 
 func (s *SessionManager) GetSession(sessionId string) (*VncSession, error) {
+	if s == nil {
+		return nil, nil
+	}
 	return s.sessions[sessionId], nil
 }
 
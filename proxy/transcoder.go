@@ -0,0 +1,449 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"strings"
+	"sync"
+
+	"github.com/exoscale/vncproxy/client"
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/encodings"
+	"github.com/exoscale/vncproxy/logger"
+	"github.com/exoscale/vncproxy/server"
+)
+
+// TranscodeConfig makes the proxy decode the vnc server's updates and encode them again in the best encoding
+// the vnc-client announced, instead of forwarding them: Tight from QEMU becomes ZRLE for a client without Tight,
+// raw or Hextile become Tight JPEG for a client asking for a quality level (noVNC on a slow link)...
+// The pixel formats are translated as well: the vnc server keeps its own, the vnc-client gets the one it asked for.
+type TranscodeConfig struct {
+	// Encodings restricts the encodings sent to the vnc-clients (tight, zrle, hextile, rre, raw & copyrect),
+	// all of them when empty
+	Encodings []common.EncodingType
+}
+
+// transcodedEncodings are the encodings the vnc-clients can get, by name
+var transcodedEncodings = map[string]common.EncodingType{
+	"tight":    common.EncTight,
+	"zrle":     common.EncZRLE,
+	"hextile":  common.EncHextile,
+	"rre":      common.EncRRE,
+	"raw":      common.EncRaw,
+	"copyrect": common.EncCopyRect,
+}
+
+// transcodePseudoEncodings are the pseudo encodings without payload, their rectangles are forwarded as they are
+// to the vnc-clients which announced them
+var transcodePseudoEncodings = []common.EncodingType{
+	common.EncQEMUExtendedKeyEventPseudo,
+	common.EncQEMUPointerMotionChangePseudo,
+}
+
+// RecordingPixelFormat is the pixel format of the recordings of transcoded sessions, whatever the vnc server & the
+// vnc-client use; the updates are recorded in Hextile (with CopyRect & DesktopSize)
+var RecordingPixelFormat = common.NewPixelFormat(32)
+
+// ParseEncodings parses a comma separated list of encodings for TranscodeConfig (e.g. "tight,zrle,copyrect")
+func ParseEncodings(list string) ([]common.EncodingType, error) {
+	var encs []common.EncodingType
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		enc, ok := transcodedEncodings[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown encoding: %s", name)
+		}
+		encs = append(encs, enc)
+	}
+	return encs, nil
+}
+
+// transcoder decodes the updates of a vnc server into a canvas, and encodes each rectangle for the vnc-client
+// as soon as it is painted, so CopyRect rectangles copy what the vnc-client has
+type transcoder struct {
+	conn      *server.ServerConn
+	canvas    *encodings.Canvas
+	sessionId string
+
+	// lock guards the vnc-client's pixel format & encodings, set from its messages
+	lock     sync.Mutex
+	pf       common.PixelFormat
+	colorMap bool // the palette of the color map pixel format goes with the next update
+	encoders encodings.EncoderSet
+	// scaler downscales the screen, it is resized with the canvas locked & t.lock held
+	scaler *scaler
+	// masker (nil = no privacy masks) paints the masks over the screen, used with the canvas locked
+	masker *masker
+	// overlay (nil = none) draws the watermark & banner over the vnc-client's screen, used like the scaler
+	overlay *overlay
+	// placeholder is set while the canvas has the proxy's placeholder screen, which isn't recorded
+	placeholder bool
+	// started is set once a vnc server was connected
+	started bool
+
+	// the FramebufferUpdate being transcoded, used by the goroutine reading the vnc server
+	rects bytes.Buffer
+	count int
+	err   error
+
+	// recorder (nil = not recording) gets the FramebufferUpdate in the recording format as well
+	recorder   common.SegmentConsumer
+	recEncoder encodings.Encoder
+	recRects   bytes.Buffer
+	recCount   int
+}
+
+func newTranscoder(cfg *TranscodeConfig, conn *server.ServerConn) *transcoder {
+	t := &transcoder{
+		conn:      conn,
+		canvas:    encodings.NewCanvas(0, 0),
+		sessionId: conn.SessionId,
+		scaler:    newScaler(nil),
+	}
+	if len(cfg.Encodings) > 0 {
+		allowed := append([]common.EncodingType{common.EncDesktopSizePseudo}, transcodePseudoEncodings...)
+		t.encoders.Allowed = append(allowed, cfg.Encodings...)
+	}
+	t.canvas.Painted = t.painted
+	t.canvas.Resized = t.resized
+	return t
+}
+
+// start sets the vnc server connection up, once connected: the vnc server keeps its pixel format when the decoders
+// can read it, as some ignore SetPixelFormat, else it is asked for true color. The vnc-client starts with the
+// server's pixel format, and its screen size once scaled; after the placeholder or a previous vnc server,
+// it is only resized.
+func (t *transcoder) start(cconn *client.ClientConn) error {
+	width, height := int(cconn.FrameBufferWidth), int(cconn.FrameBufferHeight)
+	if t.placeholder || t.started {
+		// the vnc-client is already initialized, with the placeholder's or the previous vnc server's size
+		// & its own pixel format; an update interrupted by the previous vnc server is dropped
+		t.reset()
+		if err := t.resizeScreen(width, height); err != nil {
+			return err
+		}
+	} else {
+		t.setScreen(width, height)
+		t.setPixelFormat(&cconn.PixelFormat)
+	}
+	t.started = true
+
+	if encodings.CheckPixelFormat(&cconn.PixelFormat) != nil {
+		upstreamPF := common.NewPixelFormat(32)
+		if err := cconn.SetPixelFormat(upstreamPF); err != nil {
+			return err
+		}
+		cconn.PixelFormat = *upstreamPF
+	}
+	cconn.Encs = encodings.NewDecoders(t.canvas)
+	return nil
+}
+
+// setScreen sizes the canvas & the vnc-client's screen, before the vnc-client is initialized
+func (t *transcoder) setScreen(width, height int) {
+	t.canvas.Resize(width, height)
+	t.resizeScaler(width, height)
+	if t.masker != nil {
+		t.canvas.Lock()
+		t.masker.resize(t.canvas.Image())
+		t.canvas.Unlock()
+	}
+}
+
+// showPlaceholder sets the vnc-client up for the placeholder screens, in the proxy's pixel format
+func (t *transcoder) showPlaceholder(width, height int) {
+	t.placeholder = true
+	t.setScreen(width, height)
+	t.setPixelFormat(t.conn.CurrentPixelFormat())
+}
+
+// paintScreen paints a rectangle of a screen rendered by the proxy (placeholder) on the canvas,
+// and sends it to the vnc-client
+func (t *transcoder) paintScreen(img *image.RGBA, r image.Rectangle) error {
+	t.canvas.Lock()
+	draw.Draw(t.canvas.Image(), r, img, r.Min, draw.Src)
+	t.painted(common.EncRaw, r, image.Point{})
+	t.canvas.Unlock()
+	return t.flush(&client.MsgFramebufferUpdate{})
+}
+
+// resizeScreen gives the vnc-client the screen size of a vnc server connected once it was initialized,
+// with a DesktopSize if it differs
+func (t *transcoder) resizeScreen(width, height int) error {
+	defer func() { t.placeholder = false }()
+	t.canvas.Lock()
+	size := t.canvas.Image().Rect.Size()
+	t.canvas.Unlock()
+	if size == image.Pt(width, height) {
+		return nil
+	}
+	if !t.supports(common.EncDesktopSizePseudo) {
+		return fmt.Errorf("the vnc-client can't be resized from %dx%d to %dx%d", size.X, size.Y, width, height)
+	}
+	t.canvas.Resize(width, height)
+	t.canvas.Lock()
+	t.resized(width, height)
+	t.canvas.Unlock()
+	return t.flush(&client.MsgFramebufferUpdate{})
+}
+
+// setPixelFormat sets the vnc-client's pixel format, the palette is sent before the pixels of a color map
+func (t *transcoder) setPixelFormat(pf *common.PixelFormat) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.pf = *pf
+	t.colorMap = pf.TrueColor == 0
+	if t.colorMap {
+		var cm common.ColorMap
+		copy(cm[:], encodings.ColorMapPalette())
+		t.conn.SetColorMap(&cm)
+	}
+}
+
+// record makes the transcoder feed the recorder, which gets the returned listener instead of the connections'
+// segments: the vnc server's messages without the framebuffer updates & the vnc-client's pixel format
+func (t *transcoder) record(rec common.SegmentConsumer) common.SegmentConsumer {
+	t.recorder = rec
+	t.recEncoder = &encodings.HextileEncoder{}
+	return &transcodedRecording{recorder: rec}
+}
+
+// setEncodings takes the vnc-client's encodings, and returns the ones to ask the vnc server for: the decoded ones,
+// the client's quality & compression levels, the pseudo encodings forwarded and the extended clipboard, which
+// doesn't go through the framebuffer updates
+func (t *transcoder) setEncodings(clientEncs []common.EncodingType) []common.EncodingType {
+	t.lock.Lock()
+	t.encoders.SetEncodings(clientEncs)
+	t.lock.Unlock()
+
+	upstream := append([]common.EncodingType{}, encodings.DecoderTypes...)
+	upstream = append(upstream, common.EncLastRectPseudo)
+	for _, enc := range clientEncs {
+		switch {
+		case enc >= common.EncJPEGQualityLevelPseudo1 && enc <= common.EncJPEGQualityLevelPseudo10,
+			enc >= common.EncCompressionLevel1 && enc <= common.EncCompressionLevel10,
+			enc == common.EncExtendedClipboardPseudo:
+			upstream = append(upstream, enc)
+		}
+	}
+	for _, enc := range transcodePseudoEncodings {
+		if t.supports(enc) {
+			upstream = append(upstream, enc)
+		}
+	}
+	return upstream
+}
+
+func (t *transcoder) supports(enc common.EncodingType) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.encoders.Supports(enc)
+}
+
+// painted encodes a rectangle the decoders have painted, the canvas is locked
+func (t *transcoder) painted(typ common.EncodingType, r image.Rectangle, src image.Point) {
+	if t.err != nil || r.Empty() {
+		return
+	}
+	img, recImg := t.canvas.Image(), t.canvas.Image()
+	copied, recCopied := typ == common.EncCopyRect, typ == common.EncCopyRect
+	srcRect := r.Sub(r.Min).Add(src)
+	if t.masker != nil {
+		// the copies from or to masked places are encoded, like the rectangles grown by the masks
+		masked := t.masker.update(img, r)
+		copied = copied && masked == r && !t.masker.masked(r, maskViewers) && !t.masker.masked(srcRect, maskViewers)
+		recCopied = recCopied && masked == r && !t.masker.masked(r, maskRecording) && !t.masker.masked(srcRect, maskRecording)
+		r = masked
+		img = t.masker.paint(img, r, maskViewers)
+		recImg = t.masker.paint(recImg, r, maskRecording)
+	}
+	if t.recorder != nil && !t.placeholder {
+		t.recordRect(recImg, r, src, recCopied)
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.scaler.scaling() {
+		// the copies are resampled as well, they wouldn't be exact
+		img, r = t.scaler.img, t.scaler.paint(img, r)
+		copied = false
+	}
+	if t.overlay != nil {
+		copied = copied && !t.overlay.covers(r) && !t.overlay.covers(srcRect)
+		img = t.overlay.paint(img, r)
+	}
+	if copied && t.encoders.Supports(common.EncCopyRect) {
+		t.err = encodings.WriteCopyRect(&t.rects, r, src)
+		t.count++
+		return
+	}
+	n, err := t.encoders.Encoder().Encode(&t.rects, img, r, &t.pf)
+	if err != nil {
+		t.err = fmt.Errorf("encoding %v in %s: %v", r, t.encoders.Encoder().Type(), err)
+	}
+	t.count += n
+}
+
+// recordRect encodes a painted rectangle in the recording format, the canvas is locked
+func (t *transcoder) recordRect(img *image.RGBA, r image.Rectangle, src image.Point, copied bool) {
+	if copied {
+		t.err = encodings.WriteCopyRect(&t.recRects, r, src)
+		t.recCount++
+		return
+	}
+	n, err := t.recEncoder.Encode(&t.recRects, img, r, RecordingPixelFormat)
+	if err != nil {
+		t.err = fmt.Errorf("recording %v: %v", r, err)
+	}
+	t.recCount += n
+}
+
+// resized follows the vnc server's DesktopSize, the canvas is locked
+func (t *transcoder) resized(width, height int) {
+	if t.recorder != nil && !t.placeholder {
+		encodings.WriteDesktopSize(&t.recRects, width, height)
+		t.recCount++
+	}
+	if t.masker != nil {
+		t.masker.resize(t.canvas.Image())
+	}
+	width, height = t.resizeScaler(width, height)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.encoders.Supports(common.EncDesktopSizePseudo) {
+		encodings.WriteDesktopSize(&t.rects, width, height)
+		t.count++
+	}
+}
+
+// resizeScaler sets the vnc-client's screen size from the canvas size, while nothing is painted,
+// the overlay is drawn for this size
+func (t *transcoder) resizeScaler(width, height int) (int, int) {
+	t.lock.Lock()
+	width, height = t.scaler.resize(width, height)
+	if t.overlay != nil {
+		t.overlay.resize(width, height)
+	}
+	t.lock.Unlock()
+	t.conn.SetWidth(uint16(width))
+	t.conn.SetHeight(uint16(height))
+	return width, height
+}
+
+// scalePointer maps a pointer event of the vnc-client to the vnc server's screen
+func (t *transcoder) scalePointer(msg *server.MsgPointerEvent) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.scaler.scaling() {
+		x, y := t.scaler.point(int(msg.X), int(msg.Y))
+		msg.X, msg.Y = uint16(x), uint16(y)
+	}
+}
+
+// scaleUpdateRequest maps the rectangle of a vnc-client's FramebufferUpdateRequest to the vnc server's screen
+func (t *transcoder) scaleUpdateRequest(msg *server.MsgFramebufferUpdateRequest) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.scaler.scaling() {
+		r := t.scaler.canvasRect(image.Rect(int(msg.X), int(msg.Y), int(msg.X)+int(msg.Width), int(msg.Y)+int(msg.Height)))
+		msg.X, msg.Y, msg.Width, msg.Height = uint16(r.Min.X), uint16(r.Min.Y), uint16(r.Dx()), uint16(r.Dy())
+	}
+}
+
+// flush writes the transcoded FramebufferUpdate to the vnc-client, with the forwarded pseudo rectangles
+func (t *transcoder) flush(msg *client.MsgFramebufferUpdate) error {
+	defer t.reset()
+	if t.err != nil {
+		logger.Errorf("transcoder (session %s): %s", t.sessionId, t.err)
+		return t.err
+	}
+	for _, rect := range msg.Rectangles {
+		pseudo, ok := rect.Enc.(*encodings.PseudoEncoding)
+		if !ok || !t.forwarded(common.EncodingType(pseudo.Typ)) {
+			continue
+		}
+		r := image.Rect(int(rect.X), int(rect.Y), int(rect.X)+int(rect.Width), int(rect.Y)+int(rect.Height))
+		encodings.WriteRectHeader(&t.rects, r, common.EncodingType(pseudo.Typ))
+		t.count++
+	}
+
+	if t.recorder != nil && !t.placeholder {
+		// the recorder keeps the bytes
+		t.recorder.Consume(&common.RfbSegment{SegmentType: common.SegmentBytes, Bytes: updateMessage(t.recCount, &t.recRects)})
+	}
+
+	var out bytes.Buffer
+	t.lock.Lock()
+	if t.colorMap {
+		encodings.WriteColorMapEntries(&out, 0, encodings.ColorMapPalette())
+		t.colorMap = false
+	}
+	t.lock.Unlock()
+	out.Write(updateMessage(t.count, &t.rects))
+	_, err := t.conn.Write(out.Bytes())
+	return err
+}
+
+// reset forgets the FramebufferUpdate being transcoded
+func (t *transcoder) reset() {
+	t.rects.Reset()
+	t.count = 0
+	t.recRects.Reset()
+	t.recCount = 0
+	t.err = nil
+}
+
+// updateMessage returns a FramebufferUpdate message with the encoded rectangles
+func updateMessage(count int, rects *bytes.Buffer) []byte {
+	msg := make([]byte, 4, 4+rects.Len())
+	msg[0] = byte(common.FramebufferUpdate)
+	binary.BigEndian.PutUint16(msg[2:], uint16(count))
+	return append(msg, rects.Bytes()...)
+}
+
+func (t *transcoder) forwarded(enc common.EncodingType) bool {
+	for _, e := range transcodePseudoEncodings {
+		if e == enc {
+			return t.supports(enc)
+		}
+	}
+	return false
+}
+
+// transcodedRecording passes the segments of a transcoded session on to a recorder, the framebuffer updates
+// come from the transcoder (see transcoder.record)
+type transcodedRecording struct {
+	recorder common.SegmentConsumer
+	dropping bool
+}
+
+func (r *transcodedRecording) Consume(seg *common.RfbSegment) error {
+	switch seg.SegmentType {
+	case common.SegmentServerInitMessage:
+		serverInit := *seg.Message.(*common.ServerInit)
+		serverInit.PixelFormat = *RecordingPixelFormat
+		return r.recorder.Consume(&common.RfbSegment{SegmentType: seg.SegmentType, Message: &serverInit})
+	case common.SegmentMessageStart:
+		// the vnc server's pixels, the transcoder records them in its format
+		switch common.ServerMessageType(seg.UpcomingObjectType) {
+		case common.FramebufferUpdate, common.SetColourMapEntries:
+			r.dropping = true
+		default:
+			r.dropping = false
+		}
+	case common.SegmentBytes:
+		if r.dropping {
+			return nil
+		}
+	case common.SegmentFullyParsedClientMessage:
+		if _, ok := seg.Message.(*server.MsgSetPixelFormat); ok {
+			return nil
+		}
+	}
+	return r.recorder.Consume(seg)
+}
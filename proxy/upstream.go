@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/exoscale/vncproxy/client"
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/logger"
+	"github.com/exoscale/vncproxy/server"
+)
+
+var (
+	errUpstreamUnreachable = errors.New("vnc server is not running or not reachable")
+	errUpstreamAuth        = errors.New("vnc server authentication failed")
+)
+
+// connSessionType returns how the connection is handled, which may differ from
+// the session's type when the client's token makes recording mandatory
+func (vp *VncProxy) connSessionType(session *VncSession, sconn *server.ServerConn) (SessionType, error) {
+	sessionType := session.Type
+	claims := sconn.Claims
+	if claims == nil {
+		return sessionType, nil
+	}
+
+	logger.Infof("Proxy.connSessionType: token subject=%s session=%s viewOnly=%t clipboard=%s record=%t",
+		claims.Subject, sconn.SessionId, claims.ViewOnly, claims.Clipboard, claims.Record)
+
+	if claims.Record && sessionType == SessionTypeProxyPass {
+		if vp.RecordingDir == "" {
+			logger.Errorf("Proxy.connSessionType: token requires recording but no recording dir is configured")
+			return sessionType, errors.New("recording required but not available")
+		}
+		sessionType = SessionTypeRecordingProxy
+	}
+	return sessionType, nil
+}
+
+// upstreamHandler runs during the vnc-client's security handshake: it connects and authenticates
+// to the vnc server, so that failures are reported to the vnc-client as the RFB failure reason
+func (vp *VncProxy) upstreamHandler(cfg *server.ServerConfig, sconn *server.ServerConn) error {
+	session, err := vp.getProxySession(sconn.SessionId)
+	if err != nil || session == nil {
+		logger.Errorf("Proxy.upstreamHandler can't get session: %s", sconn.SessionId)
+		return errors.New("unknown session")
+	}
+
+	sessionType, err := vp.connSessionType(session, sconn)
+	if err != nil {
+		return err
+	}
+
+	//relayed auth connects upstream by itself, and the placeholder is shown while connecting (see newServerConnHandler)
+	if vp.RelayAuth || (sessionType != SessionTypeProxyPass && sessionType != SessionTypeRecordingProxy) ||
+		vp.sessionPlaceholder(session) != nil {
+		return nil
+	}
+
+	cconn, err := vp.connectUpstream(session, sconn.SessionId, true)
+	if err != nil {
+		return err
+	}
+	vp.addAuthenticatedUpstream(sconn, cconn)
+	return nil
+}
+
+// connectUpstream dials the session's vnc server and runs the security handshake,
+// the returned errors are meant to be shown to the vnc-client
+func (vp *VncProxy) connectUpstream(session *VncSession, sessionId string, exclusive bool) (*client.ClientConn, error) {
+	cconn, err := vp.createClientConnection(vp.sessionTarget(session, sessionId), session.TargetUser, session.TargetPassword, exclusive)
+	if err != nil {
+		session.Status = SessionStatusError
+		logger.Errorf("Proxy.connectUpstream error creating connection: %s", err)
+		return nil, errUpstreamUnreachable
+	}
+
+	if err := cconn.Authenticate(); err != nil {
+		session.Status = SessionStatusError
+		logger.Errorf("Proxy.connectUpstream error authenticating to the vnc server: %s", err)
+		return nil, fmt.Errorf("%w: %s", errUpstreamAuth, err)
+	}
+	return cconn, nil
+}
+
+func (vp *VncProxy) addAuthenticatedUpstream(sconn *server.ServerConn, cconn *client.ClientConn) {
+	vp.upstreamsLock.Lock()
+	defer vp.upstreamsLock.Unlock()
+	if vp.upstreams == nil {
+		vp.upstreams = make(map[*server.ServerConn]*client.ClientConn)
+	}
+	vp.upstreams[sconn] = cconn
+	sconn.Listeners.AddListener(&pendingUpstreamCloser{vp, sconn})
+}
+
+// takeAuthenticatedUpstream returns (and forgets) the upstream connection authenticated for sconn, if any
+func (vp *VncProxy) takeAuthenticatedUpstream(sconn *server.ServerConn) *client.ClientConn {
+	vp.upstreamsLock.Lock()
+	defer vp.upstreamsLock.Unlock()
+	cconn := vp.upstreams[sconn]
+	delete(vp.upstreams, sconn)
+	return cconn
+}
+
+// pendingUpstreamCloser closes the upstream connection if the vnc-client goes away before it was handed over
+type pendingUpstreamCloser struct {
+	vp    *VncProxy
+	sconn *server.ServerConn
+}
+
+func (p *pendingUpstreamCloser) Consume(seg *common.RfbSegment) error {
+	if seg.SegmentType == common.SegmentConnectionClosed {
+		if cconn := p.vp.takeAuthenticatedUpstream(p.sconn); cconn != nil {
+			logger.Debugf("pendingUpstreamCloser: vnc-client left during the handshake, closing the vnc server connection")
+			cconn.Close()
+		}
+	}
+	return nil
+}
@@ -20,8 +20,29 @@ type VncSession struct {
 	TargetHostname string
 	TargetPort     string
 	TargetPassword string
+	TargetUser     string // username for Apple Remote Desktop auth (macOS Screen Sharing)
 	ID             string
 	Status         SessionStatus
 	Type           SessionType
 	ReplayFilePath string
+	// ClipboardPolicy overrides the proxy's clipboard policy for this session
+	ClipboardPolicy *ClipboardPolicy
+	// InputPolicy overrides the proxy's input policy for this session
+	InputPolicy *InputPolicy
+	// PasteAsKeys overrides the proxy's paste as keys setting for this session
+	PasteAsKeys *PasteConfig
+	// KeyboardLayout overrides the proxy's guest keyboard layout for this session
+	KeyboardLayout string
+	// Transcode overrides the proxy's transcoding setting for this session
+	Transcode *TranscodeConfig
+	// Scale overrides the proxy's scaling for this session, the vnc-clients may ask for their own (see ParseScaleQuery)
+	Scale *ScaleConfig
+	// MaskPolicy overrides the proxy's privacy masks for this session
+	MaskPolicy *MaskPolicy
+	// Overlay overrides the proxy's watermark & banner for this session
+	Overlay *OverlayConfig
+	// Placeholder overrides the proxy's placeholder screens for this session
+	Placeholder *PlaceholderConfig
+	// Reconnect overrides the proxy's reconnection to the vnc server for this session
+	Reconnect *ReconnectConfig
 }
@@ -17,6 +17,7 @@ func main() {
 	var recordDir = flag.String("recFile", "", "FBS file to create, recordings WILL NOT RECORD IF EMPTY.")
 	var targetVncPort = flag.String("targPort", "", "target vnc server port")
 	var targetVncPass = flag.String("targPass", "", "target vnc password")
+	var targetVncUser = flag.String("targUser", "", "target username, for Apple Remote Desktop auth (macOS Screen Sharing)")
 	var targetVncHost = flag.String("targHost", "localhost", "target vnc hostname")
 	var logLevel = flag.String("logLevel", "info", "change logging level")
 
@@ -51,6 +52,9 @@ func main() {
 	}
 	var noauth client.ClientAuthNone
 	authArr := []client.ClientAuth{&client.PasswordAuth{Password: *targetVncPass}, &noauth}
+	if *targetVncUser != "" {
+		authArr = append([]client.ClientAuth{&client.ARDAuth{Username: *targetVncUser, Password: *targetVncPass}}, authArr...)
+	}
 
 	rec, err := recorder.NewRecorder(*recordDir) //"/Users/amitbet/vncRec/recording.rbs")
 	if err != nil {
@@ -75,6 +79,7 @@ func main() {
 	encs := []common.IEncoding{
 		&encodings.TightEncoding{},
 		&encodings.PseudoEncoding{int32(common.EncJPEGQualityLevelPseudo8)},
+		&encodings.PseudoEncoding{Typ: int32(common.EncExtendedClipboardPseudo)},
 	}
 
 	clientConn.SetEncodings(encs)
@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/exoscale/vncproxy/client"
 	"github.com/exoscale/vncproxy/common"
 	"github.com/exoscale/vncproxy/logger"
 	"github.com/exoscale/vncproxy/server"
@@ -144,8 +145,15 @@ func (r *Recorder) HandleRfbSegment(data *common.RfbSegment) error {
 		case common.SetPixelFormatMsgType:
 			clientMsg := data.Message.(*server.MsgSetPixelFormat)
 			r.serverInitMessage.PixelFormat = clientMsg.PF
+		case common.ClientCutTextMsgType:
+			logger.Infof("Recorder.HandleRfbSegment: client clipboard: %s", clientMsg.(*server.MsgClientCutText))
 		default:
 		}
+	case common.SegmentFullyParsedServerMessage:
+		// the message bytes are already in the recording, only note clipboard changes
+		if cutText, ok := data.Message.(*client.MsgServerCutText); ok {
+			logger.Infof("Recorder.HandleRfbSegment: server clipboard: %s", cutText)
+		}
 
 	default:
 	}
@@ -2,15 +2,21 @@ package server
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 
 	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/keysym"
+	"github.com/exoscale/vncproxy/logger"
 )
 
 // Key represents a VNC key press.
 type Key uint32
 
-//go:generate stringer -type=Key
+// String returns the X11 name of the keysym
+func (k Key) String() string {
+	return keysym.Keysym(k).Name()
+}
 
 // Keys is a slice of Key values.
 type Keys []Key
@@ -160,7 +166,7 @@ func (msg *MsgKeyEvent) Write(c io.Writer) error {
 	return nil
 }
 
-// MsgKeyEvent holds the wire format message.
+// MsgQEMUExtKeyEvent holds the wire format message.
 type MsgQEMUExtKeyEvent struct {
 	SubmessageType uint8  // submessage type
 	DownFlag       uint16 // down-flag
@@ -173,7 +179,7 @@ func (*MsgQEMUExtKeyEvent) Type() common.ClientMessageType {
 }
 
 func (*MsgQEMUExtKeyEvent) Read(c io.Reader) (common.ClientMessage, error) {
-	msg := MsgKeyEvent{}
+	msg := MsgQEMUExtKeyEvent{}
 	if err := binary.Read(c, binary.BigEndian, &msg); err != nil {
 		return nil, err
 	}
@@ -253,10 +259,12 @@ func (msg *MsgClientFence) Write(c io.Writer) error {
 }
 
 // MsgClientCutText holds the wire format message, sans the text field.
+// Text is the raw payload: Latin-1 text, or the extended clipboard message when Extended is set.
 type MsgClientCutText struct {
-	_      [3]byte // padding
-	Length uint32  // length
-	Text   []byte
+	_        [3]byte // padding
+	Length   uint32  // length
+	Text     []byte
+	Extended *common.ExtendedClipboard
 }
 
 func (*MsgClientCutText) Type() common.ClientMessageType {
@@ -273,15 +281,61 @@ func (*MsgClientCutText) Read(c io.Reader) (common.ClientMessage, error) {
 	if err := binary.Read(c, binary.BigEndian, &msg.Length); err != nil {
 		return nil, err
 	}
+	size, extended, err := common.ParseCutTextLength(msg.Length)
+	if err != nil {
+		return nil, err
+	}
 
-	msg.Text = make([]byte, msg.Length)
-	if err := binary.Read(c, binary.BigEndian, &msg.Text); err != nil {
+	msg.Text = make([]byte, size)
+	if _, err := io.ReadFull(c, msg.Text); err != nil {
 		return nil, err
 	}
+	if extended {
+		msg.Extended, err = common.ParseExtendedClipboard(msg.Text)
+		if err != nil {
+			// keep the raw payload, it is passed through as is
+			logger.Warnf("MsgClientCutText.Read: bad extended clipboard message: %s", err)
+		}
+	}
 	return &msg, nil
 }
 
+// IsExtended tells whether the message uses the extended clipboard format
+func (msg *MsgClientCutText) IsExtended() bool {
+	return int32(msg.Length) < 0
+}
+
+// TextContent returns the text carried by the message (empty for extended messages without text)
+func (msg *MsgClientCutText) TextContent() string {
+	if msg.IsExtended() {
+		if msg.Extended == nil {
+			return ""
+		}
+		return msg.Extended.Text()
+	}
+	return common.Latin1ToString(msg.Text)
+}
+
+func (msg *MsgClientCutText) String() string {
+	if msg.IsExtended() {
+		if msg.Extended == nil {
+			return fmt.Sprintf("extended (unparsed, %d bytes)", len(msg.Text))
+		}
+		return "extended " + msg.Extended.String()
+	}
+	return fmt.Sprintf("text (%d bytes)", len(msg.Text))
+}
+
 func (msg *MsgClientCutText) Write(c io.Writer) error {
+	if msg.Extended != nil && len(msg.Text) == 0 {
+		payload, err := msg.Extended.Bytes()
+		if err != nil {
+			return err
+		}
+		msg.Text = payload
+		msg.Length = common.CutTextLength(payload, true)
+	}
+
 	if err := binary.Write(c, binary.BigEndian, msg.Type()); err != nil {
 		return err
 	}
@@ -291,7 +345,7 @@ func (msg *MsgClientCutText) Write(c io.Writer) error {
 		return err
 	}
 
-	if uint32(len(msg.Text)) > msg.Length {
+	if !msg.IsExtended() && uint32(len(msg.Text)) > msg.Length {
 		msg.Length = uint32(len(msg.Text))
 	}
 
@@ -0,0 +1,427 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/encodings"
+	"github.com/exoscale/vncproxy/logger"
+)
+
+// Framebuffer serves a screen drawn by the application, without a vnc server behind: each vnc client gets
+// the changed regions in answer to its update requests, in the best encoding it supports, and its input
+// is passed to the callbacks.
+//
+//	fb := server.NewFramebuffer(1024, 768)
+//	fb.OnKey = func(conn *server.ServerConn, key server.Key, down bool) { ... }
+//	cfg := &server.ServerConfig{SecurityHandlers: []server.SecurityHandler{&server.ServerAuthNone{}}}
+//	fb.Configure(cfg)
+//	go server.TcpServe(":5900", cfg)
+//	fb.Draw(func(img *image.RGBA) []image.Rectangle { ...; return dirty })
+type Framebuffer struct {
+	// OnKey, OnPointer & OnCutText (optional) get the input of the vnc clients,
+	// they are called from the goroutine reading the client's messages
+	OnKey     func(conn *ServerConn, key Key, down bool)
+	OnPointer func(conn *ServerConn, buttons uint8, x, y int)
+	OnCutText func(conn *ServerConn, text string)
+	// Encodings restricts the encodings used (see encodings.EncoderSet), all of them when empty
+	Encodings []common.EncodingType
+
+	lock  sync.Mutex
+	img   *image.RGBA
+	conns map[*fbConn]struct{}
+}
+
+// NewFramebuffer creates a black screen
+func NewFramebuffer(width, height int) *Framebuffer {
+	fb := &Framebuffer{conns: make(map[*fbConn]struct{})}
+	fb.img = newScreen(width, height)
+	return fb
+}
+
+func newScreen(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Rect, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	return img
+}
+
+// Configure sets the screen size, the default pixel format & messages, and the connection handler
+func (fb *Framebuffer) Configure(cfg *ServerConfig) {
+	fb.lock.Lock()
+	cfg.Width, cfg.Height = uint16(fb.img.Rect.Dx()), uint16(fb.img.Rect.Dy())
+	fb.lock.Unlock()
+	if cfg.PixelFormat == nil {
+		cfg.PixelFormat = common.NewPixelFormat(32)
+	}
+	if len(cfg.ClientMessages) == 0 {
+		cfg.ClientMessages = DefaultClientMessages
+	}
+	cfg.NewConnHandler = fb.NewConnHandler
+}
+
+// NewConnHandler serves the framebuffer to a new vnc client (see ServerConfig.NewConnHandler),
+// it is run before ServerInit, which gets the current screen size
+func (fb *Framebuffer) NewConnHandler(cfg *ServerConfig, conn *ServerConn) error {
+	c := &fbConn{
+		fb:       fb,
+		conn:     conn,
+		wake:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		pf:       *conn.CurrentPixelFormat(),
+		encoders: encodings.EncoderSet{Allowed: fb.Encodings},
+	}
+	fb.lock.Lock()
+	conn.SetWidth(uint16(fb.img.Rect.Dx()))
+	conn.SetHeight(uint16(fb.img.Rect.Dy()))
+	fb.conns[c] = struct{}{}
+	fb.lock.Unlock()
+
+	conn.Listeners.AddListener(c)
+	go c.sendUpdates()
+	return nil
+}
+
+// Draw runs fn with the screen locked, and sends the dirty rectangles it returns to the clients
+func (fb *Framebuffer) Draw(fn func(img *image.RGBA) []image.Rectangle) {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	for _, r := range fn(fb.img) {
+		fb.changed(fbUpdate{rect: r.Intersect(fb.img.Rect)})
+	}
+}
+
+// Snapshot returns a copy of the screen
+func (fb *Framebuffer) Snapshot() *image.RGBA {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	img := image.NewRGBA(fb.img.Rect)
+	copy(img.Pix, fb.img.Pix)
+	return img
+}
+
+// CopyRect moves the src rectangle of the screen to dst, it is sent as CopyRect to the clients supporting it
+func (fb *Framebuffer) CopyRect(src image.Rectangle, dst image.Point) {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	src = src.Intersect(fb.img.Rect)
+	dstRect := src.Sub(src.Min).Add(dst).Intersect(fb.img.Rect)
+	if dstRect.Empty() {
+		return
+	}
+	src = dstRect.Sub(dst).Add(src.Min)
+
+	tmp := image.NewRGBA(src)
+	draw.Draw(tmp, src, fb.img, src.Min, draw.Src)
+	draw.Draw(fb.img, dstRect, tmp, src.Min, draw.Src)
+	fb.changed(fbUpdate{rect: dstRect, copyFrom: src.Min, isCopy: true})
+}
+
+// Resize changes the screen size keeping the top left pixels, the clients supporting DesktopSize are told
+func (fb *Framebuffer) Resize(width, height int) {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	img := newScreen(width, height)
+	draw.Draw(img, img.Rect, fb.img, image.Point{}, draw.Src)
+	fb.img = img
+	for c := range fb.conns {
+		c.pending = []fbUpdate{{rect: img.Rect, resize: true}}
+		c.wakeUp()
+	}
+}
+
+// Bell rings the clients
+func (fb *Framebuffer) Bell() {
+	fb.broadcast([]byte{byte(common.Bell)})
+}
+
+// CutText sends text to the clients' clipboard, the characters missing from Latin-1 are replaced
+func (fb *Framebuffer) CutText(text string) {
+	latin1, _ := common.StringToLatin1(text)
+	msg := make([]byte, 8, 8+len(latin1))
+	msg[0] = byte(common.ServerCutText)
+	binary.BigEndian.PutUint32(msg[4:], uint32(len(latin1)))
+	fb.broadcast(append(msg, latin1...))
+}
+
+// broadcast sends a message to the clients done with the handshake, which have asked for an update
+func (fb *Framebuffer) broadcast(msg []byte) {
+	fb.lock.Lock()
+	conns := make([]*fbConn, 0, len(fb.conns))
+	for c := range fb.conns {
+		if c.initialized {
+			conns = append(conns, c)
+		}
+	}
+	fb.lock.Unlock()
+	for _, c := range conns {
+		c.write(msg)
+	}
+}
+
+// changed queues a screen change for all the clients, fb.lock must be held
+func (fb *Framebuffer) changed(u fbUpdate) {
+	if u.rect.Empty() {
+		return
+	}
+	for c := range fb.conns {
+		c.queue(u)
+	}
+}
+
+// maxPendingUpdates is the number of changes kept for a client, more are replaced by their bounding rectangle
+const maxPendingUpdates = 32
+
+// fbUpdate is a changed rectangle, a copy of the rectangle at copyFrom, or a new screen size
+type fbUpdate struct {
+	rect     image.Rectangle
+	copyFrom image.Point
+	isCopy   bool
+	resize   bool
+}
+
+// fbConn is a vnc client of the framebuffer
+type fbConn struct {
+	fb        *Framebuffer
+	conn      *ServerConn
+	wake      chan struct{}
+	quit      chan struct{}
+	writeLock sync.Mutex
+
+	// guarded by fb.lock
+	pf       common.PixelFormat
+	colorMap bool // the palette of the color map pixel format goes with the next update
+	encoders encodings.EncoderSet
+	pending  []fbUpdate
+	request  *MsgFramebufferUpdateRequest
+	// initialized is set by the first update request, the messages sent before would break the handshake
+	initialized bool
+}
+
+func (c *fbConn) Consume(seg *common.RfbSegment) error {
+	switch seg.SegmentType {
+	case common.SegmentConnectionClosed:
+		c.fb.lock.Lock()
+		if _, ok := c.fb.conns[c]; ok {
+			delete(c.fb.conns, c)
+			close(c.quit)
+		}
+		c.fb.lock.Unlock()
+		return nil
+	case common.SegmentFullyParsedClientMessage:
+	default:
+		return nil
+	}
+
+	fb := c.fb
+	switch msg := seg.Message.(type) {
+	case *MsgSetPixelFormat:
+		fb.lock.Lock()
+		c.pf = msg.PF
+		c.colorMap = msg.PF.TrueColor == 0
+		fb.lock.Unlock()
+	case *MsgSetEncodings:
+		fb.lock.Lock()
+		c.encoders.SetEncodings(msg.Encodings)
+		fb.lock.Unlock()
+	case *MsgFramebufferUpdateRequest:
+		fb.lock.Lock()
+		c.request = msg
+		c.initialized = true
+		if msg.Inc == 0 {
+			c.queue(fbUpdate{rect: image.Rect(int(msg.X), int(msg.Y), int(msg.X)+int(msg.Width), int(msg.Y)+int(msg.Height))})
+		}
+		c.wakeUp()
+		fb.lock.Unlock()
+	case *MsgKeyEvent:
+		if fb.OnKey != nil {
+			fb.OnKey(c.conn, msg.Key, msg.Down != 0)
+		}
+	case *MsgClientQemuExtendedKey:
+		if fb.OnKey != nil {
+			fb.OnKey(c.conn, Key(msg.KeySym), msg.IsDown != 0)
+		}
+	case *MsgPointerEvent:
+		if fb.OnPointer != nil {
+			fb.OnPointer(c.conn, msg.Mask, int(msg.X), int(msg.Y))
+		}
+	case *MsgClientCutText:
+		if fb.OnCutText != nil {
+			fb.OnCutText(c.conn, msg.TextContent())
+		}
+	}
+	return nil
+}
+
+func (c *fbConn) wakeUp() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// queue adds a screen change, the copies the client can't do are sent as changed rectangles, fb.lock must be held.
+// The changed rectangles queued after the last copy are merged with the ones they overlap, as they are all
+// encoded from the current screen.
+func (c *fbConn) queue(u fbUpdate) {
+	if u.isCopy {
+		src := u.rect.Sub(u.rect.Min).Add(u.copyFrom)
+		// the client's copy of the source must be up to date
+		stale := !c.encoders.Supports(common.EncCopyRect)
+		for _, p := range c.pending {
+			stale = stale || (!p.isCopy && p.rect.Overlaps(src))
+		}
+		if stale {
+			u = fbUpdate{rect: u.rect}
+		}
+	}
+	if !u.isCopy {
+		first := 0
+		for i, p := range c.pending {
+			if p.isCopy {
+				first = i + 1
+			}
+		}
+		for merged := true; merged; {
+			merged = false
+			for i := first; i < len(c.pending); i++ {
+				p := c.pending[i]
+				if u.rect.In(p.rect) {
+					return
+				}
+				if !p.resize && p.rect.Overlaps(u.rect) {
+					u.rect = u.rect.Union(p.rect)
+					c.pending = append(c.pending[:i], c.pending[i+1:]...)
+					merged = true
+					break
+				}
+			}
+		}
+	}
+	c.pending = append(c.pending, u)
+	if len(c.pending) > maxPendingUpdates {
+		c.collapse()
+	}
+	c.wakeUp()
+}
+
+// collapse replaces the pending changes by their bounding rectangle, a resize already covers the whole screen
+func (c *fbConn) collapse() {
+	if c.pending[0].resize {
+		c.pending = c.pending[:1]
+		return
+	}
+	var bounds image.Rectangle
+	for _, p := range c.pending {
+		bounds = bounds.Union(p.rect)
+	}
+	c.pending = []fbUpdate{{rect: bounds}}
+}
+
+func (c *fbConn) write(msg []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+// sendUpdates answers the update requests when there is something to send
+func (c *fbConn) sendUpdates() {
+	for {
+		select {
+		case <-c.wake:
+		case <-c.quit:
+			return
+		}
+		msg := c.nextUpdate()
+		if msg == nil {
+			continue
+		}
+		if err := c.write(msg); err != nil {
+			logger.Errorf("Framebuffer: error sending an update: %s", err)
+			return
+		}
+	}
+}
+
+// nextUpdate returns the update answering the client's request, nil when there is nothing to send:
+// the request is then kept for the next changes
+func (c *fbConn) nextUpdate() []byte {
+	c.fb.lock.Lock()
+	defer c.fb.lock.Unlock()
+	if c.request == nil || len(c.pending) == 0 {
+		return nil
+	}
+	msg := c.encodeUpdate()
+	c.pending = nil
+	if msg != nil {
+		c.request = nil
+	}
+	return msg
+}
+
+// encodeUpdate builds the FramebufferUpdate message with the pending changes, fb.lock must be held
+func (c *fbConn) encodeUpdate() []byte {
+	rects, count, err := c.encodeRects(c.encoders.Encoder())
+	if err == nil && count > math.MaxUint16 {
+		// too many rectangles for a FramebufferUpdate (Tight splits the big ones): one raw rectangle instead
+		c.collapse()
+		rects, count, err = c.encodeRects(&encodings.RawEncoder{})
+	}
+	if err != nil {
+		logger.Errorf("Framebuffer: error encoding an update: %s", err)
+		return nil
+	}
+	if count == 0 {
+		return nil
+	}
+
+	var msg bytes.Buffer
+	if c.colorMap {
+		encodings.WriteColorMapEntries(&msg, 0, encodings.ColorMapPalette())
+		c.colorMap = false
+	}
+	header := make([]byte, 4)
+	header[0] = byte(common.FramebufferUpdate)
+	binary.BigEndian.PutUint16(header[2:], uint16(count))
+	msg.Write(header)
+	msg.Write(rects.Bytes())
+	return msg.Bytes()
+}
+
+// encodeRects encodes the pending changes with the encoder, and returns the number of rectangles written
+func (c *fbConn) encodeRects(encoder encodings.Encoder) (*bytes.Buffer, int, error) {
+	var rects bytes.Buffer
+	count := 0
+	for _, u := range c.pending {
+		if u.resize {
+			c.conn.SetWidth(uint16(u.rect.Dx()))
+			c.conn.SetHeight(uint16(u.rect.Dy()))
+			if c.encoders.Supports(common.EncDesktopSizePseudo) {
+				encodings.WriteDesktopSize(&rects, u.rect.Dx(), u.rect.Dy())
+				count++
+			}
+		}
+		r := u.rect.Intersect(c.fb.img.Rect)
+		if r.Empty() {
+			continue
+		}
+		if u.isCopy {
+			encodings.WriteCopyRect(&rects, r, u.copyFrom)
+			count++
+			continue
+		}
+		n, err := encoder.Encode(&rects, c.fb.img, r, &c.pf)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%v: %s", r, err)
+		}
+		count += n
+	}
+	return &rects, count, nil
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/exoscale/vncproxy/automation"
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/encodings"
+)
+
+func TestFramebuffer(t *testing.T) {
+	keys := make(chan Key, 10)
+	fb := NewFramebuffer(64, 48)
+	fb.OnKey = func(conn *ServerConn, key Key, down bool) {
+		if !down {
+			keys <- key
+		}
+	}
+	cfg := &ServerConfig{SecurityHandlers: []SecurityHandler{&ServerAuthNone{}}}
+	fb.Configure(cfg)
+
+	cli, srv := net.Pipe()
+	go attachNewServerConn(srv, cfg, "dummySession", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := automation.New(ctx, cli, &automation.Options{})
+	if err != nil {
+		t.Fatalf("connecting: %s", err)
+	}
+	defer c.Close()
+	if w, h := c.Size(); w != 64 || h != 48 {
+		t.Errorf("unexpected size %dx%d", w, h)
+	}
+
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	square := image.Rect(8, 8, 24, 24)
+	fb.Draw(func(img *image.RGBA) []image.Rectangle {
+		draw.Draw(img, square, image.NewUniform(red), image.Point{}, draw.Src)
+		return []image.Rectangle{square}
+	})
+	ref := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	draw.Draw(ref, ref.Rect, image.NewUniform(red), image.Point{}, draw.Src)
+	if err := c.WaitForRegionMatch(ctx, square.Min, ref, 0); err != nil {
+		t.Fatalf("drawn square: %s", err)
+	}
+
+	fb.CopyRect(square, image.Pt(40, 20))
+	if err := c.WaitForRegionMatch(ctx, image.Pt(40, 20), ref, 0); err != nil {
+		t.Fatalf("copied square: %s", err)
+	}
+
+	fb.Resize(80, 60)
+	corner := image.Rect(64, 44, 80, 60)
+	fb.Draw(func(img *image.RGBA) []image.Rectangle {
+		draw.Draw(img, corner, image.NewUniform(red), image.Point{}, draw.Src)
+		return []image.Rectangle{corner}
+	})
+	if err := c.WaitForRegionMatch(ctx, corner.Min, ref, 0); err != nil {
+		t.Fatalf("after resize: %s", err)
+	}
+	if w, h := c.Size(); w != 80 || h != 60 {
+		t.Errorf("unexpected size after resize %dx%d", w, h)
+	}
+
+	if err := c.PressChord(ctx, "Return"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case key := <-keys:
+		if key != 0xff0d {
+			t.Errorf("unexpected key %s", key)
+		}
+	case <-ctx.Done():
+		t.Fatal("key event not received")
+	}
+}
+
+func TestFramebufferPendingRequest(t *testing.T) {
+	var out bytes.Buffer
+	conn, err := NewServerConn(&out, &ServerConfig{ClientMessages: DefaultClientMessages}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fb := NewFramebuffer(8, 8)
+	c := &fbConn{fb: fb, conn: conn, wake: make(chan struct{}, 1), quit: make(chan struct{}), pf: *common.NewPixelFormat(32)}
+	fb.conns[c] = struct{}{}
+
+	// nothing goes to a client in the middle of its handshake
+	fb.Bell()
+	if out.Len() != 0 {
+		t.Fatalf("bell sent during the handshake: %v", out.Bytes())
+	}
+
+	c.Consume(&common.RfbSegment{SegmentType: common.SegmentFullyParsedClientMessage,
+		Message: &MsgFramebufferUpdateRequest{Inc: 1, Width: 8, Height: 8}})
+	fb.Bell()
+	if !bytes.Equal(out.Bytes(), []byte{byte(common.Bell)}) {
+		t.Errorf("bell not sent once initialized: %v", out.Bytes())
+	}
+
+	// a change out of the screen answers nothing, the request waits for the next one
+	c.pending = []fbUpdate{{rect: image.Rect(20, 20, 30, 30)}}
+	if msg := c.nextUpdate(); msg != nil || c.request == nil {
+		t.Fatalf("empty update sent (%v) or request dropped", msg)
+	}
+	c.pending = []fbUpdate{{rect: image.Rect(0, 0, 4, 4)}}
+	if msg := c.nextUpdate(); msg == nil || msg[0] != byte(common.FramebufferUpdate) || c.request != nil {
+		t.Errorf("the waiting request wasn't answered: %v", msg)
+	}
+}
+
+func TestFramebufferMergedChanges(t *testing.T) {
+	var out bytes.Buffer
+	conn, err := NewServerConn(&out, &ServerConfig{ClientMessages: DefaultClientMessages}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fb := NewFramebuffer(64, 48)
+	c := &fbConn{fb: fb, conn: conn, wake: make(chan struct{}, 1), quit: make(chan struct{}), pf: *common.NewPixelFormat(32)}
+	fb.conns[c] = struct{}{}
+	c.encoders.SetEncodings([]common.EncodingType{common.EncZRLE})
+
+	// a client slow to ask for the next update: overlapping squares, then scattered pixels
+	for i := 0; i < 1000; i++ {
+		r := image.Rect(i%40, i%30, i%40+16, i%30+16)
+		fb.Draw(func(img *image.RGBA) []image.Rectangle {
+			draw.Draw(img, r, image.NewUniform(color.RGBA{byte(i), 0, byte(i >> 8), 0xff}), image.Point{}, draw.Src)
+			return []image.Rectangle{r}
+		})
+	}
+	for i := 0; i < 500; i++ {
+		p := image.Pt(i*7%64, i*11%48)
+		fb.Draw(func(img *image.RGBA) []image.Rectangle {
+			img.SetRGBA(p.X, p.Y, color.RGBA{0, byte(i), 0xff, 0xff})
+			return []image.Rectangle{image.Rect(p.X, p.Y, p.X+1, p.Y+1)}
+		})
+	}
+	if len(c.pending) > maxPendingUpdates {
+		t.Errorf("%d changes pending", len(c.pending))
+	}
+
+	c.request = &MsgFramebufferUpdateRequest{Inc: 1, Width: 64, Height: 48}
+	msg := c.nextUpdate()
+	if msg == nil || msg[0] != byte(common.FramebufferUpdate) {
+		t.Fatalf("unexpected update %v", msg)
+	}
+	count := int(binary.BigEndian.Uint16(msg[2:]))
+	if count > maxPendingUpdates {
+		t.Errorf("%d rectangles sent", count)
+	}
+
+	canvas := encodings.NewCanvas(64, 48)
+	decoders := encodings.NewDecoders(canvas)
+	r := common.NewRfbReadHelper(bytes.NewReader(msg[4:]))
+	for i := 0; i < count; i++ {
+		var rect common.Rectangle
+		var typ int32
+		for _, v := range []interface{}{&rect.X, &rect.Y, &rect.Width, &rect.Height, &typ} {
+			if err := binary.Read(r, binary.BigEndian, v); err != nil {
+				t.Fatalf("reading rectangle %d: %s", i, err)
+			}
+		}
+		for _, dec := range decoders {
+			if dec.Type() == typ {
+				if _, err := dec.Read(&c.pf, &rect, r); err != nil {
+					t.Fatalf("decoding %s: %s", common.EncodingType(typ), err)
+				}
+			}
+		}
+	}
+	if n := r.Reader.(*bytes.Reader).Len(); n != 0 {
+		t.Errorf("%d bytes left after the rectangles", n)
+	}
+	canvas.Lock()
+	defer canvas.Unlock()
+	if !bytes.Equal(canvas.Image().Pix, fb.Snapshot().Pix) {
+		t.Error("the decoded update isn't the screen")
+	}
+}
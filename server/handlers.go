@@ -2,6 +2,7 @@ package server
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 
@@ -13,6 +14,7 @@ const ProtoVersionLength = 12
 const (
 	ProtoVersionUnknown = ""
 	ProtoVersion33      = "RFB 003.003\n"
+	ProtoVersion37      = "RFB 003.007\n"
 	ProtoVersion38      = "RFB 003.008\n"
 )
 
@@ -50,8 +52,11 @@ func ServerVersionHandler(cfg *ServerConfig, c *ServerConn) error {
 
 	pv := ProtoVersionUnknown
 	if major == 3 {
+		// unknown minor versions below 3.7 must be handled as 3.3 (see 7.1.1)
 		if minor >= 8 {
 			pv = ProtoVersion38
+		} else if minor == 7 {
+			pv = ProtoVersion37
 		} else if minor >= 3 {
 			pv = ProtoVersion33
 		}
@@ -65,6 +70,21 @@ func ServerVersionHandler(cfg *ServerConfig, c *ServerConn) error {
 }
 
 func ServerSecurityHandler(cfg *ServerConfig, c *ServerConn) error {
+	if c.Protocol() == ProtoVersion33 {
+		return serverSecurityHandler33(cfg, c)
+	}
+
+	// a 3.7 client gets no SecurityResult for None, so when it is the only choice the
+	// upstream handler is run first, and its error sent instead of the security types
+	upstreamDone := false
+	if c.Protocol() == ProtoVersion37 && cfg.UpstreamHandler != nil && offersOnlyNone(cfg.SecurityHandlers) {
+		if err := cfg.UpstreamHandler(cfg, c); err != nil {
+			writeNoSecurityTypes(c, err)
+			return err
+		}
+		upstreamDone = true
+	}
+
 	if err := binary.Write(c, binary.BigEndian, uint8(len(cfg.SecurityHandlers))); err != nil {
 		return err
 	}
@@ -89,175 +109,171 @@ func ServerSecurityHandler(cfg *ServerConfig, c *ServerConn) error {
 	if !ok {
 		return fmt.Errorf("server type %d not implemented", secType)
 	}
+	c.securityType = secType
+	c.authType = secType
 
-	var authCode uint32
 	authErr := sType.Auth(c)
-	if authErr != nil {
-		authCode = uint32(1)
-	}
-
-	if err := binary.Write(c, binary.BigEndian, authCode); err != nil {
-		return err
+	if authErr == nil && cfg.UpstreamHandler != nil && !upstreamDone {
+		authErr = cfg.UpstreamHandler(cfg, c)
 	}
 
-	if authErr != nil {
-		if err := binary.Write(c, binary.BigEndian, len(authErr.Error())); err != nil {
-			return err
-		}
-		if err := binary.Write(c, binary.BigEndian, []byte(authErr.Error())); err != nil {
-			return err
-		}
+	if c.authType == SecTypeNone && c.Protocol() == ProtoVersion37 {
+		// no SecurityResult, the only way to report the error is to close the connection
 		return authErr
 	}
-
-	return nil
+	return writeSecurityResult(c, authErr)
 }
 
-func ServerServerInitHandler(cfg *ServerConfig, c *ServerConn) error {
-	srvInit := &common.ServerInit{
-		FBWidth:     c.Width(),
-		FBHeight:    c.Height(),
-		PixelFormat: *c.CurrentPixelFormat(),
-		NameLength:  uint32(len(cfg.DesktopName)),
-		NameText:    []byte(cfg.DesktopName),
-	}
-	if err := binary.Write(c, binary.BigEndian, srvInit.FBWidth); err != nil {
-		return err
-	}
-	if err := binary.Write(c, binary.BigEndian, srvInit.FBHeight); err != nil {
-		return err
-	}
-
-	if err := srvInit.PixelFormat.WriteTo(c); err != nil {
-		return err
-	}
-	if err := binary.Write(c, binary.BigEndian, srvInit.NameLength); err != nil {
-		return err
+// offersOnlyNone tells whether every security type ends up in None, Tight wrapping only None included
+func offersOnlyNone(handlers []SecurityHandler) bool {
+	for _, h := range handlers {
+		if tight, ok := h.(*ServerAuthTight); ok {
+			if !offersOnlyNone(tight.AuthTypes) {
+				return false
+			}
+		} else if h.Type() != SecTypeNone {
+			return false
+		}
 	}
+	return len(handlers) > 0
+}
 
-	if err := binary.Write(c, binary.BigEndian, srvInit.NameText); err != nil {
+// writeNoSecurityTypes sends an empty security type list followed by the reason (3.7+).
+func writeNoSecurityTypes(c *ServerConn, reason error) error {
+	if err := binary.Write(c, binary.BigEndian, uint8(0)); err != nil {
 		return err
 	}
-
-	return nil
+	return writeFailureReason(c, reason.Error())
 }
 
-const (
-	StandardVendor  = "STDV"
-	TridiaVncVendor = "TRDV"
-	TightVncVendor  = "TGHT"
-)
-
-type TightServerInit struct {
-	ServerMessageCaps []TightCapability
-	ClientMessageCaps []TightCapability
-	EncodingCaps      []TightCapability
-}
-
-func (t *TightServerInit) ReadFrom(r io.Reader) error {
-	var numSrvCaps uint16
-	var numCliCaps uint16
-	var numEncCaps uint16
-	var padding uint16
-
-	if err := binary.Read(r, binary.BigEndian, &numSrvCaps); err != nil {
-		return err
+// serverSecurityHandler33 is the RFB 3.3 security handshake, where the server decides on the security type.
+// The only way to report a failure reason is to send it instead of the security type.
+func serverSecurityHandler33(cfg *ServerConfig, c *ServerConn) error {
+	var sType SecurityHandler
+	for _, handler := range cfg.SecurityHandlers {
+		if handler.Type() == SecTypeNone || handler.Type() == SecTypeVNC {
+			sType = handler
+			break
+		}
 	}
-
-	if err := binary.Read(r, binary.BigEndian, &numCliCaps); err != nil {
+	if sType == nil {
+		err := errors.New("no security type supported by RFB 3.3 clients")
+		writeSecurityFailure33(c, err)
 		return err
 	}
 
-	if err := binary.Read(r, binary.BigEndian, &numEncCaps); err != nil {
-		return err
+	// None has no SecurityResult, the upstream handler is run first and its error sent instead of the security type.
+	// Otherwise the vnc-client must authenticate first, and only gets a failed SecurityResult (no reason in 3.3)
+	if sType.Type() == SecTypeNone && cfg.UpstreamHandler != nil {
+		if err := cfg.UpstreamHandler(cfg, c); err != nil {
+			writeSecurityFailure33(c, err)
+			return err
+		}
 	}
 
-	if err := binary.Read(r, binary.BigEndian, &padding); err != nil {
+	if err := binary.Write(c, binary.BigEndian, uint32(sType.Type())); err != nil {
 		return err
 	}
-
-	for i := 0; i < int(numSrvCaps); i++ {
-		cap := TightCapability{}
-		cap.ReadFrom(r)
-		t.ServerMessageCaps = append(t.ServerMessageCaps, cap)
+	c.securityType = sType.Type()
+	c.authType = sType.Type()
+	if sType.Type() == SecTypeNone {
+		return nil
 	}
 
-	for i := 0; i < int(numCliCaps); i++ {
-		cap := TightCapability{}
-		cap.ReadFrom(r)
-		t.ClientMessageCaps = append(t.ClientMessageCaps, cap)
-	}
-
-	for i := 0; i < int(numEncCaps); i++ {
-		cap := TightCapability{}
-		cap.ReadFrom(r)
-		t.EncodingCaps = append(t.EncodingCaps, cap)
+	authErr := sType.Auth(c)
+	if authErr == nil && cfg.UpstreamHandler != nil {
+		authErr = cfg.UpstreamHandler(cfg, c)
 	}
-	return nil
+	return writeSecurityResult(c, authErr)
 }
 
-func (t *TightServerInit) WriteTo(w io.Writer) error {
-	if err := binary.Write(w, binary.BigEndian, uint16(len(t.ServerMessageCaps))); err != nil {
+func writeSecurityFailure33(c *ServerConn, reason error) error {
+	if err := binary.Write(c, binary.BigEndian, uint32(SecTypeUnknown)); err != nil {
 		return err
 	}
-	if err := binary.Write(w, binary.BigEndian, uint16(len(t.ClientMessageCaps))); err != nil {
-		return err
-	}
-	if err := binary.Write(w, binary.BigEndian, uint16(len(t.EncodingCaps))); err != nil {
-		return err
+	return writeFailureReason(c, reason.Error())
+}
+
+// writeSecurityResult sends the SecurityResult message, followed by the failure reason when there is one (3.8 only).
+// the returned error is authErr, or the error writing the message.
+func writeSecurityResult(c *ServerConn, authErr error) error {
+	var authCode uint32
+	if authErr != nil {
+		authCode = uint32(1)
 	}
 
-	if err := binary.Write(w, binary.BigEndian, uint16(0)); err != nil {
+	if err := binary.Write(c, binary.BigEndian, authCode); err != nil {
 		return err
 	}
 
-	for _, s := range t.ServerMessageCaps {
-		s.WriteTo(w)
-	}
-	for _, s := range t.ClientMessageCaps {
-		s.WriteTo(w)
-	}
-	for _, s := range t.EncodingCaps {
-		s.WriteTo(w)
+	if authErr != nil {
+		if c.Protocol() == ProtoVersion38 {
+			if err := writeFailureReason(c, authErr.Error()); err != nil {
+				return err
+			}
+		}
+		return authErr
 	}
+
 	return nil
 }
 
-type TightCapability struct {
-	code   uint32
-	vendor [4]byte
-	name   [8]byte
+func writeFailureReason(w io.Writer, reason string) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(reason))); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, []byte(reason))
 }
 
-func (t *TightCapability) WriteTo(w io.Writer) error {
-	if err := binary.Write(w, binary.BigEndian, t.code); err != nil {
-		return err
+func ServerServerInitHandler(cfg *ServerConfig, c *ServerConn) error {
+	srvInit := &common.ServerInit{
+		FBWidth:     c.Width(),
+		FBHeight:    c.Height(),
+		PixelFormat: *c.CurrentPixelFormat(),
+		NameLength:  uint32(len(cfg.DesktopName)),
+		NameText:    []byte(cfg.DesktopName),
 	}
-	if err := binary.Write(w, binary.BigEndian, t.vendor); err != nil {
+	if err := binary.Write(c, binary.BigEndian, srvInit.FBWidth); err != nil {
 		return err
 	}
-	if err := binary.Write(w, binary.BigEndian, t.name); err != nil {
+	if err := binary.Write(c, binary.BigEndian, srvInit.FBHeight); err != nil {
 		return err
 	}
-	return nil
-}
 
-func (t *TightCapability) ReadFrom(r io.Reader) error {
-
-	if err := binary.Read(r, binary.BigEndian, &t.code); err != nil {
+	if err := srvInit.PixelFormat.WriteTo(c); err != nil {
 		return err
 	}
-
-	if err := binary.Read(r, binary.BigEndian, &t.vendor); err != nil {
+	if err := binary.Write(c, binary.BigEndian, srvInit.NameLength); err != nil {
 		return err
 	}
 
-	if err := binary.Read(r, binary.BigEndian, &t.name); err != nil {
+	if err := binary.Write(c, binary.BigEndian, srvInit.NameText); err != nil {
 		return err
 	}
+
+	if c.securityType == SecTypeTight {
+		return tightServerInit(cfg).WriteTo(c)
+	}
 	return nil
 }
 
+// tightServerInit lists the interaction capabilities: only the security type part of Tight is supported,
+// no server or client message capabilities are advertised (so no file transfers), and the encodings are
+// the ones in the configuration.
+func tightServerInit(cfg *ServerConfig) *TightServerInit {
+	return &TightServerInit{EncodingCaps: common.TightEncodingCaps(cfg.Encodings)}
+}
+
+const (
+	StandardVendor  = common.StandardVendor
+	TridiaVncVendor = common.TridiaVncVendor
+	TightVncVendor  = common.TightVncVendor
+)
+
+// the Tight capability types live in common, as the client needs them too
+type TightServerInit = common.TightServerInit
+type TightCapability = common.TightCapability
+
 func ServerClientInitHandler(cfg *ServerConfig, c *ServerConn) error {
 	var shared uint8
 	if err := binary.Read(c, binary.BigEndian, &shared); err != nil {
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// handshakeClient runs a raw client handshake for the given version and returns what the server
+// sent after the version messages, until the connection is closed
+func handshakeClient(c net.Conn, version string, secType uint8) []byte {
+	defer c.Close()
+	serverVersion := make([]byte, ProtoVersionLength)
+	if _, err := io.ReadFull(c, serverVersion); err != nil {
+		return nil
+	}
+	c.Write([]byte(version))
+
+	var received []byte
+	if version != ProtoVersion33 {
+		var numTypes uint8
+		binary.Read(c, binary.BigEndian, &numTypes)
+		received = append(received, numTypes)
+		if numTypes > 0 {
+			types := make([]byte, numTypes)
+			io.ReadFull(c, types)
+			received = append(received, types...)
+			c.Write([]byte{secType})
+		}
+	}
+	rest, _ := io.ReadAll(c)
+	return append(received, rest...)
+}
+
+func TestServerSecurityHandlerVersions(t *testing.T) {
+	upstreamErr := errors.New("unreachable")
+	reason := append([]byte{0, 0, 0, byte(len(upstreamErr.Error()))}, upstreamErr.Error()...)
+
+	tests := []struct {
+		name     string
+		version  string
+		upstream error
+		expected []byte
+	}{
+		{"3.3 none", ProtoVersion33, nil, []byte{0, 0, 0, 1}},
+		{"3.3 failure", ProtoVersion33, upstreamErr, append([]byte{0, 0, 0, 0}, reason...)},
+		{"3.7 none", ProtoVersion37, nil, []byte{1, 1}},
+		{"3.7 failure", ProtoVersion37, upstreamErr, append([]byte{0}, reason...)},
+		{"3.8 none", ProtoVersion38, nil, []byte{1, 1, 0, 0, 0, 0}},
+		{"3.8 failure", ProtoVersion38, upstreamErr, append([]byte{1, 1, 0, 0, 0, 1}, reason...)},
+	}
+
+	for _, tt := range tests {
+		srv, cli := net.Pipe()
+		upstream := tt.upstream
+		cfg := &ServerConfig{
+			SecurityHandlers: []SecurityHandler{&ServerAuthNone{}},
+			ClientMessages:   DefaultClientMessages,
+			UpstreamHandler:  func(*ServerConfig, *ServerConn) error { return upstream },
+		}
+		conn, err := NewServerConn(srv, cfg, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		received := make(chan []byte, 1)
+		go func() { received <- handshakeClient(cli, tt.version, uint8(SecTypeNone)) }()
+
+		if err := ServerVersionHandler(cfg, conn); err != nil {
+			t.Fatalf("%s: version handshake: %v", tt.name, err)
+		}
+		if conn.Protocol() != tt.version {
+			t.Errorf("%s: agreed on %q", tt.name, conn.Protocol())
+		}
+		err = ServerSecurityHandler(cfg, conn)
+		if (err != nil) != (tt.upstream != nil) {
+			t.Errorf("%s: unexpected result %v", tt.name, err)
+		}
+		srv.Close()
+
+		if got := <-received; string(got) != string(tt.expected) {
+			t.Errorf("%s: client received %v, want %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestServerSecurityHandler33VncAuth(t *testing.T) {
+	srv, cli := net.Pipe()
+	upstreamCalled := false
+	cfg := &ServerConfig{
+		SecurityHandlers: []SecurityHandler{&ServerAuthVNC{Pass: "secret"}},
+		ClientMessages:   DefaultClientMessages,
+		UpstreamHandler: func(*ServerConfig, *ServerConn) error {
+			upstreamCalled = true
+			return errors.New("unreachable")
+		},
+	}
+	conn, err := NewServerConn(srv, cfg, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan []byte, 1)
+	go func() {
+		defer cli.Close()
+		io.ReadFull(cli, make([]byte, ProtoVersionLength))
+		cli.Write([]byte(ProtoVersion33))
+		// the security type & the challenge, answered with a wrong response
+		secType := make([]byte, 4)
+		io.ReadFull(cli, secType)
+		io.ReadFull(cli, make([]byte, 16))
+		cli.Write(make([]byte, 16))
+		rest, _ := io.ReadAll(cli)
+		received <- append(secType, rest...)
+	}()
+
+	if err := ServerVersionHandler(cfg, conn); err != nil {
+		t.Fatalf("version handshake: %v", err)
+	}
+	if err := ServerSecurityHandler(cfg, conn); err == nil {
+		t.Errorf("wrong password accepted")
+	}
+	srv.Close()
+
+	// the vnc server isn't connected for unauthenticated vnc-clients, and they get no reason
+	if got, want := <-received, []byte{0, 0, 0, byte(SecTypeVNC), 0, 0, 0, 1}; string(got) != string(want) {
+		t.Errorf("client received %v, want %v", got, want)
+	}
+	if upstreamCalled {
+		t.Errorf("the upstream handler ran before the vnc-client authenticated")
+	}
+}
@@ -4,10 +4,19 @@ import (
 	"bytes"
 	"crypto/des"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
 
+	"github.com/exoscale/vncproxy/auth"
 	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/logger"
 )
 
 type SecurityType uint8
@@ -16,7 +25,12 @@ const (
 	SecTypeUnknown  = SecurityType(0)
 	SecTypeNone     = SecurityType(1)
 	SecTypeVNC      = SecurityType(2)
+	SecTypeRA2      = SecurityType(common.SecTypeRA2)
+	SecTypeRA2ne    = SecurityType(common.SecTypeRA2ne)
+	SecTypeTight    = SecurityType(16)
 	SecTypeVeNCrypt = SecurityType(19)
+	SecTypeRA256    = SecurityType(common.SecTypeRA256)
+	SecTypeRA256ne  = SecurityType(common.SecTypeRA256ne)
 )
 
 type SecuritySubType uint32
@@ -71,6 +85,11 @@ func (*ServerAuthNone) SubType() SecuritySubType {
 // ServerAuthVNC is the standard password authentication. See 7.2.2.
 type ServerAuthVNC struct {
 	Pass string
+
+	// Totp, when set, makes the client type Pass followed by a TOTP code of TotpUser.
+	// VNC auth only uses the first 8 password chars, so Pass must leave room for the code.
+	Totp     *auth.TotpVerifier
+	TotpUser string
 }
 
 func (*ServerAuthVNC) Type() SecurityType {
@@ -84,9 +103,9 @@ func (*ServerAuthVNC) SubType() SecuritySubType {
 const AUTH_FAIL = "Authentication Failure"
 
 func (auth *ServerAuthVNC) Auth(c common.IServerConn) error {
-	buf := make([]byte, 8+len([]byte(AUTH_FAIL)))
-	rand.Read(buf[:16]) // Random 16 bytes in buf
-	sndsz, err := c.Write(buf[:16])
+	challenge := make([]byte, 16)
+	rand.Read(challenge) // Random 16 bytes
+	sndsz, err := c.Write(challenge)
 	if err != nil {
 		log.Printf("Error sending challenge to client: %s\n", err.Error())
 		return errors.New("Error sending challenge to client:" + err.Error())
@@ -95,28 +114,472 @@ func (auth *ServerAuthVNC) Auth(c common.IServerConn) error {
 		log.Printf("The full 16 byte challenge was not sent!\n")
 		return errors.New("The full 16 byte challenge was not sent")
 	}
-	buf2 := make([]byte, 16)
-	_, err = c.Read(buf2)
+	response := make([]byte, 16)
+	_, err = io.ReadFull(c, response)
 	if err != nil {
 		log.Printf("The authentication result was not read: %s\n", err.Error())
 		return errors.New("The authentication result was not read" + err.Error())
 	}
-	AuthText := auth.Pass
-	bk, err := des.NewCipher([]byte(fixDesKey(AuthText)))
+	ok, err := auth.checkResponse(challenge, response)
+	if err != nil {
+		return err
+	}
+	if !ok { // If the result does not decrypt correctly to what we sent then a problem
+		return errors.New(AUTH_FAIL)
+	}
+	return nil
+}
+
+// checkResponse compares the client's response with the challenge encrypted with the password,
+// or with password+code for every TOTP code in the accepted window
+func (a *ServerAuthVNC) checkResponse(challenge, response []byte) (bool, error) {
+	if a.Totp != nil {
+		candidates, err := a.Totp.Candidates(a.TotpUser)
+		if err == nil {
+			if len(a.Pass)+a.Totp.CodeLength() > 8 {
+				logger.Errorf("ServerAuthVNC: password is too long to carry a %d digit TOTP code", a.Totp.CodeLength())
+				return false, nil
+			}
+			for _, candidate := range candidates {
+				expected, err := vncAuthResponse(a.Pass+candidate.Code, challenge)
+				if err != nil {
+					return false, err
+				}
+				if bytes.Equal(expected, response) {
+					if err := a.Totp.Accept(a.TotpUser, candidate.Counter); err != nil {
+						logger.Warnf("ServerAuthVNC: rejecting TOTP code for %s: %v", a.TotpUser, err)
+						return false, nil
+					}
+					return true, nil
+				}
+			}
+			logger.Warnf("ServerAuthVNC: wrong password or TOTP code for %s", a.TotpUser)
+			return false, nil
+		}
+		if err != auth.ErrTotpNotEnrolled || a.Totp.Required {
+			logger.Warnf("ServerAuthVNC: TOTP check failed for %s: %v", a.TotpUser, err)
+			return false, nil
+		}
+	}
+
+	expected, err := vncAuthResponse(a.Pass, challenge)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(expected, response), nil
+}
+
+func vncAuthResponse(password string, challenge []byte) ([]byte, error) {
+	bk, err := des.NewCipher([]byte(fixDesKey(password)))
 	if err != nil {
 		log.Printf("Error generating authentication cipher: %s\n", err.Error())
-		return errors.New("Error generating authentication cipher")
-	}
-	buf3 := make([]byte, 16)
-	bk.Encrypt(buf3, buf)               //Encrypt first 8 bytes
-	bk.Encrypt(buf3[8:], buf[8:])       // Encrypt second 8 bytes
-	if bytes.Compare(buf2, buf3) != 0 { // If the result does not decrypt correctly to what we sent then a problem
-		SetUint32(buf, 0, 1)
-		SetUint32(buf, 4, uint32(len([]byte(AUTH_FAIL))))
-		copy(buf[8:], []byte(AUTH_FAIL))
-		c.Write(buf)
-		return errors.New("Authentication failed")
+		return nil, errors.New("Error generating authentication cipher")
+	}
+	response := make([]byte, 16)
+	bk.Encrypt(response, challenge)         //Encrypt first 8 bytes
+	bk.Encrypt(response[8:], challenge[8:]) // Encrypt second 8 bytes
+	return response, nil
+}
+
+// ServerAuthVNCRelay is VNC authentication relayed to an upstream server, so the
+// password is never known here: the challenge is taken from the upstream server,
+// and the client's response is handed back to it for checking.
+type ServerAuthVNCRelay struct {
+	// Upstream starts the upstream security handshake for the connection and returns the
+	// upstream challenge, respond sends the client's response and returns the upstream result.
+	// respond is called with a nil response when the handshake is aborted on this side.
+	Upstream func(c common.IServerConn) (challenge []byte, respond func(response []byte) error, err error)
+}
+
+func (*ServerAuthVNCRelay) Type() SecurityType {
+	return SecTypeVNC
+}
+
+func (*ServerAuthVNCRelay) SubType() SecuritySubType {
+	return SecSubTypeUnknown
+}
+
+func (auth *ServerAuthVNCRelay) Auth(c common.IServerConn) error {
+	challenge, respond, err := auth.Upstream(c)
+	if err != nil {
+		return err
+	}
+	if len(challenge) != 16 {
+		respond(nil)
+		return errors.New("bad upstream challenge length")
+	}
+
+	if _, err := c.Write(challenge); err != nil {
+		respond(nil)
+		return errors.New("Error sending challenge to client:" + err.Error())
+	}
+
+	response := make([]byte, 16)
+	if _, err := io.ReadFull(c, response); err != nil {
+		respond(nil)
+		return errors.New("The authentication result was not read" + err.Error())
+	}
+
+	return respond(response)
+}
+
+// ServerAuthVeNCrypt is the VeNCrypt (version 0.2) authentication with the Plain
+// and TLSPlain sub types, checking username & password against Users.
+// When Totp is set, enrolled users append their TOTP code to the password.
+type ServerAuthVeNCrypt struct {
+	Users     auth.UserStore
+	Totp      *auth.TotpVerifier
+	TLSConfig *tls.Config // nil = only Plain is offered
+}
+
+const maxVeNCryptCredentialLen = 1024
+
+func (*ServerAuthVeNCrypt) Type() SecurityType {
+	return SecTypeVeNCrypt
+}
+
+func (a *ServerAuthVeNCrypt) SubType() SecuritySubType {
+	if a.TLSConfig != nil {
+		return SecSubTypeVeNCrypt02TLSPlain
+	}
+	return SecSubTypeVeNCrypt02Plain
+}
+
+func (a *ServerAuthVeNCrypt) subTypes() []SecuritySubType {
+	if a.TLSConfig != nil {
+		return []SecuritySubType{SecSubTypeVeNCrypt02TLSPlain, SecSubTypeVeNCrypt02Plain}
+	}
+	return []SecuritySubType{SecSubTypeVeNCrypt02Plain}
+}
+
+func (a *ServerAuthVeNCrypt) Auth(c common.IServerConn) error {
+	if err := binary.Write(c, binary.BigEndian, []uint8{0, 2}); err != nil {
+		return err
+	}
+	var version [2]uint8
+	if err := binary.Read(c, binary.BigEndian, &version); err != nil {
+		return err
+	}
+	if version[0] != 0 || version[1] != 2 {
+		binary.Write(c, binary.BigEndian, uint8(1))
+		return fmt.Errorf("unsupported VeNCrypt version %d.%d", version[0], version[1])
+	}
+	if err := binary.Write(c, binary.BigEndian, uint8(0)); err != nil {
+		return err
+	}
+
+	subTypes := a.subTypes()
+	if err := binary.Write(c, binary.BigEndian, uint8(len(subTypes))); err != nil {
+		return err
+	}
+	if err := binary.Write(c, binary.BigEndian, subTypes); err != nil {
+		return err
+	}
+
+	var subType SecuritySubType
+	if err := binary.Read(c, binary.BigEndian, &subType); err != nil {
+		return err
+	}
+
+	switch subType {
+	case SecSubTypeVeNCrypt02TLSPlain:
+		if a.TLSConfig == nil {
+			return fmt.Errorf("VeNCrypt sub type %d not offered", subType)
+		}
+		if err := binary.Write(c, binary.BigEndian, uint8(1)); err != nil {
+			return err
+		}
+		if err := startTLS(c, a.TLSConfig); err != nil {
+			return err
+		}
+	case SecSubTypeVeNCrypt02Plain:
+	default:
+		return fmt.Errorf("VeNCrypt sub type %d not implemented", subType)
+	}
+
+	var userLen, passLen uint32
+	if err := binary.Read(c, binary.BigEndian, &userLen); err != nil {
+		return err
+	}
+	if err := binary.Read(c, binary.BigEndian, &passLen); err != nil {
+		return err
+	}
+	if userLen > maxVeNCryptCredentialLen || passLen > maxVeNCryptCredentialLen {
+		return errors.New("VeNCrypt credentials too long")
+	}
+	user := make([]byte, userLen)
+	if err := binary.Read(c, binary.BigEndian, &user); err != nil {
+		return err
+	}
+	pass := make([]byte, passLen)
+	if err := binary.Read(c, binary.BigEndian, &pass); err != nil {
+		return err
+	}
+
+	if !a.checkCredentials(string(user), string(pass)) {
+		return errors.New(AUTH_FAIL)
+	}
+	logger.Infof("ServerAuthVeNCrypt: user %s authenticated", user)
+	setUser(c, string(user))
+	return nil
+}
+
+func (a *ServerAuthVeNCrypt) checkCredentials(user, password string) bool {
+	return checkUserCredentials("ServerAuthVeNCrypt", a.Users, a.Totp, user, password)
+}
+
+// setUser keeps the authenticated username on the connection
+func setUser(c common.IServerConn, user string) {
+	if conn, ok := c.(*ServerConn); ok {
+		conn.User = user
+	}
+}
+
+// checkUserCredentials checks the password, and the TOTP code appended to it when totp is set and the user is enrolled
+func checkUserCredentials(name string, users auth.UserStore, totp *auth.TotpVerifier, user, password string) bool {
+	if totp != nil {
+		secret, err := users.TotpSecret(user)
+		if err != nil {
+			logger.Errorf("%s: can't get TOTP secret for %s: %v", name, user, err)
+			return false
+		}
+		if secret != nil {
+			pass, code, err := totp.SplitCode(password)
+			if err != nil || !users.CheckPassword(user, pass) {
+				logger.Warnf("%s: wrong password or missing TOTP code for %s", name, user)
+				return false
+			}
+			if err := totp.Check(user, code); err != nil {
+				logger.Warnf("%s: rejecting TOTP code for %s: %v", name, user, err)
+				return false
+			}
+			return true
+		}
+		if totp.Required {
+			logger.Warnf("%s: user %s has no enrolled TOTP secret", name, user)
+			return false
+		}
+	}
+
+	if !users.CheckPassword(user, password) {
+		logger.Warnf("%s: wrong password for %s", name, user)
+		return false
+	}
+	return true
+}
+
+// ServerAuthRSAAES is the RSA-AES authentication (types 5, 6, 129 & 133): the client gets the
+// server's RSA key, both sides exchange encrypted randoms giving the AES-EAX session keys, and the
+// credentials are sent encrypted. Only the security handshake is encrypted with the "ne" types.
+// With Users the client sends a username & password, otherwise only a password checked against Password.
+type ServerAuthRSAAES struct {
+	SecType  SecurityType
+	Key      *rsa.PrivateKey
+	Users    auth.UserStore
+	Totp     *auth.TotpVerifier
+	Password string
+}
+
+func (a *ServerAuthRSAAES) Type() SecurityType {
+	return a.SecType
+}
+
+func (*ServerAuthRSAAES) SubType() SecuritySubType {
+	return SecSubTypeUnknown
+}
+
+func (a *ServerAuthRSAAES) Auth(c common.IServerConn) error {
+	sconn, ok := c.(*ServerConn)
+	if !ok {
+		return errors.New("RSA-AES is only supported on a *ServerConn")
+	}
+	params, err := common.RsaAesParamsFor(uint8(a.SecType))
+	if err != nil {
+		return err
+	}
+	if a.Users == nil && a.Password == "" {
+		return errors.New("RSA-AES: no users or password configured")
+	}
+
+	serverKeyBytes := common.RsaPublicKeyBytes(&a.Key.PublicKey)
+	if _, err := c.Write(serverKeyBytes); err != nil {
+		return err
+	}
+	clientKey, clientKeyBytes, err := common.ReadRsaPublicKey(c)
+	if err != nil {
+		return err
+	}
+
+	serverRandom := make([]byte, params.KeySize)
+	if _, err := rand.Read(serverRandom); err != nil {
+		return err
+	}
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, clientKey, serverRandom)
+	if err != nil {
+		return err
+	}
+	if err := common.WriteRsaAesRandom(c, encrypted); err != nil {
+		return err
+	}
+	clientRandom, err := common.ReadRsaAesRandom(c, a.Key)
+	if err != nil {
+		return err
+	}
+	if len(clientRandom) != params.KeySize {
+		return fmt.Errorf("RSA-AES: bad client random length: %d", len(clientRandom))
+	}
+
+	plain := sconn.Conn()
+	stream, err := common.NewAesEaxStream(plain, params.SessionKey(serverRandom, clientRandom), params.SessionKey(clientRandom, serverRandom))
+	if err != nil {
+		return err
+	}
+	sconn.SetConn(stream)
+	if !params.Encrypted {
+		defer sconn.SetConn(plain)
+	}
+
+	if _, err := stream.Write(params.KeyHash(serverKeyBytes, clientKeyBytes)); err != nil {
+		return err
+	}
+	clientHash := make([]byte, params.NewHash().Size())
+	if _, err := io.ReadFull(stream, clientHash); err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(clientHash, params.KeyHash(clientKeyBytes, serverKeyBytes)) != 1 {
+		return errors.New("RSA-AES: public key hash mismatch")
+	}
+
+	subType := uint8(common.RsaAesSubTypePass)
+	if a.Users != nil {
+		subType = common.RsaAesSubTypeUserPass
+	}
+	if err := binary.Write(stream, binary.BigEndian, subType); err != nil {
+		return err
+	}
+
+	user, err := readRsaAesCredential(stream)
+	if err != nil {
+		return err
+	}
+	password, err := readRsaAesCredential(stream)
+	if err != nil {
+		return err
+	}
+
+	if a.Users != nil {
+		if !checkUserCredentials("ServerAuthRSAAES", a.Users, a.Totp, user, password) {
+			return errors.New(AUTH_FAIL)
+		}
+		logger.Infof("ServerAuthRSAAES: user %s authenticated", user)
+		setUser(c, user)
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(password), []byte(a.Password)) != 1 {
+		return errors.New(AUTH_FAIL)
+	}
+	return nil
+}
+
+// readRsaAesCredential reads a string preceded by its u8 length
+func readRsaAesCredential(r io.Reader) (string, error) {
+	var length uint8
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// ServerAuthTight is the TightVNC security type: no tunneling is offered, and the client picks
+// one of AuthTypes through the Tight auth capability list. The connection then gets the Tight
+// interaction capabilities after ServerInit.
+type ServerAuthTight struct {
+	AuthTypes []SecurityHandler // None, VNC and VeNCrypt handlers can be offered
+}
+
+func (*ServerAuthTight) Type() SecurityType {
+	return SecTypeTight
+}
+
+func (*ServerAuthTight) SubType() SecuritySubType {
+	return SecSubTypeUnknown
+}
+
+func tightAuthCapability(t SecurityType) (common.TightCapability, bool) {
+	switch t {
+	case SecTypeNone:
+		return common.TightCapNoAuth, true
+	case SecTypeVNC:
+		return common.TightCapVncAuth, true
+	case SecTypeVeNCrypt:
+		return common.TightCapVeNCrypt, true
+	}
+	return common.TightCapability{}, false
+}
+
+func (a *ServerAuthTight) Auth(c common.IServerConn) error {
+	sconn, ok := c.(*ServerConn)
+	if !ok {
+		return errors.New("Tight security is only supported on a *ServerConn")
+	}
+
+	// no tunneling
+	if err := common.WriteTightCapabilities(c, nil); err != nil {
+		return err
+	}
+
+	var caps []common.TightCapability
+	handlers := make(map[uint32]SecurityHandler)
+	for _, h := range a.AuthTypes {
+		if cap, ok := tightAuthCapability(h.Type()); ok {
+			caps = append(caps, cap)
+			handlers[cap.Code] = h
+		}
+	}
+	if len(caps) == 0 {
+		return errors.New("no auth type available for Tight security")
+	}
+
+	// a lone None is offered as an empty list (the client doesn't have to choose)
+	if len(caps) == 1 && caps[0].Code == common.TightCapNoAuth.Code {
+		sconn.authType = SecTypeNone
+		return common.WriteTightCapabilities(c, nil)
+	}
+
+	if err := common.WriteTightCapabilities(c, caps); err != nil {
+		return err
+	}
+	var code uint32
+	if err := binary.Read(c, binary.BigEndian, &code); err != nil {
+		return err
+	}
+	h, ok := handlers[code]
+	if !ok {
+		return fmt.Errorf("Tight auth type %d not offered", code)
+	}
+	sconn.authType = h.Type()
+	return h.Auth(c)
+}
+
+// startTLS upgrades the connection to TLS, all further reads & writes go through the TLS session.
+func startTLS(c common.IServerConn, cfg *tls.Config) error {
+	sconn, ok := c.(*ServerConn)
+	if !ok {
+		return errors.New("TLS is only supported on a *ServerConn")
+	}
+	nc, ok := sconn.Conn().(net.Conn)
+	if !ok {
+		return errors.New("TLS is not supported on this connection type")
+	}
+	tlsConn := tls.Server(nc, cfg)
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("TLS handshake failed: %v", err)
 	}
+	sconn.SetConn(tlsConn)
 	return nil
 }
 
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"crypto/des"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base32"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/exoscale/vncproxy/auth"
+	"github.com/exoscale/vncproxy/client"
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/encodings"
+)
+
+var testTotpSecret = []byte("12345678901234567890")
+
+func newTestUsers(t *testing.T) *auth.StaticUserStore {
+	users, err := auth.NewStaticUserStore([]auth.UserEntry{
+		{Username: "alice", Password: "secret", TotpSecret: base32.StdEncoding.EncodeToString(testTotpSecret)},
+		{Username: "bob", Password: "hunter2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return users
+}
+
+func currentTotpCode() string {
+	return auth.TotpCode(testTotpSecret, uint64(time.Now().Unix()/30), 6)
+}
+
+// runAuth runs handler.Auth on one side of a pipe and the client function on the other.
+func runAuth(t *testing.T, handler SecurityHandler, clientFn func(c net.Conn)) error {
+	srv, cli := net.Pipe()
+	defer srv.Close()
+	defer cli.Close()
+
+	conn, err := NewServerConn(srv, &ServerConfig{ClientMessages: DefaultClientMessages}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go clientFn(cli)
+	return handler.Auth(conn)
+}
+
+func vncClient(password string) func(c net.Conn) {
+	return func(c net.Conn) {
+		challenge := make([]byte, 16)
+		if _, err := io.ReadFull(c, challenge); err != nil {
+			return
+		}
+		bk, _ := des.NewCipher(fixDesKey(password))
+		response := make([]byte, 16)
+		bk.Encrypt(response, challenge)
+		bk.Encrypt(response[8:], challenge[8:])
+		c.Write(response)
+		io.Copy(io.Discard, c)
+	}
+}
+
+func TestServerAuthVNCTotp(t *testing.T) {
+	newHandler := func() *ServerAuthVNC {
+		return &ServerAuthVNC{
+			Pass:     "ab",
+			Totp:     &auth.TotpVerifier{Users: newTestUsers(t), Skew: 1},
+			TotpUser: "alice",
+		}
+	}
+
+	handler := newHandler()
+	code := currentTotpCode()
+	if err := runAuth(t, handler, vncClient("ab"+code)); err != nil {
+		t.Fatalf("valid password+code rejected: %v", err)
+	}
+	if err := runAuth(t, handler, vncClient("ab"+code)); err == nil {
+		t.Fatal("replayed code accepted")
+	}
+	if err := runAuth(t, newHandler(), vncClient("ab")); err == nil {
+		t.Fatal("password without code accepted")
+	}
+}
+
+func veNCryptPlainClient(user, password string) func(c net.Conn) {
+	return func(c net.Conn) {
+		var version [2]uint8
+		binary.Read(c, binary.BigEndian, &version)
+		binary.Write(c, binary.BigEndian, version)
+		var ack, numTypes uint8
+		binary.Read(c, binary.BigEndian, &ack)
+		binary.Read(c, binary.BigEndian, &numTypes)
+		subTypes := make([]uint32, numTypes)
+		binary.Read(c, binary.BigEndian, &subTypes)
+		binary.Write(c, binary.BigEndian, uint32(SecSubTypeVeNCrypt02Plain))
+		binary.Write(c, binary.BigEndian, uint32(len(user)))
+		binary.Write(c, binary.BigEndian, uint32(len(password)))
+		c.Write([]byte(user))
+		c.Write([]byte(password))
+	}
+}
+
+func TestServerAuthVeNCryptPlain(t *testing.T) {
+	users := newTestUsers(t)
+	noTotp := &ServerAuthVeNCrypt{Users: users}
+	if err := runAuth(t, noTotp, veNCryptPlainClient("bob", "hunter2")); err != nil {
+		t.Fatalf("valid credentials rejected: %v", err)
+	}
+	if err := runAuth(t, noTotp, veNCryptPlainClient("bob", "hunter3")); err == nil {
+		t.Fatal("wrong password accepted")
+	}
+
+	withTotp := &ServerAuthVeNCrypt{Users: users, Totp: &auth.TotpVerifier{Users: users, Required: true, Skew: 1}}
+	if err := runAuth(t, withTotp, veNCryptPlainClient("alice", "secret"+currentTotpCode())); err != nil {
+		t.Fatalf("valid password+code rejected: %v", err)
+	}
+	if err := runAuth(t, withTotp, veNCryptPlainClient("alice", "secret")); err == nil {
+		t.Fatal("password without code accepted")
+	}
+	if err := runAuth(t, withTotp, veNCryptPlainClient("bob", "hunter2")); err == nil {
+		t.Fatal("not enrolled user accepted while TOTP is required")
+	}
+}
+
+func TestServerAuthTight(t *testing.T) {
+	srv, cli := net.Pipe()
+	defer srv.Close()
+	defer cli.Close()
+
+	cfg := &ServerConfig{
+		SecurityHandlers: []SecurityHandler{&ServerAuthTight{AuthTypes: []SecurityHandler{&ServerAuthVNC{Pass: "pass"}}}},
+		Encodings:        []common.IEncoding{&encodings.RawEncoding{}, &encodings.TightEncoding{}, &encodings.CopyRectEncoding{}},
+		PixelFormat:      common.NewPixelFormat(32),
+		ClientMessages:   DefaultClientMessages,
+		DesktopName:      []byte("tight"),
+		Width:            640,
+		Height:           480,
+	}
+	conn, err := NewServerConn(srv, cfg, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		for _, handler := range []ServerHandler{ServerVersionHandler, ServerSecurityHandler, ServerClientInitHandler, ServerServerInitHandler} {
+			if err := handler(cfg, conn); err != nil {
+				serverErr <- err
+				return
+			}
+		}
+		serverErr <- nil
+	}()
+
+	cconn, _ := client.NewClientConn(cli, &client.ClientConfig{
+		Auth: []client.ClientAuth{&client.ClientAuthTight{Auth: []client.ClientAuth{&client.PasswordAuth{Password: "pass"}}}},
+	})
+	if err := cconn.Connect(); err != nil {
+		t.Fatalf("client handshake failed: %v", err)
+	}
+	if err := <-serverErr; err != nil {
+		t.Fatalf("server handshake failed: %v", err)
+	}
+
+	if cconn.TightCaps == nil {
+		t.Fatal("Tight capabilities were not received")
+	}
+	if len(cconn.TightCaps.EncodingCaps) != 2 {
+		t.Errorf("expected the Tight & CopyRect encoding capabilities, got %v", cconn.TightCaps.EncodingCaps)
+	}
+	if conn.authType != SecTypeVNC {
+		t.Errorf("expected VNC auth inside Tight, got %d", conn.authType)
+	}
+}
+
+func TestServerAuthRSAAES(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := newTestUsers(t)
+
+	tests := []struct {
+		secType  SecurityType
+		password string
+		isErr    bool
+	}{
+		{SecTypeRA2, "hunter2", false},
+		{SecTypeRA2ne, "hunter2", false},
+		{SecTypeRA256, "hunter2", false},
+		{SecTypeRA256ne, "hunter2", false},
+		{SecTypeRA256, "hunter3", true},
+	}
+
+	for _, tt := range tests {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := &ServerConfig{
+			SecurityHandlers: []SecurityHandler{&ServerAuthRSAAES{SecType: tt.secType, Key: key, Users: users}},
+			PixelFormat:      common.NewPixelFormat(32),
+			ClientMessages:   DefaultClientMessages,
+			DesktopName:      []byte("encrypted"),
+			Width:            640,
+			Height:           480,
+		}
+		go func() {
+			nc, err := ln.Accept()
+			ln.Close()
+			if err != nil {
+				return
+			}
+			defer nc.Close()
+			conn, _ := NewServerConn(nc, cfg, "test")
+			for _, handler := range []ServerHandler{ServerVersionHandler, ServerSecurityHandler, ServerClientInitHandler, ServerServerInitHandler} {
+				if handler(cfg, conn) != nil {
+					return
+				}
+			}
+			// wait for the client to go away
+			conn.Read(make([]byte, 1))
+		}()
+
+		nc, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		cconn, _ := client.NewClientConn(nc, &client.ClientConfig{
+			Auth: []client.ClientAuth{&client.RsaAesAuth{Type: uint8(tt.secType), Username: "bob", Password: tt.password, KeyBits: 1024}},
+		})
+		err = cconn.Connect()
+		if tt.isErr {
+			if err == nil {
+				t.Errorf("type %d: wrong password accepted", tt.secType)
+			}
+		} else if err != nil {
+			t.Errorf("type %d: handshake failed: %v", tt.secType, err)
+		} else if cconn.DesktopName != "encrypted" {
+			t.Errorf("type %d: bad ServerInit: %q", tt.secType, cconn.DesktopName)
+		}
+		cconn.Close()
+	}
+}
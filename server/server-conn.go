@@ -4,8 +4,10 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"net/url"
 	"sync"
 
+	"github.com/exoscale/vncproxy/auth"
 	"github.com/exoscale/vncproxy/common"
 	"github.com/exoscale/vncproxy/logger"
 )
@@ -44,6 +46,18 @@ type ServerConn struct {
 
 	SessionId string
 
+	// Claims from the JWT presented by the client, nil if no token was required
+	Claims *auth.Claims
+	// Query holds the parameters of the websocket url, nil for tcp clients
+	Query url.Values
+	// User is the username the client authenticated with (VeNCrypt or RSA-AES), empty otherwise
+	User string
+
+	// security type picked by the client, and the authentication actually done
+	// (they differ when the security type wraps another one, like Tight)
+	securityType SecurityType
+	authType     SecurityType
+
 	quit chan struct{}
 }
 
@@ -69,6 +83,11 @@ func (c *ServerConn) Conn() io.ReadWriter {
 	return c.c
 }
 
+// SetConn replaces the underlying connection, used when a security type wraps the stream (TLS, encryption)
+func (c *ServerConn) SetConn(rw io.ReadWriter) {
+	c.c = rw
+}
+
 func (c *ServerConn) SetEncodings(encs []common.EncodingType) error {
 	encodings := make(map[int32]common.IEncoding)
 	for _, enc := range c.cfg.Encodings {
@@ -1,12 +1,13 @@
 package server
 
 import (
-	"fmt"
 	"io"
 	"net"
 
+	"github.com/exoscale/vncproxy/auth"
 	"github.com/exoscale/vncproxy/common"
 	"github.com/exoscale/vncproxy/logger"
+	"golang.org/x/net/websocket"
 )
 
 var DefaultClientMessages = []common.ClientMessage{
@@ -39,13 +40,26 @@ type ServerConfig struct {
 	Width            uint16
 	UseDummySession  bool
 
+	// JwtVerifier, when set, makes the websocket listener require a valid JWT
+	// on the upgrade request, its claims are available in ServerConn.Claims
+	JwtVerifier *auth.JwtVerifier
+	// JwtSessionRequired refuses the tokens without a session claim, when the websocket path picks among
+	// several sessions: only the claim decides the session then
+	JwtSessionRequired bool
+
 	//handler to allow for registering for messages, this can't be a channel
 	//because of the websockets handler function which will kill the connection on exit if conn.handle() is run on another thread
 	NewConnHandler ServerHandler
+
+	// UpstreamHandler (optional) is run during the security handshake, once the client is authenticated but
+	// before the security result is sent, so its error (e.g. the vnc server can't be reached) reaches the client
+	// as the RFB failure reason. RFB 3.3 can't report a reason after authentication, so there it is run before
+	// the security type is sent.
+	UpstreamHandler ServerHandler
 }
 
-func wsHandlerFunc(ws io.ReadWriter, cfg *ServerConfig, sessionId string) {
-	err := attachNewServerConn(ws, cfg, sessionId)
+func wsHandlerFunc(ws io.ReadWriter, cfg *ServerConfig, sessionId string, claims *auth.Claims) {
+	err := attachNewServerConn(ws, cfg, sessionId, claims)
 	if err != nil {
 		logger.Errorf("error attaching new connection: %s", err)
 	}
@@ -67,26 +81,38 @@ func TcpServe(url string, cfg *ServerConfig) error {
 		if err != nil {
 			return err
 		}
-		go attachNewServerConn(c, cfg, "dummySession")
+		go attachNewServerConn(c, cfg, "dummySession", nil)
 	}
 	return nil
 }
 
-func attachNewServerConn(c io.ReadWriter, cfg *ServerConfig, sessionId string) error {
+func attachNewServerConn(c io.ReadWriter, cfg *ServerConfig, sessionId string, claims *auth.Claims) error {
 
 	conn, err := NewServerConn(c, cfg, sessionId)
 	if err != nil {
 		return err
 	}
+	conn.Claims = claims
+	if ws, ok := c.(*websocket.Conn); ok {
+		conn.Query = ws.Request().URL.Query()
+	}
 
 	if err := ServerVersionHandler(cfg, conn); err != nil {
-		fmt.Errorf("err: %v\n", err)
+		logger.Errorf("err: %v\n", err)
 		conn.Close()
 		return err
 	}
 
-	if err := ServerSecurityHandler(cfg, conn); err != nil {
+	//listeners may have been registered during the security handshake (UpstreamHandler), let them know we are done
+	closeConn := func() {
+		conn.Listeners.Consume(&common.RfbSegment{
+			SegmentType: common.SegmentConnectionClosed,
+		})
 		conn.Close()
+	}
+
+	if err := ServerSecurityHandler(cfg, conn); err != nil {
+		closeConn()
 		return err
 	}
 
@@ -94,17 +120,17 @@ func attachNewServerConn(c io.ReadWriter, cfg *ServerConfig, sessionId string) e
 	//this is done before the init sequence to allow listening to server-init messages (and maybe even interception in the future)
 	err = cfg.NewConnHandler(cfg, conn)
 	if err != nil {
-		conn.Close()
+		closeConn()
 		return err
 	}
 
 	if err := ServerClientInitHandler(cfg, conn); err != nil {
-		conn.Close()
+		closeConn()
 		return err
 	}
 
 	if err := ServerServerInitHandler(cfg, conn); err != nil {
-		conn.Close()
+		closeConn()
 		return err
 	}
 
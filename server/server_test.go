@@ -1,40 +1,71 @@
 package server
 
 import (
-	"log"
+	"context"
+	"net"
 	"testing"
+	"time"
 
+	"github.com/exoscale/vncproxy/automation"
 	"github.com/exoscale/vncproxy/common"
 	"github.com/exoscale/vncproxy/encodings"
 )
 
-func newServerConnHandler(cfg *ServerConfig, conn *ServerConn) error {
+// messageCollector passes the parsed client messages to a channel
+type messageCollector chan common.ClientMessage
 
+func (m messageCollector) Consume(seg *common.RfbSegment) error {
+	if seg.SegmentType == common.SegmentFullyParsedClientMessage {
+		m <- seg.Message.(common.ClientMessage)
+	}
 	return nil
 }
 
 func TestServer(t *testing.T) {
-	chClient := make(chan common.ServerMessage)
+	chClient := make(messageCollector, 100)
 
 	cfg := &ServerConfig{
-		SecurityHandlers: []SecurityHandler{&ServerAuthVNC{"Ch_#!T@8"}},
+		SecurityHandlers: []SecurityHandler{&ServerAuthVNC{Pass: "Ch_#!T@8"}},
 		Encodings:        []common.IEncoding{&encodings.RawEncoding{}, &encodings.TightEncoding{}, &encodings.CopyRectEncoding{}},
 		PixelFormat:      common.NewPixelFormat(32),
 		ClientMessages:   DefaultClientMessages,
 		DesktopName:      []byte("workDesk"),
 		Height:           uint16(768),
 		Width:            uint16(1024),
-		NewConnHandler:   newServerConnHandler,
+		NewConnHandler: func(cfg *ServerConfig, conn *ServerConn) error {
+			conn.Listeners.AddListener(chClient)
+			return nil
+		},
+	}
+	cli, srv := net.Pipe()
+	go attachNewServerConn(srv, cfg, "dummySession", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := automation.New(ctx, cli, &automation.Options{Password: "Ch_#!T@8"})
+	if err != nil {
+		t.Fatalf("connecting: %s", err)
 	}
-	url := "http://localhost:8091/"
-	go WsServe(url, cfg)
-	go TcpServe(":5903", cfg)
+	defer c.Close()
+	if w, h := c.Size(); w != 1024 || h != 768 {
+		t.Errorf("unexpected size %dx%d", w, h)
+	}
+	if err := c.PressChord(ctx, "Return"); err != nil {
+		t.Fatal(err)
+	}
+
 	// Process messages coming in on the ClientMessage channel.
 	for {
-		msg := <-chClient
-		switch msg.Type() {
-		default:
-			log.Printf("Received message type:%v msg:%v\n", msg.Type(), msg)
+		select {
+		case msg := <-chClient:
+			if key, ok := msg.(*MsgKeyEvent); ok && key.Down == 0 {
+				if key.Key != 0xff0d {
+					t.Errorf("unexpected key %s", key.Key)
+				}
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("key event not received")
 		}
 	}
 }
@@ -1,10 +1,12 @@
 package server
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/url"
 
+	"github.com/exoscale/vncproxy/auth"
 	"github.com/exoscale/vncproxy/logger"
 	"golang.org/x/net/websocket"
 )
@@ -13,7 +15,9 @@ type WsServer struct {
 	cfg *ServerConfig
 }
 
-type WsHandler func(io.ReadWriter, *ServerConfig, string)
+type WsHandler func(io.ReadWriter, *ServerConfig, string, *auth.Claims)
+
+type claimsContextKey struct{}
 
 func (wsServer *WsServer) Listen(urlStr string, handlerFunc WsHandler) {
 	if urlStr == "" {
@@ -24,7 +28,7 @@ func (wsServer *WsServer) Listen(urlStr string, handlerFunc WsHandler) {
 		logger.Errorf("error while parsing url: ", err)
 	}
 
-	http.Handle(url.Path, websocket.Handler(
+	wsHandler := websocket.Handler(
 		func(ws *websocket.Conn) {
 			path := ws.Request().URL.Path
 			var sessionId string
@@ -32,14 +36,54 @@ func (wsServer *WsServer) Listen(urlStr string, handlerFunc WsHandler) {
 				sessionId = path[1:]
 			}
 
+			claims, _ := ws.Request().Context().Value(claimsContextKey{}).(*auth.Claims)
+			if claims != nil && claims.Session != "" {
+				sessionId = claims.Session
+			}
+
 			logger.Debugf("incoming WS request for %s from %s", path, ws.Request().RemoteAddr)
 
 			ws.PayloadType = websocket.BinaryFrame
-			handlerFunc(ws, wsServer.cfg, sessionId)
-		}))
+			handlerFunc(ws, wsServer.cfg, sessionId, claims)
+		})
+
+	http.HandleFunc(url.Path, func(w http.ResponseWriter, r *http.Request) {
+		if wsServer.cfg.JwtVerifier == nil {
+			wsHandler.ServeHTTP(w, r)
+			return
+		}
+		claims, ok := wsServer.authorize(w, r)
+		if !ok {
+			return
+		}
+		wsHandler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsContextKey{}, claims)))
+	})
 
 	err = http.ListenAndServe(url.Host, nil)
 	if err != nil {
 		panic("ListenAndServe: " + err.Error())
 	}
 }
+
+// authorize checks the token of an upgrade request, before the upgrade so unauthorized clients get
+// a plain http error: it is written when the request is refused
+func (wsServer *WsServer) authorize(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	claims, err := wsServer.cfg.JwtVerifier.VerifyRequest(r)
+	if err != nil {
+		logger.Warnf("rejecting WS request for %s from %s: %v", r.URL.Path, r.RemoteAddr, err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	if claims.Session == "" && wsServer.cfg.JwtSessionRequired {
+		logger.Warnf("rejecting WS request for %s from %s: token without session", r.URL.Path, r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	if len(r.URL.Path) > 1 && claims.Session != "" && r.URL.Path[1:] != claims.Session {
+		logger.Warnf("rejecting WS request for %s from %s: token is for session %s", r.URL.Path, r.RemoteAddr, claims.Session)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/exoscale/vncproxy/auth"
+)
+
+func TestWsAuthorize(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "OKP", "kid": "ed1", "crv": "Ed25519", "x": b64.EncodeToString(pub)},
+	}})
+	keys, err := auth.ParseKeySet(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := func(claims map[string]interface{}) string {
+		claims["exp"] = time.Now().Add(time.Minute).Unix()
+		header, _ := json.Marshal(map[string]string{"alg": "EdDSA", "kid": "ed1"})
+		body, _ := json.Marshal(claims)
+		signed := b64.EncodeToString(header) + "." + b64.EncodeToString(body)
+		return signed + "." + b64.EncodeToString(ed25519.Sign(key, []byte(signed)))
+	}
+
+	for _, test := range []struct {
+		name            string
+		sessionRequired bool
+		path            string
+		token           string
+		status          int
+	}{
+		{"session claim", true, "/vm1", token(map[string]interface{}{"session": "vm1"}), http.StatusOK},
+		{"other session", true, "/vm2", token(map[string]interface{}{"session": "vm1"}), http.StatusForbidden},
+		{"no session claim", true, "/vm2", token(map[string]interface{}{}), http.StatusForbidden},
+		{"no session claim, single session", false, "/", token(map[string]interface{}{}), http.StatusOK},
+		{"bad signature", false, "/", token(map[string]interface{}{})[:20], http.StatusUnauthorized},
+	} {
+		wsServer := &WsServer{&ServerConfig{JwtVerifier: &auth.JwtVerifier{Keys: keys}, JwtSessionRequired: test.sessionRequired}}
+		r := httptest.NewRequest(http.MethodGet, test.path, nil)
+		r.Header.Set("Authorization", "Bearer "+test.token)
+		w := httptest.NewRecorder()
+		_, ok := wsServer.authorize(w, r)
+		if ok != (test.status == http.StatusOK) || w.Code != test.status {
+			t.Errorf("%s: authorized %v, status %d", test.name, ok, w.Code)
+		}
+	}
+}
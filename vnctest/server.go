@@ -0,0 +1,447 @@
+// Package vnctest provides an in-process vnc server for tests: its screen is an image the test draws into,
+// the changes are sent to the connected vnc clients, and every message they send is recorded.
+package vnctest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/encodings"
+	"github.com/exoscale/vncproxy/server"
+)
+
+// SupportedEncodings are the encodings the server can send, DesktopSize is used by Resize
+var SupportedEncodings = append([]common.EncodingType{common.EncCopyRect, common.EncDesktopSizePseudo}, encodings.EncoderTypes...)
+
+// Config describes the vnc server, the zero value is a 1024x768 black screen without authentication
+type Config struct {
+	// Version is the protocol version offered (server.ProtoVersion33, 37 or 38), 3.8 by default
+	Version string
+	// Password enables VNC authentication when SecurityHandlers is empty
+	Password string
+	// SecurityHandlers are the security types offered, in order
+	SecurityHandlers []server.SecurityHandler
+	// Image is the initial screen, a black Width x Height screen when nil
+	Image         image.Image
+	Width, Height int
+	// Encodings restricts the encodings the server may use (see SupportedEncodings), raw is always possible.
+	// The server picks the first encoding of the client's SetEncodings that is in the list.
+	Encodings   []common.EncodingType
+	DesktopName string
+	// PixelFormat is the format announced in ServerInit, until the client sets its own, 32bpp by default
+	PixelFormat *common.PixelFormat
+}
+
+// ConnInfo describes a vnc client connection, as negotiated during the handshake
+type ConnInfo struct {
+	Version      string
+	SecurityType server.SecurityType
+	// AuthErr is the security handshake error, the connection is closed after it
+	AuthErr error
+	Shared  bool
+	Closed  bool
+}
+
+// Server is an in-process vnc server, see Start & Pipe for the ways to connect to it.
+// The screen is a server.Framebuffer.
+type Server struct {
+	cfg Config
+	fb  *server.Framebuffer
+
+	lock     sync.Mutex
+	conns    map[*serverConn]struct{}
+	infos    []*ConnInfo
+	messages []common.ClientMessage
+	// received is closed (and replaced) when a message is recorded
+	received chan struct{}
+	listener net.Listener
+}
+
+// NewServer creates a server without listening, cfg may be nil
+func NewServer(cfg *Config) *Server {
+	s := &Server{conns: make(map[*serverConn]struct{}), received: make(chan struct{})}
+	if cfg != nil {
+		s.cfg = *cfg
+	}
+	if s.cfg.Version == "" {
+		s.cfg.Version = server.ProtoVersion38
+	}
+	if len(s.cfg.SecurityHandlers) == 0 {
+		if s.cfg.Password != "" {
+			s.cfg.SecurityHandlers = []server.SecurityHandler{&server.ServerAuthVNC{Pass: s.cfg.Password}}
+		} else {
+			s.cfg.SecurityHandlers = []server.SecurityHandler{&server.ServerAuthNone{}}
+		}
+	}
+	if len(s.cfg.Encodings) == 0 {
+		s.cfg.Encodings = SupportedEncodings
+	}
+	if s.cfg.PixelFormat == nil {
+		s.cfg.PixelFormat = common.NewPixelFormat(32)
+	}
+	if s.cfg.DesktopName == "" {
+		s.cfg.DesktopName = "vnctest"
+	}
+
+	if s.cfg.Image != nil {
+		bounds := s.cfg.Image.Bounds()
+		s.fb = server.NewFramebuffer(bounds.Dx(), bounds.Dy())
+		s.Draw(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), s.cfg.Image, bounds.Min)
+	} else {
+		if s.cfg.Width <= 0 || s.cfg.Height <= 0 {
+			s.cfg.Width, s.cfg.Height = 1024, 768
+		}
+		s.fb = server.NewFramebuffer(s.cfg.Width, s.cfg.Height)
+	}
+	s.fb.Encodings = s.cfg.Encodings
+	return s
+}
+
+// Start listens on a random loopback tcp port, see Addr
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	s.listener = ln
+	s.lock.Unlock()
+
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.Serve(nc)
+		}
+	}()
+	return nil
+}
+
+// Addr is the host:port the server listens on, empty before Start
+func (s *Server) Addr() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Pipe returns an in-memory connection to the server
+func (s *Server) Pipe() net.Conn {
+	cli, srv := net.Pipe()
+	go s.Serve(srv)
+	return cli
+}
+
+// Disconnect closes the client connections, the server keeps accepting new ones
+func (s *Server) Disconnect() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for c := range s.conns {
+		c.nc.Close()
+	}
+}
+
+// Close stops listening and closes the client connections
+func (s *Server) Close() error {
+	s.Disconnect()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+// Conns describes the connections made to the server so far, in order
+func (s *Server) Conns() []ConnInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	infos := make([]ConnInfo, len(s.infos))
+	for i, info := range s.infos {
+		infos[i] = *info
+	}
+	return infos
+}
+
+// Messages returns the messages received from all the vnc clients, in order
+func (s *Server) Messages() []common.ClientMessage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]common.ClientMessage{}, s.messages...)
+}
+
+// KeyEvents returns the key events received, as a shortcut to filtering Messages
+func (s *Server) KeyEvents() []*server.MsgKeyEvent {
+	var keys []*server.MsgKeyEvent
+	for _, msg := range s.Messages() {
+		if key, ok := msg.(*server.MsgKeyEvent); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// WaitForMessage returns the first message received, already or within timeout, for which match is true
+func (s *Server) WaitForMessage(timeout time.Duration, match func(common.ClientMessage) bool) (common.ClientMessage, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	checked := 0
+	for {
+		s.lock.Lock()
+		messages, received := s.messages[checked:], s.received
+		checked = len(s.messages)
+		s.lock.Unlock()
+		for _, msg := range messages {
+			if match(msg) {
+				return msg, nil
+			}
+		}
+		select {
+		case <-received:
+		case <-timer.C:
+			return nil, fmt.Errorf("no matching message after %s", timeout)
+		}
+	}
+}
+
+func (s *Server) record(msg common.ClientMessage) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.messages = append(s.messages, msg)
+	close(s.received)
+	s.received = make(chan struct{})
+}
+
+// Screen returns a copy of the screen
+func (s *Server) Screen() *image.RGBA {
+	return s.fb.Snapshot()
+}
+
+// Draw draws src (from sp) over the screen rectangle r, and sends it to the clients
+func (s *Server) Draw(r image.Rectangle, src image.Image, sp image.Point) {
+	s.fb.Draw(func(img *image.RGBA) []image.Rectangle {
+		draw.Draw(img, r, src, sp, draw.Src)
+		return []image.Rectangle{r}
+	})
+}
+
+// Fill paints a rectangle of the screen, and sends it to the clients
+func (s *Server) Fill(r image.Rectangle, c color.Color) {
+	s.Draw(r, image.NewUniform(c), image.Point{})
+}
+
+// CopyRect copies the src rectangle of the screen to dst, sent as CopyRect to the clients supporting it
+func (s *Server) CopyRect(src image.Rectangle, dst image.Point) {
+	s.fb.CopyRect(src, dst)
+}
+
+// Resize changes the screen size, keeping the top left pixels, and sends it to the clients supporting DesktopSize
+func (s *Server) Resize(width, height int) {
+	s.fb.Resize(width, height)
+}
+
+// Bell rings the clients
+func (s *Server) Bell() {
+	s.fb.Bell()
+}
+
+// CutText sends a ServerCutText message to the clients
+func (s *Server) CutText(text string) {
+	s.fb.CutText(text)
+}
+
+// serverConn is a vnc client connection, the framebuffer listens to its messages
+type serverConn struct {
+	s  *Server
+	nc net.Conn
+	// sc is the framebuffer's view of the connection, MsgSetEncodings needs it as a common.IServerConn
+	sc   *server.ServerConn
+	info *ConnInfo
+}
+
+// Serve runs the RFB protocol on a connection until it is closed
+func (s *Server) Serve(nc net.Conn) {
+	defer nc.Close()
+	c := &serverConn{s: s, nc: nc, info: &ConnInfo{}}
+	cfg := &server.ServerConfig{ClientMessages: server.DefaultClientMessages, PixelFormat: s.cfg.PixelFormat}
+	var err error
+	c.sc, err = server.NewServerConn(nc, cfg, "vnctest")
+	if err != nil {
+		return
+	}
+	s.lock.Lock()
+	s.infos = append(s.infos, c.info)
+	s.conns[c] = struct{}{}
+	s.lock.Unlock()
+	defer func() {
+		c.sc.Listeners.Consume(&common.RfbSegment{SegmentType: common.SegmentConnectionClosed})
+		s.lock.Lock()
+		delete(s.conns, c)
+		c.info.Closed = true
+		s.lock.Unlock()
+	}()
+
+	if err := c.handshake(); err != nil {
+		s.lock.Lock()
+		c.info.AuthErr = err
+		s.lock.Unlock()
+		return
+	}
+	c.readMessages()
+}
+
+func (c *serverConn) handshake() error {
+	cfg := &c.s.cfg
+	if _, err := io.WriteString(c.nc, cfg.Version); err != nil {
+		return err
+	}
+	var version [server.ProtoVersionLength]byte
+	if _, err := io.ReadFull(c.nc, version[:]); err != nil {
+		return err
+	}
+	major, minor, err := server.ParseProtoVersion(version[:])
+	if err != nil {
+		return err
+	}
+	_, serverMinor, _ := server.ParseProtoVersion([]byte(cfg.Version))
+	if major != 3 || minor < 3 {
+		return fmt.Errorf("unsupported client version %q", version[:])
+	}
+	switch {
+	case minor >= 8 && serverMinor >= 8:
+		c.info.Version = server.ProtoVersion38
+	case minor >= 7 && serverMinor >= 7:
+		c.info.Version = server.ProtoVersion37
+	default:
+		c.info.Version = server.ProtoVersion33
+	}
+	c.sc.SetProtoVersion(c.info.Version)
+
+	if err := c.security(); err != nil {
+		return err
+	}
+
+	var shared uint8
+	if err := binary.Read(c.nc, binary.BigEndian, &shared); err != nil {
+		return err
+	}
+	c.info.Shared = shared != 0
+
+	// the framebuffer sets the screen size
+	if err := c.s.fb.NewConnHandler(nil, c.sc); err != nil {
+		return err
+	}
+	if err := binary.Write(c.nc, binary.BigEndian, []uint16{c.sc.Width(), c.sc.Height()}); err != nil {
+		return err
+	}
+	if err := cfg.PixelFormat.WriteTo(c.nc); err != nil {
+		return err
+	}
+	if err := binary.Write(c.nc, binary.BigEndian, uint32(len(cfg.DesktopName))); err != nil {
+		return err
+	}
+	_, err = io.WriteString(c.nc, cfg.DesktopName)
+	return err
+}
+
+// security runs the security handshake of the negotiated version, see server.ServerSecurityHandler
+func (c *serverConn) security() error {
+	handlers := c.s.cfg.SecurityHandlers
+	var handler server.SecurityHandler
+
+	if c.info.Version == server.ProtoVersion33 {
+		for _, h := range handlers {
+			if h.Type() == server.SecTypeNone || h.Type() == server.SecTypeVNC {
+				handler = h
+				break
+			}
+		}
+		if handler == nil {
+			binary.Write(c.nc, binary.BigEndian, uint32(server.SecTypeUnknown))
+			writeReason(c.nc, "no security type supported by RFB 3.3 clients")
+			return errors.New("no security type for RFB 3.3")
+		}
+		if err := binary.Write(c.nc, binary.BigEndian, uint32(handler.Type())); err != nil {
+			return err
+		}
+	} else {
+		types := []byte{byte(len(handlers))}
+		for _, h := range handlers {
+			types = append(types, byte(h.Type()))
+		}
+		if _, err := c.nc.Write(types); err != nil {
+			return err
+		}
+		var secType server.SecurityType
+		if err := binary.Read(c.nc, binary.BigEndian, &secType); err != nil {
+			return err
+		}
+		for _, h := range handlers {
+			if h.Type() == secType {
+				handler = h
+			}
+		}
+		if handler == nil {
+			return fmt.Errorf("security type %d not offered", secType)
+		}
+	}
+	c.info.SecurityType = handler.Type()
+
+	authErr := handler.Auth(c.sc)
+	if handler.Type() == server.SecTypeNone && c.info.Version != server.ProtoVersion38 {
+		return authErr
+	}
+	if authErr == nil {
+		return binary.Write(c.nc, binary.BigEndian, uint32(0))
+	}
+	binary.Write(c.nc, binary.BigEndian, uint32(1))
+	if c.info.Version == server.ProtoVersion38 {
+		writeReason(c.nc, authErr.Error())
+	}
+	return authErr
+}
+
+func writeReason(w io.Writer, reason string) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(reason))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, reason)
+	return err
+}
+
+func (c *serverConn) readMessages() {
+	messages := make(map[common.ClientMessageType]common.ClientMessage)
+	for _, msg := range server.DefaultClientMessages {
+		messages[msg.Type()] = msg
+	}
+
+	for {
+		var messageType common.ClientMessageType
+		if err := binary.Read(c.nc, binary.BigEndian, &messageType); err != nil {
+			return
+		}
+		msg, ok := messages[messageType]
+		if !ok {
+			return
+		}
+		parsed, err := msg.Read(c.sc)
+		if err != nil {
+			return
+		}
+		c.s.record(parsed)
+		c.sc.Listeners.Consume(&common.RfbSegment{SegmentType: common.SegmentFullyParsedClientMessage, Message: parsed})
+	}
+}
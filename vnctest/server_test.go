@@ -0,0 +1,128 @@
+package vnctest
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/exoscale/vncproxy/automation"
+	"github.com/exoscale/vncproxy/common"
+	"github.com/exoscale/vncproxy/server"
+)
+
+var (
+	red  = color.RGBA{0xff, 0, 0, 0xff}
+	blue = color.RGBA{0, 0, 0xff, 0xff}
+)
+
+func dial(t *testing.T, s *Server, password string) *automation.Client {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := automation.New(ctx, s.Pipe(), &automation.Options{Password: password})
+	if err != nil {
+		t.Fatalf("connecting: %s", err)
+	}
+	return c
+}
+
+func waitMatch(t *testing.T, c *automation.Client, name string, at image.Point, ref image.Image) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.WaitForRegionMatch(ctx, at, ref, 0); err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+}
+
+func TestServerUpdates(t *testing.T) {
+	for _, enc := range []common.EncodingType{common.EncHextile, common.EncRRE, common.EncRaw} {
+		initial := image.NewRGBA(image.Rect(0, 0, 40, 30))
+		for i := range initial.Pix {
+			initial.Pix[i] = 0xff
+		}
+		initial.SetRGBA(5, 5, red)
+		s := NewServer(&Config{Image: initial, Password: "secret", Encodings: []common.EncodingType{enc, common.EncCopyRect, common.EncDesktopSizePseudo}})
+		c := dial(t, s, "secret")
+
+		waitMatch(t, c, enc.String()+" initial", image.Point{}, initial)
+
+		s.Fill(image.Rect(20, 0, 40, 10), blue)
+		waitMatch(t, c, enc.String()+" fill", image.Point{}, s.Screen())
+
+		s.CopyRect(image.Rect(0, 0, 10, 10), image.Pt(25, 15))
+		waitMatch(t, c, enc.String()+" copy", image.Point{}, s.Screen())
+
+		s.Resize(50, 20)
+		waitMatch(t, c, enc.String()+" resize", image.Point{}, s.Screen())
+		if w, h := c.Size(); w != 50 || h != 20 {
+			t.Errorf("%s: client size %dx%d after resize", enc, w, h)
+		}
+		c.Close()
+	}
+}
+
+func TestServerMessages(t *testing.T) {
+	s := NewServer(nil)
+	c := dial(t, s, "")
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.TypeString(ctx, "A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Click(ctx, 3, 4); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.WaitForMessage(5*time.Second, func(msg common.ClientMessage) bool {
+		pointer, ok := msg.(*server.MsgPointerEvent)
+		return ok && pointer.X == 3 && pointer.Y == 4 && pointer.Mask == 0
+	})
+	if err != nil {
+		t.Fatalf("click release: %s", err)
+	}
+
+	var keys []server.Key
+	for _, key := range s.KeyEvents() {
+		if key.Down != 0 {
+			keys = append(keys, key.Key)
+		}
+	}
+	if len(keys) != 2 || keys[0] != 0xffe1 || keys[1] != 'A' {
+		t.Errorf("unexpected keys pressed: %v", keys)
+	}
+
+	if _, ok := s.Messages()[0].(*server.MsgSetPixelFormat); !ok {
+		t.Errorf("expected SetPixelFormat first, got %T", s.Messages()[0])
+	}
+}
+
+func TestServerHandshake(t *testing.T) {
+	for _, version := range []string{server.ProtoVersion33, server.ProtoVersion37, server.ProtoVersion38} {
+		s := NewServer(&Config{Version: version, Password: "secret", Width: 8, Height: 8})
+		dial(t, s, "secret").Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if _, err := automation.New(ctx, s.Pipe(), &automation.Options{Password: "wrong"}); err == nil {
+			t.Errorf("%q: wrong password accepted", version)
+		}
+		cancel()
+
+		// the server may not be done with the failed connection yet
+		conns := s.Conns()
+		for i := 0; i < 100 && len(conns) == 2 && !conns[1].Closed; i++ {
+			time.Sleep(10 * time.Millisecond)
+			conns = s.Conns()
+		}
+		if len(conns) != 2 {
+			t.Fatalf("%q: %d connections", version, len(conns))
+		}
+		if conns[0].Version != version || conns[0].SecurityType != server.SecTypeVNC || conns[0].AuthErr != nil {
+			t.Errorf("%q: unexpected connection %+v", version, conns[0])
+		}
+		if conns[1].AuthErr == nil || !conns[1].Closed {
+			t.Errorf("%q: unexpected failed connection %+v", version, conns[1])
+		}
+	}
+}